@@ -0,0 +1,55 @@
+// Command rebuildRollups counts the daily sales rollups of shops again from their sold items.
+// Run it from the project root once after upgrading, and whenever rollups look out of step:
+//
+//	go run ./cmd/rebuildRollups            rebuilds every shop
+//	go run ./cmd/rebuildRollups -shop 12   rebuilds the shop with ID 12
+package main
+
+import (
+	"flag"
+	"log"
+
+	initializer "EtsyScraper/init"
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+)
+
+func main() {
+	ShopID := flag.Uint("shop", 0, "ID of the shop to rebuild, every shop when 0")
+	flag.Parse()
+
+	config := initializer.LoadProjConfig(".")
+	initializer.DataBaseConnect(&config)
+	if err := initializer.DB.AutoMigrate(&models.ShopDailyRollup{}, &models.ItemDailyRollup{}); err != nil {
+		log.Fatal("failed to migrate sales rollups: ", err)
+	}
+
+	Repository := &repository.DataBase{DB: initializer.DB}
+
+	ShopIDs := []uint{uint(*ShopID)}
+	if *ShopID == 0 {
+		Shops, err := Repository.GetAllShops()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ShopIDs = ShopIDs[:0]
+		for _, Shop := range *Shops {
+			ShopIDs = append(ShopIDs, Shop.ID)
+		}
+	}
+
+	failed := 0
+	for _, ID := range ShopIDs {
+		if err := Repository.RebuildSalesRollups(ID); err != nil {
+			log.Printf("failed to rebuild sales rollups of Shop.ID %v: %v\n", ID, err)
+			failed++
+			continue
+		}
+		log.Printf("rebuilt sales rollups of Shop.ID %v\n", ID)
+	}
+
+	if failed > 0 {
+		log.Fatalf("failed to rebuild sales rollups of %v out of %v shops", failed, len(ShopIDs))
+	}
+	log.Printf("rebuilt sales rollups of %v shops\n", len(ShopIDs))
+}
@@ -0,0 +1,57 @@
+// Command tagSellingHistory marks the sold units scraped from the selling history of shops
+// added before units were tagged on ingestion, so sales analyses leave them out. Run it from
+// the project root once after upgrading, then rebuild the rollups with cmd/rebuildRollups:
+//
+//	go run ./cmd/tagSellingHistory            tags every shop
+//	go run ./cmd/tagSellingHistory -shop 12   tags the shop with ID 12
+package main
+
+import (
+	"flag"
+	"log"
+
+	initializer "EtsyScraper/init"
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+)
+
+func main() {
+	ShopID := flag.Uint("shop", 0, "ID of the shop to tag, every shop when 0")
+	flag.Parse()
+
+	config := initializer.LoadProjConfig(".")
+	initializer.DataBaseConnect(&config)
+	if err := initializer.DB.AutoMigrate(&models.SoldItems{}); err != nil {
+		log.Fatal("failed to migrate sold items: ", err)
+	}
+
+	Repository := &repository.DataBase{DB: initializer.DB}
+
+	ShopIDs := []uint{uint(*ShopID)}
+	if *ShopID == 0 {
+		Shops, err := Repository.GetAllShops()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ShopIDs = ShopIDs[:0]
+		for _, Shop := range *Shops {
+			ShopIDs = append(ShopIDs, Shop.ID)
+		}
+	}
+
+	failed := 0
+	for _, ID := range ShopIDs {
+		tagged, err := Repository.TagSellingHistory(ID)
+		if err != nil {
+			log.Printf("failed to tag the selling history of Shop.ID %v: %v\n", ID, err)
+			failed++
+			continue
+		}
+		log.Printf("tagged %v sold units of Shop.ID %v as selling history\n", tagged, ID)
+	}
+
+	if failed > 0 {
+		log.Fatalf("failed to tag the selling history of %v out of %v shops", failed, len(ShopIDs))
+	}
+	log.Printf("tagged the selling history of %v shops\n", len(ShopIDs))
+}
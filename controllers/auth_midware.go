@@ -3,6 +3,7 @@ package controllers
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -72,6 +73,41 @@ func Authorization(userRepo repository.UserRepository) gin.HandlerFunc {
 
 	}
 }
+
+// IsAdmin lets the request through only for accounts whose email is listed in
+// AdminEmails, a comma separated list.
+func IsAdmin(userRepo repository.UserRepository, AdminEmails string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+
+		Account, err := userRepo.GetAccountByID(currentUserUUID)
+		if err != nil {
+			HandleResponse(ctx, err, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+
+		if !IsAdminEmail(Account.Email, AdminEmails) {
+			HandleResponse(ctx, nil, http.StatusForbidden, "no permission", nil)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func IsAdminEmail(email, AdminEmails string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	for _, adminEmail := range strings.Split(AdminEmails, ",") {
+		if strings.ToLower(strings.TrimSpace(adminEmail)) == email {
+			return true
+		}
+	}
+	return false
+}
+
 func IsAccountFollowingShop(userRepo repository.UserRepository) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
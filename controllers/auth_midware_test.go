@@ -533,3 +533,60 @@ func TestIsAuthorizedFailedIsBlackListed(t *testing.T) {
 
 	assert.False(t, result)
 }
+
+func TestIsAdminSuccess(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	UserRepo := &MockedUserRepository{}
+
+	IsNextCalled := false
+	currentUserUUID := uuid.New()
+
+	UserRepo.On("GetAccountByID").Return(&models.Account{ID: currentUserUUID, Email: "Admin@Example.com"}, nil)
+
+	router.POST("/", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+
+	}, controllers.IsAdmin(UserRepo, "owner@example.com, admin@example.com"), func(ctx *gin.Context) {
+		IsNextCalled = true
+	})
+
+	c.Request, _ = http.NewRequest("POST", "/", nil)
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.True(t, IsNextCalled)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestIsAdminNotListed(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	UserRepo := &MockedUserRepository{}
+
+	IsNextCalled := false
+	currentUserUUID := uuid.New()
+
+	UserRepo.On("GetAccountByID").Return(&models.Account{ID: currentUserUUID, Email: "user@example.com"}, nil)
+
+	router.POST("/", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+
+	}, controllers.IsAdmin(UserRepo, "admin@example.com"), func(ctx *gin.Context) {
+		IsNextCalled = true
+	})
+
+	c.Request, _ = http.NewRequest("POST", "/", nil)
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.False(t, IsNextCalled)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestIsAdminEmailEmptyList(t *testing.T) {
+	assert.False(t, controllers.IsAdminEmail("admin@example.com", ""))
+	assert.False(t, controllers.IsAdminEmail("", ""))
+}
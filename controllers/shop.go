@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"sync"
 	"time"
 
@@ -17,6 +18,8 @@ type Shop struct {
 	Operations ShopOperations
 	User       repository.UserRepository
 	Shop       repository.ShopRepository
+	Refresher  ShopRefresher
+	Onboarding *OnboardingQueue
 }
 
 func NewShopController(implementSHOP Shop) *Shop {
@@ -26,6 +29,8 @@ func NewShopController(implementSHOP Shop) *Shop {
 		Operations: &implementSHOP,
 		User:       implementSHOP.User,
 		Shop:       implementSHOP.Shop,
+		Refresher:  implementSHOP.Refresher,
+		Onboarding: implementSHOP.Onboarding,
 	}
 }
 
@@ -40,6 +45,11 @@ type FollowShopRequest struct {
 type UnFollowShopRequest struct {
 	UnFollowShopName string `json:"unfollow_shop"`
 }
+type MergeShopsRequest struct {
+	TargetShopID uint `json:"target_shop_id" binding:"required"`
+	SourceShopID uint `json:"source_shop_id" binding:"required"`
+}
+
 type ResponseSoldItemInfo struct {
 	Name           string
 	ItemID         uint
@@ -55,8 +65,91 @@ type ResponseSoldItemInfo struct {
 type DailySoldStats struct {
 	TotalSales   int     `json:"total_sales"`
 	DailyRevenue float64 `json:"daily_revenue"`
+	Estimated    bool    `json:"estimated"`
+	ShopRating   float64 `json:"shop_rating,omitempty"`
+	RatingChange float64 `json:"rating_change"`
+	ReviewsCount int     `json:"reviews_count,omitempty"`
+	NewReviews   int     `json:"new_reviews"`
+	OnVacation   bool    `json:"on_vacation"`
+	SoldUnits    int     `json:"sold_units"`
 	Items        []models.Item
 }
+type StockoutPeriod struct {
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Days      float64    `json:"days"`
+}
+
+type ItemStockouts struct {
+	ItemID     uint             `json:"item_id"`
+	ListingID  uint             `json:"listing_id"`
+	Name       string           `json:"name"`
+	OutOfStock bool             `json:"out_of_stock"`
+	TotalDays  float64          `json:"total_days"`
+	Periods    []StockoutPeriod `json:"periods"`
+}
+
+type CategoryState struct {
+	MenuItemID uint      `json:"menu_item_id"`
+	SectionID  string    `json:"section_id"`
+	Category   string    `json:"category"`
+	Amount     int       `json:"amount"`
+	Since      time.Time `json:"since"`
+}
+
+type CategoryChange struct {
+	Date        time.Time `json:"date"`
+	Event       string    `json:"event"`
+	MenuItemID  uint      `json:"menu_item_id"`
+	SectionID   string    `json:"section_id"`
+	OldCategory string    `json:"old_category,omitempty"`
+	NewCategory string    `json:"new_category,omitempty"`
+	OldAmount   int       `json:"old_amount"`
+	NewAmount   int       `json:"new_amount"`
+}
+
+type CategoryTimeline struct {
+	Categories []CategoryState  `json:"categories"`
+	Changes    []CategoryChange `json:"changes"`
+}
+
+type ProfileChange struct {
+	Date     time.Time `json:"date"`
+	Field    string    `json:"field"`
+	OldValue string    `json:"old_value"`
+	NewValue string    `json:"new_value"`
+}
+
+type ItemAttributeChangeInfo struct {
+	Date      time.Time `json:"date"`
+	ItemID    uint      `json:"item_id"`
+	ListingID uint      `json:"listing_id"`
+	Name      string    `json:"name"`
+	Attribute string    `json:"attribute"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+}
+
+type ListingRank struct {
+	Date     time.Time `json:"date"`
+	Position int       `json:"position"`
+	Featured bool      `json:"featured"`
+}
+
+type BestSeller struct {
+	Rank             int     `json:"rank"`
+	PreviousRank     *int    `json:"previous_rank"`
+	RankChange       *int    `json:"rank_change"`
+	ItemID           uint    `json:"item_id"`
+	ListingID        uint    `json:"listing_id"`
+	Name             string  `json:"name"`
+	Category         string  `json:"category"`
+	CurrencySymbol   string  `json:"currency_symbol"`
+	SoldQuantity     int     `json:"sold_quantity"`
+	EstimatedRevenue float64 `json:"estimated_revenue"`
+	SalesShare       float64 `json:"sales_share"`
+}
+
 type itemsCount struct {
 	Available       int
 	OutOfProduction int
@@ -71,6 +164,24 @@ type ShopRoutesInterface interface {
 	HandleGetSoldItemsByShopID(ctx *gin.Context)
 	ProcessStatsRequest(ctx *gin.Context)
 	HandleGetItemsCountByShopID(ctx *gin.Context)
+	RefreshShop(ctx *gin.Context)
+	HandleGetRefreshJob(ctx *gin.Context)
+	HandleMergeShops(ctx *gin.Context)
+	HandleGetStockoutsByShopID(ctx *gin.Context)
+	HandleGetCategoryTimeline(ctx *gin.Context)
+	HandleGetProfileTimeline(ctx *gin.Context)
+	HandleGetVacationPeriods(ctx *gin.Context)
+	HandleGetItemChangesByShopID(ctx *gin.Context)
+	HandleGetListingRankHistory(ctx *gin.Context)
+	HandleGetBestSellers(ctx *gin.Context)
+	HandleRecomputeRevenue(ctx *gin.Context)
+	HandleGetSalesForecast(ctx *gin.Context)
+	HandleGetPriceImpact(ctx *gin.Context)
+	HandleGetItemPriceImpact(ctx *gin.Context)
+	HandleGetCategoryStats(ctx *gin.Context)
+	HandleCompareShops(ctx *gin.Context)
+	HandleGetListingLifecycle(ctx *gin.Context)
+	HandleGetPromotions(ctx *gin.Context)
 }
 
 type ShopOperations interface {
@@ -82,14 +193,93 @@ type ShopOperations interface {
 	GetSoldItemsByShopID(ID uint) (SoldItemInfos []ResponseSoldItemInfo, err error)
 	GetSellingStatsByPeriod(ShopID uint, timePeriod time.Time) (map[string]DailySoldStats, error)
 	UpdateSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) error
+	RecordDailyRevenue(ShopID uint, Items []models.Item) error
 	UpdateDiscontinuedItems(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) ([]models.SoldItems, error)
-	CreateSoldStats(dailyShopSales []models.DailyShopSales) (map[string]DailySoldStats, error)
+	CreateSoldStats(dailyShopSales []models.DailyShopSales, loc *time.Location) (map[string]DailySoldStats, error)
 	EstablishAccountShopRelation(requestedShop *models.Shop, userID uuid.UUID) error
 	SaveShopToDB(scrappedShop *models.Shop, ShopRequest *models.ShopRequest) error
 	UpdateShopMenuToDB(Shop *models.Shop, ShopRequest *models.ShopRequest) error
 	CreateOutOfProdMenu(Shop *models.Shop, SoldOutItems []models.Item, ShopRequest *models.ShopRequest) error
 	CheckAndUpdateOutOfProdMenu(AllMenus []models.MenuItem, SoldOutItems []models.Item, ShopRequest *models.ShopRequest) (bool, error)
-	GetItemsBySoldItems(SoldItems []models.SoldItems) ([]models.Item, error)
+	EnqueueShopRefresh(ShopID uint, AccountID uuid.UUID, FullRefresh bool) (*models.ShopRefreshJob, bool, error)
+	RunShopRefresh(job *models.ShopRefreshJob) error
+	EnqueueShopOnboarding(ShopRequest *models.ShopRequest) error
+	EnqueueSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest, delay time.Duration) error
+	MergeDuplicateShops(TargetShopID, SourceShopID uint) (*repository.ShopMerge, error)
+}
+
+type ShopRefresher interface {
+	RefreshShop(ShopID uint, needUpdateItems bool) error
 }
 
 var queueMutex sync.Mutex
+var coalesceMutex sync.Mutex
+var refreshMutex sync.Mutex
+
+// refreshJobs counts the refresh jobs started by this instance, shutdown waits for them.
+var refreshJobs sync.WaitGroup
+var enqueueRefreshMutex sync.Mutex
+
+// inFlightShopRequests maps a normalized shop name to the request that is
+// creating the shop and the requests that arrived while it was running.
+var inFlightShopRequests = make(map[string]*coalescedShopRequest)
+
+type coalescedShopRequest struct {
+	Leader    *models.ShopRequest
+	Followers []*models.ShopRequest
+}
+
+var ShopRefreshCooldown = 30 * time.Minute
+
+// RefreshJobTimeout is how long a refresh job may stay queued or running. Jobs run in the
+// process that accepted them, which renews its claim on them every RefreshClaimInterval;
+// a job whose claim was not renewed for RefreshClaimTimeout was lost in a crash.
+var RefreshJobTimeout = 2 * time.Hour
+var RefreshClaimInterval = time.Minute
+var RefreshClaimTimeout = 5 * time.Minute
+var RefreshQuotaWindow = 24 * time.Hour
+
+// ShopLockTimeout is how long a shop lock lasts, a holder that crashed stops blocking the
+// shop after it. ShopLockWait is how long a job waits for a shop that is locked.
+var ShopLockTimeout = time.Hour
+var ShopLockWait = 10 * time.Minute
+var ShopLockRetryInterval = 5 * time.Second
+var DefaultRefreshQuota = 3
+var RefreshQuotaBySubscription = map[string]int{
+	"basic":    3,
+	"premium":  10,
+	"business": 25,
+}
+
+var ErrRefreshQuotaExceeded = errors.New("daily refresh quota exceeded")
+var ErrRefreshCooldown = errors.New("shop was refreshed recently, please try again later")
+var ErrRefreshJobTimedOut = errors.New("refresh job timed out before finishing")
+var ErrShopLocked = errors.New("shop is being updated by another job, please try again later")
+var ErrMergeSameShop = errors.New("a shop can not be merged into itself")
+var ErrShopsNotDuplicates = errors.New("shops do not share the same name")
+var ErrUnknownProfileField = errors.New("unknown profile field")
+var ErrUnknownItemAttribute = errors.New("unknown item attribute")
+var ErrInvalidPeriod = errors.New("invalid period provided")
+var ErrInvalidLimit = errors.New("limit must be a number between 1 and 100")
+
+var DefaultBestSellersLimit = 10
+var MaxBestSellersLimit = 100
+
+var ItemAttributes = map[string]bool{
+	models.ItemAttributeName:          true,
+	models.ItemAttributeOriginalPrice: true,
+	models.ItemAttributeAvailable:     true,
+	models.ItemAttributeSalePrice:     true,
+	models.ItemAttributeDiscount:      true,
+	models.ItemAttributeCurrency:      true,
+}
+
+var ProfileFields = map[string]bool{
+	models.ShopFieldDescription:  true,
+	models.ShopFieldLocation:     true,
+	models.ShopFieldOnVacation:   true,
+	models.ShopFieldMembers:      true,
+	models.ShopFieldSocialLinks:  true,
+	models.ShopFieldRating:       true,
+	models.ShopFieldReviewsCount: true,
+}
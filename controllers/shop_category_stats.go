@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// OutOfProductionCategory is the menu sold out items are moved to, see CheckAndUpdateOutOfProdMenu.
+var OutOfProductionCategory = "Out Of Production"
+
+// UncategorizedCategory holds the items whose category is no longer part of the shop's menu.
+var UncategorizedCategory = "Uncategorized"
+
+type CategoryDayStats struct {
+	Category                string  `json:"category"`
+	Units                   int     `json:"units"`
+	Revenue                 float64 `json:"revenue"`
+	AveragePrice            float64 `json:"average_price"`
+	ActiveListings          int     `json:"active_listings"`
+	OutOfProductionListings int     `json:"out_of_production_listings"`
+}
+
+type CategoryStatsDay struct {
+	Date       string             `json:"date"`
+	EndDate    string             `json:"end_date"`
+	Categories []CategoryDayStats `json:"categories"`
+}
+
+type CategoryTotals struct {
+	CategoryDayStats
+	SalesShare      float64  `json:"sales_share"`
+	PreviousUnits   int      `json:"previous_units"`
+	PreviousRevenue float64  `json:"previous_revenue"`
+	UnitsChange     *float64 `json:"units_change"`
+	RevenueChange   *float64 `json:"revenue_change"`
+}
+
+type CategoryStats struct {
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Granularity string             `json:"granularity"`
+	Categories  []CategoryTotals   `json:"categories"`
+	Series      []CategoryStatsDay `json:"series"`
+}
+
+// itemListingState is where an item was listed at some point in time. HomeMenuItemID is the
+// last category the item was listed in before it was moved to the Out Of Production menu.
+type itemListingState struct {
+	Exists         bool
+	Available      bool
+	HomeMenuItemID uint
+}
+
+// itemListingStateAt replays the item's history changes, oldest first, up to At. Items whose
+// first change is their creation did not exist before it, other items are taken to have been
+// listed since they were added to the database with the values of their first change.
+func itemListingStateAt(item models.Item, At time.Time, OutOfProductionIDs map[uint]bool) itemListingState {
+	state := itemListingState{Exists: !item.CreatedAt.After(At), Available: item.Available}
+	setMenu := func(MenuItemID uint) {
+		if MenuItemID != 0 && !OutOfProductionIDs[MenuItemID] {
+			state.HomeMenuItemID = MenuItemID
+		}
+	}
+
+	if len(item.PriceHistory) == 0 {
+		setMenu(item.MenuItemID)
+		return state
+	}
+
+	first := item.PriceHistory[0]
+	if first.NewItemCreated {
+		state.Exists = false
+	} else {
+		state.Available = first.OldAvailable
+		setMenu(first.OldMenuItemID)
+	}
+
+	for _, change := range item.PriceHistory {
+		if change.CreatedAt.After(At) {
+			break
+		}
+		state.Exists = true
+		state.Available = change.NewAvailable
+		setMenu(change.NewMenuItemID)
+	}
+	return state
+}
+
+// CreateCategoryStats breaks the shop's sales and listings down by category for every bucket of
+// the range, and compares every category with the range of the same length right before. Sales
+// come from the item rollups, a day's units count towards the category the item was listed in
+// at the end of the day, at the price it had then. A bucket's listings are counted at its end.
+// Items in the Out Of Production menu count towards the category they were listed in before,
+// as out of production listings.
+func CreateCategoryStats(Menu []models.MenuItem, Items []models.Item, Rollups []models.ItemDailyRollup, statsRange StatsRange, now time.Time, AverageItemPrice float64) CategoryStats {
+	loc := statsRange.From.Location()
+	previousStart := statsRange.Previous().From
+	rangeEnd := statsRange.To.AddDate(0, 0, 1)
+	if now.Before(rangeEnd) {
+		rangeEnd = now
+	}
+
+	MenuCategories := make(map[uint]string)
+	OutOfProductionIDs := make(map[uint]bool)
+	for _, menu := range Menu {
+		MenuCategories[menu.ID] = menu.Category
+		if menu.Category == OutOfProductionCategory {
+			OutOfProductionIDs[menu.ID] = true
+		}
+	}
+	categoryOf := func(state itemListingState) string {
+		if state.HomeMenuItemID == 0 {
+			return OutOfProductionCategory
+		}
+		if category, ok := MenuCategories[state.HomeMenuItemID]; ok {
+			return category
+		}
+		return UncategorizedCategory
+	}
+
+	buckets := []time.Time{}
+	for day := statsRange.From; !day.After(statsRange.To); day = day.AddDate(0, 0, 1) {
+		if start, _ := statsRange.bucketBounds(day); len(buckets) == 0 || !buckets[len(buckets)-1].Equal(start) {
+			buckets = append(buckets, start)
+		}
+	}
+
+	totals := make(map[string]*CategoryTotals)
+	dayStats := make(map[string]map[string]*CategoryDayStats)
+	totalFor := func(category string) *CategoryTotals {
+		if _, ok := totals[category]; !ok {
+			totals[category] = &CategoryTotals{CategoryDayStats: CategoryDayStats{Category: category}}
+		}
+		return totals[category]
+	}
+	dayFor := func(date, category string) *CategoryDayStats {
+		totalFor(category)
+		if _, ok := dayStats[date]; !ok {
+			dayStats[date] = make(map[string]*CategoryDayStats)
+		}
+		if _, ok := dayStats[date][category]; !ok {
+			dayStats[date][category] = &CategoryDayStats{Category: category}
+		}
+		return dayStats[date][category]
+	}
+
+	ItemByID := make(map[uint]models.Item, len(Items))
+	for _, item := range Items {
+		ItemByID[item.ID] = item
+	}
+
+	totalUnits := 0
+	for _, rollup := range Rollups {
+		item, ok := ItemByID[rollup.ItemID]
+		day := rollupDay(rollup, loc)
+		if !ok || day.Before(previousStart) || !day.Before(rangeEnd) {
+			continue
+		}
+
+		At := rollupPricedAt(rollup)
+		category := categoryOf(itemListingStateAt(item, At, OutOfProductionIDs))
+		revenue := SoldItemPrice(item, At, AverageItemPrice) * float64(rollup.SoldUnits)
+		if day.Before(statsRange.From) {
+			total := totalFor(category)
+			total.PreviousUnits += rollup.SoldUnits
+			total.PreviousRevenue += revenue
+			continue
+		}
+
+		start, _ := statsRange.bucketBounds(day)
+		stats := dayFor(start.Format("2006-01-02"), category)
+		stats.Units += rollup.SoldUnits
+		stats.Revenue += revenue
+		totalUnits += rollup.SoldUnits
+	}
+
+	for _, item := range Items {
+		for _, bucket := range buckets {
+			_, end := statsRange.bucketBounds(bucket)
+			At := end.AddDate(0, 0, 1)
+			if At.After(now) {
+				At = now
+			}
+			state := itemListingStateAt(item, At, OutOfProductionIDs)
+			if !state.Exists {
+				continue
+			}
+
+			stats := dayFor(bucket.Format("2006-01-02"), categoryOf(state))
+			if state.Available {
+				stats.ActiveListings++
+			} else {
+				stats.OutOfProductionListings++
+			}
+		}
+	}
+
+	for _, dayCategories := range dayStats {
+		for category, stats := range dayCategories {
+			total := totals[category]
+			total.Units += stats.Units
+			total.Revenue += stats.Revenue
+			stats.Revenue = utils.RoundToTwoDecimalDigits(stats.Revenue)
+			stats.AveragePrice = averagePrice(stats.Revenue, stats.Units)
+		}
+	}
+
+	report := CategoryStats{
+		From:        statsRange.From.Format("2006-01-02"),
+		To:          statsRange.To.Format("2006-01-02"),
+		Granularity: statsRange.Granularity,
+		Categories:  []CategoryTotals{},
+		Series:      []CategoryStatsDay{},
+	}
+	for category, total := range totals {
+		if len(buckets) > 0 {
+			if lastDay, ok := dayStats[buckets[len(buckets)-1].Format("2006-01-02")][category]; ok {
+				total.ActiveListings = lastDay.ActiveListings
+				total.OutOfProductionListings = lastDay.OutOfProductionListings
+			}
+		}
+		total.Revenue = utils.RoundToTwoDecimalDigits(total.Revenue)
+		total.PreviousRevenue = utils.RoundToTwoDecimalDigits(total.PreviousRevenue)
+		total.AveragePrice = averagePrice(total.Revenue, total.Units)
+		if totalUnits > 0 {
+			total.SalesShare = utils.RoundToTwoDecimalDigits(float64(total.Units) / float64(totalUnits) * 100)
+		}
+		total.UnitsChange = percentChange(float64(total.PreviousUnits), float64(total.Units))
+		total.RevenueChange = percentChange(total.PreviousRevenue, total.Revenue)
+		report.Categories = append(report.Categories, *total)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Revenue != report.Categories[j].Revenue {
+			return report.Categories[i].Revenue > report.Categories[j].Revenue
+		}
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+
+	for _, bucket := range buckets {
+		date := bucket.Format("2006-01-02")
+		_, end := statsRange.bucketBounds(bucket)
+		seriesDay := CategoryStatsDay{Date: date, EndDate: end.Format("2006-01-02"), Categories: []CategoryDayStats{}}
+		for _, total := range report.Categories {
+			stats := CategoryDayStats{Category: total.Category}
+			if dayCategory, ok := dayStats[date][total.Category]; ok {
+				stats = *dayCategory
+			}
+			seriesDay.Categories = append(seriesDay.Categories, stats)
+		}
+		report.Series = append(report.Series, seriesDay)
+	}
+
+	return report
+}
+
+func averagePrice(revenue float64, units int) float64 {
+	if units == 0 {
+		return 0
+	}
+	return utils.RoundToTwoDecimalDigits(revenue / float64(units))
+}
+
+// percentChange is nil when there is nothing to compare with.
+func percentChange(previous, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := utils.RoundToTwoDecimalDigits((current - previous) / previous * 100)
+	return &change
+}
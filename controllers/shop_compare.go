@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// MaxComparedShops is the most shops one comparison can hold.
+var MaxComparedShops = 10
+
+var ErrInvalidShopIDs = errors.New("ids must be a comma separated list of 1 to 10 shop ids")
+var ErrShopNotFollowed = errors.New("no permission")
+
+type ShopKPIs struct {
+	Sales              int      `json:"sales"`
+	Revenue            float64  `json:"revenue"`
+	Admirers           int      `json:"admirers"`
+	AdmirersGrowth     int      `json:"admirers_growth"`
+	AdmirersGrowthRate *float64 `json:"admirers_growth_rate"`
+	AveragePrice       float64  `json:"average_price"`
+	ItemCount          int      `json:"item_count"`
+	Rating             float64  `json:"rating"`
+	RatingChange       float64  `json:"rating_change"`
+}
+
+// ShopSeries holds one value per date of the comparison, null on days without a snapshot.
+type ShopSeries struct {
+	Sales    []*int     `json:"sales"`
+	Revenue  []*float64 `json:"revenue"`
+	Admirers []*int     `json:"admirers"`
+	Rating   []*float64 `json:"rating"`
+}
+
+type ComparedShop struct {
+	ShopID uint       `json:"shop_id"`
+	Name   string     `json:"shop_name"`
+	KPIs   ShopKPIs   `json:"kpis"`
+	Series ShopSeries `json:"series"`
+}
+
+type ShopComparison struct {
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Granularity string         `json:"granularity"`
+	Dates       []string       `json:"dates"`
+	Shops       []ComparedShop `json:"shops"`
+}
+
+// ParseShopIDs reads a comma separated list of shop ids, dropping repeated ones.
+func ParseShopIDs(ids string) ([]uint, error) {
+	ShopIDs := []uint{}
+	seen := make(map[uint]bool)
+	for _, id := range strings.Split(ids, ",") {
+		ShopID, err := utils.StringToUint(strings.TrimSpace(id))
+		if err != nil || ShopID == 0 {
+			return nil, ErrInvalidShopIDs
+		}
+		if !seen[ShopID] {
+			seen[ShopID] = true
+			ShopIDs = append(ShopIDs, ShopID)
+		}
+	}
+	if len(ShopIDs) > MaxComparedShops {
+		return nil, ErrInvalidShopIDs
+	}
+	return ShopIDs, nil
+}
+
+// ComparisonDates are the first days of the buckets of the range.
+func ComparisonDates(statsRange StatsRange) []time.Time {
+	Dates := []time.Time{}
+	for day := statsRange.From; !day.After(statsRange.To); day = day.AddDate(0, 0, 1) {
+		if start, _ := statsRange.bucketBounds(day); len(Dates) == 0 || !Dates[len(Dates)-1].Equal(start) {
+			Dates = append(Dates, start)
+		}
+	}
+	return Dates
+}
+
+// CreateComparedShop lines the shop's daily snapshots up with the buckets of the range. The
+// snapshots may start before the range, the last one before it is the base the KPIs grow from.
+// A day's sales are the change of the total since the snapshot of the day before, a bucket's
+// sales and revenue add up its days and its admirers and rating are the last ones of it. A
+// bucket without any snapshot is null.
+func CreateComparedShop(Shop *models.Shop, dailySales []models.DailyShopSales, dailyReviews []models.DailyShopReviews, statsRange StatsRange) ComparedShop {
+	Dates := ComparisonDates(statsRange)
+	compared := ComparedShop{
+		ShopID: Shop.ID,
+		Name:   Shop.Name,
+		KPIs:   ShopKPIs{Admirers: Shop.Admirers, Rating: Shop.Reviews.ShopRating},
+		Series: ShopSeries{
+			Sales:    make([]*int, len(Dates)),
+			Revenue:  make([]*float64, len(Dates)),
+			Admirers: make([]*int, len(Dates)),
+			Rating:   make([]*float64, len(Dates)),
+		},
+	}
+	if len(Dates) == 0 {
+		return compared
+	}
+	loc := statsRange.From.Location()
+
+	dateIndex := make(map[time.Time]int)
+	for i, date := range Dates {
+		dateIndex[date] = i
+	}
+	bucketIndex := func(day time.Time) (int, bool) {
+		if day.Before(statsRange.From) || day.After(statsRange.To) {
+			return 0, false
+		}
+		start, _ := statsRange.bucketBounds(day)
+		return dateIndex[start], true
+	}
+
+	sort.Slice(dailySales, func(i, j int) bool { return dailySales[i].CreatedAt.Before(dailySales[j].CreatedAt) })
+	var base, last *models.DailyShopSales
+	snapshotDays := make(map[time.Time]models.DailyShopSales)
+	soldByDay := make(map[time.Time]int)
+	for i := range dailySales {
+		sales := dailySales[i]
+		day := utils.TruncateDateInLocation(sales.CreatedAt, loc)
+		snapshotDays[day] = sales
+
+		index, ok := bucketIndex(day)
+		if !ok {
+			if day.Before(Dates[0]) {
+				base = &dailySales[i]
+			}
+			continue
+		}
+		if base == nil {
+			base = &dailySales[i]
+		}
+		last = &dailySales[i]
+
+		revenue := sales.DailyRevenue
+		if compared.Series.Revenue[index] != nil {
+			revenue += *compared.Series.Revenue[index]
+		}
+		admirers := sales.Admirers
+		compared.Series.Revenue[index] = &revenue
+		compared.Series.Admirers[index] = &admirers
+		compared.KPIs.Revenue += sales.DailyRevenue
+
+		if previous, ok := snapshotDays[day.AddDate(0, 0, -1)]; ok {
+			sold := sales.TotalSales - previous.TotalSales
+			if sold < 0 {
+				sold = 0
+			}
+			soldByDay[day] = sold
+		}
+	}
+	for day, sold := range soldByDay {
+		index, _ := bucketIndex(day)
+		if compared.Series.Sales[index] != nil {
+			sold += *compared.Series.Sales[index]
+		}
+		compared.Series.Sales[index] = &sold
+	}
+	compared.KPIs.Revenue = utils.RoundToTwoDecimalDigits(compared.KPIs.Revenue)
+	for _, revenue := range compared.Series.Revenue {
+		if revenue != nil {
+			*revenue = utils.RoundToTwoDecimalDigits(*revenue)
+		}
+	}
+
+	if last != nil {
+		compared.KPIs.Sales = last.TotalSales - base.TotalSales
+		compared.KPIs.Admirers = last.Admirers
+		compared.KPIs.AdmirersGrowth = last.Admirers - base.Admirers
+		if base.Admirers > 0 {
+			growthRate := utils.RoundToTwoDecimalDigits(float64(compared.KPIs.AdmirersGrowth) / float64(base.Admirers) * 100)
+			compared.KPIs.AdmirersGrowthRate = &growthRate
+		}
+	}
+
+	sort.Slice(dailyReviews, func(i, j int) bool { return dailyReviews[i].CreatedAt.Before(dailyReviews[j].CreatedAt) })
+	var baseReviews, lastReviews *models.DailyShopReviews
+	for i := range dailyReviews {
+		reviews := dailyReviews[i]
+		index, ok := bucketIndex(utils.TruncateDateInLocation(reviews.CreatedAt, loc))
+		if !ok {
+			if reviews.CreatedAt.Before(Dates[0]) {
+				baseReviews = &dailyReviews[i]
+			}
+			continue
+		}
+		if baseReviews == nil {
+			baseReviews = &dailyReviews[i]
+		}
+		lastReviews = &dailyReviews[i]
+
+		rating := reviews.ShopRating
+		compared.Series.Rating[index] = &rating
+	}
+	if lastReviews != nil {
+		compared.KPIs.Rating = lastReviews.ShopRating
+		compared.KPIs.RatingChange = utils.RoundToTwoDecimalDigits(lastReviews.ShopRating - baseReviews.ShopRating)
+	}
+
+	return compared
+}
@@ -2,61 +2,306 @@ package controllers
 
 import (
 	"EtsyScraper/models"
+	"EtsyScraper/repository"
 	"EtsyScraper/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func (s *Shop) CreateSoldStats(dailyShopSales []models.DailyShopSales) (map[string]DailySoldStats, error) {
+// CreateSoldStats builds the stats of every day with a snapshot from the shop's daily rollups.
+// Rollups are kept per UTC day, so a snapshot gets the items saved on its UTC day: the update
+// that takes the snapshot is the one saving the new sold items.
+func (s *Shop) CreateSoldStats(dailyShopSales []models.DailyShopSales, loc *time.Location) (map[string]DailySoldStats, error) {
 	stats := make(map[string]DailySoldStats)
+	if len(dailyShopSales) == 0 {
+		return stats, nil
+	}
 
+	From := dailyShopSales[0].CreatedAt
 	for _, sales := range dailyShopSales {
+		if sales.CreatedAt.Before(From) {
+			From = sales.CreatedAt
+		}
+	}
+	From = utils.TruncateDate(From)
 
-		day := utils.TruncateDate(sales.CreatedAt)
+	ShopRollups, err := s.Shop.GetShopRollupsByPeriod(dailyShopSales[0].ShopID, From)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	ItemRollups, err := s.Shop.GetItemRollupsByPeriod(dailyShopSales[0].ShopID, From)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
 
-		soldItems, err := s.Shop.GetSoldItemsInRange(day, sales.ShopID)
-		if err != nil {
-			return nil, utils.HandleError(err)
+	soldUnits := make(map[string]int)
+	for _, rollup := range ShopRollups {
+		soldUnits[rollup.Date.UTC().Format("2006-01-02")] = rollup.SoldUnits
+	}
+	soldItems := make(map[string][]models.Item)
+	for _, rollup := range ItemRollups {
+		date := rollup.Date.UTC().Format("2006-01-02")
+		for i := 0; i < rollup.SoldUnits; i++ {
+			soldItems[date] = append(soldItems[date], rollup.Item)
+		}
+	}
+
+	for _, sales := range dailyShopSales {
+		rollupDate := utils.TruncateDate(sales.CreatedAt).Format("2006-01-02")
+		dateCreated := utils.TruncateDateInLocation(sales.CreatedAt, loc).Format("2006-01-02")
+
+		stats[dateCreated] = DailySoldStats{
+			TotalSales:   sales.TotalSales,
+			DailyRevenue: sales.DailyRevenue,
+			Estimated:    sales.Estimated,
+			SoldUnits:    soldUnits[rollupDate],
+			Items:        soldItems[rollupDate],
+		}
+	}
+
+	return stats, nil
+}
+
+// AddReviewStats adds the rating and the reviews gained since the snapshot before to every
+// day from periodStart on. dailyReviews must be ordered by creation time and may start
+// with the last snapshot before periodStart, which is only used as the base of the first day.
+func AddReviewStats(stats map[string]DailySoldStats, dailyReviews []models.DailyShopReviews, periodStart time.Time, loc *time.Location) {
+	for i, reviews := range dailyReviews {
+		if reviews.CreatedAt.Before(periodStart) {
+			continue
 		}
 
-		dateCreated := sales.CreatedAt.Format("2006-01-02")
-		if len(soldItems) == 0 {
-			stats[dateCreated] = DailySoldStats{
-				TotalSales:   sales.TotalSales,
-				DailyRevenue: sales.DailyRevenue,
+		dateCreated := utils.TruncateDateInLocation(reviews.CreatedAt, loc).Format("2006-01-02")
+		dayStats := stats[dateCreated]
+		dayStats.ShopRating = reviews.ShopRating
+		dayStats.ReviewsCount = reviews.ReviewsCount
+
+		if i > 0 {
+			previous := dailyReviews[i-1]
+			dayStats.RatingChange = utils.RoundToTwoDecimalDigits(reviews.ShopRating - previous.ShopRating)
+			if NewReviews := reviews.ReviewsCount - previous.ReviewsCount; NewReviews > 0 {
+				dayStats.NewReviews = NewReviews
 			}
+		}
+		stats[dateCreated] = dayStats
+	}
+}
+
+// IsVacationDay reports whether any vacation period covers part of the day starting at dayStart,
+// so both the day a shop left and the day it came back count as vacation days.
+func IsVacationDay(dayStart time.Time, Periods []models.VacationPeriod) bool {
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	for _, period := range Periods {
+		if !period.StartedAt.Before(dayEnd) {
 			continue
 		}
-		items, err := s.Operations.GetItemsBySoldItems(soldItems)
-		if err != nil {
-			return nil, utils.HandleError(err)
+		if period.EndedAt == nil || period.EndedAt.After(dayStart) {
+			return true
 		}
+	}
+	return false
+}
 
-		stats[dateCreated] = DailySoldStats{
-			TotalSales:   sales.TotalSales,
-			DailyRevenue: sales.DailyRevenue,
-			Items:        items,
+// VacationOverlapDays is how many days of the stretch from From to To the shop spent on
+// vacation, a period that has not ended yet lasts until To.
+func VacationOverlapDays(Periods []models.VacationPeriod, From, To time.Time) float64 {
+	days := 0.0
+	for _, period := range Periods {
+		start, end := period.StartedAt, To
+		if period.EndedAt != nil && period.EndedAt.Before(To) {
+			end = *period.EndedAt
+		}
+		if start.Before(From) {
+			start = From
+		}
+		if end.After(start) {
+			days += end.Sub(start).Hours() / 24
+		}
+	}
+	return days
+}
+
+func MarkVacationDays(stats map[string]DailySoldStats, Periods []models.VacationPeriod, loc *time.Location) {
+	for date, dayStats := range stats {
+		dayStart, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			continue
+		}
+		if IsVacationDay(dayStart, Periods) {
+			dayStats.OnVacation = true
+			stats[date] = dayStats
 		}
+	}
+}
 
+// CalculateSalesVelocity returns the average sales per day, a day's sales being the change in
+// total sales since the day before. Vacation days are left out since their sales are frozen.
+func CalculateSalesVelocity(stats map[string]DailySoldStats) float64 {
+	dates := make([]string, 0, len(stats))
+	for date := range stats {
+		dates = append(dates, date)
 	}
+	sort.Strings(dates)
 
-	return stats, nil
+	totalSold, days := 0, 0
+	for i := 1; i < len(dates); i++ {
+		current := stats[dates[i]]
+		if current.OnVacation {
+			continue
+		}
+		if sold := current.TotalSales - stats[dates[i-1]].TotalSales; sold > 0 {
+			totalSold += sold
+		}
+		days++
+	}
+
+	if days == 0 {
+		return 0
+	}
+	return utils.RoundToTwoDecimalDigits(float64(totalSold) / float64(days))
 }
 
-func CalculateTotalRevenue(soldItems []ResponseSoldItemInfo, AverageItemPrice float64) float64 {
+// CalculateTotalRevenue values every sold unit at the price the item had on the day it was
+// sold. Items without a known price use AverageItemPrice.
+func CalculateTotalRevenue(Items []models.Item, AverageItemPrice float64) float64 {
 	var revenue float64
-	var ItemPrice float64
 
-	for _, soldItem := range soldItems {
-		if soldItem.OriginalPrice > 0 {
-			ItemPrice = soldItem.OriginalPrice
-		} else {
-			ItemPrice = AverageItemPrice
+	for _, item := range Items {
+		for _, soldItem := range item.SoldUnits {
+			revenue += SoldItemPrice(item, soldItem.CreatedAt, AverageItemPrice)
 		}
-		revenue += ItemPrice * float64(soldItem.SoldQuantity)
 	}
 	revenue = utils.RoundToTwoDecimalDigits(revenue)
 	return revenue
 }
 
+// CurrentItemPrice is what a buyer pays for the item now, the discounted price while the
+// item is on sale.
+func CurrentItemPrice(item models.Item) float64 {
+	if item.SalePrice > 0 {
+		return item.SalePrice
+	}
+	return item.OriginalPrice
+}
+
+// ItemPriceAt rebuilds what a buyer paid for the item at a given time from its price history
+// and its sale price changes, both ordered by creation time. A time before the first recorded
+// change gets the old value of that change, an item without changes its current value.
+func ItemPriceAt(item models.Item, At time.Time) float64 {
+	OriginalPrice := OriginalPriceAt(item, At)
+
+	SalePrice := item.SalePrice
+	first := true
+	for _, change := range item.AttributeHistory {
+		if change.Attribute != models.ItemAttributeSalePrice {
+			continue
+		}
+		if !change.CreatedAt.After(At) {
+			SalePrice = parseSalePrice(change.NewValue)
+			first = false
+			continue
+		}
+		if first {
+			SalePrice = parseSalePrice(change.OldValue)
+		}
+		break
+	}
+
+	return CurrentItemPrice(models.Item{OriginalPrice: OriginalPrice, SalePrice: SalePrice})
+}
+
+// OriginalPriceAt rebuilds the item's price before any discount at a given time from its price history.
+func OriginalPriceAt(item models.Item, At time.Time) float64 {
+	OriginalPrice := item.OriginalPrice
+	for i, change := range item.PriceHistory {
+		if !change.CreatedAt.After(At) {
+			OriginalPrice = change.NewPrice
+			continue
+		}
+		if i == 0 {
+			OriginalPrice = change.OldPrice
+			if change.NewItemCreated {
+				OriginalPrice = change.NewPrice
+			}
+		}
+		break
+	}
+	return OriginalPrice
+}
+
+func parseSalePrice(value string) float64 {
+	SalePrice, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return -1
+	}
+	return SalePrice
+}
+
+// SoldItemPrice is the price of one unit sold at SoldAt, AverageItemPrice when the item had
+// no price then.
+func SoldItemPrice(item models.Item, SoldAt time.Time, AverageItemPrice float64) float64 {
+	ItemPrice := ItemPriceAt(item, SoldAt)
+	if ItemPrice <= 0 {
+		return AverageItemPrice
+	}
+	return ItemPrice
+}
+
+// CalculateDailyRevenue sums the sold units of every day at their price on that day and
+// returns the snapshots whose revenue changed. dailySales must be ordered by creation time.
+// Units scraped from the selling history are left out. A run of estimated days and the
+// snapshot closing it share the revenue of all of their days evenly, the same way the daily
+// sales gaps are backfilled.
+func CalculateDailyRevenue(dailySales []models.DailyShopSales, Items []models.Item, AverageItemPrice float64) []models.DailyShopSales {
+	if len(dailySales) == 0 {
+		return nil
+	}
+
+	revenueByDay := make(map[time.Time]float64)
+	for _, item := range Items {
+		for _, soldItem := range item.SoldUnits {
+			if soldItem.FromHistory {
+				continue
+			}
+			day := utils.TruncateDate(soldItem.CreatedAt)
+			revenueByDay[day] += SoldItemPrice(item, soldItem.CreatedAt, AverageItemPrice)
+		}
+	}
+
+	changed := []models.DailyShopSales{}
+	setRevenue := func(sales models.DailyShopSales, revenue float64) {
+		if sales.DailyRevenue != revenue {
+			sales.DailyRevenue = revenue
+			changed = append(changed, sales)
+		}
+	}
+
+	estimated := []models.DailyShopSales{}
+	for _, sales := range dailySales {
+		if sales.Estimated {
+			estimated = append(estimated, sales)
+			continue
+		}
+
+		revenue := revenueByDay[utils.TruncateDate(sales.CreatedAt)]
+		if len(estimated) > 0 {
+			for _, estimatedSales := range estimated {
+				revenue += revenueByDay[utils.TruncateDate(estimatedSales.CreatedAt)]
+			}
+			revenueShare := utils.RoundToTwoDecimalDigits(revenue / float64(len(estimated)+1))
+			for _, estimatedSales := range estimated {
+				setRevenue(estimatedSales, revenueShare)
+			}
+			revenue -= revenueShare * float64(len(estimated))
+			estimated = estimated[:0]
+		}
+		setRevenue(sales, utils.RoundToTwoDecimalDigits(revenue))
+	}
+	return changed
+}
+
 func FilterSoldOutItems(scrapSoldItems []models.SoldItems, existingItems []models.Item, FilterSoldItems map[uint]struct{}) []models.Item {
 	SoldOutItems := []models.Item{}
 
@@ -87,7 +332,7 @@ func PopulateItemIDsFromListings(ScrappedSoldItems []models.SoldItems, AllItems
 		for _, item := range AllItems {
 			if ScrappedSoldItem.ListingID == item.ListingID {
 				ScrappedSoldItems[i].ItemID = item.ID
-				dailyRevenue += item.OriginalPrice
+				dailyRevenue += CurrentItemPrice(item)
 				break
 			}
 		}
@@ -115,3 +360,409 @@ func CreateSoldItemInfo(Item *models.Item) *ResponseSoldItemInfo {
 	}
 	return newSoldItem
 }
+
+func GetRefreshQuota(SubscriptionType string) int {
+	if quota, ok := RefreshQuotaBySubscription[SubscriptionType]; ok {
+		return quota
+	}
+	return DefaultRefreshQuota
+}
+
+func GetOnboardingWeight(SubscriptionType string) int {
+	if weight, ok := OnboardingWeightBySubscription[SubscriptionType]; ok {
+		return weight
+	}
+	return DefaultOnboardingWeight
+}
+
+// IsRefreshJobActive tells whether the job is queued or running, has not outlived
+// RefreshJobTimeout and its instance still renews the claim on it.
+func IsRefreshJobActive(job *models.ShopRefreshJob, now time.Time) bool {
+	if !IsRefreshJobPending(job) || now.Sub(job.RequestedAt) >= RefreshJobTimeout {
+		return false
+	}
+	claimedAt := job.RequestedAt
+	if job.ClaimedAt != nil {
+		claimedAt = *job.ClaimedAt
+	}
+	return now.Sub(claimedAt) < RefreshClaimTimeout
+}
+
+// IsRefreshJobPending tells whether the job was never finished, even if it was lost.
+func IsRefreshJobPending(job *models.ShopRefreshJob) bool {
+	return job != nil && (job.Status == "queued" || job.Status == "running")
+}
+
+func IsShopInRefreshCooldown(job *models.ShopRefreshJob, now time.Time) bool {
+	if job == nil || job.Status == "failed" {
+		return false
+	}
+	return now.Sub(job.RequestedAt) < ShopRefreshCooldown
+}
+
+// PlanShopMerge decides where every menu, item, sold item and daily sales row of
+// source ends up inside target. Menus are matched by section id, falling back to
+// the category name, and items by listing id. Both shops track the same Etsy shop,
+// so a sold item or daily sales row the target already has for that day is dropped.
+func PlanShopMerge(target, source *models.Shop, targetSales, sourceSales []models.DailyShopSales) *repository.ShopMerge {
+	merge := &repository.ShopMerge{
+		TargetShopID:     target.ID,
+		SourceShopID:     source.ID,
+		TargetShopMenuID: target.ShopMenu.ID,
+		MovedItems:       make(map[uint]uint),
+		MergedItems:      make(map[uint]uint),
+		MergedMenus:      make(map[uint]uint),
+	}
+
+	targetMenus := make(map[string]uint)
+	targetItems := make(map[uint]models.Item)
+	for _, menu := range target.ShopMenu.Menu {
+		targetMenus[shopMergeMenuKey(menu)] = menu.ID
+		for _, item := range menu.Items {
+			targetItems[item.ListingID] = item
+		}
+	}
+
+	for _, menu := range source.ShopMenu.Menu {
+		targetMenuID, menuExists := targetMenus[shopMergeMenuKey(menu)]
+		if !menuExists {
+			merge.MovedMenus = append(merge.MovedMenus, menu.ID)
+		}
+
+		for _, item := range menu.Items {
+			targetItem, itemExists := targetItems[item.ListingID]
+			if !itemExists {
+				if menuExists {
+					merge.MovedItems[item.ID] = targetMenuID
+				}
+				continue
+			}
+
+			merge.MergedItems[item.ID] = targetItem.ID
+			merge.DroppedSoldItems = append(merge.DroppedSoldItems, duplicatedSoldItems(targetItem.SoldUnits, item.SoldUnits)...)
+		}
+
+		if menuExists {
+			merge.DroppedMenus = append(merge.DroppedMenus, menu.ID)
+			merge.MergedMenus[menu.ID] = targetMenuID
+		}
+	}
+
+	targetDays := make(map[time.Time]struct{})
+	for _, sales := range targetSales {
+		targetDays[utils.TruncateDate(sales.CreatedAt)] = struct{}{}
+	}
+	for _, sales := range sourceSales {
+		if _, exists := targetDays[utils.TruncateDate(sales.CreatedAt)]; exists {
+			merge.DroppedDailySales = append(merge.DroppedDailySales, sales.ID)
+			continue
+		}
+		merge.MovedDailySales = append(merge.MovedDailySales, sales.ID)
+	}
+
+	return merge
+}
+
+// PlanDailyReviewsMerge moves the source's review snapshots to the target, except on
+// days the target already has one.
+func PlanDailyReviewsMerge(merge *repository.ShopMerge, targetReviews, sourceReviews []models.DailyShopReviews) {
+	targetDays := make(map[time.Time]struct{})
+	for _, reviews := range targetReviews {
+		targetDays[utils.TruncateDate(reviews.CreatedAt)] = struct{}{}
+	}
+	for _, reviews := range sourceReviews {
+		if _, exists := targetDays[utils.TruncateDate(reviews.CreatedAt)]; exists {
+			merge.DroppedDailyReviews = append(merge.DroppedDailyReviews, reviews.ID)
+			continue
+		}
+		merge.MovedDailyReviews = append(merge.MovedDailyReviews, reviews.ID)
+	}
+}
+
+func shopMergeMenuKey(menu models.MenuItem) string {
+	if menu.SectionID != "" {
+		return "section:" + menu.SectionID
+	}
+	return "category:" + menu.Category
+}
+
+// duplicatedSoldItems returns the source sold items that the target already
+// recorded, as many per day as the target has on that day.
+func duplicatedSoldItems(targetSoldItems, sourceSoldItems []models.SoldItems) []uint {
+	targetPerDay := make(map[time.Time]int)
+	for _, soldItem := range targetSoldItems {
+		targetPerDay[utils.TruncateDate(soldItem.CreatedAt)]++
+	}
+
+	duplicates := []uint{}
+	for _, soldItem := range sourceSoldItems {
+		day := utils.TruncateDate(soldItem.CreatedAt)
+		if targetPerDay[day] > 0 {
+			targetPerDay[day]--
+			duplicates = append(duplicates, soldItem.ID)
+		}
+	}
+	return duplicates
+}
+
+// CalculateItemStockouts walks the item's history changes, oldest first, and
+// returns every period the item was not available. A period that is still open
+// lasts until now.
+func CalculateItemStockouts(item models.Item, now time.Time) ItemStockouts {
+	stockouts := ItemStockouts{
+		ItemID:    item.ID,
+		ListingID: item.ListingID,
+		Name:      item.Name,
+		Periods:   []StockoutPeriod{},
+	}
+
+	available := item.Available
+	if len(item.PriceHistory) > 0 {
+		available = item.PriceHistory[0].OldAvailable || item.PriceHistory[0].NewItemCreated
+	}
+	startedAt := item.CreatedAt
+
+	for _, change := range item.PriceHistory {
+		if change.NewItemCreated {
+			available = change.NewAvailable
+			startedAt = change.CreatedAt
+			continue
+		}
+		if available && !change.NewAvailable {
+			available = false
+			startedAt = change.CreatedAt
+		} else if !available && change.NewAvailable {
+			available = true
+			endedAt := change.CreatedAt
+			stockouts.Periods = append(stockouts.Periods, newStockoutPeriod(startedAt, &endedAt, now))
+		}
+	}
+
+	if !available {
+		stockouts.OutOfStock = true
+		stockouts.Periods = append(stockouts.Periods, newStockoutPeriod(startedAt, nil, now))
+	}
+
+	for _, period := range stockouts.Periods {
+		stockouts.TotalDays += period.Days
+	}
+	stockouts.TotalDays = utils.RoundToTwoDecimalDigits(stockouts.TotalDays)
+
+	return stockouts
+}
+
+func newStockoutPeriod(startedAt time.Time, endedAt *time.Time, now time.Time) StockoutPeriod {
+	end := now
+	if endedAt != nil {
+		end = *endedAt
+	}
+	return StockoutPeriod{
+		StartedAt: startedAt,
+		EndedAt:   endedAt,
+		Days:      utils.RoundToTwoDecimalDigits(end.Sub(startedAt).Hours() / 24),
+	}
+}
+
+func CreateCategoryTimeline(Menus []models.MenuItem, MenuChanges []models.MenuHistoryChange) CategoryTimeline {
+	timeline := CategoryTimeline{
+		Categories: []CategoryState{},
+		Changes:    []CategoryChange{},
+	}
+
+	for _, Menu := range Menus {
+		timeline.Categories = append(timeline.Categories, CategoryState{
+			MenuItemID: Menu.ID,
+			SectionID:  Menu.SectionID,
+			Category:   Menu.Category,
+			Amount:     Menu.Amount,
+			Since:      Menu.CreatedAt,
+		})
+	}
+
+	for _, change := range MenuChanges {
+		timeline.Changes = append(timeline.Changes, CategoryChange{
+			Date:        change.CreatedAt,
+			Event:       change.Event,
+			MenuItemID:  change.MenuItemID,
+			SectionID:   change.SectionID,
+			OldCategory: change.OldCategory,
+			NewCategory: change.NewCategory,
+			OldAmount:   change.OldAmount,
+			NewAmount:   change.NewAmount,
+		})
+	}
+
+	return timeline
+}
+
+// CreateProfileTimeline keeps the changes of Field only, or every change when Field is empty.
+func CreateProfileTimeline(ShopChanges []models.ShopHistoryChange, Field string) []ProfileChange {
+	timeline := []ProfileChange{}
+
+	for _, change := range ShopChanges {
+		if Field != "" && change.Field != Field {
+			continue
+		}
+		timeline = append(timeline, ProfileChange{
+			Date:     change.CreatedAt,
+			Field:    change.Field,
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		})
+	}
+
+	return timeline
+}
+
+// CreateItemChangesTimeline lists the attribute changes of every item ordered by date, only
+// the changes of Attribute are kept unless it is empty.
+func CreateItemChangesTimeline(Items []models.Item, Attribute string) []ItemAttributeChangeInfo {
+	timeline := []ItemAttributeChangeInfo{}
+
+	for _, item := range Items {
+		for _, change := range item.AttributeHistory {
+			if Attribute != "" && change.Attribute != Attribute {
+				continue
+			}
+			timeline = append(timeline, ItemAttributeChangeInfo{
+				Date:      change.CreatedAt,
+				ItemID:    item.ID,
+				ListingID: item.ListingID,
+				Name:      item.Name,
+				Attribute: change.Attribute,
+				OldValue:  change.OldValue,
+				NewValue:  change.NewValue,
+			})
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Date.Before(timeline[j].Date)
+	})
+	return timeline
+}
+
+func CreateListingRankHistory(Positions []models.ListingPosition) []ListingRank {
+	History := []ListingRank{}
+	for _, Position := range Positions {
+		History = append(History, ListingRank{
+			Date:     Position.CreatedAt,
+			Position: Position.Position,
+			Featured: Position.Featured,
+		})
+	}
+	return History
+}
+
+// PeriodOffset returns how far back from today a stats period starts.
+func PeriodOffset(Period string) (years, months, days int, err error) {
+	switch Period {
+	case "lastSevenDays":
+		days = -6
+	case "lastThirtyDays":
+		days = -29
+	case "lastThreeMonths":
+		months = -3
+	case "lastSixMonths":
+		months = -6
+	case "lastYear":
+		years = -1
+	default:
+		return 0, 0, 0, ErrInvalidPeriod
+	}
+	return years, months, days, nil
+}
+
+// rollupDay is the day of an item rollup in loc. Rollups are kept by UTC day, the day the
+// snapshot saving their units is shown on.
+func rollupDay(rollup models.ItemDailyRollup, loc *time.Location) time.Time {
+	date := rollup.Date.UTC()
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
+
+// rollupPricedAt is the end of a rollup's UTC day, by when all of its units were saved.
+func rollupPricedAt(rollup models.ItemDailyRollup) time.Time {
+	return rollup.Date.UTC().AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// RollupRevenues sums the revenue of every item from its rollups of the days from From up to
+// but not including To, each day's units at the price the item ended the day at or
+// AverageItemPrice when it had none then.
+func RollupRevenues(Rollups []models.ItemDailyRollup, Items []models.Item, From, To time.Time, AverageItemPrice float64) map[uint]float64 {
+	ItemByID := make(map[uint]models.Item, len(Items))
+	for _, item := range Items {
+		ItemByID[item.ID] = item
+	}
+
+	Revenues := make(map[uint]float64, len(Items))
+	for _, rollup := range Rollups {
+		day := rollupDay(rollup, From.Location())
+		if day.Before(From) || !day.Before(To) {
+			continue
+		}
+		Revenues[rollup.ItemID] += SoldItemPrice(ItemByID[rollup.ItemID], rollupPricedAt(rollup), AverageItemPrice) * float64(rollup.SoldUnits)
+	}
+	return Revenues
+}
+
+// RankBestSellers orders items by units sold, then by revenue. Revenues holds the revenue of
+// every item's units at their price when they sold, see RollupRevenues. The share is the percent of all units
+// the shop sold in the period.
+func RankBestSellers(Sales []repository.ItemSales, Revenues map[uint]float64, Category string) []BestSeller {
+	totalSold := 0
+	for _, sale := range Sales {
+		totalSold += sale.SoldQuantity
+	}
+
+	BestSellers := []BestSeller{}
+	for _, sale := range Sales {
+		if Category != "" && !strings.EqualFold(sale.Category, Category) {
+			continue
+		}
+
+		BestSellers = append(BestSellers, BestSeller{
+			ItemID:           sale.ItemID,
+			ListingID:        sale.ListingID,
+			Name:             sale.Name,
+			Category:         sale.Category,
+			CurrencySymbol:   sale.CurrencySymbol,
+			SoldQuantity:     sale.SoldQuantity,
+			EstimatedRevenue: utils.RoundToTwoDecimalDigits(Revenues[sale.ItemID]),
+			SalesShare:       utils.RoundToTwoDecimalDigits(float64(sale.SoldQuantity) / float64(totalSold) * 100),
+		})
+	}
+
+	sort.SliceStable(BestSellers, func(i, j int) bool {
+		if BestSellers[i].SoldQuantity != BestSellers[j].SoldQuantity {
+			return BestSellers[i].SoldQuantity > BestSellers[j].SoldQuantity
+		}
+		if BestSellers[i].EstimatedRevenue != BestSellers[j].EstimatedRevenue {
+			return BestSellers[i].EstimatedRevenue > BestSellers[j].EstimatedRevenue
+		}
+		return BestSellers[i].ItemID < BestSellers[j].ItemID
+	})
+
+	for i := range BestSellers {
+		BestSellers[i].Rank = i + 1
+	}
+	return BestSellers
+}
+
+// AddRankChanges sets the rank every item had in the previous period and how many places
+// it moved up since. Both stay empty for items that did not sell in the previous period.
+func AddRankChanges(BestSellers, PreviousBestSellers []BestSeller) {
+	previousRanks := make(map[uint]int)
+	for _, previous := range PreviousBestSellers {
+		previousRanks[previous.ItemID] = previous.Rank
+	}
+
+	for i, bestSeller := range BestSellers {
+		previousRank, ok := previousRanks[bestSeller.ItemID]
+		if !ok {
+			continue
+		}
+		rankChange := previousRank - bestSeller.Rank
+		BestSellers[i].PreviousRank = &previousRank
+		BestSellers[i].RankChange = &rankChange
+	}
+}
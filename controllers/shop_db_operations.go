@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"EtsyScraper/models"
+	"EtsyScraper/repository"
 	"EtsyScraper/utils"
 	"errors"
 	"fmt"
@@ -14,6 +15,9 @@ import (
 func (s *Shop) SaveShopToDB(scrappedShop *models.Shop, ShopRequest *models.ShopRequest) error {
 
 	if err := s.Shop.CreateShop(scrappedShop); err != nil {
+		if errors.Is(err, repository.ErrShopNameTaken) {
+			return utils.HandleError(err)
+		}
 		ShopRequest.Status = "failed"
 		s.Operations.CreateShopRequest(ShopRequest)
 		message := fmt.Sprintf("failed to save Shop's data while handling ShopRequest.ID: %v", ShopRequest.ID)
@@ -111,23 +115,85 @@ func (s *Shop) GetSellingStatsByPeriod(ShopID uint, timePeriod time.Time) (map[s
 		return nil, utils.HandleError(err)
 	}
 
-	stats, err := s.Operations.CreateSoldStats(dailyShopSales)
+	stats, err := s.Operations.CreateSoldStats(dailyShopSales, timePeriod.Location())
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	dailyReviews, err := s.Shop.FetchReviewsStatsByPeriod(ShopID, timePeriod.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	AddReviewStats(stats, dailyReviews, timePeriod, timePeriod.Location())
+
+	Periods, err := s.Shop.GetVacationPeriodsByShopID(ShopID)
 	if err != nil {
 		return nil, utils.HandleError(err)
 	}
+	MarkVacationDays(stats, Periods, timePeriod.Location())
+
 	return stats, nil
 }
 
 func (s *Shop) GetTotalRevenue(ShopID uint, AverageItemPrice float64) (float64, error) {
 
-	soldItems, err := s.Operations.GetSoldItemsByShopID(ShopID)
+	Items, err := s.Shop.GetItemsWithPriceHistoryByShopID(ShopID)
 	if err != nil {
 		return 0, utils.HandleError(err, "error while calculating revenue")
 	}
-	revenue := CalculateTotalRevenue(soldItems, AverageItemPrice)
+	revenue := CalculateTotalRevenue(Items, AverageItemPrice)
 	return revenue, nil
 }
 
+// RecomputeDailyRevenue values the stored daily revenue of a shop again at the prices the
+// items had when they were sold and returns the number of days that changed.
+func (s *Shop) RecomputeDailyRevenue(ShopID uint) (int, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return 0, utils.HandleError(err)
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return 0, utils.HandleError(err)
+	}
+
+	Items, err := s.Shop.GetItemsWithPriceHistoryByShopID(ShopID)
+	if err != nil {
+		return 0, utils.HandleError(err)
+	}
+
+	dailySales, err := s.Shop.GetDailySalesByShopID(ShopID)
+	if err != nil {
+		return 0, utils.HandleError(err)
+	}
+
+	changed := CalculateDailyRevenue(dailySales, Items, AverageItemPrice)
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	if err := s.Shop.UpdateDailyRevenue(changed); err != nil {
+		return 0, utils.HandleError(err)
+	}
+	return len(changed), nil
+}
+
+func (s *Shop) GetAccountLocation(AccountID uuid.UUID) *time.Location {
+	account, err := s.User.GetAccountByID(AccountID)
+	if err != nil {
+		return time.UTC
+	}
+	return utils.LoadLocation(account.TimeZone)
+}
+
+func (s *Shop) GetAccountOnboardingWeight(AccountID uuid.UUID) int {
+	account, err := s.User.GetAccountByID(AccountID)
+	if err != nil {
+		return DefaultOnboardingWeight
+	}
+	return GetOnboardingWeight(account.SubscriptionType)
+}
+
 func (s *Shop) EstablishAccountShopRelation(requestedShop *models.Shop, userID uuid.UUID) error {
 
 	currentAccount, err := s.User.GetAccountByID(userID)
@@ -204,21 +270,6 @@ func (s *Shop) GetSoldItemsByShopID(ID uint) (SoldItemInfos []ResponseSoldItemIn
 	return
 }
 
-func (s *Shop) GetItemsBySoldItems(SoldItems []models.SoldItems) ([]models.Item, error) {
-
-	items := []models.Item{}
-
-	for _, soldItem := range SoldItems {
-		item, err := s.Shop.FetchItemsBySoldItems(soldItem.ID)
-		if err != nil {
-			return nil, utils.HandleError(err, "error parsing sold items")
-		}
-		items = append(items, item)
-	}
-
-	return items, nil
-}
-
 func (s *Shop) GetItemsCountByShopID(ID uint) (itemsCount, error) {
 	itemCount := itemsCount{}
 
@@ -236,3 +287,453 @@ func (s *Shop) GetItemsCountByShopID(ID uint) (itemsCount, error) {
 
 	return itemCount, nil
 }
+
+func (s *Shop) GetStockoutsByShopID(ShopID uint) ([]ItemStockouts, error) {
+	items, err := s.Shop.GetItemsWithHistoryByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err, "error while retrieving items history")
+	}
+
+	now := time.Now()
+	stockouts := []ItemStockouts{}
+	for _, item := range items {
+		itemStockouts := CalculateItemStockouts(item, now)
+		if len(itemStockouts.Periods) == 0 {
+			continue
+		}
+		stockouts = append(stockouts, itemStockouts)
+	}
+
+	return stockouts, nil
+}
+
+func (s *Shop) GetCategoryTimeline(ShopID uint) (CategoryTimeline, error) {
+	Shop, err := s.Shop.FetchShopByID(ShopID)
+	if err != nil {
+		return CategoryTimeline{}, utils.HandleError(err)
+	}
+
+	MenuChanges, err := s.Shop.GetMenuHistoryByShopID(ShopID)
+	if err != nil {
+		return CategoryTimeline{}, utils.HandleError(err, "error while retrieving categories history")
+	}
+
+	return CreateCategoryTimeline(Shop.ShopMenu.Menu, MenuChanges), nil
+}
+
+func (s *Shop) GetProfileTimeline(ShopID uint, Field string) ([]ProfileChange, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	ShopChanges, err := s.Shop.GetShopHistoryByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err, "error while retrieving shop history")
+	}
+
+	return CreateProfileTimeline(ShopChanges, Field), nil
+}
+
+func (s *Shop) GetVacationPeriods(ShopID uint) ([]models.VacationPeriod, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Periods, err := s.Shop.GetVacationPeriodsByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return Periods, nil
+}
+
+func (s *Shop) GetListingRankHistory(ShopID, ListingID uint) ([]ListingRank, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Positions, err := s.Shop.GetListingPositions(ShopID, ListingID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return CreateListingRankHistory(Positions), nil
+}
+
+// GetBestSellers ranks the items sold from periodStart until the end of now's day and compares
+// every rank with the period of the same length right before it. Both are read from the daily
+// rollups.
+func (s *Shop) GetBestSellers(ShopID uint, periodStart, now time.Time, Category string, Limit int) ([]BestSeller, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	periodEnd := utils.TruncateDateInLocation(now, periodStart.Location()).AddDate(0, 0, 1)
+	Sales, err := s.Shop.GetItemSalesByPeriod(ShopID, periodStart, periodEnd)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	previousStart := utils.TruncateDateInLocation(periodStart.Add(-now.Sub(periodStart)), periodStart.Location())
+	PreviousSales, err := s.Shop.GetItemSalesByPeriod(ShopID, previousStart, periodStart)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Rollups, Items, err := s.getItemRollups(ShopID, previousStart, Sales, PreviousSales)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Revenues := RollupRevenues(Rollups, Items, periodStart, periodEnd, AverageItemPrice)
+	PreviousRevenues := RollupRevenues(Rollups, Items, previousStart, periodStart, AverageItemPrice)
+
+	BestSellers := RankBestSellers(Sales, Revenues, Category)
+	AddRankChanges(BestSellers, RankBestSellers(PreviousSales, PreviousRevenues, Category))
+
+	if len(BestSellers) > Limit {
+		BestSellers = BestSellers[:Limit]
+	}
+	return BestSellers, nil
+}
+
+// getItemRollups loads the item rollups of the shop from From on and the items counted in
+// Sales with their price history, to price the units the rollups counted.
+func (s *Shop) getItemRollups(ShopID uint, From time.Time, Sales ...[]repository.ItemSales) ([]models.ItemDailyRollup, []models.Item, error) {
+	ItemIDs := []uint{}
+	for _, sales := range Sales {
+		for _, sale := range sales {
+			ItemIDs = append(ItemIDs, sale.ItemID)
+		}
+	}
+	if len(ItemIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	Rollups, err := s.Shop.GetItemRollupsByPeriod(ShopID, From)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	Items, err := s.Shop.GetItemPriceHistoryByIDs(ItemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Rollups, Items, nil
+}
+
+// GetSalesForecast projects the sales and revenue of a shop and of its best selling items
+// over the ForecastHorizons from the day after now, cutting days at midnight in now's location.
+// Items are ranked and projected on the units tracked as they sold.
+func (s *Shop) GetSalesForecast(ShopID uint, now time.Time) (*ShopForecast, error) {
+	if _, err := s.Shop.FetchShopByID(ShopID); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	loc := now.Location()
+	today := utils.TruncateDateInLocation(now, loc)
+	historyStart := today.AddDate(0, 0, -ForecastHistoryDays)
+
+	dailySales, err := s.Shop.FetchStatsByPeriod(ShopID, historyStart)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Periods, err := s.Shop.GetVacationPeriodsByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	sales, revenue := ShopSalesSeries(dailySales, Periods, loc)
+	if len(sales) < MinForecastHistoryDays {
+		return nil, ErrNotEnoughHistory
+	}
+
+	salesModel, revenueModel := FitSeasonalModel(sales), FitSeasonalModel(revenue)
+	start := today.AddDate(0, 0, 1)
+	forecast := &ShopForecast{
+		HistoryDays: len(sales),
+		Horizons:    CreateForecastHorizons(salesModel, revenueModel, start),
+		Daily:       CreateForecastDays(salesModel, revenueModel, start, ForecastHorizons[len(ForecastHorizons)-1]),
+		Items:       []ItemForecast{},
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	ItemSales, err := s.Shop.GetItemSalesByPeriod(ShopID, historyStart, start)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Rollups, Items, err := s.getItemRollups(ShopID, historyStart, ItemSales)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	Revenues := RollupRevenues(Rollups, Items, historyStart, start, AverageItemPrice)
+
+	BestSellers := RankBestSellers(ItemSales, Revenues, "")
+	if len(BestSellers) > ForecastTopItems {
+		BestSellers = BestSellers[:ForecastTopItems]
+	}
+	if len(BestSellers) == 0 {
+		return forecast, nil
+	}
+
+	ItemIDs := []uint{}
+	for _, bestSeller := range BestSellers {
+		ItemIDs = append(ItemIDs, bestSeller.ItemID)
+	}
+
+	SoldItems, err := s.Shop.GetSoldItemsByItemIDs(ItemIDs, historyStart)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	soldByItem := make(map[uint][]models.SoldItems)
+	for _, soldItem := range SoldItems {
+		soldByItem[soldItem.ItemID] = append(soldByItem[soldItem.ItemID], soldItem)
+	}
+
+	Days := []time.Time{}
+	for _, point := range sales {
+		Days = append(Days, point.Day)
+	}
+
+	for _, bestSeller := range BestSellers {
+		// the average price the item's units sold at, not its current price.
+		ItemPrice := Revenues[bestSeller.ItemID] / float64(bestSeller.SoldQuantity)
+		itemModel := FitSeasonalModel(ItemSalesSeries(soldByItem[bestSeller.ItemID], Days, loc))
+		forecast.Items = append(forecast.Items, CreateItemForecast(bestSeller, itemModel, ItemPrice, start))
+	}
+
+	return forecast, nil
+}
+
+// GetPriceImpact compares the sales of every item of the shop before and after its price changes.
+func (s *Shop) GetPriceImpact(ShopID uint, now time.Time) (*ShopPriceImpact, error) {
+	_, Items, TrackingStart, err := s.getTrackedItems(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Periods, err := s.Shop.GetVacationPeriodsByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	if err := s.addSoldUnits(ShopID, Items, TrackingStart); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	report := AnalyzeShopPriceImpact(Items, Periods, TrackingStart, now)
+	return &report, nil
+}
+
+func (s *Shop) GetItemPriceImpact(ShopID, ListingID uint, now time.Time) (*ItemPriceImpact, error) {
+	_, Items, TrackingStart, err := s.getTrackedItems(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Periods, err := s.Shop.GetVacationPeriodsByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	for i, item := range Items {
+		if item.ListingID == ListingID {
+			if err := s.addSoldUnits(ShopID, Items[i:i+1], TrackingStart); err != nil {
+				return nil, utils.HandleError(err)
+			}
+			impact := AnalyzeItemPriceImpact(Items[i], Periods, TrackingStart, now)
+			return &impact, nil
+		}
+	}
+	return nil, ErrListingNotFound
+}
+
+// getTrackedItems loads the shop with its items and their price history, and the time the
+// shop's first daily snapshot was taken, from when on sales were recorded as they happened.
+// The items' sales are added by addSoldUnits for the stretch a report needs.
+func (s *Shop) getTrackedItems(ShopID uint) (*models.Shop, []models.Item, time.Time, error) {
+	Shop, err := s.Shop.FetchShopByID(ShopID)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	Items, err := s.Shop.GetItemPriceHistoryByShopID(ShopID)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	dailySales, err := s.Shop.GetDailySalesByShopID(ShopID)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	TrackingStart := time.Time{}
+	if len(dailySales) > 0 {
+		TrackingStart = dailySales[0].CreatedAt
+	}
+	return Shop, Items, TrackingStart, nil
+}
+
+// addSoldUnits gives the items their units sold from From on, leaving out the selling history.
+func (s *Shop) addSoldUnits(ShopID uint, Items []models.Item, From time.Time) error {
+	SoldItems, err := s.Shop.GetSoldItemsByShopID(ShopID, From)
+	if err != nil {
+		return err
+	}
+
+	ItemIndex := make(map[uint]int, len(Items))
+	for i, item := range Items {
+		ItemIndex[item.ID] = i
+	}
+	for _, soldItem := range SoldItems {
+		if i, ok := ItemIndex[soldItem.ItemID]; ok {
+			Items[i].SoldUnits = append(Items[i].SoldUnits, soldItem)
+		}
+	}
+	return nil
+}
+
+// GetCategoryStats breaks the shop's sales and listings down by category over the range, the
+// sales from the daily rollups.
+func (s *Shop) GetCategoryStats(ShopID uint, statsRange StatsRange, now time.Time) (*CategoryStats, error) {
+	Shop, Items, _, err := s.getTrackedItems(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	Rollups, err := s.Shop.GetItemRollupsByPeriod(ShopID, statsRange.Previous().From)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	report := CreateCategoryStats(Shop.ShopMenu.Menu, Items, Rollups, statsRange, now, AverageItemPrice)
+	return &report, nil
+}
+
+// GetShopComparison lines up the stats of the shops the account follows over the range. Every
+// shop must be followed by the account.
+func (s *Shop) GetShopComparison(AccountID uuid.UUID, ShopIDs []uint, statsRange StatsRange) (*ShopComparison, error) {
+	Account, err := s.User.GetAccountWithShops(AccountID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	followed := make(map[uint]bool)
+	for _, shop := range Account.ShopsFollowing {
+		followed[shop.ID] = true
+	}
+	for _, ShopID := range ShopIDs {
+		if !followed[ShopID] {
+			return nil, ErrShopNotFollowed
+		}
+	}
+
+	comparison := &ShopComparison{
+		From:        statsRange.From.Format("2006-01-02"),
+		To:          statsRange.To.Format("2006-01-02"),
+		Granularity: statsRange.Granularity,
+		Dates:       []string{},
+		Shops:       []ComparedShop{},
+	}
+	for _, date := range ComparisonDates(statsRange) {
+		comparison.Dates = append(comparison.Dates, date.Format("2006-01-02"))
+	}
+
+	for _, ShopID := range ShopIDs {
+		Shop, err := s.Shop.FetchShopByID(ShopID)
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+
+		dailySales, err := s.Shop.FetchStatsByPeriod(ShopID, statsRange.From.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+
+		dailyReviews, err := s.Shop.FetchReviewsStatsByPeriod(ShopID, statsRange.From.AddDate(0, 0, -1))
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+
+		AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+
+		ItemsCount, err := s.GetItemsCountByShopID(ShopID)
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+
+		compared := CreateComparedShop(Shop, dailySales, dailyReviews, statsRange)
+		compared.KPIs.AveragePrice = AverageItemPrice
+		compared.KPIs.ItemCount = ItemsCount.Available
+		comparison.Shops = append(comparison.Shops, compared)
+	}
+
+	return comparison, nil
+}
+
+// GetListingLifecycle reports the listings the shop added and discontinued in the range and how
+// they did.
+func (s *Shop) GetListingLifecycle(ShopID uint, statsRange StatsRange, now time.Time) (*ListingLifecycleReport, error) {
+	Shop, Items, _, err := s.getTrackedItems(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	if err := s.addSoldUnits(ShopID, Items, statsRange.From); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	report := CreateListingLifecycleReport(Shop.ShopMenu.Menu, Items, statsRange, now, AverageItemPrice)
+	return &report, nil
+}
+
+// GetPromotions reports the promotions the shop ran during the range and the sales lift of each.
+func (s *Shop) GetPromotions(ShopID uint, statsRange StatsRange, now time.Time) (*PromotionReport, error) {
+	_, Items, TrackingStart, err := s.getTrackedItems(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	AverageItemPrice, err := s.Shop.GetAverageItemPrice(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	if err := s.addSoldUnits(ShopID, Items, PromotionSalesFrom(Items, statsRange, now)); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	report := CreatePromotionReport(Items, TrackingStart, statsRange, now, AverageItemPrice)
+	return &report, nil
+}
+
+func (s *Shop) GetItemChangesByShopID(ShopID uint, Attribute string) ([]ItemAttributeChangeInfo, error) {
+	Items, err := s.Shop.GetItemsWithAttributeHistoryByShopID(ShopID)
+	if err != nil {
+		return nil, utils.HandleError(err, "error while retrieving item changes")
+	}
+	return CreateItemChangesTimeline(Items, Attribute), nil
+}
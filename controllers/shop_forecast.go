@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// ForecastHorizons are the number of days ahead a forecast is given for.
+var ForecastHorizons = []int{7, 30, 90}
+
+// ForecastHistoryDays is how far back the sales history used for a forecast goes, and
+// MinForecastHistoryDays how many usable days it needs at least.
+var ForecastHistoryDays = 180
+var MinForecastHistoryDays = 7
+
+// ForecastTopItems is the number of best selling items that get their own forecast.
+var ForecastTopItems = 5
+
+// ForecastBandZ is the z-score of the confidence bands, 1.96 covers 95% of normal errors.
+var ForecastBandZ = 1.96
+
+const seasonLength = 7
+
+var ErrNotEnoughHistory = errors.New("not enough sales history to forecast")
+
+type SeriesPoint struct {
+	Day   time.Time
+	Value float64
+}
+
+type ForecastRange struct {
+	Value float64 `json:"value"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+type ForecastHorizon struct {
+	Days    int           `json:"days"`
+	Sales   ForecastRange `json:"sales"`
+	Revenue ForecastRange `json:"revenue"`
+}
+
+type ForecastDay struct {
+	Date    string        `json:"date"`
+	Sales   ForecastRange `json:"sales"`
+	Revenue ForecastRange `json:"revenue"`
+}
+
+type ItemForecast struct {
+	ItemID    uint              `json:"item_id"`
+	ListingID uint              `json:"listing_id"`
+	Name      string            `json:"name"`
+	Horizons  []ForecastHorizon `json:"horizons"`
+}
+
+type ShopForecast struct {
+	HistoryDays int               `json:"history_days"`
+	Horizons    []ForecastHorizon `json:"horizons"`
+	Daily       []ForecastDay     `json:"daily"`
+	Items       []ItemForecast    `json:"items"`
+}
+
+// SeasonalModel is a weekly additive decomposition of a daily series. Level is the moving
+// average of the last week once the weekday effects in Season are taken out, Sigma the
+// standard deviation of the one day ahead errors of that moving average over the history.
+type SeasonalModel struct {
+	Level  float64
+	Season [seasonLength]float64
+	Sigma  float64
+}
+
+// FitSeasonalModel expects the points ordered by day. Weekday effects are only estimated
+// from two weeks of history on, a shorter series is treated as having none. Days missing
+// from the series are unknown, not zero: the level averages the days present in the last
+// week and errors are only measured after seven days in a row.
+func FitSeasonalModel(Points []SeriesPoint) SeasonalModel {
+	model := SeasonalModel{}
+	n := len(Points)
+	if n == 0 {
+		return model
+	}
+
+	if n >= 2*seasonLength {
+		model.Season = seasonalIndices(Points)
+	}
+
+	adjusted := make([]float64, n)
+	for i, point := range Points {
+		adjusted[i] = point.Value - model.Season[point.Day.Weekday()]
+	}
+
+	lastWeek := []float64{}
+	weekStart := Points[n-1].Day.AddDate(0, 0, -seasonLength)
+	for i, point := range Points {
+		if point.Day.After(weekStart) {
+			lastWeek = append(lastWeek, adjusted[i])
+		}
+	}
+	model.Level = mean(lastWeek)
+
+	errorsSum, errorsCount := 0.0, 0
+	for i := seasonLength; i < n; i++ {
+		if !isConsecutiveDays(Points[i-seasonLength : i+1]) {
+			continue
+		}
+		e := adjusted[i] - mean(adjusted[i-seasonLength:i])
+		errorsSum += e * e
+		errorsCount++
+	}
+	if errorsCount == 0 {
+		for _, value := range adjusted {
+			errorsSum += (value - model.Level) * (value - model.Level)
+		}
+		errorsCount = n
+	}
+	model.Sigma = math.Sqrt(errorsSum / float64(errorsCount))
+
+	return model
+}
+
+// seasonalIndices averages the distance of every point from the centered weekly moving
+// average by weekday, skipping weeks with a day missing. The indices add up to zero so
+// they only move sales between weekdays.
+func seasonalIndices(Points []SeriesPoint) [seasonLength]float64 {
+	var sums [seasonLength]float64
+	var counts [seasonLength]int
+
+	half := seasonLength / 2
+	for i := half; i < len(Points)-half; i++ {
+		week := Points[i-half : i+half+1]
+		if !isConsecutiveDays(week) {
+			continue
+		}
+
+		trend := 0.0
+		for _, point := range week {
+			trend += point.Value
+		}
+		trend /= seasonLength
+
+		weekday := Points[i].Day.Weekday()
+		sums[weekday] += Points[i].Value - trend
+		counts[weekday]++
+	}
+
+	var indices [seasonLength]float64
+	total := 0.0
+	for weekday := range indices {
+		if counts[weekday] > 0 {
+			indices[weekday] = sums[weekday] / float64(counts[weekday])
+		}
+		total += indices[weekday]
+	}
+	for weekday := range indices {
+		indices[weekday] -= total / seasonLength
+	}
+	return indices
+}
+
+// isConsecutiveDays reports whether every point falls on the calendar day after the one before it.
+func isConsecutiveDays(Points []SeriesPoint) bool {
+	for i := 1; i < len(Points); i++ {
+		if !Points[i].Day.Equal(Points[i-1].Day.AddDate(0, 0, 1)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Predict is the expected value on a day, never below zero.
+func (m SeasonalModel) Predict(Day time.Time) float64 {
+	return math.Max(0, m.Level+m.Season[Day.Weekday()])
+}
+
+// Forecast sums the expected values of the Days days from start on. The band grows with the
+// daily errors, which add up as independent, and with the error of the level itself, which
+// is the same for every day.
+func (m SeasonalModel) Forecast(start time.Time, Days int) ForecastRange {
+	total := 0.0
+	for day := 0; day < Days; day++ {
+		total += m.Predict(start.AddDate(0, 0, day))
+	}
+
+	horizon := float64(Days)
+	spread := ForecastBandZ * m.Sigma * math.Sqrt(horizon+horizon*horizon/seasonLength)
+	return newForecastRange(total, spread)
+}
+
+func newForecastRange(value, spread float64) ForecastRange {
+	return ForecastRange{
+		Value: utils.RoundToTwoDecimalDigits(value),
+		Lower: utils.RoundToTwoDecimalDigits(math.Max(0, value-spread)),
+		Upper: utils.RoundToTwoDecimalDigits(value + spread),
+	}
+}
+
+// scaleForecastRange turns a forecast of units into one of revenue at a fixed price.
+func scaleForecastRange(units ForecastRange, price float64) ForecastRange {
+	return ForecastRange{
+		Value: utils.RoundToTwoDecimalDigits(units.Value * price),
+		Lower: utils.RoundToTwoDecimalDigits(units.Lower * price),
+		Upper: utils.RoundToTwoDecimalDigits(units.Upper * price),
+	}
+}
+
+// ShopSalesSeries turns the shop's daily snapshots into units sold and revenue per day. A
+// day's units are the change in total sales since the day before. Days without a snapshot
+// the day before and vacation days are left out since their sales are unknown or frozen.
+func ShopSalesSeries(dailySales []models.DailyShopSales, Periods []models.VacationPeriod, loc *time.Location) (sales, revenue []SeriesPoint) {
+	snapshots := make(map[time.Time]models.DailyShopSales)
+	days := []time.Time{}
+	for _, snapshot := range dailySales {
+		day := utils.TruncateDateInLocation(snapshot.CreatedAt, loc)
+		if existing, ok := snapshots[day]; !ok {
+			days = append(days, day)
+		} else if existing.CreatedAt.After(snapshot.CreatedAt) {
+			continue
+		}
+		snapshots[day] = snapshot
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	for _, day := range days {
+		previous, ok := snapshots[day.AddDate(0, 0, -1)]
+		if !ok || IsVacationDay(day, Periods) {
+			continue
+		}
+
+		current := snapshots[day]
+		sold := math.Max(0, float64(current.TotalSales-previous.TotalSales))
+		sales = append(sales, SeriesPoint{Day: day, Value: sold})
+		revenue = append(revenue, SeriesPoint{Day: day, Value: current.DailyRevenue})
+	}
+	return sales, revenue
+}
+
+// ItemSalesSeries counts the units of one item sold on each of the given days. Units scraped
+// from the selling history were not sold on the day they were saved and are left out.
+func ItemSalesSeries(SoldItems []models.SoldItems, Days []time.Time, loc *time.Location) []SeriesPoint {
+	soldByDay := make(map[time.Time]int)
+	for _, soldItem := range SoldItems {
+		if soldItem.FromHistory {
+			continue
+		}
+		soldByDay[utils.TruncateDateInLocation(soldItem.CreatedAt, loc)]++
+	}
+
+	series := make([]SeriesPoint, 0, len(Days))
+	for _, day := range Days {
+		series = append(series, SeriesPoint{Day: day, Value: float64(soldByDay[day])})
+	}
+	return series
+}
+
+func CreateForecastHorizons(salesModel, revenueModel SeasonalModel, start time.Time) []ForecastHorizon {
+	horizons := []ForecastHorizon{}
+	for _, days := range ForecastHorizons {
+		horizons = append(horizons, ForecastHorizon{
+			Days:    days,
+			Sales:   salesModel.Forecast(start, days),
+			Revenue: revenueModel.Forecast(start, days),
+		})
+	}
+	return horizons
+}
+
+func CreateForecastDays(salesModel, revenueModel SeasonalModel, start time.Time, Days int) []ForecastDay {
+	daily := []ForecastDay{}
+	for day := 0; day < Days; day++ {
+		date := start.AddDate(0, 0, day)
+		daily = append(daily, ForecastDay{
+			Date:    date.Format("2006-01-02"),
+			Sales:   salesModel.Forecast(date, 1),
+			Revenue: revenueModel.Forecast(date, 1),
+		})
+	}
+	return daily
+}
+
+func CreateItemForecast(BestSeller BestSeller, salesModel SeasonalModel, ItemPrice float64, start time.Time) ItemForecast {
+	forecast := ItemForecast{
+		ItemID:    BestSeller.ItemID,
+		ListingID: BestSeller.ListingID,
+		Name:      BestSeller.Name,
+		Horizons:  []ForecastHorizon{},
+	}
+	for _, days := range ForecastHorizons {
+		sales := salesModel.Forecast(start, days)
+		forecast.Horizons = append(forecast.Horizons, ForecastHorizon{
+			Days:    days,
+			Sales:   sales,
+			Revenue: scaleForecastRange(sales, ItemPrice),
+		})
+	}
+	return forecast
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
@@ -5,10 +5,12 @@ import (
 	"EtsyScraper/utils"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func (s *Shop) CreateNewShopRequest(ctx *gin.Context) {
@@ -44,11 +46,25 @@ func (s *Shop) CreateNewShopRequest(ctx *gin.Context) {
 	}
 
 	ShopRequest.Status = "Pending"
+	MarkShopRequestClaimed(ShopRequest, s.OnboardingInstanceID())
 	s.Operations.CreateShopRequest(ShopRequest)
 
-	HandleResponse(ctx, nil, http.StatusOK, "shop request received successfully", nil)
+	if AttachToInFlightShopRequest(ShopRequest) {
+		HandleResponse(ctx, nil, http.StatusOK, "shop request received successfully", nil)
+		return
+	}
+
+	if err := s.Operations.EnqueueShopOnboarding(ShopRequest); err != nil {
+		HandleResponse(ctx, err, http.StatusServiceUnavailable, "shop requests are not accepted at the moment", nil)
+
+		ShopRequest.Status = "failed"
+		s.Operations.CreateShopRequest(ShopRequest)
+		s.ResolveCoalescedRequests(ShopRequest, nil)
+
+		return
+	}
 
-	go s.Operations.CreateNewShop(ShopRequest)
+	HandleResponse(ctx, nil, http.StatusOK, "shop request received successfully", nil)
 
 }
 
@@ -156,6 +172,42 @@ func (s *Shop) HandleGetItemsCountByShopID(ctx *gin.Context) {
 	HandleResponse(ctx, nil, http.StatusOK, "", Items)
 }
 
+func (s *Shop) HandleGetStockoutsByShopID(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+	Stockouts, err := s.GetStockoutsByShopID(ShopIDToUint)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling stockouts", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"stockouts": Stockouts})
+}
+
+func (s *Shop) HandleGetCategoryTimeline(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+	Timeline, err := s.GetCategoryTimeline(ShopIDToUint)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling categories", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", Timeline)
+}
+
 func (s *Shop) HandleGetSoldItemsByShopID(ctx *gin.Context) {
 	ShopID := ctx.Param("shopID")
 	ShopIDToUint, err := utils.StringToUint(ShopID)
@@ -182,35 +234,520 @@ func (s *Shop) ProcessStatsRequest(ctx *gin.Context) {
 		return
 	}
 
-	year, month, day := 0, 0, 0
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
 
-	switch Period {
-	case "lastSevenDays":
-		day = -6
-	case "lastThirtyDays":
-		day = -29
-	case "lastThreeMonths":
-		month = -3
-	case "lastSixMonths":
-		month = -6
-	case "lastYear":
-		year = -1
+	StatsRange, err := ParseStatsRange(Period, ctx.Query("from"), ctx.Query("to"), ctx.DefaultQuery("granularity", GranularityDay), time.Now().In(loc))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	Compare, err := ParseCompare(ctx.Query("compare"))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// the day before the first day is needed as the base of the first day's sales
+	FetchFrom := StatsRange.From
+	if Compare {
+		FetchFrom = StatsRange.Previous().From
+	}
+	Stats, err := s.Operations.GetSellingStatsByPeriod(ShopIDToUint, FetchFrom.AddDate(0, 0, -1))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling stats", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", CreateStatsReport(Stats, StatsRange, Compare))
+
+}
+
+func (s *Shop) RefreshShop(ctx *gin.Context) {
+
+	currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	FullRefresh := false
+	switch ctx.DefaultQuery("mode", "light") {
+	case "light":
+	case "full":
+		FullRefresh = true
 	default:
-		err := errors.New("invalid period provided")
-		HandleResponse(ctx, err, http.StatusInternalServerError, err.Error(), nil)
+		err := errors.New("invalid refresh mode provided")
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	job, isDuplicate, err := s.Operations.EnqueueShopRefresh(ShopIDToUint, currentUserUUID, FullRefresh)
+	if err != nil {
+		if errors.Is(err, ErrRefreshQuotaExceeded) || errors.Is(err, ErrRefreshCooldown) {
+			HandleResponse(ctx, err, http.StatusTooManyRequests, err.Error(), nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while queueing refresh", nil)
 		return
+	}
 
+	if !isDuplicate {
+		s.StartShopRefresh(job)
 	}
 
-	date := time.Now().AddDate(year, month, day)
-	dateMidnight := utils.TruncateDate(date)
+	HandleResponse(ctx, nil, http.StatusAccepted, "", gin.H{"job_id": job.JobID, "status": job.Status, "deduplicated": isDuplicate})
+}
 
-	LastSevenDays, err := s.Operations.GetSellingStatsByPeriod(ShopIDToUint, dateMidnight)
+func (s *Shop) HandleGetRefreshJob(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
 	if err != nil {
-		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling stats", nil)
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
 		return
 	}
 
-	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"stats": LastSevenDays})
+	JobID, err := uuid.Parse(ctx.Param("jobID"))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get job id", nil)
+		return
+	}
+
+	job, err := s.Shop.GetRefreshJobByJobID(JobID)
+	if err != nil || job.ShopID != ShopIDToUint {
+		HandleResponse(ctx, err, http.StatusNotFound, "refresh job not found", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", job)
+}
+
+func (s *Shop) HandleMergeShops(ctx *gin.Context) {
+
+	var mergeRequest MergeShopsRequest
+	if err := ctx.ShouldBindJSON(&mergeRequest); err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get the shops to merge", nil)
+		return
+	}
+
+	merge, err := s.Operations.MergeDuplicateShops(mergeRequest.TargetShopID, mergeRequest.SourceShopID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrMergeSameShop), errors.Is(err, ErrShopsNotDuplicates):
+			HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+		default:
+			HandleResponse(ctx, err, http.StatusInternalServerError, "error while merging shops", nil)
+		}
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "shops merged", gin.H{
+		"target_shop_id":      merge.TargetShopID,
+		"source_shop_id":      merge.SourceShopID,
+		"moved_menus":         len(merge.MovedMenus),
+		"moved_items":         len(merge.MovedItems),
+		"merged_items":        len(merge.MergedItems),
+		"dropped_sold_items":  len(merge.DroppedSoldItems),
+		"moved_daily_sales":   len(merge.MovedDailySales),
+		"dropped_daily_sales": len(merge.DroppedDailySales),
+	})
+}
+
+func (s *Shop) HandleGetProfileTimeline(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	Field := ctx.Query("field")
+	if Field != "" && !ProfileFields[Field] {
+		HandleResponse(ctx, ErrUnknownProfileField, http.StatusBadRequest, "unknown profile field", nil)
+		return
+	}
+
+	Timeline, err := s.GetProfileTimeline(ShopIDToUint, Field)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling shop timeline", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"changes": Timeline})
+}
+
+func (s *Shop) HandleGetVacationPeriods(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	Periods, err := s.GetVacationPeriods(ShopIDToUint)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling vacations", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"vacations": Periods})
+}
+
+func (s *Shop) HandleGetItemChangesByShopID(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	Attribute := ctx.Query("attribute")
+	if Attribute != "" && !ItemAttributes[Attribute] {
+		HandleResponse(ctx, ErrUnknownItemAttribute, http.StatusBadRequest, "unknown item attribute", nil)
+		return
+	}
+
+	Changes, err := s.GetItemChangesByShopID(ShopIDToUint, Attribute)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling item changes", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"changes": Changes})
+}
+
+func (s *Shop) HandleGetListingRankHistory(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	ListingID := ctx.Param("listingID")
+	ListingIDToUint, err := utils.StringToUint(ListingID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get listing id", nil)
+		return
+	}
+
+	History, err := s.GetListingRankHistory(ShopIDToUint, ListingIDToUint)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling listing positions", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"positions": History})
+}
+
+func (s *Shop) HandleGetBestSellers(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	year, month, day, err := PeriodOffset(ctx.DefaultQuery("period", "lastThirtyDays"))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	Limit, err := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(DefaultBestSellersLimit)))
+	if err != nil || Limit < 1 || Limit > MaxBestSellersLimit {
+		HandleResponse(ctx, ErrInvalidLimit, http.StatusBadRequest, ErrInvalidLimit.Error(), nil)
+		return
+	}
+
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
+
+	now := time.Now().In(loc)
+	periodStart := utils.TruncateDateInLocation(now.AddDate(year, month, day), loc)
+
+	BestSellers, err := s.GetBestSellers(ShopIDToUint, periodStart, now, ctx.Query("category"), Limit)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling bestsellers", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"bestsellers": BestSellers})
+}
+
+func (s *Shop) HandleGetSalesForecast(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
+
+	Forecast, err := s.GetSalesForecast(ShopIDToUint, time.Now().In(loc))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		if errors.Is(err, ErrNotEnoughHistory) {
+			HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling forecast", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"forecast": Forecast})
+}
+
+func (s *Shop) HandleGetPriceImpact(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	Report, err := s.GetPriceImpact(ShopIDToUint, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling price impact", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"price_impact": Report})
+}
+
+func (s *Shop) HandleGetItemPriceImpact(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	ListingID := ctx.Param("listingID")
+	ListingIDToUint, err := utils.StringToUint(ListingID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get listing id", nil)
+		return
+	}
+
+	Report, err := s.GetItemPriceImpact(ShopIDToUint, ListingIDToUint, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		if errors.Is(err, ErrListingNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling price impact", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"price_impact": Report})
+}
+
+func (s *Shop) HandleGetCategoryStats(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
+	now := time.Now().In(loc)
+
+	Period := ctx.Param("period")
+	if Period == "" && ctx.Query("from") == "" {
+		Period = "lastThirtyDays"
+	}
+	StatsRange, err := ParseStatsRange(Period, ctx.Query("from"), ctx.Query("to"), ctx.DefaultQuery("granularity", GranularityDay), now)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	Stats, err := s.GetCategoryStats(ShopIDToUint, StatsRange, now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling category stats", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", Stats)
+}
+
+func (s *Shop) HandleCompareShops(ctx *gin.Context) {
+	currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+
+	ShopIDs, err := ParseShopIDs(ctx.Query("ids"))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	loc := s.GetAccountLocation(currentUserUUID)
+
+	Period := ctx.Query("period")
+	if Period == "" && ctx.Query("from") == "" {
+		Period = "lastThirtyDays"
+	}
+	StatsRange, err := ParseStatsRange(Period, ctx.Query("from"), ctx.Query("to"), ctx.DefaultQuery("granularity", GranularityDay), time.Now().In(loc))
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	Comparison, err := s.GetShopComparison(currentUserUUID, ShopIDs, StatsRange)
+	if err != nil {
+		if errors.Is(err, ErrShopNotFollowed) {
+			HandleResponse(ctx, err, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while comparing shops", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", Comparison)
+}
+
+func (s *Shop) HandleGetListingLifecycle(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
+	now := time.Now().In(loc)
+
+	Period := ctx.Query("period")
+	if Period == "" && ctx.Query("from") == "" {
+		Period = "lastThirtyDays"
+	}
+	StatsRange, err := ParseStatsRange(Period, ctx.Query("from"), ctx.Query("to"), ctx.DefaultQuery("granularity", GranularityWeek), now)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	Report, err := s.GetListingLifecycle(ShopIDToUint, StatsRange, now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling listing lifecycle", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", Report)
+}
+
+func (s *Shop) HandleGetPromotions(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	loc := time.UTC
+	if currentUserUUID, ok := ctx.Get("currentUserUUID"); ok {
+		loc = s.GetAccountLocation(currentUserUUID.(uuid.UUID))
+	}
+	now := time.Now().In(loc)
+
+	Period := ctx.Query("period")
+	if Period == "" && ctx.Query("from") == "" {
+		Period = "lastThreeMonths"
+	}
+	StatsRange, err := ParseStatsRange(Period, ctx.Query("from"), ctx.Query("to"), GranularityDay, now)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	Report, err := s.GetPromotions(ShopIDToUint, StatsRange, now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while handling promotions", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", Report)
+}
+
+func (s *Shop) HandleRecomputeRevenue(ctx *gin.Context) {
+	ShopID := ctx.Param("shopID")
+	ShopIDToUint, err := utils.StringToUint(ShopID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to get Shop id", nil)
+		return
+	}
+
+	UpdatedDays, err := s.RecomputeDailyRevenue(ShopIDToUint)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			HandleResponse(ctx, err, http.StatusNotFound, "shop not found", nil)
+			return
+		}
+		HandleResponse(ctx, err, http.StatusInternalServerError, "error while recomputing revenue", nil)
+		return
+	}
 
+	HandleResponse(ctx, nil, http.StatusOK, "revenue recomputed", gin.H{"updated_days": UpdatedDays})
 }
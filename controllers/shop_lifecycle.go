@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// YoungListingDays is the age under which a listing's sales count towards the young listings revenue.
+var YoungListingDays = 30.0
+
+type ListingLifecycleBucket struct {
+	Date                 string `json:"date"`
+	EndDate              string `json:"end_date"`
+	NewListings          int    `json:"new_listings"`
+	DiscontinuedListings int    `json:"discontinued_listings"`
+}
+
+// ListingLifecycleSummary holds the averages of the period, null when no listing could be measured.
+type ListingLifecycleSummary struct {
+	NewListings               int      `json:"new_listings"`
+	DiscontinuedListings      int      `json:"discontinued_listings"`
+	ActiveListings            int      `json:"active_listings"`
+	AverageLifetimeDays       *float64 `json:"average_lifetime_days"`
+	MeasuredLifetimes         int      `json:"measured_lifetimes"`
+	AverageDaysToFirstSale    *float64 `json:"average_days_to_first_sale"`
+	MedianDaysToFirstSale     *float64 `json:"median_days_to_first_sale"`
+	SoldNewListings           int      `json:"sold_new_listings"`
+	UnsoldNewListings         int      `json:"unsold_new_listings"`
+	Revenue                   float64  `json:"revenue"`
+	YoungListingsRevenue      float64  `json:"young_listings_revenue"`
+	YoungListingsRevenueShare *float64 `json:"young_listings_revenue_share"`
+}
+
+type ListingLifecycleReport struct {
+	From        string                   `json:"from"`
+	To          string                   `json:"to"`
+	Granularity string                   `json:"granularity"`
+	Summary     ListingLifecycleSummary  `json:"summary"`
+	Series      []ListingLifecycleBucket `json:"series"`
+}
+
+// listingLifecycle is when an item was listed, discontinued and first sold. ListedAt is zero for
+// items listed before the shop was tracked, DiscontinuedAt for items that are still listed.
+type listingLifecycle struct {
+	ListedAt       time.Time
+	DiscontinuedAt time.Time
+	FirstSoldAt    time.Time
+}
+
+// itemLifecycle reads the item's history changes, oldest first. An item is listed by the change
+// creating it and discontinued by the last change taking it off sale or moving it to the Out Of
+// Production menu, unless a later change lists it again. Units scraped from the selling history
+// when the shop was added do not count as a first sale.
+func itemLifecycle(item models.Item, OutOfProductionIDs map[uint]bool) listingLifecycle {
+	lifecycle := listingLifecycle{}
+
+	for _, change := range item.PriceHistory {
+		if change.NewItemCreated {
+			lifecycle.ListedAt = change.CreatedAt
+			lifecycle.DiscontinuedAt = time.Time{}
+			continue
+		}
+
+		wasListed := change.OldAvailable && !OutOfProductionIDs[change.OldMenuItemID]
+		isListed := change.NewAvailable && !OutOfProductionIDs[change.NewMenuItemID]
+		if wasListed && !isListed {
+			lifecycle.DiscontinuedAt = change.CreatedAt
+		} else if isListed {
+			lifecycle.DiscontinuedAt = time.Time{}
+		}
+	}
+
+	if lifecycle.ListedAt.IsZero() {
+		return lifecycle
+	}
+	for _, soldItem := range item.SoldUnits {
+		if soldItem.FromHistory || soldItem.CreatedAt.Before(lifecycle.ListedAt) {
+			continue
+		}
+		if lifecycle.FirstSoldAt.IsZero() || soldItem.CreatedAt.Before(lifecycle.FirstSoldAt) {
+			lifecycle.FirstSoldAt = soldItem.CreatedAt
+		}
+	}
+	return lifecycle
+}
+
+// CreateListingLifecycleReport counts the listings the shop added and discontinued in every bucket
+// of the range. Lifetimes are measured for listings discontinued in the range that were listed
+// while the shop was tracked, the time to a first sale for listings added in the range. The
+// young listings revenue is the part of the range's revenue sold by listings under
+// YoungListingDays old.
+func CreateListingLifecycleReport(Menu []models.MenuItem, Items []models.Item, statsRange StatsRange, now time.Time, AverageItemPrice float64) ListingLifecycleReport {
+	report := ListingLifecycleReport{
+		From:        statsRange.From.Format("2006-01-02"),
+		To:          statsRange.To.Format("2006-01-02"),
+		Granularity: statsRange.Granularity,
+		Series:      []ListingLifecycleBucket{},
+	}
+	rangeEnd := statsRange.To.AddDate(0, 0, 1)
+	inRange := func(At time.Time) bool {
+		return !At.IsZero() && !At.Before(statsRange.From) && At.Before(rangeEnd)
+	}
+
+	bucketIndex := make(map[string]int)
+	for day := statsRange.From; !day.After(statsRange.To); day = day.AddDate(0, 0, 1) {
+		start, end := statsRange.bucketBounds(day)
+		date := start.Format("2006-01-02")
+		if _, ok := bucketIndex[date]; !ok {
+			bucketIndex[date] = len(report.Series)
+			report.Series = append(report.Series, ListingLifecycleBucket{Date: date, EndDate: end.Format("2006-01-02")})
+		}
+	}
+	bucketOf := func(At time.Time) *ListingLifecycleBucket {
+		start, _ := statsRange.bucketBounds(utils.TruncateDateInLocation(At, statsRange.From.Location()))
+		return &report.Series[bucketIndex[start.Format("2006-01-02")]]
+	}
+
+	OutOfProductionIDs := make(map[uint]bool)
+	for _, menu := range Menu {
+		if menu.Category == OutOfProductionCategory {
+			OutOfProductionIDs[menu.ID] = true
+		}
+	}
+
+	summary := &report.Summary
+	lifetimes, daysToFirstSale := []float64{}, []float64{}
+	for _, item := range Items {
+		lifecycle := itemLifecycle(item, OutOfProductionIDs)
+
+		if item.Available && !OutOfProductionIDs[item.MenuItemID] {
+			summary.ActiveListings++
+		}
+
+		if inRange(lifecycle.ListedAt) {
+			summary.NewListings++
+			bucketOf(lifecycle.ListedAt).NewListings++
+			if lifecycle.FirstSoldAt.IsZero() {
+				summary.UnsoldNewListings++
+			} else {
+				summary.SoldNewListings++
+				daysToFirstSale = append(daysToFirstSale, lifecycle.FirstSoldAt.Sub(lifecycle.ListedAt).Hours()/24)
+			}
+		}
+
+		if inRange(lifecycle.DiscontinuedAt) {
+			summary.DiscontinuedListings++
+			bucketOf(lifecycle.DiscontinuedAt).DiscontinuedListings++
+			if !lifecycle.ListedAt.IsZero() {
+				lifetimes = append(lifetimes, lifecycle.DiscontinuedAt.Sub(lifecycle.ListedAt).Hours()/24)
+			}
+		}
+
+		for _, soldItem := range item.SoldUnits {
+			if soldItem.FromHistory || !inRange(soldItem.CreatedAt) || soldItem.CreatedAt.After(now) {
+				continue
+			}
+			price := SoldItemPrice(item, soldItem.CreatedAt, AverageItemPrice)
+			summary.Revenue += price
+			if !lifecycle.ListedAt.IsZero() && soldItem.CreatedAt.Sub(lifecycle.ListedAt).Hours()/24 < YoungListingDays {
+				summary.YoungListingsRevenue += price
+			}
+		}
+	}
+
+	summary.MeasuredLifetimes = len(lifetimes)
+	if len(lifetimes) > 0 {
+		average := utils.RoundToTwoDecimalDigits(mean(lifetimes))
+		summary.AverageLifetimeDays = &average
+	}
+	if len(daysToFirstSale) > 0 {
+		average := utils.RoundToTwoDecimalDigits(mean(daysToFirstSale))
+		median := utils.RoundToTwoDecimalDigits(median(daysToFirstSale))
+		summary.AverageDaysToFirstSale = &average
+		summary.MedianDaysToFirstSale = &median
+	}
+	summary.Revenue = utils.RoundToTwoDecimalDigits(summary.Revenue)
+	summary.YoungListingsRevenue = utils.RoundToTwoDecimalDigits(summary.YoungListingsRevenue)
+	if summary.Revenue > 0 {
+		share := utils.RoundToTwoDecimalDigits(summary.YoungListingsRevenue / summary.Revenue * 100)
+		summary.YoungListingsRevenueShare = &share
+	}
+
+	return report
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
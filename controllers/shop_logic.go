@@ -2,16 +2,26 @@ package controllers
 
 import (
 	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	scrap "EtsyScraper/scraping"
 	"EtsyScraper/utils"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func (s *Shop) CreateNewShop(ShopRequest *models.ShopRequest) error {
-	queueMutex.Lock()
-	defer queueMutex.Unlock()
+	var createdShop *models.Shop
+	defer func() {
+		s.ResolveCoalescedRequests(ShopRequest, createdShop)
+	}()
+
 	scrappedShop, err := s.Scraper.ScrapShop(ShopRequest.ShopName)
 	if err != nil {
 		message := fmt.Sprintf("failed to initiate Shop while handling ShopRequest.ID: %v", ShopRequest.ID)
@@ -21,24 +31,29 @@ func (s *Shop) CreateNewShop(ShopRequest *models.ShopRequest) error {
 	scrappedShop.CreatedByUserID = ShopRequest.AccountID
 
 	if err = s.Operations.SaveShopToDB(scrappedShop, ShopRequest); err != nil {
+		if errors.Is(err, repository.ErrShopNameTaken) {
+			createdShop, err = s.AttachToExistingShop(ShopRequest)
+			return err
+		}
 		return utils.HandleError(err)
 	}
 
 	log.Println("starting Shop's menu scraping for ShopRequest.ID: ", ShopRequest.ID)
 
-	scrapeMenu := s.Scraper.ScrapAllMenuItems(scrappedShop)
+	scrapeMenu := ScrapMenuItems(s.Scraper, scrappedShop)
 
 	if err = s.Operations.UpdateShopMenuToDB(scrapeMenu, ShopRequest); err != nil {
 		return utils.HandleError(err)
 
 	}
+	createdShop = scrapeMenu
 
 	Task := new(models.TaskSchedule)
 
 	if scrapeMenu.HasSoldHistory && scrapeMenu.TotalSales > 0 {
-		log.Println("Shop's selling history initiated for ShopRequest.ID: ", ShopRequest.ID)
+		log.Println("Shop's selling history queued for ShopRequest.ID: ", ShopRequest.ID)
 
-		if err := s.Operations.UpdateSellingHistory(scrapeMenu, Task, ShopRequest); err != nil {
+		if err := s.Operations.EnqueueSellingHistory(scrapeMenu, Task, ShopRequest, 0); err != nil {
 			ShopRequest.Status = "failed"
 			s.Operations.CreateShopRequest(ShopRequest)
 			message := fmt.Sprintf("Shop's selling history failed for ShopRequest.ID: %v", ShopRequest.ID)
@@ -52,6 +67,57 @@ func (s *Shop) CreateNewShop(ShopRequest *models.ShopRequest) error {
 	return nil
 }
 
+// AttachToExistingShop completes ShopRequest with the shop another instance created
+// under the same name while this one was scraping it.
+func (s *Shop) AttachToExistingShop(ShopRequest *models.ShopRequest) (*models.Shop, error) {
+	existingShop, err := s.Shop.GetShopByName(ShopRequest.ShopName)
+	if err != nil {
+		ShopRequest.Status = "failed"
+		s.Operations.CreateShopRequest(ShopRequest)
+		message := fmt.Sprintf("failed to find the existing Shop while handling ShopRequest.ID: %v", ShopRequest.ID)
+		return nil, utils.HandleError(err, message)
+	}
+
+	log.Println("Shop was created by another instance, attaching ShopRequest.ID: ", ShopRequest.ID)
+	ShopRequest.Status = "done"
+	s.Operations.CreateShopRequest(ShopRequest)
+	return existingShop, nil
+}
+
+// ScrapMenuItems scrapes the menu of a shop. The menu scraper keeps its page bookkeeping
+// in package state, so only one shop menu can be scraped at a time.
+func ScrapMenuItems(Scraper scrap.ScrapeUpdateProcess, Shop *models.Shop) *models.Shop {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	return Scraper.ScrapAllMenuItems(Shop)
+}
+
+// LockShop waits up to ShopLockWait for the lock of a shop and takes it. The scheduled
+// update, refreshes and merges hold it while they write a shop's sales, menus and items,
+// on any instance. The returned func gives the lock back.
+func LockShop(Repo repository.ShopRepository, ShopID uint) (unlock func(), err error) {
+	Owner := uuid.New().String()
+	deadline := time.Now().Add(ShopLockWait)
+
+	for {
+		locked, err := Repo.LockShop(ShopID, Owner, time.Now().Add(-ShopLockTimeout))
+		if err != nil {
+			return nil, utils.HandleError(err)
+		}
+		if locked {
+			return func() {
+				if err := Repo.UnlockShop(ShopID, Owner); err != nil {
+					log.Printf("failed to unlock Shop.ID %v: %v\n", ShopID, err)
+				}
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, utils.HandleError(ErrShopLocked)
+		}
+		time.Sleep(ShopLockRetryInterval)
+	}
+}
+
 func (s *Shop) UpdateSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) error {
 
 	ScrappedSoldItems, err := s.Operations.UpdateDiscontinuedItems(Shop, Task, ShopRequest)
@@ -73,17 +139,23 @@ func (s *Shop) UpdateSellingHistory(Shop *models.Shop, Task *models.TaskSchedule
 		return utils.HandleError(err)
 	}
 
-	ScrappedSoldItems, dailyRevenue := PopulateItemIDsFromListings(ScrappedSoldItems, AllItems)
+	ScrappedSoldItems, _ = PopulateItemIDsFromListings(ScrappedSoldItems, AllItems)
 
 	ScrappedSoldItems = ReverseSoldItems(ScrappedSoldItems)
 
-	if err = s.Shop.SaveSoldItemsToDB(ScrappedSoldItems); err != nil {
+	if Task.UpdateSoldItems == 0 {
+		for i := range ScrappedSoldItems {
+			ScrappedSoldItems[i].FromHistory = true
+		}
+	}
+
+	if err = s.Shop.SaveSoldItemsToDB(ScrappedSoldItems, Shop.ID); err != nil {
 		return utils.HandleError(err)
 	}
 
 	if Task.UpdateSoldItems > 0 {
 
-		if err = s.Shop.UpdateDailySales(ScrappedSoldItems, Shop.ID, dailyRevenue); err != nil {
+		if err = s.RecordDailyRevenue(Shop.ID, AllItems); err != nil {
 			return utils.HandleError(err)
 		}
 	}
@@ -95,13 +167,48 @@ func (s *Shop) UpdateSellingHistory(Shop *models.Shop, Task *models.TaskSchedule
 	return nil
 }
 
+// RecordDailyRevenue sets today's revenue of a shop from every unit saved today, not only
+// the last batch. A refresh and the scheduled update both ingest units during the day, the
+// one writing last leaves the revenue of all of them, also when the refresh ran before
+// today's snapshot existed. Units of a selling history scraped today were not sold today.
+func (s *Shop) RecordDailyRevenue(ShopID uint, Items []models.Item) error {
+	ItemIDs := make([]uint, 0, len(Items))
+	ItemByID := make(map[uint]models.Item, len(Items))
+	for _, item := range Items {
+		ItemIDs = append(ItemIDs, item.ID)
+		ItemByID[item.ID] = item
+	}
+
+	SoldItems, err := s.Shop.GetSoldItemsByItemIDs(ItemIDs, utils.TruncateDate(time.Now()))
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	var dailyRevenue float64
+	TrackedItems := make([]models.SoldItems, 0, len(SoldItems))
+	for _, soldItem := range SoldItems {
+		if soldItem.FromHistory {
+			continue
+		}
+		dailyRevenue += CurrentItemPrice(ItemByID[soldItem.ItemID])
+		TrackedItems = append(TrackedItems, soldItem)
+	}
+
+	if err := s.Shop.UpdateDailySales(TrackedItems, ShopID, dailyRevenue); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
 func (s *Shop) UpdateDiscontinuedItems(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) ([]models.SoldItems, error) {
 
 	FilterSoldItems := map[uint]struct{}{}
 
 	scrapSoldItems, NewTask := s.Scraper.ScrapSalesHistory(Shop.Name, Task)
 	if !NewTask.IsScrapeFinished {
-		go s.SoldItemsTask(Shop, NewTask, ShopRequest)
+		if err := s.SoldItemsTask(Shop, NewTask, ShopRequest); err != nil {
+			return nil, utils.HandleError(err)
+		}
 	}
 
 	if len(scrapSoldItems) == 0 {
@@ -133,10 +240,573 @@ func (s *Shop) UpdateDiscontinuedItems(Shop *models.Shop, Task *models.TaskSched
 func (s *Shop) SoldItemsTask(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) error {
 
 	randTimeSet := time.Duration(rand.Intn(79) + 10)
-	durationUntilNextTask := time.Until(time.Now().Add(randTimeSet * time.Second))
 
-	time.AfterFunc(durationUntilNextTask, func() {
-		s.Operations.UpdateSellingHistory(Shop, Task, ShopRequest)
-	})
+	return s.Operations.EnqueueSellingHistory(Shop, Task, ShopRequest, randTimeSet*time.Second)
+}
+
+func (s *Shop) EnqueueShopOnboarding(ShopRequest *models.ShopRequest) error {
+	if s.Onboarding == nil {
+		return utils.HandleError(ErrOnboardingQueueClosed)
+	}
+
+	job := &OnboardingJob{
+		Kind:        OnboardingProfileJob,
+		AccountID:   ShopRequest.AccountID,
+		Weight:      s.GetAccountOnboardingWeight(ShopRequest.AccountID),
+		ShopRequest: ShopRequest,
+	}
+
+	if err := s.Onboarding.Push(job); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (s *Shop) EnqueueSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest, delay time.Duration) error {
+	if s.Onboarding == nil {
+		return utils.HandleError(ErrOnboardingQueueClosed)
+	}
+
+	job := &OnboardingJob{
+		Kind:        OnboardingHistoryJob,
+		AccountID:   ShopRequest.AccountID,
+		Weight:      s.GetAccountOnboardingWeight(ShopRequest.AccountID),
+		ShopRequest: ShopRequest,
+		Shop:        Shop,
+		Task:        Task,
+		NotBefore:   time.Now().Add(delay),
+	}
+
+	if err := s.Onboarding.Push(job); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (s *Shop) ProcessOnboardingJob(job *OnboardingJob) {
+	var err error
+
+	switch job.Kind {
+	case OnboardingProfileJob:
+		err = s.Operations.CreateNewShop(job.ShopRequest)
+	case OnboardingHistoryJob:
+		err = s.Operations.UpdateSellingHistory(job.Shop, job.Task, job.ShopRequest)
+	default:
+		err = fmt.Errorf("unknown onboarding job kind: %s", job.Kind)
+	}
+
+	if err != nil {
+		log.Printf("onboarding %s job failed for ShopRequest.ID: %v, error: %v\n", job.Kind, job.ShopRequest.ID, err)
+	}
+}
+
+func (s *Shop) EnqueueShopRefresh(ShopID uint, AccountID uuid.UUID, FullRefresh bool) (*models.ShopRefreshJob, bool, error) {
+	enqueueRefreshMutex.Lock()
+	defer enqueueRefreshMutex.Unlock()
+
+	now := time.Now()
+
+	latestJob, err := s.Shop.GetLatestRefreshJob(ShopID)
+	if err != nil {
+		return nil, false, utils.HandleError(err, "error while checking refresh jobs")
+	}
+
+	if IsRefreshJobActive(latestJob, now) {
+		return latestJob, true, nil
+	}
+
+	if IsRefreshJobPending(latestJob) {
+		finishedAt := now
+		latestJob.Status = "failed"
+		latestJob.Error = ErrRefreshJobTimedOut.Error()
+		latestJob.FinishedAt = &finishedAt
+		if err := s.Shop.SaveRefreshJob(latestJob); err != nil {
+			return nil, false, utils.HandleError(err, "error while failing stale refresh job")
+		}
+	}
+
+	if IsShopInRefreshCooldown(latestJob, now) {
+		return nil, false, utils.HandleError(ErrRefreshCooldown)
+	}
+
+	account, err := s.User.GetAccountByID(AccountID)
+	if err != nil {
+		return nil, false, utils.HandleError(err)
+	}
+
+	usedQuota, err := s.Shop.CountRefreshJobsSince(AccountID, now.Add(-RefreshQuotaWindow))
+	if err != nil {
+		return nil, false, utils.HandleError(err, "error while checking refresh quota")
+	}
+
+	if usedQuota >= int64(GetRefreshQuota(account.SubscriptionType)) {
+		return nil, false, utils.HandleError(ErrRefreshQuotaExceeded)
+	}
+
+	job := &models.ShopRefreshJob{
+		JobID:       uuid.New(),
+		ShopID:      ShopID,
+		AccountID:   AccountID,
+		FullRefresh: FullRefresh,
+		Status:      "queued",
+		RequestedAt: now,
+		ClaimedBy:   s.OnboardingInstanceID(),
+		ClaimedAt:   &now,
+	}
+
+	if err := s.Shop.CreateRefreshJob(job); err != nil {
+		return nil, false, utils.HandleError(err, "error while creating refresh job")
+	}
+
+	return job, false, nil
+}
+
+func (s *Shop) RunShopRefresh(job *models.ShopRefreshJob) error {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+
+	job.Status = "running"
+	if err := s.Shop.SaveRefreshJob(job); err != nil {
+		return utils.HandleError(err)
+	}
+
+	var err error
+	if s.Refresher == nil {
+		err = fmt.Errorf("no shop refresher configured")
+	} else {
+		err = s.Refresher.RefreshShop(job.ShopID, job.FullRefresh)
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = "done"
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+	}
+
+	if saveErr := s.Shop.SaveRefreshJob(job); saveErr != nil {
+		return utils.HandleError(saveErr)
+	}
+
+	if err != nil {
+		message := fmt.Sprintf("refresh job %v failed for Shop.ID: %v", job.JobID, job.ShopID)
+		return utils.HandleError(err, message)
+	}
+
+	log.Printf("refresh job %v finished for Shop.ID: %v\n", job.JobID, job.ShopID)
+	return nil
+}
+
+// StartShopRefresh runs the job in the background, shutdown waits for it in DrainRefreshJobs.
+func (s *Shop) StartShopRefresh(job *models.ShopRefreshJob) {
+	refreshJobs.Add(1)
+	go func() {
+		defer refreshJobs.Done()
+		s.Operations.RunShopRefresh(job)
+	}()
+}
+
+// DrainRefreshJobs waits on shutdown for the refresh jobs this instance started. Jobs still
+// running when ctx ends stop being renewed and are failed once their claim runs out.
+func (s *Shop) DrainRefreshJobs(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		refreshJobs.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return utils.HandleError(ctx.Err(), "refresh jobs did not finish before shutdown")
+	}
+}
+
+// KeepRefreshClaims renews this instance's claims on its queued and running refresh jobs
+// every interval.
+func (s *Shop) KeepRefreshClaims(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Shop.RenewRefreshJobClaims(s.OnboardingInstanceID()); err != nil {
+					log.Println("failed to renew refresh job claims: ", err)
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// FailStaleRefreshJobs runs on start and fails the refresh jobs a crashed or restarted
+// instance left queued or running: the ones older than RefreshJobTimeout and the ones whose
+// claim was not renewed for RefreshClaimTimeout. Jobs of other running instances keep
+// renewed claims and are not touched.
+func (s *Shop) FailStaleRefreshJobs() error {
+	now := time.Now()
+	failed, err := s.Shop.FailStaleRefreshJobs(now.Add(-RefreshJobTimeout), now.Add(-RefreshClaimTimeout), ErrRefreshJobTimedOut.Error())
+	if err != nil {
+		return utils.HandleError(err, "error while failing stale refresh jobs")
+	}
+	if failed > 0 {
+		log.Printf("marked %v stale refresh jobs as failed\n", failed)
+	}
+	return nil
+}
+
+func (s *Shop) CheckpointOnboardingJobs(jobs []*OnboardingJob) error {
+	checkpoints := []models.ScrapeCheckpoint{}
+
+	for _, job := range jobs {
+		checkpoint := models.ScrapeCheckpoint{
+			ShopRequestID: job.ShopRequest.ID,
+			Kind:          job.Kind,
+		}
+		if job.Shop != nil {
+			checkpoint.ShopID = job.Shop.ID
+		}
+		if job.Task != nil {
+			checkpoint.Task = *job.Task
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	if len(checkpoints) == 0 {
+		return nil
+	}
+
+	if err := s.Shop.SaveScrapeCheckpoints(checkpoints); err != nil {
+		return utils.HandleError(err)
+	}
+
+	log.Printf("saved %v scrape checkpoints\n", len(checkpoints))
+	return nil
+}
+
+// RecoverOnboarding resumes the checkpoints and the "Pending" requests no instance works on.
+// It runs on start and then with every claim renewal, so the requests of an instance that
+// crashed are picked up once its claims run out. Several instances may run it at once, a
+// request is only resumed by the one that claims it.
+func (s *Shop) RecoverOnboarding() error {
+
+	checkpoints, err := s.Shop.GetScrapeCheckpoints()
+	if err != nil {
+		return utils.HandleError(err, "error while loading scrape checkpoints")
+	}
+
+	resumed := make(map[uint]struct{})
+	for _, checkpoint := range checkpoints {
+		claimed, err := s.claimShopRequest(checkpoint.ShopRequestID)
+		if err != nil {
+			log.Printf("failed to claim ShopRequest.ID: %v, error: %v\n", checkpoint.ShopRequestID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.ResumeCheckpoint(checkpoint); err != nil {
+			log.Printf("failed to resume scrape checkpoint %v: %v\n", checkpoint.ID, err)
+			continue
+		}
+		resumed[checkpoint.ShopRequestID] = struct{}{}
+
+		if err := s.Shop.DeleteScrapeCheckpoint(checkpoint.ID); err != nil {
+			return utils.HandleError(err)
+		}
+	}
+
+	pendingRequests, err := s.Shop.GetShopRequestsByStatus("Pending")
+	if err != nil {
+		return utils.HandleError(err, "error while loading pending shop requests")
+	}
+
+	for i := range pendingRequests {
+		ShopRequest := &pendingRequests[i]
+		if _, ok := resumed[ShopRequest.ID]; ok {
+			continue
+		}
+
+		claimed, err := s.claimShopRequest(ShopRequest.ID)
+		if err != nil {
+			log.Printf("failed to claim ShopRequest.ID: %v, error: %v\n", ShopRequest.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		MarkShopRequestClaimed(ShopRequest, s.OnboardingInstanceID())
+
+		if err := s.RecoverShopRequest(ShopRequest); err != nil {
+			log.Printf("failed to recover ShopRequest.ID: %v, error: %v\n", ShopRequest.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// OnboardingInstanceID names this instance in its claims on shop requests and refresh jobs.
+func (s *Shop) OnboardingInstanceID() string {
+	if s.Onboarding == nil {
+		return ""
+	}
+	return s.Onboarding.InstanceID
+}
+
+// MarkShopRequestClaimed records a claim on the request before it is saved, so saving it
+// keeps the claim.
+func MarkShopRequestClaimed(ShopRequest *models.ShopRequest, InstanceID string) {
+	claimedAt := time.Now()
+	ShopRequest.ClaimedBy = InstanceID
+	ShopRequest.ClaimedAt = &claimedAt
+}
+
+func (s *Shop) claimShopRequest(ID uint) (bool, error) {
+	return s.Shop.ClaimShopRequest(ID, s.OnboardingInstanceID(), time.Now().Add(-OnboardingClaimTimeout))
+}
+
+// KeepOnboardingClaims renews this instance's claims on the requests it works on every
+// interval and recovers the ones other instances stopped renewing.
+func (s *Shop) KeepOnboardingClaims(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Shop.RenewShopRequestClaims(s.OnboardingInstanceID()); err != nil {
+					log.Println("failed to renew shop request claims: ", err)
+					continue
+				}
+				if err := s.RecoverOnboarding(); err != nil {
+					log.Println("failed to recover shop onboarding: ", err)
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// ReleaseOnboardingClaims gives up this instance's claims on shutdown, after its unfinished
+// work was checkpointed, so the next instance to start resumes it right away.
+func (s *Shop) ReleaseOnboardingClaims() error {
+	if err := s.Shop.ReleaseShopRequestClaims(s.OnboardingInstanceID()); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (s *Shop) ResumeCheckpoint(checkpoint models.ScrapeCheckpoint) error {
+
+	ShopRequest, err := s.Shop.GetShopRequestByID(checkpoint.ShopRequestID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	switch checkpoint.Kind {
+	case OnboardingProfileJob:
+		if AttachToInFlightShopRequest(ShopRequest) {
+			return nil
+		}
+		return s.Operations.EnqueueShopOnboarding(ShopRequest)
+	case OnboardingHistoryJob:
+		shop, err := s.Shop.FetchShopByID(checkpoint.ShopID)
+		if err != nil {
+			return utils.HandleError(err)
+		}
+		Task := checkpoint.Task
+		return s.Operations.EnqueueSellingHistory(shop, &Task, ShopRequest, 0)
+	}
+
+	return fmt.Errorf("unknown scrape checkpoint kind: %s", checkpoint.Kind)
+}
+
+// RecoverShopRequest restarts a "Pending" request that was left without a
+// checkpoint, from the step its shop data shows it had reached.
+func (s *Shop) RecoverShopRequest(ShopRequest *models.ShopRequest) error {
+
+	shop, err := s.Shop.GetShopByName(ShopRequest.ShopName)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("restarting orphaned ShopRequest.ID: ", ShopRequest.ID)
+			if AttachToInFlightShopRequest(ShopRequest) {
+				return nil
+			}
+			return s.Operations.EnqueueShopOnboarding(ShopRequest)
+		}
+		return utils.HandleError(err)
+	}
+
+	if shop.HasSoldHistory && shop.TotalSales > 0 {
+		log.Println("restarting selling history for orphaned ShopRequest.ID: ", ShopRequest.ID)
+		return s.Operations.EnqueueSellingHistory(shop, new(models.TaskSchedule), ShopRequest, 0)
+	}
+
+	ShopRequest.Status = "done"
+	return s.Operations.CreateShopRequest(ShopRequest)
+}
+
+// AttachToInFlightShopRequest registers ShopRequest as the one creating its shop,
+// or, when another request for the same shop is already running, attaches it to
+// that one and returns true.
+func AttachToInFlightShopRequest(ShopRequest *models.ShopRequest) bool {
+	coalesceMutex.Lock()
+	defer coalesceMutex.Unlock()
+
+	key := utils.NormalizeShopName(ShopRequest.ShopName)
+	if inFlight, ok := inFlightShopRequests[key]; ok {
+		inFlight.Followers = append(inFlight.Followers, ShopRequest)
+		return true
+	}
+
+	inFlightShopRequests[key] = &coalescedShopRequest{Leader: ShopRequest}
+	return false
+}
+
+func ReleaseInFlightShopRequest(ShopRequest *models.ShopRequest) []*models.ShopRequest {
+	coalesceMutex.Lock()
+	defer coalesceMutex.Unlock()
+
+	key := utils.NormalizeShopName(ShopRequest.ShopName)
+	inFlight, ok := inFlightShopRequests[key]
+	if !ok || inFlight.Leader != ShopRequest {
+		return nil
+	}
+
+	delete(inFlightShopRequests, key)
+	return inFlight.Followers
+}
+
+// ResolveCoalescedRequests finishes the requests attached to ShopRequest. When the
+// shop was created every requesting account follows it, otherwise the attached
+// requests fail along with it.
+func (s *Shop) ResolveCoalescedRequests(ShopRequest *models.ShopRequest, createdShop *models.Shop) {
+	followers := ReleaseInFlightShopRequest(ShopRequest)
+
+	if createdShop == nil {
+		for _, follower := range followers {
+			follower.Status = "failed"
+			s.Operations.CreateShopRequest(follower)
+		}
+		return
+	}
+
+	followed := make(map[uuid.UUID]struct{})
+	for _, request := range append([]*models.ShopRequest{ShopRequest}, followers...) {
+		if _, ok := followed[request.AccountID]; ok {
+			continue
+		}
+		followed[request.AccountID] = struct{}{}
+
+		if err := s.Operations.EstablishAccountShopRelation(createdShop, request.AccountID); err != nil {
+			log.Printf("failed to follow Shop.ID: %v for ShopRequest.ID: %v, error: %v\n", createdShop.ID, request.ID, err)
+		}
+	}
+
+	for _, follower := range followers {
+		follower.Status = "done"
+		s.Operations.CreateShopRequest(follower)
+	}
+}
+
+func (s *Shop) MergeDuplicateShops(TargetShopID, SourceShopID uint) (*repository.ShopMerge, error) {
+	if TargetShopID == SourceShopID {
+		return nil, utils.HandleError(ErrMergeSameShop)
+	}
+
+	// both shops stay locked from reading their rows until they are merged, so no
+	// update adds rows the merge plan does not know about. The lower ID is locked
+	// first so two merges of the same pair can't wait on each other.
+	firstShopID, secondShopID := TargetShopID, SourceShopID
+	if firstShopID > secondShopID {
+		firstShopID, secondShopID = secondShopID, firstShopID
+	}
+	unlockFirst, err := LockShop(s.Shop, firstShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	defer unlockFirst()
+	unlockSecond, err := LockShop(s.Shop, secondShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	defer unlockSecond()
+
+	target, err := s.Shop.GetShopWithSoldItemsByShopID(TargetShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	source, err := s.Shop.GetShopWithSoldItemsByShopID(SourceShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	if utils.NormalizeShopName(target.Name) != utils.NormalizeShopName(source.Name) {
+		return nil, utils.HandleError(ErrShopsNotDuplicates)
+	}
+
+	targetSales, err := s.Shop.GetDailySalesByShopID(TargetShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	sourceSales, err := s.Shop.GetDailySalesByShopID(SourceShopID)
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	targetReviews, err := s.Shop.FetchReviewsStatsByPeriod(TargetShopID, time.Time{})
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+	sourceReviews, err := s.Shop.FetchReviewsStatsByPeriod(SourceShopID, time.Time{})
+	if err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	merge := PlanShopMerge(target, source, targetSales, sourceSales)
+	PlanDailyReviewsMerge(merge, targetReviews, sourceReviews)
+
+	if err := s.Shop.MergeShops(merge); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	log.Printf("Shop.ID %v was merged into Shop.ID %v\n", SourceShopID, TargetShopID)
+	return merge, nil
+}
+
+// MergeDuplicateShopNames folds every shop into the oldest shop with the same normalized
+// name and then creates the unique index on it. It runs before the server starts so the
+// index is never built over duplicates left from before names were compared this way.
+func (s *Shop) MergeDuplicateShopNames() error {
+	groups, err := s.Shop.GetDuplicateShopIDs()
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	for _, shopIDs := range groups {
+		for _, sourceShopID := range shopIDs[1:] {
+			if _, err := s.MergeDuplicateShops(shopIDs[0], sourceShopID); err != nil {
+				return utils.HandleError(err)
+			}
+		}
+	}
+
+	if err := s.Shop.CreateShopNameIndex(); err != nil {
+		return utils.HandleError(err)
+	}
 	return nil
 }
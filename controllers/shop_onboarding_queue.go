@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"EtsyScraper/models"
+)
+
+const (
+	OnboardingProfileJob = "profile"
+	OnboardingHistoryJob = "history"
+)
+
+// onboardingPriority lists the lanes from highest to lowest priority, a lane is
+// only served once every lane before it has nothing ready.
+var onboardingPriority = []string{OnboardingProfileJob, OnboardingHistoryJob}
+
+var OnboardingWorkers = 2
+
+// OnboardingClaimInterval is how often an instance renews its claims on the shop requests it
+// works on, OnboardingClaimTimeout how long a claim lasts without being renewed.
+var OnboardingClaimInterval = time.Minute
+var OnboardingClaimTimeout = 5 * time.Minute
+var DefaultOnboardingWeight = 1
+var OnboardingWeightBySubscription = map[string]int{
+	"basic":    1,
+	"premium":  2,
+	"business": 4,
+}
+
+var ErrOnboardingQueueClosed = errors.New("shop onboarding queue is not accepting new jobs")
+
+type OnboardingJob struct {
+	Kind        string
+	AccountID   uuid.UUID
+	Weight      int
+	ShopRequest *models.ShopRequest
+	Shop        *models.Shop
+	Task        *models.TaskSchedule
+	NotBefore   time.Time
+}
+
+type onboardingJobKey struct {
+	ShopRequestID uint
+	Kind          string
+}
+
+type onboardingAccount struct {
+	jobs []*OnboardingJob
+	pass float64
+}
+
+type onboardingLane struct {
+	accounts map[uuid.UUID]*onboardingAccount
+	vtime    float64
+}
+
+// OnboardingQueue hands out shop onboarding jobs by lane priority and, inside a
+// lane, shares the workers between accounts in proportion to their weight.
+type OnboardingQueue struct {
+	// InstanceID names this instance in the claims it holds on shop requests.
+	InstanceID string
+
+	mu       sync.Mutex
+	lanes    map[string]*onboardingLane
+	active   map[*OnboardingJob]*OnboardingJob
+	wake     chan struct{}
+	stop     chan struct{}
+	workers  sync.WaitGroup
+	draining bool
+	closed   bool
+}
+
+func NewOnboardingQueue() *OnboardingQueue {
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = "onboarding"
+	}
+
+	lanes := make(map[string]*onboardingLane)
+	for _, kind := range onboardingPriority {
+		lanes[kind] = &onboardingLane{accounts: make(map[uuid.UUID]*onboardingAccount)}
+	}
+	return &OnboardingQueue{
+		InstanceID: fmt.Sprintf("%s-%s", hostName, uuid.New().String()),
+		lanes:      lanes,
+		active:     make(map[*OnboardingJob]*OnboardingJob),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+}
+
+func (q *OnboardingQueue) Push(job *OnboardingJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrOnboardingQueueClosed
+	}
+
+	lane, ok := q.lanes[job.Kind]
+	if !ok {
+		return errors.New("unknown onboarding job kind: " + job.Kind)
+	}
+
+	account, ok := lane.accounts[job.AccountID]
+	if !ok {
+		account = &onboardingAccount{pass: lane.vtime}
+		lane.accounts[job.AccountID] = account
+	} else if len(account.jobs) == 0 && account.pass < lane.vtime {
+		account.pass = lane.vtime
+	}
+	account.jobs = append(account.jobs, job)
+
+	q.signal()
+	return nil
+}
+
+// Pop returns the next ready job. When nothing is ready it returns the time the
+// earliest delayed job becomes ready, or a zero time if the queue is empty.
+func (q *OnboardingQueue) Pop(now time.Time) (*OnboardingJob, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pop(now)
+}
+
+// pop must be called with q.mu held.
+func (q *OnboardingQueue) pop(now time.Time) (*OnboardingJob, time.Time) {
+	var earliest time.Time
+	for _, kind := range onboardingPriority {
+		lane := q.lanes[kind]
+
+		var chosen *onboardingAccount
+		var chosenID uuid.UUID
+		chosenIndex := -1
+
+		for accountID, account := range lane.accounts {
+			index := -1
+			for i, job := range account.jobs {
+				if !job.NotBefore.After(now) {
+					index = i
+					break
+				}
+				if earliest.IsZero() || job.NotBefore.Before(earliest) {
+					earliest = job.NotBefore
+				}
+			}
+			if index < 0 {
+				continue
+			}
+			if chosen == nil || account.pass < chosen.pass ||
+				(account.pass == chosen.pass && accountID.String() < chosenID.String()) {
+				chosen, chosenID, chosenIndex = account, accountID, index
+			}
+		}
+
+		if chosen == nil {
+			continue
+		}
+
+		job := chosen.jobs[chosenIndex]
+		chosen.jobs = append(chosen.jobs[:chosenIndex], chosen.jobs[chosenIndex+1:]...)
+
+		lane.vtime = chosen.pass
+		chosen.pass += 1 / float64(onboardingJobWeight(job))
+
+		q.signal()
+		return job, time.Time{}
+	}
+
+	return nil, earliest
+}
+
+func (q *OnboardingQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	total := 0
+	for _, lane := range q.lanes {
+		for _, account := range lane.accounts {
+			total += len(account.jobs)
+		}
+	}
+	return total
+}
+
+// Next blocks until a job is ready or the queue stops handing out work. The job
+// counts as active until Done is called for it.
+func (q *OnboardingQueue) Next() (*OnboardingJob, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed || q.draining {
+			q.mu.Unlock()
+			return nil, false
+		}
+		job, wakeAt := q.pop(time.Now())
+		if job != nil {
+			q.markActive(job)
+		}
+		q.mu.Unlock()
+
+		if job != nil {
+			return job, true
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !wakeAt.IsZero() {
+			timer = time.NewTimer(time.Until(wakeAt))
+			timeout = timer.C
+		}
+
+		select {
+		case <-q.wake:
+		case <-timeout:
+		case <-q.stop:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (q *OnboardingQueue) Done(job *OnboardingJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.active, job)
+}
+
+func (q *OnboardingQueue) Start(workers int, handle func(job *OnboardingJob)) {
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for {
+				job, ok := q.Next()
+				if !ok {
+					return
+				}
+				handle(job)
+				q.Done(job)
+			}
+		}()
+	}
+}
+
+func (q *OnboardingQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.stopWorkers()
+}
+
+// Shutdown stops handing out jobs and waits for the running ones until ctx is
+// done. Jobs pushed meanwhile, such as the next sold history slice, are still
+// accepted. It returns every job that did not finish: the queued ones and the
+// ones still running, the latter as they were when they started. A running job
+// that already queued its next slice is left out, the slice picks up after it
+// and resuming both would scrape and save the same pages twice.
+func (q *OnboardingQueue) Shutdown(ctx context.Context) []*OnboardingJob {
+	q.mu.Lock()
+	q.draining = true
+	q.stopWorkers()
+	q.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+
+	queued := []*OnboardingJob{}
+	continued := make(map[onboardingJobKey]bool)
+	for _, kind := range onboardingPriority {
+		lane := q.lanes[kind]
+		for _, account := range lane.accounts {
+			for _, job := range account.jobs {
+				if job.ShopRequest != nil {
+					continued[onboardingJobKey{ShopRequestID: job.ShopRequest.ID, Kind: job.Kind}] = true
+				}
+			}
+			queued = append(queued, account.jobs...)
+			account.jobs = nil
+		}
+	}
+
+	remaining := []*OnboardingJob{}
+	for _, snapshot := range q.active {
+		if snapshot.ShopRequest != nil && continued[onboardingJobKey{ShopRequestID: snapshot.ShopRequest.ID, Kind: snapshot.Kind}] {
+			continue
+		}
+		remaining = append(remaining, snapshot)
+	}
+	remaining = append(remaining, queued...)
+	q.active = make(map[*OnboardingJob]*OnboardingJob)
+
+	return remaining
+}
+
+// markActive must be called with q.mu held.
+func (q *OnboardingQueue) markActive(job *OnboardingJob) {
+	snapshot := *job
+	if job.Task != nil {
+		task := *job.Task
+		snapshot.Task = &task
+	}
+	q.active[job] = &snapshot
+}
+
+// stopWorkers must be called with q.mu held.
+func (q *OnboardingQueue) stopWorkers() {
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+	}
+}
+
+// signal must be called with q.mu held.
+func (q *OnboardingQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func onboardingJobWeight(job *OnboardingJob) int {
+	if job.Weight > 0 {
+		return job.Weight
+	}
+	return DefaultOnboardingWeight
+}
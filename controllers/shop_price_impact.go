@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// PriceImpactWindowDays is the longest stretch before and after a price change whose sales are
+// compared. A window also ends at the item's previous or next price change.
+var PriceImpactWindowDays = 30
+
+// MinPriceImpactWindowDays is how long both windows must be for a change to count as significant.
+var MinPriceImpactWindowDays = 7.0
+
+// PriceImpactSignificanceZ is the z-score a change in sales velocity needs to be significant,
+// 1.96 is a 5% chance that the change is noise.
+var PriceImpactSignificanceZ = 1.96
+
+var ErrListingNotFound = errors.New("listing not found")
+
+type SalesWindow struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Days      float64   `json:"days"`
+	UnitsSold int       `json:"units_sold"`
+	Velocity  float64   `json:"velocity"`
+}
+
+type PriceChangeImpact struct {
+	ChangedAt      time.Time   `json:"changed_at"`
+	OldPrice       float64     `json:"old_price"`
+	NewPrice       float64     `json:"new_price"`
+	PriceChange    float64     `json:"price_change"`
+	Before         SalesWindow `json:"before"`
+	After          SalesWindow `json:"after"`
+	VelocityChange *float64    `json:"velocity_change"`
+	Elasticity     *float64    `json:"elasticity"`
+	ZScore         float64     `json:"z_score"`
+	Significant    bool        `json:"significant"`
+}
+
+type ItemPriceImpact struct {
+	ItemID         uint                `json:"item_id"`
+	ListingID      uint                `json:"listing_id"`
+	Name           string              `json:"name"`
+	CurrencySymbol string              `json:"currency_symbol"`
+	Elasticity     *float64            `json:"elasticity"`
+	Changes        []PriceChangeImpact `json:"changes"`
+}
+
+type ShopPriceImpact struct {
+	PriceChanges       int               `json:"price_changes"`
+	SignificantChanges int               `json:"significant_changes"`
+	Elasticity         *float64          `json:"elasticity"`
+	Items              []ItemPriceImpact `json:"items"`
+}
+
+// ItemPriceChanges are the changes of the item's price history that changed its price, the
+// history also records availability and category changes.
+func ItemPriceChanges(item models.Item) []models.ItemHistoryChange {
+	Changes := []models.ItemHistoryChange{}
+	for _, change := range item.PriceHistory {
+		if change.NewItemCreated || change.OldPrice <= 0 || change.NewPrice <= 0 || change.OldPrice == change.NewPrice {
+			continue
+		}
+		Changes = append(Changes, change)
+	}
+	return Changes
+}
+
+// NewSalesWindow counts the units sold from From up to but not including To, leaving out the
+// units scraped from the selling history. The time the shop spent on vacation is not part of
+// the window's days since it could not sell then.
+func NewSalesWindow(SoldUnits []models.SoldItems, Periods []models.VacationPeriod, From, To time.Time) SalesWindow {
+	window := SalesWindow{From: From, To: To}
+	if !To.After(From) {
+		window.To = From
+		return window
+	}
+
+	for _, soldItem := range SoldUnits {
+		if !soldItem.FromHistory && !soldItem.CreatedAt.Before(From) && soldItem.CreatedAt.Before(To) {
+			window.UnitsSold++
+		}
+	}
+	days := To.Sub(From).Hours()/24 - VacationOverlapDays(Periods, From, To)
+	if days <= 0 {
+		return window
+	}
+	window.Days = utils.RoundToTwoDecimalDigits(days)
+	window.Velocity = utils.RoundToTwoDecimalDigits(float64(window.UnitsSold) / days)
+	return window
+}
+
+// PriceElasticity is the arc elasticity of the sales velocity to the price, the percent change
+// of the velocity for a one percent change of the price, both taken from their midpoint so a
+// window without sales still gives a value. It is nil when neither window sold anything.
+func PriceElasticity(OldPrice, NewPrice, VelocityBefore, VelocityAfter float64) *float64 {
+	if VelocityBefore+VelocityAfter == 0 || OldPrice+NewPrice == 0 || OldPrice == NewPrice {
+		return nil
+	}
+	velocityChange := (VelocityAfter - VelocityBefore) / ((VelocityAfter + VelocityBefore) / 2)
+	priceChange := (NewPrice - OldPrice) / ((NewPrice + OldPrice) / 2)
+	elasticity := utils.RoundToTwoDecimalDigits(velocityChange / priceChange)
+	return &elasticity
+}
+
+// VelocityZScore compares the sales velocity of two windows as the rates of two Poisson
+// processes, the difference of the rates over its standard error.
+func VelocityZScore(Before, After SalesWindow) float64 {
+	beforeDays, afterDays := Before.Days, After.Days
+	if beforeDays <= 0 || afterDays <= 0 {
+		return 0
+	}
+	before := float64(Before.UnitsSold) / beforeDays
+	after := float64(After.UnitsSold) / afterDays
+	variance := float64(Before.UnitsSold)/(beforeDays*beforeDays) + float64(After.UnitsSold)/(afterDays*afterDays)
+	if variance == 0 {
+		return 0
+	}
+	return utils.RoundToTwoDecimalDigits((after - before) / math.Sqrt(variance))
+}
+
+// AnalyzeItemPriceImpact compares the sales of the item before and after each of its price
+// changes. Sales were only recorded as they happened from TrackingStart on, so no window
+// starts before it.
+func AnalyzeItemPriceImpact(item models.Item, Periods []models.VacationPeriod, TrackingStart, now time.Time) ItemPriceImpact {
+	impact := ItemPriceImpact{
+		ItemID:         item.ID,
+		ListingID:      item.ListingID,
+		Name:           item.Name,
+		CurrencySymbol: item.CurrencySymbol,
+		Changes:        []PriceChangeImpact{},
+	}
+
+	Changes := ItemPriceChanges(item)
+	weightedElasticity, weights := 0.0, 0.0
+	for i, change := range Changes {
+		beforeFrom := change.CreatedAt.AddDate(0, 0, -PriceImpactWindowDays)
+		if i > 0 && Changes[i-1].CreatedAt.After(beforeFrom) {
+			beforeFrom = Changes[i-1].CreatedAt
+		}
+		if TrackingStart.After(beforeFrom) {
+			beforeFrom = TrackingStart
+		}
+
+		afterTo := change.CreatedAt.AddDate(0, 0, PriceImpactWindowDays)
+		if i < len(Changes)-1 && Changes[i+1].CreatedAt.Before(afterTo) {
+			afterTo = Changes[i+1].CreatedAt
+		}
+		if now.Before(afterTo) {
+			afterTo = now
+		}
+
+		changeImpact := PriceChangeImpact{
+			ChangedAt:   change.CreatedAt,
+			OldPrice:    change.OldPrice,
+			NewPrice:    change.NewPrice,
+			PriceChange: utils.RoundToTwoDecimalDigits((change.NewPrice - change.OldPrice) / change.OldPrice * 100),
+			Before:      NewSalesWindow(item.SoldUnits, Periods, beforeFrom, change.CreatedAt),
+			After:       NewSalesWindow(item.SoldUnits, Periods, change.CreatedAt, afterTo),
+		}
+
+		if changeImpact.Before.Velocity > 0 {
+			velocityChange := utils.RoundToTwoDecimalDigits((changeImpact.After.Velocity - changeImpact.Before.Velocity) / changeImpact.Before.Velocity * 100)
+			changeImpact.VelocityChange = &velocityChange
+		}
+		changeImpact.Elasticity = PriceElasticity(change.OldPrice, change.NewPrice, changeImpact.Before.Velocity, changeImpact.After.Velocity)
+		changeImpact.ZScore = VelocityZScore(changeImpact.Before, changeImpact.After)
+		changeImpact.Significant = changeImpact.Before.Days >= MinPriceImpactWindowDays &&
+			changeImpact.After.Days >= MinPriceImpactWindowDays &&
+			math.Abs(changeImpact.ZScore) >= PriceImpactSignificanceZ
+
+		if changeImpact.Elasticity != nil {
+			weight := float64(changeImpact.Before.UnitsSold + changeImpact.After.UnitsSold)
+			weightedElasticity += *changeImpact.Elasticity * weight
+			weights += weight
+		}
+		impact.Changes = append(impact.Changes, changeImpact)
+	}
+
+	if weights > 0 {
+		elasticity := utils.RoundToTwoDecimalDigits(weightedElasticity / weights)
+		impact.Elasticity = &elasticity
+	}
+	return impact
+}
+
+// AnalyzeShopPriceImpact reports the items whose price changed, the most recently changed
+// first. The shop's elasticity averages the elasticity of every change weighted by the units
+// sold around it, so changes measured on more sales count for more.
+func AnalyzeShopPriceImpact(Items []models.Item, Periods []models.VacationPeriod, TrackingStart, now time.Time) ShopPriceImpact {
+	report := ShopPriceImpact{Items: []ItemPriceImpact{}}
+
+	weightedElasticity, weights := 0.0, 0.0
+	for _, item := range Items {
+		impact := AnalyzeItemPriceImpact(item, Periods, TrackingStart, now)
+		if len(impact.Changes) == 0 {
+			continue
+		}
+
+		for _, change := range impact.Changes {
+			report.PriceChanges++
+			if change.Significant {
+				report.SignificantChanges++
+			}
+			if change.Elasticity != nil {
+				weight := float64(change.Before.UnitsSold + change.After.UnitsSold)
+				weightedElasticity += *change.Elasticity * weight
+				weights += weight
+			}
+		}
+		report.Items = append(report.Items, impact)
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		lastI := report.Items[i].Changes[len(report.Items[i].Changes)-1].ChangedAt
+		lastJ := report.Items[j].Changes[len(report.Items[j].Changes)-1].ChangedAt
+		return lastI.After(lastJ)
+	})
+
+	if weights > 0 {
+		elasticity := utils.RoundToTwoDecimalDigits(weightedElasticity / weights)
+		report.Elasticity = &elasticity
+	}
+	return report
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// PromotionBaselineDays is the longest stretch before a promotion whose sales it is compared to.
+var PromotionBaselineDays = 30
+
+// MinPromotionBaselineDays is how many days without a promotion the baseline needs for a sales lift.
+var MinPromotionBaselineDays = 7.0
+
+type PromotedItem struct {
+	ItemID                uint       `json:"item_id"`
+	ListingID             uint       `json:"listing_id"`
+	Name                  string     `json:"name"`
+	StartedAt             time.Time  `json:"started_at"`
+	EndedAt               *time.Time `json:"ended_at"`
+	StartedBeforeTracking bool       `json:"started_before_tracking"`
+	OriginalPrice         float64    `json:"original_price"`
+	SalePrice             float64    `json:"sale_price"`
+	DiscountPercent       float64    `json:"discount_percent"`
+}
+
+// PromotionPeriod is a stretch of time during which at least one of the shop's items was on
+// sale. The baseline and the lifts are null when the shop was not tracked long enough
+// without a promotion before it.
+type PromotionPeriod struct {
+	StartedAt                        time.Time      `json:"started_at"`
+	EndedAt                          *time.Time     `json:"ended_at"`
+	Days                             float64        `json:"days"`
+	StartedBeforeTracking            bool           `json:"started_before_tracking"`
+	ItemsCount                       int            `json:"items_count"`
+	AverageDiscountPercent           float64        `json:"average_discount_percent"`
+	MaxDiscountPercent               float64        `json:"max_discount_percent"`
+	Sales                            int            `json:"sales"`
+	Revenue                          float64        `json:"revenue"`
+	SalesPerDay                      float64        `json:"sales_per_day"`
+	BaselineSalesPerDay              *float64       `json:"baseline_sales_per_day"`
+	SalesLift                        *float64       `json:"sales_lift"`
+	PromotedItemsSales               int            `json:"promoted_items_sales"`
+	PromotedItemsSalesPerDay         float64        `json:"promoted_items_sales_per_day"`
+	PromotedItemsBaselineSalesPerDay *float64       `json:"promoted_items_baseline_sales_per_day"`
+	PromotedItemsSalesLift           *float64       `json:"promoted_items_sales_lift"`
+	Items                            []PromotedItem `json:"items"`
+}
+
+// PromotionSummary holds the averages of the promotions in the range, null when none could be measured.
+type PromotionSummary struct {
+	Promotions             int      `json:"promotions"`
+	OngoingPromotions      int      `json:"ongoing_promotions"`
+	PromotionDays          float64  `json:"promotion_days"`
+	AverageDiscountPercent *float64 `json:"average_discount_percent"`
+	AverageSalesLift       *float64 `json:"average_sales_lift"`
+}
+
+type PromotionReport struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Summary    PromotionSummary  `json:"summary"`
+	Promotions []PromotionPeriod `json:"promotions"`
+}
+
+// discountPercent is how much lower the sale price is than the original price, in percent.
+func discountPercent(OriginalPrice, SalePrice float64) float64 {
+	if OriginalPrice <= 0 || SalePrice <= 0 || SalePrice >= OriginalPrice {
+		return 0
+	}
+	return utils.RoundToTwoDecimalDigits((OriginalPrice - SalePrice) / OriginalPrice * 100)
+}
+
+// ItemPromotions rebuilds the periods the item was on sale from its sale price changes and its
+// price history, both ordered by creation time. An item is on sale while it is available with a
+// sale price, a period keeps the deepest discount it reached. An item already on sale when it
+// was first scraped, without a change creating it, started its sale before the shop was tracked.
+func ItemPromotions(item models.Item) []PromotedItem {
+	SaleChanges := []models.ItemAttributeChange{}
+	for _, change := range item.AttributeHistory {
+		if change.Attribute == models.ItemAttributeSalePrice {
+			SaleChanges = append(SaleChanges, change)
+		}
+	}
+
+	SalePrice := item.SalePrice
+	if len(SaleChanges) > 0 {
+		SalePrice = parseSalePrice(SaleChanges[0].OldValue)
+	}
+	available := item.Available
+	if len(item.PriceHistory) > 0 {
+		available = item.PriceHistory[0].OldAvailable || item.PriceHistory[0].NewItemCreated
+	}
+
+	promotions := []PromotedItem{}
+	var current *PromotedItem
+	update := func(At time.Time) {
+		if !available || SalePrice <= 0 {
+			if current != nil {
+				EndedAt := At
+				current.EndedAt = &EndedAt
+				promotions = append(promotions, *current)
+				current = nil
+			}
+			return
+		}
+
+		OriginalPrice := OriginalPriceAt(item, At)
+		discount := discountPercent(OriginalPrice, SalePrice)
+		if current == nil {
+			current = &PromotedItem{
+				ItemID:          item.ID,
+				ListingID:       item.ListingID,
+				Name:            item.Name,
+				StartedAt:       At,
+				OriginalPrice:   OriginalPrice,
+				SalePrice:       SalePrice,
+				DiscountPercent: discount,
+			}
+			return
+		}
+		if discount > current.DiscountPercent {
+			current.OriginalPrice = OriginalPrice
+			current.SalePrice = SalePrice
+			current.DiscountPercent = discount
+		}
+	}
+
+	update(item.CreatedAt)
+	if current != nil && (len(item.PriceHistory) == 0 || !item.PriceHistory[0].NewItemCreated) {
+		current.StartedBeforeTracking = true
+	}
+
+	i, j := 0, 0
+	for i < len(item.PriceHistory) || j < len(SaleChanges) {
+		if j == len(SaleChanges) || (i < len(item.PriceHistory) && !item.PriceHistory[i].CreatedAt.After(SaleChanges[j].CreatedAt)) {
+			change := item.PriceHistory[i]
+			available = change.NewAvailable
+			i++
+			update(change.CreatedAt)
+			continue
+		}
+		change := SaleChanges[j]
+		SalePrice = parseSalePrice(change.NewValue)
+		j++
+		update(change.CreatedAt)
+	}
+
+	if current != nil {
+		promotions = append(promotions, *current)
+	}
+	return promotions
+}
+
+// promotionEnd is when the promotion ended, now for an ongoing one.
+func promotionEnd(EndedAt *time.Time, now time.Time) time.Time {
+	if EndedAt == nil {
+		return now
+	}
+	return *EndedAt
+}
+
+// DetectPromotions merges the sale periods of the shop's items into the shop's promotions, a
+// promotion lasts from the first item going on sale until the last one of the overlapping
+// periods comes off sale. Promotions are ordered by start.
+func DetectPromotions(Items []models.Item, now time.Time) []PromotionPeriod {
+	ItemPeriods := []PromotedItem{}
+	for _, item := range Items {
+		ItemPeriods = append(ItemPeriods, ItemPromotions(item)...)
+	}
+	sort.SliceStable(ItemPeriods, func(i, j int) bool {
+		return ItemPeriods[i].StartedAt.Before(ItemPeriods[j].StartedAt)
+	})
+
+	promotions := []PromotionPeriod{}
+	ends, ongoing := []time.Time{}, []bool{}
+	for _, itemPeriod := range ItemPeriods {
+		itemEnd := promotionEnd(itemPeriod.EndedAt, now)
+		last := len(promotions) - 1
+		if last < 0 || itemPeriod.StartedAt.After(ends[last]) {
+			promotions = append(promotions, PromotionPeriod{StartedAt: itemPeriod.StartedAt, Items: []PromotedItem{}})
+			ends, ongoing = append(ends, itemEnd), append(ongoing, false)
+			last++
+		}
+
+		promotion := &promotions[last]
+		promotion.Items = append(promotion.Items, itemPeriod)
+		promotion.StartedBeforeTracking = promotion.StartedBeforeTracking || itemPeriod.StartedBeforeTracking
+		if itemEnd.After(ends[last]) {
+			ends[last] = itemEnd
+		}
+		ongoing[last] = ongoing[last] || itemPeriod.EndedAt == nil
+	}
+
+	for i := range promotions {
+		promotion := &promotions[i]
+		if !ongoing[i] {
+			promotion.EndedAt = &ends[i]
+		}
+		promotion.ItemsCount = len(promotion.Items)
+		promotion.Days = utils.RoundToTwoDecimalDigits(promotionEnd(promotion.EndedAt, now).Sub(promotion.StartedAt).Hours() / 24)
+
+		discounts := []float64{}
+		for _, item := range promotion.Items {
+			discounts = append(discounts, item.DiscountPercent)
+			if item.DiscountPercent > promotion.MaxDiscountPercent {
+				promotion.MaxDiscountPercent = item.DiscountPercent
+			}
+		}
+		promotion.AverageDiscountPercent = utils.RoundToTwoDecimalDigits(mean(discounts))
+	}
+	return promotions
+}
+
+// overlapDays is how many days of the stretch from From to To fall inside one of the promotions.
+func overlapDays(promotions []PromotionPeriod, From, To, now time.Time) float64 {
+	days := 0.0
+	for _, promotion := range promotions {
+		start, end := promotion.StartedAt, promotionEnd(promotion.EndedAt, now)
+		if start.Before(From) {
+			start = From
+		}
+		if end.After(To) {
+			end = To
+		}
+		if end.After(start) {
+			days += end.Sub(start).Hours() / 24
+		}
+	}
+	return days
+}
+
+// countOutsidePromotions counts the times that fall outside all of the promotions. The times
+// are sorted and walked along the promotions in one pass, DetectPromotions orders those by
+// start and merges the ones that overlap.
+func countOutsidePromotions(Times []time.Time, promotions []PromotionPeriod, now time.Time) int {
+	sort.Slice(Times, func(i, j int) bool {
+		return Times[i].Before(Times[j])
+	})
+
+	count, next := 0, 0
+	for _, At := range Times {
+		for next < len(promotions) && !At.Before(promotionEnd(promotions[next].EndedAt, now)) {
+			next++
+		}
+		if next == len(promotions) || At.Before(promotions[next].StartedAt) {
+			count++
+		}
+	}
+	return count
+}
+
+// measurePromotion counts the sales during the promotion and compares their rate to the rate
+// of its baseline, the PromotionBaselineDays before it left of the time other promotions ran.
+// Sales were only recorded as they happened from TrackingStart on, so neither stretch starts
+// before it, and units scraped from the selling history are left out.
+func measurePromotion(promotion *PromotionPeriod, promotions []PromotionPeriod, Items []models.Item, TrackingStart, now time.Time, AverageItemPrice float64) {
+	PromotedIDs := make(map[uint]bool)
+	for _, item := range promotion.Items {
+		PromotedIDs[item.ItemID] = true
+	}
+
+	from, to := promotion.StartedAt, promotionEnd(promotion.EndedAt, now)
+	if from.Before(TrackingStart) {
+		from = TrackingStart
+	}
+	baselineFrom := promotion.StartedAt.AddDate(0, 0, -PromotionBaselineDays)
+	if baselineFrom.Before(TrackingStart) {
+		baselineFrom = TrackingStart
+	}
+
+	BaselineTimes, PromotedBaselineTimes := []time.Time{}, []time.Time{}
+	for _, item := range Items {
+		for _, soldItem := range item.SoldUnits {
+			if soldItem.FromHistory {
+				continue
+			}
+			if !soldItem.CreatedAt.Before(from) && soldItem.CreatedAt.Before(to) {
+				promotion.Sales++
+				promotion.Revenue += SoldItemPrice(item, soldItem.CreatedAt, AverageItemPrice)
+				if PromotedIDs[item.ID] {
+					promotion.PromotedItemsSales++
+				}
+			}
+			if !soldItem.CreatedAt.Before(baselineFrom) && soldItem.CreatedAt.Before(promotion.StartedAt) {
+				BaselineTimes = append(BaselineTimes, soldItem.CreatedAt)
+				if PromotedIDs[item.ID] {
+					PromotedBaselineTimes = append(PromotedBaselineTimes, soldItem.CreatedAt)
+				}
+			}
+		}
+	}
+	baselineSales := countOutsidePromotions(BaselineTimes, promotions, now)
+	promotedBaselineSales := countOutsidePromotions(PromotedBaselineTimes, promotions, now)
+	promotion.Revenue = utils.RoundToTwoDecimalDigits(promotion.Revenue)
+
+	days := to.Sub(from).Hours() / 24
+	if days <= 0 {
+		return
+	}
+	promotion.SalesPerDay = utils.RoundToTwoDecimalDigits(float64(promotion.Sales) / days)
+	promotion.PromotedItemsSalesPerDay = utils.RoundToTwoDecimalDigits(float64(promotion.PromotedItemsSales) / days)
+
+	baselineDays := 0.0
+	if promotion.StartedAt.After(baselineFrom) {
+		baselineDays = promotion.StartedAt.Sub(baselineFrom).Hours()/24 - overlapDays(promotions, baselineFrom, promotion.StartedAt, now)
+	}
+	if baselineDays < MinPromotionBaselineDays {
+		return
+	}
+	baseline := utils.RoundToTwoDecimalDigits(float64(baselineSales) / baselineDays)
+	promotedBaseline := utils.RoundToTwoDecimalDigits(float64(promotedBaselineSales) / baselineDays)
+	promotion.BaselineSalesPerDay = &baseline
+	promotion.PromotedItemsBaselineSalesPerDay = &promotedBaseline
+	promotion.SalesLift = percentChange(baseline, promotion.SalesPerDay)
+	promotion.PromotedItemsSalesLift = percentChange(promotedBaseline, promotion.PromotedItemsSalesPerDay)
+}
+
+// promotionRangeEnd is the end of the range, now while the range has not ended yet.
+func promotionRangeEnd(statsRange StatsRange, now time.Time) time.Time {
+	rangeEnd := statsRange.To.AddDate(0, 0, 1)
+	if rangeEnd.After(now) {
+		return now
+	}
+	return rangeEnd
+}
+
+// promotionInRange tells whether some of the promotion ran during the range.
+func promotionInRange(promotion PromotionPeriod, statsRange StatsRange, now time.Time) bool {
+	return promotion.StartedAt.Before(promotionRangeEnd(statsRange, now)) && promotionEnd(promotion.EndedAt, now).After(statsRange.From)
+}
+
+// PromotionSalesFrom is the earliest sale CreatePromotionReport looks at, the start of the
+// baseline of the first promotion that ran during the range.
+func PromotionSalesFrom(Items []models.Item, statsRange StatsRange, now time.Time) time.Time {
+	From := statsRange.From
+	for _, promotion := range DetectPromotions(Items, now) {
+		if !promotionInRange(promotion, statsRange, now) {
+			continue
+		}
+		if baselineFrom := promotion.StartedAt.AddDate(0, 0, -PromotionBaselineDays); baselineFrom.Before(From) {
+			From = baselineFrom
+		}
+	}
+	return From
+}
+
+// CreatePromotionReport lists the shop's promotions that ran during the range with the sales
+// lift each one brought over its baseline. The summary's promotion days are the days of the
+// range some promotion ran.
+func CreatePromotionReport(Items []models.Item, TrackingStart time.Time, statsRange StatsRange, now time.Time, AverageItemPrice float64) PromotionReport {
+	report := PromotionReport{
+		From:       statsRange.From.Format("2006-01-02"),
+		To:         statsRange.To.Format("2006-01-02"),
+		Promotions: []PromotionPeriod{},
+	}
+	rangeEnd := promotionRangeEnd(statsRange, now)
+
+	promotions := DetectPromotions(Items, now)
+	discounts, lifts := []float64{}, []float64{}
+	for _, promotion := range promotions {
+		if !promotionInRange(promotion, statsRange, now) {
+			continue
+		}
+		measurePromotion(&promotion, promotions, Items, TrackingStart, now, AverageItemPrice)
+		report.Promotions = append(report.Promotions, promotion)
+
+		if promotion.EndedAt == nil {
+			report.Summary.OngoingPromotions++
+		}
+		for _, item := range promotion.Items {
+			discounts = append(discounts, item.DiscountPercent)
+		}
+		if promotion.SalesLift != nil {
+			lifts = append(lifts, *promotion.SalesLift)
+		}
+	}
+
+	report.Summary.Promotions = len(report.Promotions)
+	report.Summary.PromotionDays = utils.RoundToTwoDecimalDigits(overlapDays(promotions, statsRange.From, rangeEnd, now))
+	if len(discounts) > 0 {
+		average := utils.RoundToTwoDecimalDigits(mean(discounts))
+		report.Summary.AverageDiscountPercent = &average
+	}
+	if len(lifts) > 0 {
+		average := utils.RoundToTwoDecimalDigits(mean(lifts))
+		report.Summary.AverageSalesLift = &average
+	}
+	return report
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// MaxStatsRangeDays is the longest custom date range stats can be asked for.
+var MaxStatsRangeDays = 731
+
+var ErrInvalidDateRange = errors.New("from and to must be dates formatted as YYYY-MM-DD and from can not be after to")
+var ErrStatsRangeTooLong = errors.New("date range can not be longer than 731 days")
+var ErrInvalidGranularity = errors.New("granularity must be day, week or month")
+var ErrInvalidCompare = errors.New("compare must be true or false")
+
+// StatsRange is the days from From to To, both midnight and both included.
+type StatsRange struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+type StatsBucket struct {
+	Date         string        `json:"date"`
+	EndDate      string        `json:"end_date"`
+	TotalSales   int           `json:"total_sales"`
+	Sales        int           `json:"sales"`
+	SoldUnits    int           `json:"sold_units"`
+	Revenue      float64       `json:"revenue"`
+	Estimated    bool          `json:"estimated"`
+	ShopRating   float64       `json:"shop_rating,omitempty"`
+	RatingChange float64       `json:"rating_change"`
+	ReviewsCount int           `json:"reviews_count,omitempty"`
+	NewReviews   int           `json:"new_reviews"`
+	VacationDays int           `json:"vacation_days"`
+	Items        []models.Item `json:"items"`
+}
+
+type StatsPeriod struct {
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	Sales         int           `json:"sales"`
+	Revenue       float64       `json:"revenue"`
+	NewReviews    int           `json:"new_reviews"`
+	SalesVelocity float64       `json:"sales_velocity"`
+	Stats         []StatsBucket `json:"stats"`
+}
+
+// StatsComparison holds the percent changes from the previous period, null when the previous
+// period had none.
+type StatsComparison struct {
+	SalesChange         *float64 `json:"sales_change"`
+	RevenueChange       *float64 `json:"revenue_change"`
+	NewReviewsChange    *float64 `json:"new_reviews_change"`
+	SalesVelocityChange *float64 `json:"sales_velocity_change"`
+}
+
+type StatsReport struct {
+	Granularity string `json:"granularity"`
+	StatsPeriod
+	Previous   *StatsPeriod     `json:"previous,omitempty"`
+	Comparison *StatsComparison `json:"comparison,omitempty"`
+}
+
+// ParseStatsRange reads the range stats are asked for, either one of the periods PeriodOffset
+// knows ending today or a custom range from From to To. To defaults to today and is cut at
+// today, days are taken in now's location.
+func ParseStatsRange(Period, From, To, Granularity string, now time.Time) (StatsRange, error) {
+	loc := now.Location()
+	today := utils.TruncateDateInLocation(now, loc)
+
+	if Granularity != GranularityDay && Granularity != GranularityWeek && Granularity != GranularityMonth {
+		return StatsRange{}, ErrInvalidGranularity
+	}
+	statsRange := StatsRange{To: today, Granularity: Granularity}
+
+	if Period != "" {
+		years, months, days, err := PeriodOffset(Period)
+		if err != nil {
+			return StatsRange{}, err
+		}
+		statsRange.From = utils.TruncateDateInLocation(now.AddDate(years, months, days), loc)
+		return statsRange, nil
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", From, loc)
+	if err != nil {
+		return StatsRange{}, ErrInvalidDateRange
+	}
+	statsRange.From = from
+
+	if To != "" {
+		to, err := time.ParseInLocation("2006-01-02", To, loc)
+		if err != nil {
+			return StatsRange{}, ErrInvalidDateRange
+		}
+		if to.Before(today) {
+			statsRange.To = to
+		}
+	}
+
+	if statsRange.From.After(statsRange.To) {
+		return StatsRange{}, ErrInvalidDateRange
+	}
+	if statsRange.Days() > MaxStatsRangeDays {
+		return StatsRange{}, ErrStatsRangeTooLong
+	}
+	return statsRange, nil
+}
+
+// ParseCompare reads the compare query parameter, false when it is empty.
+func ParseCompare(Compare string) (bool, error) {
+	if Compare == "" {
+		return false, nil
+	}
+	compare, err := strconv.ParseBool(Compare)
+	if err != nil {
+		return false, ErrInvalidCompare
+	}
+	return compare, nil
+}
+
+// Days is the number of days in the range. It is rounded since a day across a daylight saving
+// change is not 24 hours long.
+func (r StatsRange) Days() int {
+	return int(math.Round(r.To.Sub(r.From).Hours()/24)) + 1
+}
+
+// Previous is the range of the same number of days right before r.
+func (r StatsRange) Previous() StatsRange {
+	return StatsRange{From: r.From.AddDate(0, 0, -r.Days()), To: r.From.AddDate(0, 0, -1), Granularity: r.Granularity}
+}
+
+// bucketBounds returns the first and last day of the bucket a day falls in, weeks start on
+// Monday. Buckets at the edges of the range are cut to it.
+func (r StatsRange) bucketBounds(day time.Time) (time.Time, time.Time) {
+	start, end := day, day
+	switch r.Granularity {
+	case GranularityWeek:
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		end = start.AddDate(0, 0, 6)
+	case GranularityMonth:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(0, 1, -1)
+	}
+	if start.Before(r.From) {
+		start = r.From
+	}
+	if end.After(r.To) {
+		end = r.To
+	}
+	return start, end
+}
+
+// CreateStatsPeriod groups the daily stats of the range into buckets, leaving out buckets
+// without any stats. A bucket's sales are the change of the total sales since the last day
+// before it with a snapshot, which may be the day before the range.
+func CreateStatsPeriod(stats map[string]DailySoldStats, statsRange StatsRange) StatsPeriod {
+	period := StatsPeriod{
+		From:  statsRange.From.Format("2006-01-02"),
+		To:    statsRange.To.Format("2006-01-02"),
+		Stats: []StatsBucket{},
+	}
+
+	dates := make([]string, 0, len(stats))
+	for date := range stats {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	lastTotal := 0
+	rangeStats := make(map[string]DailySoldStats)
+	for _, date := range dates {
+		if date < period.From && stats[date].TotalSales > 0 {
+			lastTotal = stats[date].TotalSales
+			rangeStats = map[string]DailySoldStats{date: stats[date]}
+		}
+	}
+
+	var bucket *StatsBucket
+	for day := statsRange.From; !day.After(statsRange.To); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		dayStats, ok := stats[date]
+		if !ok {
+			continue
+		}
+		rangeStats[date] = dayStats
+
+		start, end := statsRange.bucketBounds(day)
+		if bucket == nil || bucket.Date != start.Format("2006-01-02") {
+			period.Stats = append(period.Stats, StatsBucket{
+				Date:    start.Format("2006-01-02"),
+				EndDate: end.Format("2006-01-02"),
+				Items:   []models.Item{},
+			})
+			bucket = &period.Stats[len(period.Stats)-1]
+		}
+
+		if dayStats.TotalSales > 0 {
+			if sold := dayStats.TotalSales - lastTotal; lastTotal > 0 && sold > 0 {
+				bucket.Sales += sold
+			}
+			lastTotal = dayStats.TotalSales
+			bucket.TotalSales = dayStats.TotalSales
+		}
+		bucket.SoldUnits += dayStats.SoldUnits
+		bucket.Revenue = utils.RoundToTwoDecimalDigits(bucket.Revenue + dayStats.DailyRevenue)
+		bucket.Estimated = bucket.Estimated || dayStats.Estimated
+		if dayStats.ShopRating > 0 {
+			bucket.ShopRating = dayStats.ShopRating
+			bucket.ReviewsCount = dayStats.ReviewsCount
+		}
+		bucket.RatingChange = utils.RoundToTwoDecimalDigits(bucket.RatingChange + dayStats.RatingChange)
+		bucket.NewReviews += dayStats.NewReviews
+		if dayStats.OnVacation {
+			bucket.VacationDays++
+		}
+		bucket.Items = append(bucket.Items, dayStats.Items...)
+	}
+
+	for _, bucket := range period.Stats {
+		period.Sales += bucket.Sales
+		period.Revenue += bucket.Revenue
+		period.NewReviews += bucket.NewReviews
+	}
+	period.Revenue = utils.RoundToTwoDecimalDigits(period.Revenue)
+	period.SalesVelocity = CalculateSalesVelocity(rangeStats)
+
+	return period
+}
+
+// CreateStatsReport builds the stats of the range and, when compare is set, of the previous
+// range of the same length. stats must reach back to the day before the first range.
+func CreateStatsReport(stats map[string]DailySoldStats, statsRange StatsRange, compare bool) StatsReport {
+	report := StatsReport{Granularity: statsRange.Granularity, StatsPeriod: CreateStatsPeriod(stats, statsRange)}
+	if !compare {
+		return report
+	}
+
+	previous := CreateStatsPeriod(stats, statsRange.Previous())
+	report.Previous = &previous
+	report.Comparison = &StatsComparison{
+		SalesChange:         percentChange(float64(previous.Sales), float64(report.Sales)),
+		RevenueChange:       percentChange(previous.Revenue, report.Revenue),
+		NewReviewsChange:    percentChange(float64(previous.NewReviews), float64(report.NewReviews)),
+		SalesVelocityChange: percentChange(previous.SalesVelocity, report.SalesVelocity),
+	}
+	return report
+}
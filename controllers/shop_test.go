@@ -2,10 +2,13 @@ package controllers_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,9 +16,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 
 	"EtsyScraper/controllers"
 	"EtsyScraper/models"
+	"EtsyScraper/repository"
 	scrap "EtsyScraper/scraping"
 	setupMockServer "EtsyScraper/setupTests"
 )
@@ -39,7 +44,7 @@ func (m *MockedShop) CreateNewShop(ShopRequest *models.ShopRequest) error {
 	args := m.Called()
 	return args.Error(0)
 }
-func (m *MockedShop) CreateSoldStats(dailyShopSales []models.DailyShopSales) (map[string]controllers.DailySoldStats, error) {
+func (m *MockedShop) CreateSoldStats(dailyShopSales []models.DailyShopSales, loc *time.Location) (map[string]controllers.DailySoldStats, error) {
 	args := m.Called()
 
 	return args.Get(0).(map[string]controllers.DailySoldStats), args.Error(1)
@@ -106,6 +111,10 @@ func (m *MockedShop) UpdateSellingHistory(Shop *models.Shop, Task *models.TaskSc
 	args := m.Called()
 	return args.Error(0)
 }
+func (m *MockedShop) RecordDailyRevenue(ShopID uint, Items []models.Item) error {
+	args := m.Called()
+	return args.Error(0)
+}
 func (m *MockedShop) EstablishAccountShopRelation(requestedShop *models.Shop, userID uuid.UUID) error {
 	args := m.Called()
 	return args.Error(0)
@@ -119,14 +128,39 @@ func (m *MockedShop) UpdateDiscontinuedItems(Shop *models.Shop, Task *models.Tas
 	}
 	return soldItems, args.Error(1)
 }
-func (m *MockedShop) GetItemsBySoldItems(SoldItems []models.SoldItems) ([]models.Item, error) {
+
+func (m *MockedShop) EnqueueShopRefresh(ShopID uint, AccountID uuid.UUID, FullRefresh bool) (*models.ShopRefreshJob, bool, error) {
 	args := m.Called()
-	shopInterface := args.Get(0)
-	var Items []models.Item
-	if shopInterface != nil {
-		Items = shopInterface.([]models.Item)
+	jobInterface := args.Get(0)
+	var job *models.ShopRefreshJob
+	if jobInterface != nil {
+		job = jobInterface.(*models.ShopRefreshJob)
 	}
-	return Items, args.Error(1)
+	return job, args.Bool(1), args.Error(2)
+}
+func (m *MockedShop) RunShopRefresh(job *models.ShopRefreshJob) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockedShop) EnqueueShopOnboarding(ShopRequest *models.ShopRequest) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockedShop) EnqueueSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest, delay time.Duration) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockedShop) MergeDuplicateShops(TargetShopID, SourceShopID uint) (*repository.ShopMerge, error) {
+	args := m.Called()
+	mergeInterface := args.Get(0)
+	var merge *repository.ShopMerge
+	if mergeInterface != nil {
+		merge = mergeInterface.(*repository.ShopMerge)
+	}
+	return merge, args.Error(1)
 }
 
 type MockScrapper struct {
@@ -218,13 +252,13 @@ func (sr *MockedShopRepository) CreateNewItem(item models.Item) (models.Item, er
 	}
 	return Item, args.Error(1)
 }
-func (sr *MockedShopRepository) SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems) error {
+func (sr *MockedShopRepository) SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems, ShopID uint) error {
 	args := sr.Called()
 	return args.Error(0)
 
 }
 func (sr *MockedShopRepository) UpdateDailySales(ScrappedSoldItems []models.SoldItems, ShopID uint, dailyRevenue float64) error {
-	args := sr.Called()
+	args := sr.Called(dailyRevenue)
 	return args.Error(0)
 }
 func (sr *MockedShopRepository) CreateMenu(Menus models.MenuItem) (models.MenuItem, error) {
@@ -239,7 +273,7 @@ func (sr *MockedShopRepository) UpdateColumnsInShop(Shop models.Shop, updateData
 	args := sr.Called()
 	return args.Error(0)
 }
-func (sr *MockedShopRepository) CreateDailySales(ShopID uint, TotalSales, Admirers int) error {
+func (sr *MockedShopRepository) CreateDailySales(ShopID uint, TotalSales, Admirers int, FencingToken int64) error {
 	args := sr.Called()
 	return args.Error(0)
 }
@@ -270,23 +304,31 @@ func (sr *MockedShopRepository) FetchSoldItemsByListingID(listingIDs []uint) ([]
 	}
 	return SoldItems, args.Error(1)
 }
-func (sr *MockedShopRepository) FetchItemsBySoldItems(soldItemID uint) (models.Item, error) {
+func (sr *MockedShopRepository) GetShopRollupsByPeriod(ShopID uint, From time.Time) ([]models.ShopDailyRollup, error) {
 	args := sr.Called()
 	shopInterface := args.Get(0)
-	var Item models.Item
+	var Rollups []models.ShopDailyRollup
 	if shopInterface != nil {
-		Item = shopInterface.(models.Item)
+		Rollups = shopInterface.([]models.ShopDailyRollup)
 	}
-	return Item, args.Error(1)
+	return Rollups, args.Error(1)
 }
-func (sr *MockedShopRepository) GetSoldItemsInRange(fromDate time.Time, ShopID uint) ([]models.SoldItems, error) {
+func (sr *MockedShopRepository) GetItemRollupsByPeriod(ShopID uint, From time.Time) ([]models.ItemDailyRollup, error) {
 	args := sr.Called()
 	shopInterface := args.Get(0)
-	var SoldItems []models.SoldItems
+	var Rollups []models.ItemDailyRollup
 	if shopInterface != nil {
-		SoldItems = shopInterface.([]models.SoldItems)
+		Rollups = shopInterface.([]models.ItemDailyRollup)
 	}
-	return SoldItems, args.Error(1)
+	return Rollups, args.Error(1)
+}
+func (sr *MockedShopRepository) RebuildSalesRollups(ShopID uint) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) TagSellingHistory(ShopID uint) (int64, error) {
+	args := sr.Called()
+	return args.Get(0).(int64), args.Error(1)
 }
 func (sr *MockedShopRepository) UpdateAccountShopRelation(requestedShop *models.Shop, UserID uuid.UUID) error {
 	args := sr.Called()
@@ -320,6 +362,337 @@ func (sr *MockedShopRepository) GetShopByName(ShopName string) (shop *models.Sho
 	return shop, args.Error(1)
 }
 
+func (sr *MockedShopRepository) CreateRefreshJob(job *models.ShopRefreshJob) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) SaveRefreshJob(job *models.ShopRefreshJob) error {
+	args := sr.Called(job.Status)
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) GetLatestRefreshJob(ShopID uint) (*models.ShopRefreshJob, error) {
+	args := sr.Called()
+	jobInterface := args.Get(0)
+	var job *models.ShopRefreshJob
+	if jobInterface != nil {
+		job = jobInterface.(*models.ShopRefreshJob)
+	}
+	return job, args.Error(1)
+}
+func (sr *MockedShopRepository) GetRefreshJobByJobID(JobID uuid.UUID) (*models.ShopRefreshJob, error) {
+	args := sr.Called()
+	jobInterface := args.Get(0)
+	var job *models.ShopRefreshJob
+	if jobInterface != nil {
+		job = jobInterface.(*models.ShopRefreshJob)
+	}
+	return job, args.Error(1)
+}
+func (sr *MockedShopRepository) RenewRefreshJobClaims(InstanceID string) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) FailStaleRefreshJobs(before, claimedBefore time.Time, reason string) (int64, error) {
+	args := sr.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+func (sr *MockedShopRepository) CountRefreshJobsSince(AccountID uuid.UUID, since time.Time) (int64, error) {
+	args := sr.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetDailySalesByShopID(ShopID uint) ([]models.DailyShopSales, error) {
+	args := sr.Called()
+	salesInterface := args.Get(0)
+	var Sales []models.DailyShopSales
+	if salesInterface != nil {
+		Sales = salesInterface.([]models.DailyShopSales)
+	}
+	return Sales, args.Error(1)
+}
+func (sr *MockedShopRepository) SaveDailySales(dailySales *models.DailyShopSales) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetShopRequestByID(ID uint) (*models.ShopRequest, error) {
+	args := sr.Called()
+	requestInterface := args.Get(0)
+	var ShopRequest *models.ShopRequest
+	if requestInterface != nil {
+		ShopRequest = requestInterface.(*models.ShopRequest)
+	}
+	return ShopRequest, args.Error(1)
+}
+func (sr *MockedShopRepository) GetShopRequestsByStatus(Status string) ([]models.ShopRequest, error) {
+	args := sr.Called()
+	requestsInterface := args.Get(0)
+	var ShopRequests []models.ShopRequest
+	if requestsInterface != nil {
+		ShopRequests = requestsInterface.([]models.ShopRequest)
+	}
+	return ShopRequests, args.Error(1)
+}
+func (sr *MockedShopRepository) SaveScrapeCheckpoints(checkpoints []models.ScrapeCheckpoint) error {
+	args := sr.Called(checkpoints)
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) GetScrapeCheckpoints() ([]models.ScrapeCheckpoint, error) {
+	args := sr.Called()
+	checkpointsInterface := args.Get(0)
+	var checkpoints []models.ScrapeCheckpoint
+	if checkpointsInterface != nil {
+		checkpoints = checkpointsInterface.([]models.ScrapeCheckpoint)
+	}
+	return checkpoints, args.Error(1)
+}
+func (sr *MockedShopRepository) ClaimShopRequest(ID uint, InstanceID string, staleBefore time.Time) (bool, error) {
+	args := sr.Called(ID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (sr *MockedShopRepository) RenewShopRequestClaims(InstanceID string) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) ReleaseShopRequestClaims(InstanceID string) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) DeleteScrapeCheckpoint(ID uint) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetShopWithSoldItemsByShopID(ID uint) (*models.Shop, error) {
+	args := sr.Called(ID)
+	shopInterface := args.Get(0)
+	var shop *models.Shop
+	if shopInterface != nil {
+		shop = shopInterface.(*models.Shop)
+	}
+	return shop, args.Error(1)
+}
+func (sr *MockedShopRepository) MergeShops(merge *repository.ShopMerge) error {
+	args := sr.Called(merge)
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) LockShop(ShopID uint, Owner string, staleBefore time.Time) (bool, error) {
+	args := sr.Called(ShopID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (sr *MockedShopRepository) UnlockShop(ShopID uint, Owner string) error {
+	args := sr.Called(ShopID)
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetShopSalesByID(ID uint) (*models.Shop, error) {
+	args := sr.Called()
+	shopInterface := args.Get(0)
+	var shop *models.Shop
+	if shopInterface != nil {
+		shop = shopInterface.(*models.Shop)
+	}
+	return shop, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetDuplicateShopIDs() ([][]uint, error) {
+	args := sr.Called()
+	return args.Get(0).([][]uint), args.Error(1)
+}
+
+func (sr *MockedShopRepository) CreateShopNameIndex() error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetItemsWithHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	args := sr.Called()
+	itemsInterface := args.Get(0)
+	var items []models.Item
+	if itemsInterface != nil {
+		items = itemsInterface.([]models.Item)
+	}
+	return items, args.Error(1)
+}
+
+func (sr *MockedShopRepository) UpdateMenu(Menu models.MenuItem, changes map[string]interface{}) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) DeleteMenu(MenuID uint) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) CreateMenuHistoryChange(Change models.MenuHistoryChange) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) GetMenuHistoryByShopID(ShopID uint) ([]models.MenuHistoryChange, error) {
+	args := sr.Called()
+	changesInterface := args.Get(0)
+	var changes []models.MenuHistoryChange
+	if changesInterface != nil {
+		changes = changesInterface.([]models.MenuHistoryChange)
+	}
+	return changes, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetShopProfileByID(ID uint) (*models.Shop, error) {
+	args := sr.Called()
+	shopInterface := args.Get(0)
+	var shop *models.Shop
+	if shopInterface != nil {
+		shop = shopInterface.(*models.Shop)
+	}
+	return shop, args.Error(1)
+}
+func (sr *MockedShopRepository) UpdateShopProfile(Shop *models.Shop, Changes []models.ShopHistoryChange) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) GetShopHistoryByShopID(ShopID uint) ([]models.ShopHistoryChange, error) {
+	args := sr.Called()
+	changesInterface := args.Get(0)
+	var changes []models.ShopHistoryChange
+	if changesInterface != nil {
+		changes = changesInterface.([]models.ShopHistoryChange)
+	}
+	return changes, args.Error(1)
+}
+
+func (sr *MockedShopRepository) CreateDailyReviews(DailyReviews *models.DailyShopReviews) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) FetchReviewsStatsByPeriod(ShopID uint, timePeriod time.Time) ([]models.DailyShopReviews, error) {
+	args := sr.Called()
+	reviewsInterface := args.Get(0)
+	var dailyReviews []models.DailyShopReviews
+	if reviewsInterface != nil {
+		dailyReviews = reviewsInterface.([]models.DailyShopReviews)
+	}
+	return dailyReviews, args.Error(1)
+}
+
+func (sr *MockedShopRepository) RecordVacationChange(Change repository.VacationChange) (bool, error) {
+	args := sr.Called()
+	return args.Bool(0), args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetVacationPeriodsByShopID(ShopID uint) ([]models.VacationPeriod, error) {
+	args := sr.Called()
+	periodsInterface := args.Get(0)
+	var Periods []models.VacationPeriod
+	if periodsInterface != nil {
+		Periods = periodsInterface.([]models.VacationPeriod)
+	}
+	return Periods, args.Error(1)
+}
+func (sr *MockedShopRepository) CreateShopNotifications(ShopID uint, Kind, Message string) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) CreateItemAttributeChanges(Changes []models.ItemAttributeChange) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+func (sr *MockedShopRepository) GetItemsWithAttributeHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	args := sr.Called()
+	itemsInterface := args.Get(0)
+	var items []models.Item
+	if itemsInterface != nil {
+		items = itemsInterface.([]models.Item)
+	}
+	return items, args.Error(1)
+}
+
+func (sr *MockedShopRepository) CreateListingPositions(Positions []models.ListingPosition) error {
+	args := sr.Called()
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetListingPositions(ShopID, ListingID uint) ([]models.ListingPosition, error) {
+	args := sr.Called()
+	positionsInterface := args.Get(0)
+	var positions []models.ListingPosition
+	if positionsInterface != nil {
+		positions = positionsInterface.([]models.ListingPosition)
+	}
+	return positions, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetItemSalesByPeriod(ShopID uint, From, To time.Time) ([]repository.ItemSales, error) {
+	args := sr.Called()
+	salesInterface := args.Get(0)
+	var sales []repository.ItemSales
+	if salesInterface != nil {
+		sales = salesInterface.([]repository.ItemSales)
+	}
+	return sales, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetItemsWithPriceHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	args := sr.Called()
+	itemsInterface := args.Get(0)
+	var items []models.Item
+	if itemsInterface != nil {
+		items = itemsInterface.([]models.Item)
+	}
+	return items, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetItemPriceHistoryByIDs(ItemIDs []uint) ([]models.Item, error) {
+	args := sr.Called()
+	itemsInterface := args.Get(0)
+	var items []models.Item
+	if itemsInterface != nil {
+		items = itemsInterface.([]models.Item)
+	}
+	return items, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetItemPriceHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	args := sr.Called()
+	itemsInterface := args.Get(0)
+	var items []models.Item
+	if itemsInterface != nil {
+		items = itemsInterface.([]models.Item)
+	}
+	return items, args.Error(1)
+}
+
+func (sr *MockedShopRepository) GetSoldItemsByShopID(ShopID uint, From time.Time) ([]models.SoldItems, error) {
+	args := sr.Called()
+	soldItemsInterface := args.Get(0)
+	var soldItems []models.SoldItems
+	if soldItemsInterface != nil {
+		soldItems = soldItemsInterface.([]models.SoldItems)
+	}
+	return soldItems, args.Error(1)
+}
+
+func (sr *MockedShopRepository) UpdateDailyRevenue(dailySales []models.DailyShopSales) error {
+	args := sr.Called(dailySales)
+	return args.Error(0)
+}
+
+func (sr *MockedShopRepository) GetSoldItemsByItemIDs(ItemIDs []uint, From time.Time) ([]models.SoldItems, error) {
+	args := sr.Called()
+	soldItemsInterface := args.Get(0)
+	var soldItems []models.SoldItems
+	if soldItemsInterface != nil {
+		soldItems = soldItemsInterface.([]models.SoldItems)
+	}
+	return soldItems, args.Error(1)
+}
+
 func TestCreateNewShopRequestPanic(t *testing.T) {
 
 	ctx, router, w := setupMockServer.SetGinTestMode()
@@ -433,7 +806,7 @@ func TestCreateNewShopRequestSuccess(t *testing.T) {
 
 	ShopRepo.On("GetShopByName").Return(nil, errors.New("no Shop was Found ,error: record not found"))
 	TestShop.On("CreateShopRequest").Return(nil)
-	TestShop.On("CreateNewShop").Return(nil)
+	TestShop.On("EnqueueShopOnboarding").Return(nil)
 
 	router.POST("/create_shop", func(ctx *gin.Context) {
 		ctx.Set("currentUserUUID", currentUserUUID)
@@ -447,11 +820,39 @@ func TestCreateNewShopRequestSuccess(t *testing.T) {
 
 	TestShop.AssertCalled(t, "CreateShopRequest")
 	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 1)
+	TestShop.AssertNumberOfCalls(t, "EnqueueShopOnboarding", 1)
 	assert.Contains(t, w.Body.String(), "shop request received successfully")
 	assert.Equal(t, http.StatusOK, w.Code)
 
 }
 
+func TestCreateNewShopRequestQueueClosed(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRepo.On("GetShopByName").Return(nil, errors.New("no Shop was Found ,error: record not found"))
+	TestShop.On("CreateShopRequest").Return(nil)
+	TestShop.On("EnqueueShopOnboarding").Return(controllers.ErrOnboardingQueueClosed)
+
+	router.POST("/create_shop", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, implShop.CreateNewShopRequest)
+
+	body := []byte(`{"new_shop_name":"QueueClosedShop"}`)
+	req, _ := http.NewRequest("POST", "/create_shop", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	router.ServeHTTP(w, req)
+
+	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 2)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 func TestCreateNewShopScrapperErr(t *testing.T) {
 
 	Scraper := &MockScrapper{}
@@ -497,11 +898,12 @@ func TestCreateNewShopFailedSaveShopToDB(t *testing.T) {
 	assert.Contains(t, err.Error(), "Failed to save shop")
 
 }
-func TestCreateNewShopSaveMenuToDBFail(t *testing.T) {
+func TestCreateNewShopNameTakenByAnotherInstance(t *testing.T) {
 
 	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
 	Scraper := &MockScrapper{}
-	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop}
+	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop, Shop: ShopRepo}
 
 	userID := uuid.New()
 	ShopRequest := &models.ShopRequest{
@@ -512,24 +914,76 @@ func TestCreateNewShopSaveMenuToDBFail(t *testing.T) {
 	ShopExample := &models.Shop{
 		Name: "exampleShop",
 	}
+	existingShop := &models.Shop{Name: "exampleShop"}
+	existingShop.ID = 7
 
-	TestShop.On("CreateShopRequest").Return(nil)
-	TestShop.On("SaveShopToDB").Return(nil)
-	TestShop.On("UpdateShopMenuToDB").Return(errors.New("failed to save new record"))
 	Scraper.On("ScrapShop").Return(ShopExample, nil)
-	Scraper.On("ScrapAllMenuItems").Return(ShopExample)
+	TestShop.On("SaveShopToDB").Return(fmt.Errorf("error: %w", repository.ErrShopNameTaken))
+	ShopRepo.On("GetShopByName").Return(existingShop, nil)
+	TestShop.On("CreateShopRequest").Return(nil)
+	TestShop.On("EstablishAccountShopRelation").Return(nil)
 
 	err := implShop.CreateNewShop(ShopRequest)
 
-	assert.Error(t, err)
-
+	assert.NoError(t, err)
+	assert.Equal(t, "done", ShopRequest.Status)
+	TestShop.AssertNumberOfCalls(t, "EstablishAccountShopRelation", 1)
+	TestShop.AssertNotCalled(t, "UpdateShopMenuToDB")
+	Scraper.AssertNotCalled(t, "ScrapAllMenuItems")
 }
-func TestCreateNewShopSaveMenuToDBSuccess(t *testing.T) {
+func TestSaveShopToDBNameTakenKeepsRequestPending(t *testing.T) {
 
 	TestShop := &MockedShop{}
-	Scraper := &MockScrapper{}
-	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop}
-
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRequest := &models.ShopRequest{
+		AccountID: uuid.New(),
+		ShopName:  "exampleShop",
+		Status:    "Pending",
+	}
+
+	ShopRepo.On("CreateShop").Return(fmt.Errorf("exampleShop: %w", repository.ErrShopNameTaken))
+
+	err := implShop.SaveShopToDB(&models.Shop{Name: "exampleShop"}, ShopRequest)
+
+	assert.ErrorIs(t, err, repository.ErrShopNameTaken)
+	assert.Equal(t, "Pending", ShopRequest.Status)
+	TestShop.AssertNotCalled(t, "CreateShopRequest")
+}
+func TestCreateNewShopSaveMenuToDBFail(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	Scraper := &MockScrapper{}
+	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop}
+
+	userID := uuid.New()
+	ShopRequest := &models.ShopRequest{
+		AccountID: userID,
+		ShopName:  "exampleShop",
+		Status:    "Pending",
+	}
+	ShopExample := &models.Shop{
+		Name: "exampleShop",
+	}
+
+	TestShop.On("CreateShopRequest").Return(nil)
+	TestShop.On("SaveShopToDB").Return(nil)
+	TestShop.On("UpdateShopMenuToDB").Return(errors.New("failed to save new record"))
+	Scraper.On("ScrapShop").Return(ShopExample, nil)
+	Scraper.On("ScrapAllMenuItems").Return(ShopExample)
+
+	err := implShop.CreateNewShop(ShopRequest)
+
+	assert.Error(t, err)
+
+}
+func TestCreateNewShopSaveMenuToDBSuccess(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	Scraper := &MockScrapper{}
+	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop}
+
 	userID := uuid.New()
 	ShopRequest := &models.ShopRequest{
 		AccountID: userID,
@@ -543,6 +997,7 @@ func TestCreateNewShopSaveMenuToDBSuccess(t *testing.T) {
 	TestShop.On("CreateShopRequest").Return(nil)
 	TestShop.On("SaveShopToDB").Return(nil)
 	TestShop.On("UpdateShopMenuToDB").Return(nil)
+	TestShop.On("EstablishAccountShopRelation").Return(nil)
 	Scraper.On("ScrapShop").Return(ShopExample, nil)
 	Scraper.On("ScrapAllMenuItems").Return(ShopExample)
 
@@ -572,15 +1027,48 @@ func TestCreateNewShopHasSoldHistory(t *testing.T) {
 
 	TestShop.On("SaveShopToDB").Return(nil)
 	TestShop.On("UpdateShopMenuToDB").Return(nil)
+	TestShop.On("EstablishAccountShopRelation").Return(nil)
 	Scraper.On("ScrapShop").Return(ShopExample, nil)
 	Scraper.On("ScrapAllMenuItems").Return(ShopExample)
-	TestShop.On("UpdateSellingHistory").Return(nil)
+	TestShop.On("EnqueueSellingHistory").Return(nil)
 
 	err := implShop.CreateNewShop(ShopRequest)
 
 	assert.NoError(t, err)
-	TestShop.AssertNumberOfCalls(t, "UpdateSellingHistory", 1)
+	TestShop.AssertNumberOfCalls(t, "EnqueueSellingHistory", 1)
+	TestShop.AssertNotCalled(t, "UpdateSellingHistory")
+
+}
+
+func TestCreateNewShopHasSoldHistoryEnqueueFail(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	Scraper := &MockScrapper{}
+	implShop := controllers.Shop{Scraper: Scraper, Operations: TestShop}
+
+	ShopRequest := &models.ShopRequest{
+		AccountID: uuid.New(),
+		ShopName:  "exampleShop",
+		Status:    "Pending",
+	}
+	ShopExample := &models.Shop{
+		Name:           "exampleShop",
+		TotalSales:     10,
+		HasSoldHistory: true,
+	}
+
+	TestShop.On("SaveShopToDB").Return(nil)
+	TestShop.On("UpdateShopMenuToDB").Return(nil)
+	TestShop.On("EstablishAccountShopRelation").Return(nil)
+	TestShop.On("CreateShopRequest").Return(nil)
+	Scraper.On("ScrapShop").Return(ShopExample, nil)
+	Scraper.On("ScrapAllMenuItems").Return(ShopExample)
+	TestShop.On("EnqueueSellingHistory").Return(controllers.ErrOnboardingQueueClosed)
+
+	err := implShop.CreateNewShop(ShopRequest)
 
+	assert.Error(t, err)
+	assert.Equal(t, "failed", ShopRequest.Status)
 }
 
 func TestUpdateSellingHistoryDisContintuesSoldItemsFail(t *testing.T) {
@@ -749,7 +1237,8 @@ func TestUpdateSellingHistoryInsertIntoDB(t *testing.T) {
 		HasSoldHistory: true,
 	}
 
-	TestShop.On("UpdateDiscontinuedItems").Return([]models.SoldItems{{}, {}}, nil)
+	SoldItems := []models.SoldItems{{}, {}}
+	TestShop.On("UpdateDiscontinuedItems").Return(SoldItems, nil)
 	TestShop.On("GetItemsByShopID").Return([]models.Item{{}, {}, {}}, nil)
 	TestShop.On("CreateShopRequest").Return(nil)
 	ShopRepo.On("SaveSoldItemsToDB").Return(nil)
@@ -759,6 +1248,7 @@ func TestUpdateSellingHistoryInsertIntoDB(t *testing.T) {
 	assert.NoError(t, err)
 	TestShop.AssertNumberOfCalls(t, "UpdateDiscontinuedItems", 1)
 	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 1)
+	assert.True(t, SoldItems[0].FromHistory && SoldItems[1].FromHistory, "units of the selling history are tagged")
 
 }
 func TestUpdateSellingHistoryTaskSoldItem(t *testing.T) {
@@ -786,11 +1276,13 @@ func TestUpdateSellingHistoryTaskSoldItem(t *testing.T) {
 		HasSoldHistory: true,
 	}
 
-	TestShop.On("UpdateDiscontinuedItems").Return([]models.SoldItems{{}, {}}, nil)
+	SoldItems := []models.SoldItems{{}, {}}
+	TestShop.On("UpdateDiscontinuedItems").Return(SoldItems, nil)
 	TestShop.On("GetItemsByShopID").Return([]models.Item{{}, {}, {}}, nil)
 	TestShop.On("CreateShopRequest").Return(nil)
 	ShopRepo.On("SaveSoldItemsToDB").Return(nil)
-	ShopRepo.On("UpdateDailySales").Return(nil)
+	ShopRepo.On("GetSoldItemsByItemIDs").Return([]models.SoldItems{}, nil)
+	ShopRepo.On("UpdateDailySales", float64(0)).Return(nil)
 
 	err := implShop.UpdateSellingHistory(ShopExample, Task, ShopRequest)
 
@@ -799,7 +1291,27 @@ func TestUpdateSellingHistoryTaskSoldItem(t *testing.T) {
 	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 1)
 	ShopRepo.AssertNumberOfCalls(t, "SaveSoldItemsToDB", 1)
 	ShopRepo.AssertNumberOfCalls(t, "UpdateDailySales", 1)
+	assert.False(t, SoldItems[0].FromHistory || SoldItems[1].FromHistory, "units of a daily update are tracked")
+
+}
 
+func TestRecordDailyRevenueCountsEveryUnitSavedToday(t *testing.T) {
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	Items := []models.Item{{OriginalPrice: 10}, {OriginalPrice: 20, SalePrice: 15}}
+	Items[0].ID = 1
+	Items[1].ID = 2
+
+	// the first unit was saved by a refresh before the snapshot, the others by the scheduled update.
+	// The last one comes from a selling history scraped today.
+	ShopRepo.On("GetSoldItemsByItemIDs").Return([]models.SoldItems{{ItemID: 1}, {ItemID: 2}, {ItemID: 2}, {ItemID: 1, FromHistory: true}}, nil)
+	ShopRepo.On("UpdateDailySales", float64(40)).Return(nil)
+
+	err := implShop.RecordDailyRevenue(7, Items)
+
+	assert.NoError(t, err)
+	ShopRepo.AssertExpectations(t)
 }
 
 func TestUpdateDiscontinuedItemsEmptySoldItems(t *testing.T) {
@@ -1376,36 +1888,40 @@ func TestGetSoldItemsByShopIDNoSoldItemsInDB(t *testing.T) {
 
 func TestGetTotalRevenueFail(t *testing.T) {
 
-	TestShop := &MockedShop{}
-	implShop := controllers.Shop{Operations: TestShop}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
 
 	ShopExample := models.Shop{}
 	ShopExample.ID = uint(2)
 	AverageItemPrice := 19.2
-	TestShop.On("GetSoldItemsByShopID").Return(nil, errors.New("Sold items where not found"))
+	ShopRepo.On("GetItemsWithPriceHistoryByShopID").Return(nil, errors.New("Sold items where not found"))
 
 	_, err := implShop.GetTotalRevenue(ShopExample.ID, AverageItemPrice)
 
-	TestShop.AssertNumberOfCalls(t, "GetSoldItemsByShopID", 1)
+	ShopRepo.AssertNumberOfCalls(t, "GetItemsWithPriceHistoryByShopID", 1)
 
 	assert.Contains(t, err.Error(), "Sold items where not found")
 
 }
 func TestGetTotalRevenueSuccess(t *testing.T) {
 
-	TestShop := &MockedShop{}
-	implShop := controllers.Shop{Operations: TestShop}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
 
 	ShopExample := models.Shop{}
 	ShopExample.ID = uint(2)
 	AverageItemPrice := 19.2
 	revenueExpected := 485.68
 
-	TestShop.On("GetSoldItemsByShopID").Return([]controllers.ResponseSoldItemInfo{{Available: true, OriginalPrice: 15.2, SoldQuantity: 3}, {Available: true, OriginalPrice: 19.12, SoldQuantity: 10}, {Available: true, OriginalPrice: 124.44, SoldQuantity: 2}}, nil)
+	ShopRepo.On("GetItemsWithPriceHistoryByShopID").Return([]models.Item{
+		{Available: true, OriginalPrice: 15.2, SalePrice: -1, SoldUnits: make([]models.SoldItems, 3)},
+		{Available: true, OriginalPrice: 19.12, SalePrice: -1, SoldUnits: make([]models.SoldItems, 10)},
+		{Available: true, OriginalPrice: 124.44, SalePrice: -1, SoldUnits: make([]models.SoldItems, 2)},
+	}, nil)
 
 	Revenue, err := implShop.GetTotalRevenue(ShopExample.ID, AverageItemPrice)
 
-	TestShop.AssertNumberOfCalls(t, "GetSoldItemsByShopID", 1)
+	ShopRepo.AssertNumberOfCalls(t, "GetItemsWithPriceHistoryByShopID", 1)
 	assert.NoError(t, err)
 	assert.Equal(t, revenueExpected, Revenue)
 
@@ -1433,7 +1949,7 @@ func TestProcessStatsRequestInvalidPeriod(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Contains(t, w.Body.String(), "invalid period provided")
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 }
 func TestProcessStatsRequestGetSellingStatsByPeriodFail(t *testing.T) {
@@ -1516,6 +2032,8 @@ func TestGetSellingStatsByPeriodSelectData(t *testing.T) {
 
 	ShopRepo.On("FetchStatsByPeriod").Return(stats, nil)
 	TestShop.On("CreateSoldStats").Return(map[string]controllers.DailySoldStats{}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
 
 	_, err := implShop.GetSellingStatsByPeriod(ShopID, Period)
 
@@ -1539,6 +2057,51 @@ func TestGetSellingStatsByPeriodSelectDataaFail(t *testing.T) {
 
 }
 
+func TestGetSellingStatsByPeriodAddsReviews(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	Period := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	before := models.DailyShopReviews{ShopRating: 4.8, ReviewsCount: 120}
+	before.CreatedAt = time.Date(2024, 5, 1, 15, 12, 0, 0, time.UTC)
+	firstDay := models.DailyShopReviews{ShopRating: 4.9, ReviewsCount: 123}
+	firstDay.CreatedAt = time.Date(2024, 5, 2, 15, 12, 0, 0, time.UTC)
+	secondDay := models.DailyShopReviews{ShopRating: 4.9, ReviewsCount: 122}
+	secondDay.CreatedAt = time.Date(2024, 5, 3, 15, 12, 0, 0, time.UTC)
+
+	ShopRepo.On("FetchStatsByPeriod").Return([]models.DailyShopSales{}, nil)
+	TestShop.On("CreateSoldStats").Return(map[string]controllers.DailySoldStats{
+		"2024-05-02": {TotalSales: 100, DailyRevenue: 20},
+	}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{before, firstDay, secondDay}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+
+	stats, err := implShop.GetSellingStatsByPeriod(2, Period)
+
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, controllers.DailySoldStats{TotalSales: 100, DailyRevenue: 20, ShopRating: 4.9, RatingChange: 0.1, ReviewsCount: 123, NewReviews: 3}, stats["2024-05-02"])
+	assert.Equal(t, controllers.DailySoldStats{ShopRating: 4.9, ReviewsCount: 122}, stats["2024-05-03"])
+}
+
+func TestGetSellingStatsByPeriodReviewsFail(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRepo.On("FetchStatsByPeriod").Return([]models.DailyShopSales{}, nil)
+	TestShop.On("CreateSoldStats").Return(map[string]controllers.DailySoldStats{}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return(nil, errors.New("error while retrieving daily reviews"))
+
+	_, err := implShop.GetSellingStatsByPeriod(2, time.Now())
+
+	assert.Contains(t, err.Error(), "error while retrieving daily reviews")
+}
+
 func TestSaveShopToDB(t *testing.T) {
 
 	ShopRepo := &MockedShopRepository{}
@@ -1831,37 +2394,6 @@ func TestEstablishAccountShopRelation(t *testing.T) {
 
 }
 
-func TestGetItemsBySoldItemsSuccess(t *testing.T) {
-
-	ShopRepo := &MockedShopRepository{}
-	implShop := controllers.Shop{Shop: ShopRepo}
-
-	SoldItems := make([]models.SoldItems, 5)
-
-	ShopRepo.On("FetchItemsBySoldItems").Return(models.Item{}, nil)
-
-	items, err := implShop.GetItemsBySoldItems(SoldItems)
-
-	assert.NoError(t, err)
-	assert.Equal(t, len(SoldItems), len(items))
-
-}
-
-func TestGetItemsBySoldItemsFail(t *testing.T) {
-
-	ShopRepo := &MockedShopRepository{}
-	implShop := controllers.Shop{Shop: ShopRepo}
-
-	SoldItems := make([]models.SoldItems, 5)
-
-	ShopRepo.On("FetchItemsBySoldItems").Return(nil, errors.New("error while processing database operations"))
-	_, err := implShop.GetItemsBySoldItems(SoldItems)
-
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "error while processing database operations")
-
-}
-
 func TestHandleHandleGetShopByIDNoShop(t *testing.T) {
 
 	_, router, w := setupMockServer.SetGinTestMode()
@@ -2102,24 +2634,42 @@ func TestHandleGetItemsCountByShopIDSuccess(t *testing.T) {
 }
 
 func TestCalculateTotalRevenue(t *testing.T) {
-	soldItems := []controllers.ResponseSoldItemInfo{
-		{OriginalPrice: 19.2, SoldQuantity: 10}, {OriginalPrice: 12.4, SoldQuantity: 9}, {OriginalPrice: 5.2, SoldQuantity: 11}, {SoldQuantity: 2}, {SoldQuantity: 19},
+	Items := []models.Item{
+		{OriginalPrice: 19.2, SoldUnits: make([]models.SoldItems, 10)}, {OriginalPrice: 12.4, SoldUnits: make([]models.SoldItems, 9)}, {OriginalPrice: 5.2, SoldUnits: make([]models.SoldItems, 11)}, {SoldUnits: make([]models.SoldItems, 2)}, {SoldUnits: make([]models.SoldItems, 19)},
 	}
 	AverageItemPrice := 7.5
 
 	var expectedRevenue float64
-	for _, soldItem := range soldItems {
-		if soldItem.OriginalPrice > 0 {
-			expectedRevenue += soldItem.OriginalPrice * float64(soldItem.SoldQuantity)
+	for _, item := range Items {
+		if item.OriginalPrice > 0 {
+			expectedRevenue += item.OriginalPrice * float64(len(item.SoldUnits))
 		} else {
-			expectedRevenue += AverageItemPrice * float64(soldItem.SoldQuantity)
+			expectedRevenue += AverageItemPrice * float64(len(item.SoldUnits))
 		}
 	}
 
-	revenue := controllers.CalculateTotalRevenue(soldItems, AverageItemPrice)
+	revenue := controllers.CalculateTotalRevenue(Items, AverageItemPrice)
 	assert.Equal(t, expectedRevenue, revenue)
 }
 
+func TestCalculateTotalRevenueUsesPriceAtSaleDate(t *testing.T) {
+	beforeRaise := time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC)
+	priceRaised := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	afterRaise := time.Date(2024, 6, 10, 15, 0, 0, 0, time.UTC)
+
+	raise := models.ItemHistoryChange{OldPrice: 20, NewPrice: 30}
+	raise.CreatedAt = priceRaised
+
+	first := models.SoldItems{}
+	first.CreatedAt = beforeRaise
+	second := models.SoldItems{}
+	second.CreatedAt = afterRaise
+
+	item := models.Item{OriginalPrice: 30, SalePrice: -1, PriceHistory: []models.ItemHistoryChange{raise}, SoldUnits: []models.SoldItems{first, second}}
+
+	assert.Equal(t, 50.0, controllers.CalculateTotalRevenue([]models.Item{item}, 7.5))
+}
+
 func TestCreateSoldStatsFail(t *testing.T) {
 	ShopRepo := &MockedShopRepository{}
 	implShop := controllers.Shop{Shop: ShopRepo}
@@ -2132,19 +2682,33 @@ func TestCreateSoldStatsFail(t *testing.T) {
 		},
 	}
 
-	ShopRepo.On("GetSoldItemsInRange").Return(nil, errors.New("internal error"))
+	ShopRepo.On("GetShopRollupsByPeriod").Return(nil, errors.New("internal error"))
 
-	_, err := implShop.CreateSoldStats(dailyShopSales)
+	_, err := implShop.CreateSoldStats(dailyShopSales, time.UTC)
 
 	assert.Error(t, err)
+	ShopRepo.AssertNotCalled(t, "GetItemRollupsByPeriod")
+
+}
+
+func TestCreateSoldStatsItemRollupsFail(t *testing.T) {
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	dailyShopSales := []models.DailyShopSales{{ShopID: 1, TotalSales: 100}}
 
+	ShopRepo.On("GetShopRollupsByPeriod").Return([]models.ShopDailyRollup{}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return(nil, errors.New("internal error"))
+
+	_, err := implShop.CreateSoldStats(dailyShopSales, time.UTC)
+
+	assert.Error(t, err)
 }
 
 func TestCreateSoldStatsSuccessWithItems(t *testing.T) {
 
-	TestShop := &MockedShop{}
 	ShopRepo := &MockedShopRepository{}
-	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+	implShop := controllers.Shop{Shop: ShopRepo}
 
 	dailyShopSales := []models.DailyShopSales{
 		{
@@ -2154,33 +2718,40 @@ func TestCreateSoldStatsSuccessWithItems(t *testing.T) {
 		},
 		{
 			ShopID:       1,
-			TotalSales:   101,
+			TotalSales:   103,
 			DailyRevenue: 16.1,
 		},
 	}
-	for i := range dailyShopSales {
-		dailyShopSales[i].CreatedAt = time.Now().AddDate(0, 0, (-len(dailyShopSales) + i))
-	}
+	dailyShopSales[0].CreatedAt = time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	dailyShopSales[1].CreatedAt = time.Date(2024, 4, 2, 15, 12, 0, 0, time.UTC)
 
-	ShopRepo.On("GetSoldItemsInRange").Return([]models.SoldItems{{}, {}, {}}, nil)
-	TestShop.On("GetItemsBySoldItems").Return([]models.Item{{}}, nil)
+	ShopRepo.On("GetShopRollupsByPeriod").Return([]models.ShopDailyRollup{
+		{ShopID: 1, Date: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC), SoldUnits: 3},
+	}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{
+		{ShopID: 1, ItemID: 1, Date: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC), SoldUnits: 2, Item: models.Item{Name: "item1"}},
+		{ShopID: 1, ItemID: 2, Date: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC), SoldUnits: 1, Item: models.Item{Name: "item2"}},
+	}, nil)
 
-	stats, err := implShop.CreateSoldStats(dailyShopSales)
-
-	for _, record := range stats {
-		assert.Equal(t, 1, len(record.Items))
-	}
+	stats, err := implShop.CreateSoldStats(dailyShopSales, time.UTC)
 
-	assert.Equal(t, len(dailyShopSales), len(stats))
 	assert.NoError(t, err)
+	assert.Equal(t, len(dailyShopSales), len(stats))
+	assert.Equal(t, 0, stats["2024-04-01"].SoldUnits)
+	assert.Empty(t, stats["2024-04-01"].Items)
+	assert.Equal(t, 3, stats["2024-04-02"].SoldUnits)
+	assert.Len(t, stats["2024-04-02"].Items, 3)
+	assert.Equal(t, "item1", stats["2024-04-02"].Items[0].Name)
+	assert.Equal(t, "item2", stats["2024-04-02"].Items[2].Name)
+	ShopRepo.AssertNumberOfCalls(t, "GetShopRollupsByPeriod", 1)
+	ShopRepo.AssertNumberOfCalls(t, "GetItemRollupsByPeriod", 1)
 
 }
 
 func TestCreateSoldStatsSuccesswithNoItems(t *testing.T) {
 
-	TestShop := &MockedShop{}
 	ShopRepo := &MockedShopRepository{}
-	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+	implShop := controllers.Shop{Shop: ShopRepo}
 
 	dailyShopSales := []models.DailyShopSales{
 		{
@@ -2198,10 +2769,10 @@ func TestCreateSoldStatsSuccesswithNoItems(t *testing.T) {
 		dailyShopSales[i].CreatedAt = time.Now().AddDate(0, 0, (-len(dailyShopSales) + i))
 	}
 
-	ShopRepo.On("GetSoldItemsInRange").Return([]models.SoldItems{}, nil)
-	TestShop.On("GetItemsBySoldItems").Return([]models.Item{}, nil)
+	ShopRepo.On("GetShopRollupsByPeriod").Return([]models.ShopDailyRollup{}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{}, nil)
 
-	stats, err := implShop.CreateSoldStats(dailyShopSales)
+	stats, err := implShop.CreateSoldStats(dailyShopSales, time.UTC)
 
 	for _, record := range stats {
 		assert.Equal(t, 0, len(record.Items))
@@ -2212,6 +2783,90 @@ func TestCreateSoldStatsSuccesswithNoItems(t *testing.T) {
 
 }
 
+func TestCreateSoldStatsNoSnapshots(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	stats, err := implShop.CreateSoldStats([]models.DailyShopSales{}, time.UTC)
+
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	ShopRepo.AssertNotCalled(t, "GetShopRollupsByPeriod")
+}
+
+func TestCreateSoldStatsMarksEstimatedDays(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	dailyShopSales := []models.DailyShopSales{
+		{ShopID: 1, TotalSales: 100, DailyRevenue: 20},
+		{ShopID: 1, TotalSales: 102, DailyRevenue: 20, Estimated: true},
+	}
+	dailyShopSales[0].CreatedAt = time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	dailyShopSales[1].CreatedAt = time.Date(2024, 4, 2, 15, 12, 0, 0, time.UTC)
+
+	ShopRepo.On("GetShopRollupsByPeriod").Return([]models.ShopDailyRollup{}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{}, nil)
+
+	stats, err := implShop.CreateSoldStats(dailyShopSales, time.UTC)
+
+	assert.NoError(t, err)
+	assert.False(t, stats["2024-04-01"].Estimated)
+	assert.True(t, stats["2024-04-02"].Estimated)
+}
+
+func TestCreateSoldStatsBucketsByLocation(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	dailyShopSales := []models.DailyShopSales{{ShopID: 1, TotalSales: 100}}
+	dailyShopSales[0].CreatedAt = time.Date(2024, 4, 1, 18, 0, 0, 0, time.UTC)
+
+	ShopRepo.On("GetShopRollupsByPeriod").Return([]models.ShopDailyRollup{
+		{ShopID: 1, Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), SoldUnits: 4},
+	}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{}, nil)
+
+	stats, err := implShop.CreateSoldStats(dailyShopSales, tokyo)
+
+	assert.NoError(t, err)
+	assert.Contains(t, stats, "2024-04-02")
+	assert.NotContains(t, stats, "2024-04-01")
+	assert.Equal(t, 4, stats["2024-04-02"].SoldUnits)
+}
+
+func TestProcessStatsRequestUsesAccountTimeZone(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+
+	TestShop := &MockedShop{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Operations: TestShop, User: UserRepo}
+
+	currentUserUUID := uuid.New()
+	UserRepo.On("GetAccountByID").Return(&models.Account{ID: currentUserUUID, TimeZone: "America/New_York"}, nil)
+	TestShop.On("GetSellingStatsByPeriod").Return(map[string]controllers.DailySoldStats{}, nil)
+
+	router.GET("/stats/:shopID/:period", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+		implShop.ProcessStatsRequest(ctx)
+	})
+
+	req, _ := http.NewRequest("GET", "/stats/2/lastSevenDays", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	UserRepo.AssertNumberOfCalls(t, "GetAccountByID", 1)
+}
+
 func TestCreateShopRequestTypeShopFailNoAccount(t *testing.T) {
 
 	implShop := controllers.Shop{}
@@ -2289,3 +2944,3205 @@ func TestGetItemsByShopIDTypeShopFail(t *testing.T) {
 	assert.Contains(t, err.Error(), "error while getting shop from DB")
 
 }
+
+func TestEnqueueShopRefreshReturnsActiveJob(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	activeJob := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 1, Status: "running", RequestedAt: time.Now()}
+	ShopRepo.On("GetLatestRefreshJob").Return(activeJob, nil)
+
+	job, isDuplicate, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.NoError(t, err)
+	assert.True(t, isDuplicate)
+	assert.Equal(t, activeJob.JobID, job.JobID)
+	ShopRepo.AssertNotCalled(t, "CreateRefreshJob")
+}
+
+func TestEnqueueShopRefreshFailsStaleJob(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+
+	staleJob := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 1, Status: "running", RequestedAt: time.Now().Add(-controllers.RefreshJobTimeout - time.Minute)}
+	ShopRepo.On("GetLatestRefreshJob").Return(staleJob, nil)
+	ShopRepo.On("SaveRefreshJob", "failed").Return(nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{SubscriptionType: "basic"}, nil)
+	ShopRepo.On("CountRefreshJobsSince").Return(int64(0), nil)
+	ShopRepo.On("CreateRefreshJob").Return(nil)
+
+	job, isDuplicate, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.NotEqual(t, staleJob.JobID, job.JobID)
+	assert.Equal(t, "failed", staleJob.Status)
+	assert.Equal(t, controllers.ErrRefreshJobTimedOut.Error(), staleJob.Error)
+	assert.NotNil(t, staleJob.FinishedAt)
+}
+
+func TestEnqueueShopRefreshFailsJobWithLostClaim(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+
+	claimedAt := time.Now().Add(-controllers.RefreshClaimTimeout - time.Minute)
+	lostJob := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 1, Status: "running", RequestedAt: claimedAt, ClaimedBy: "crashed-instance", ClaimedAt: &claimedAt}
+	ShopRepo.On("GetLatestRefreshJob").Return(lostJob, nil)
+	ShopRepo.On("SaveRefreshJob", "failed").Return(nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{SubscriptionType: "basic"}, nil)
+	ShopRepo.On("CountRefreshJobsSince").Return(int64(0), nil)
+	ShopRepo.On("CreateRefreshJob").Return(nil)
+
+	job, isDuplicate, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.NotEqual(t, lostJob.JobID, job.JobID)
+	assert.Equal(t, "failed", lostJob.Status)
+}
+
+func TestIsRefreshJobActiveWithRenewedClaim(t *testing.T) {
+	now := time.Now()
+	renewedAt := now.Add(-time.Minute)
+	job := &models.ShopRefreshJob{Status: "running", RequestedAt: now.Add(-time.Hour), ClaimedAt: &renewedAt}
+
+	assert.True(t, controllers.IsRefreshJobActive(job, now))
+	assert.False(t, controllers.IsRefreshJobActive(job, now.Add(controllers.RefreshClaimTimeout)))
+	assert.False(t, controllers.IsRefreshJobActive(job, now.Add(controllers.RefreshJobTimeout)))
+}
+
+func TestDrainRefreshJobsWaitsForStartedJobs(t *testing.T) {
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	release := make(chan time.Time)
+	TestShop.On("RunShopRefresh").WaitUntil(release).Return(nil)
+
+	implShop.StartShopRefresh(&models.ShopRefreshJob{ShopID: 1})
+
+	expired, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, implShop.DrainRefreshJobs(expired))
+
+	close(release)
+	assert.NoError(t, implShop.DrainRefreshJobs(context.Background()))
+	TestShop.AssertNumberOfCalls(t, "RunShopRefresh", 1)
+}
+
+func TestFailStaleRefreshJobs(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FailStaleRefreshJobs").Return(int64(2), nil)
+
+	assert.NoError(t, implShop.FailStaleRefreshJobs())
+
+	ShopRepo = &MockedShopRepository{}
+	implShop = controllers.Shop{Shop: ShopRepo}
+	ShopRepo.On("FailStaleRefreshJobs").Return(int64(0), errors.New("database error"))
+
+	assert.Error(t, implShop.FailStaleRefreshJobs())
+}
+
+func TestEnqueueShopRefreshCooldown(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	lastJob := &models.ShopRefreshJob{ShopID: 1, Status: "done", RequestedAt: time.Now().Add(-5 * time.Minute)}
+	ShopRepo.On("GetLatestRefreshJob").Return(lastJob, nil)
+
+	job, _, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, controllers.ErrRefreshCooldown)
+}
+
+func TestEnqueueShopRefreshFailedJobSkipsCooldown(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+
+	lastJob := &models.ShopRefreshJob{ShopID: 1, Status: "failed", RequestedAt: time.Now().Add(-5 * time.Minute)}
+	ShopRepo.On("GetLatestRefreshJob").Return(lastJob, nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{SubscriptionType: "basic"}, nil)
+	ShopRepo.On("CountRefreshJobsSince").Return(int64(0), nil)
+	ShopRepo.On("CreateRefreshJob").Return(nil)
+
+	job, isDuplicate, err := implShop.EnqueueShopRefresh(1, uuid.New(), true)
+
+	assert.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.Equal(t, "queued", job.Status)
+	assert.True(t, job.FullRefresh)
+}
+
+func TestEnqueueShopRefreshQuotaExceeded(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+
+	ShopRepo.On("GetLatestRefreshJob").Return(nil, nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{SubscriptionType: "premium"}, nil)
+	ShopRepo.On("CountRefreshJobsSince").Return(int64(10), nil)
+
+	job, _, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, controllers.ErrRefreshQuotaExceeded)
+	ShopRepo.AssertNotCalled(t, "CreateRefreshJob")
+}
+
+func TestEnqueueShopRefreshSuccess(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+	AccountID := uuid.New()
+
+	ShopRepo.On("GetLatestRefreshJob").Return(nil, nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{SubscriptionType: "premium"}, nil)
+	ShopRepo.On("CountRefreshJobsSince").Return(int64(9), nil)
+	ShopRepo.On("CreateRefreshJob").Return(nil)
+
+	job, isDuplicate, err := implShop.EnqueueShopRefresh(1, AccountID, false)
+
+	assert.NoError(t, err)
+	assert.False(t, isDuplicate)
+	assert.NotEqual(t, uuid.Nil, job.JobID)
+	assert.Equal(t, AccountID, job.AccountID)
+	assert.Equal(t, "queued", job.Status)
+}
+
+func TestEnqueueShopRefreshLatestJobError(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("GetLatestRefreshJob").Return(nil, errors.New("database error"))
+
+	job, _, err := implShop.EnqueueShopRefresh(1, uuid.New(), false)
+
+	assert.Nil(t, job)
+	assert.Contains(t, err.Error(), "database error")
+}
+
+type MockRefresher struct {
+	mock.Mock
+}
+
+func (m *MockRefresher) RefreshShop(ShopID uint, needUpdateItems bool) error {
+	args := m.Called(ShopID, needUpdateItems)
+	return args.Error(0)
+}
+
+func TestLockShopTakesAndGivesBackTheLock(t *testing.T) {
+	ShopRepo := &MockedShopRepository{}
+	ShopRepo.On("LockShop", uint(3)).Return(true, nil).Once()
+	ShopRepo.On("UnlockShop", uint(3)).Return(nil).Once()
+
+	unlock, err := controllers.LockShop(ShopRepo, 3)
+	assert.NoError(t, err)
+
+	unlock()
+	ShopRepo.AssertExpectations(t)
+}
+
+func TestLockShopGivesUpWhenShopStaysLocked(t *testing.T) {
+	defer func(wait, retry time.Duration) {
+		controllers.ShopLockWait, controllers.ShopLockRetryInterval = wait, retry
+	}(controllers.ShopLockWait, controllers.ShopLockRetryInterval)
+	controllers.ShopLockWait = 20 * time.Millisecond
+	controllers.ShopLockRetryInterval = 5 * time.Millisecond
+
+	ShopRepo := &MockedShopRepository{}
+	ShopRepo.On("LockShop", uint(3)).Return(false, nil)
+
+	unlock, err := controllers.LockShop(ShopRepo, 3)
+
+	assert.Nil(t, unlock)
+	assert.True(t, errors.Is(err, controllers.ErrShopLocked))
+	ShopRepo.AssertNotCalled(t, "UnlockShop", uint(3))
+}
+
+func TestRunShopRefreshSuccess(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	Refresher := &MockRefresher{}
+	implShop := controllers.Shop{Shop: ShopRepo, Refresher: Refresher}
+
+	job := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 3, FullRefresh: true, Status: "queued"}
+	ShopRepo.On("SaveRefreshJob", "running").Return(nil)
+	ShopRepo.On("SaveRefreshJob", "done").Return(nil)
+	Refresher.On("RefreshShop", uint(3), true).Return(nil)
+
+	err := implShop.RunShopRefresh(job)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "done", job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	Refresher.AssertNumberOfCalls(t, "RefreshShop", 1)
+}
+
+func TestRunShopRefreshFailed(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	Refresher := &MockRefresher{}
+	implShop := controllers.Shop{Shop: ShopRepo, Refresher: Refresher}
+
+	job := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 3, Status: "queued"}
+	ShopRepo.On("SaveRefreshJob", "running").Return(nil)
+	ShopRepo.On("SaveRefreshJob", "failed").Return(nil)
+	Refresher.On("RefreshShop", uint(3), false).Return(errors.New("scrape failed"))
+
+	err := implShop.RunShopRefresh(job)
+
+	assert.Error(t, err)
+	assert.Equal(t, "failed", job.Status)
+	assert.Contains(t, job.Error, "scrape failed")
+}
+
+func TestRunShopRefreshNoRefresher(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	job := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 3, Status: "queued"}
+	ShopRepo.On("SaveRefreshJob", "running").Return(nil)
+	ShopRepo.On("SaveRefreshJob", "failed").Return(nil)
+
+	err := implShop.RunShopRefresh(job)
+
+	assert.Error(t, err)
+	assert.Equal(t, "failed", job.Status)
+}
+
+func TestRefreshShopInvalidMode(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	router.POST("/shop/:shopID/refresh", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", uuid.New())
+	}, implShop.RefreshShop)
+
+	req, _ := http.NewRequest("POST", "/shop/1/refresh?mode=everything", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid refresh mode provided")
+	TestShop.AssertNotCalled(t, "EnqueueShopRefresh")
+}
+
+func TestRefreshShopQuotaExceeded(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	TestShop.On("EnqueueShopRefresh").Return(nil, false, fmt.Errorf("error: %w", controllers.ErrRefreshQuotaExceeded))
+
+	router.POST("/shop/:shopID/refresh", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", uuid.New())
+	}, implShop.RefreshShop)
+
+	req, _ := http.NewRequest("POST", "/shop/1/refresh", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "daily refresh quota exceeded")
+}
+
+func TestRefreshShopDeduplicated(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	job := &models.ShopRefreshJob{JobID: uuid.New(), Status: "running"}
+	TestShop.On("EnqueueShopRefresh").Return(job, true, nil)
+
+	router.POST("/shop/:shopID/refresh", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", uuid.New())
+	}, implShop.RefreshShop)
+
+	req, _ := http.NewRequest("POST", "/shop/1/refresh?mode=full", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), job.JobID.String())
+	assert.Contains(t, w.Body.String(), `"deduplicated":true`)
+	TestShop.AssertNotCalled(t, "RunShopRefresh")
+}
+
+func TestHandleGetRefreshJobWrongShop(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("GetRefreshJobByJobID").Return(&models.ShopRefreshJob{ShopID: 2}, nil)
+
+	router.GET("/shop/:shopID/refresh/:jobID", implShop.HandleGetRefreshJob)
+
+	req, _ := http.NewRequest("GET", "/shop/1/refresh/"+uuid.New().String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleGetRefreshJobSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	job := &models.ShopRefreshJob{JobID: uuid.New(), ShopID: 1, Status: "done"}
+	ShopRepo.On("GetRefreshJobByJobID").Return(job, nil)
+
+	router.GET("/shop/:shopID/refresh/:jobID", implShop.HandleGetRefreshJob)
+
+	req, _ := http.NewRequest("GET", "/shop/1/refresh/"+job.JobID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"done"`)
+}
+
+func TestOnboardingQueueProfileJobsBeforeHistory(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	AccountID := uuid.New()
+	now := time.Now()
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID})
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, AccountID: uuid.New()})
+
+	job, _ := queue.Pop(now)
+	assert.Equal(t, controllers.OnboardingProfileJob, job.Kind)
+
+	job, _ = queue.Pop(now)
+	assert.Equal(t, controllers.OnboardingHistoryJob, job.Kind)
+
+	job, wakeAt := queue.Pop(now)
+	assert.Nil(t, job)
+	assert.True(t, wakeAt.IsZero())
+}
+
+func TestOnboardingQueueWaitsForDelayedJobs(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	now := time.Now()
+	readyAt := now.Add(time.Minute)
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: uuid.New(), NotBefore: readyAt})
+
+	job, wakeAt := queue.Pop(now)
+	assert.Nil(t, job)
+	assert.Equal(t, readyAt, wakeAt)
+
+	job, _ = queue.Pop(readyAt)
+	assert.NotNil(t, job)
+}
+
+func TestOnboardingQueueSharesSlicesByWeight(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	basicAccount := uuid.New()
+	businessAccount := uuid.New()
+
+	for i := 0; i < 20; i++ {
+		queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: basicAccount, Weight: 1})
+		queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: businessAccount, Weight: 4})
+	}
+
+	served := map[uuid.UUID]int{}
+	for i := 0; i < 10; i++ {
+		job, _ := queue.Pop(time.Now())
+		served[job.AccountID]++
+	}
+
+	assert.Equal(t, 2, served[basicAccount])
+	assert.Equal(t, 8, served[businessAccount])
+	assert.Equal(t, 30, queue.Len())
+}
+
+func TestOnboardingQueueNewAccountIsNotStarved(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	bigShopAccount := uuid.New()
+	newAccount := uuid.New()
+
+	for i := 0; i < 50; i++ {
+		queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: bigShopAccount})
+	}
+	for i := 0; i < 10; i++ {
+		queue.Pop(time.Now())
+	}
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: newAccount})
+
+	served := []uuid.UUID{}
+	for i := 0; i < 2; i++ {
+		job, _ := queue.Pop(time.Now())
+		served = append(served, job.AccountID)
+	}
+
+	assert.Contains(t, served, newAccount)
+}
+
+func TestOnboardingQueueClosed(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	queue.Close()
+
+	err := queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, AccountID: uuid.New()})
+	assert.ErrorIs(t, err, controllers.ErrOnboardingQueueClosed)
+
+	_, ok := queue.Next()
+	assert.False(t, ok)
+}
+
+func TestOnboardingQueueStartRunsJobs(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	done := make(chan *controllers.OnboardingJob, 1)
+
+	queue.Start(1, func(job *controllers.OnboardingJob) {
+		done <- job
+	})
+	defer queue.Close()
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, AccountID: uuid.New()})
+
+	select {
+	case job := <-done:
+		assert.Equal(t, controllers.OnboardingProfileJob, job.Kind)
+	case <-time.After(time.Second):
+		t.Fatal("onboarding job was not processed")
+	}
+}
+
+func TestEnqueueShopOnboardingWithoutQueue(t *testing.T) {
+
+	implShop := controllers.Shop{}
+
+	err := implShop.EnqueueShopOnboarding(&models.ShopRequest{AccountID: uuid.New()})
+
+	assert.ErrorIs(t, err, controllers.ErrOnboardingQueueClosed)
+}
+
+func TestEnqueueShopOnboardingUsesSubscriptionWeight(t *testing.T) {
+
+	UserRepo := &MockedUserRepository{}
+	queue := controllers.NewOnboardingQueue()
+	implShop := controllers.Shop{User: UserRepo, Onboarding: queue}
+
+	AccountID := uuid.New()
+	UserRepo.On("GetAccountByID").Return(&models.Account{ID: AccountID, SubscriptionType: "business"}, nil)
+
+	err := implShop.EnqueueShopOnboarding(&models.ShopRequest{AccountID: AccountID, ShopName: "exampleShop"})
+	assert.NoError(t, err)
+
+	job, _ := queue.Pop(time.Now())
+	assert.Equal(t, controllers.OnboardingProfileJob, job.Kind)
+	assert.Equal(t, controllers.OnboardingWeightBySubscription["business"], job.Weight)
+}
+
+func TestEnqueueSellingHistoryDelaysSlice(t *testing.T) {
+
+	UserRepo := &MockedUserRepository{}
+	queue := controllers.NewOnboardingQueue()
+	implShop := controllers.Shop{User: UserRepo, Onboarding: queue}
+
+	UserRepo.On("GetAccountByID").Return(nil, errors.New("record not found"))
+
+	Task := &models.TaskSchedule{CurrentPage: 5}
+	err := implShop.EnqueueSellingHistory(&models.Shop{Name: "exampleShop"}, Task, &models.ShopRequest{AccountID: uuid.New()}, time.Minute)
+	assert.NoError(t, err)
+
+	job, wakeAt := queue.Pop(time.Now())
+	assert.Nil(t, job)
+	assert.False(t, wakeAt.IsZero())
+
+	job, _ = queue.Pop(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, controllers.DefaultOnboardingWeight, job.Weight)
+	assert.Equal(t, 5, job.Task.CurrentPage)
+}
+
+func TestProcessOnboardingJobDispatchesByKind(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	TestShop.On("CreateNewShop").Return(nil)
+	TestShop.On("UpdateSellingHistory").Return(errors.New("scrape failed"))
+
+	implShop.ProcessOnboardingJob(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, ShopRequest: &models.ShopRequest{}})
+	implShop.ProcessOnboardingJob(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, ShopRequest: &models.ShopRequest{}})
+
+	TestShop.AssertNumberOfCalls(t, "CreateNewShop", 1)
+	TestShop.AssertNumberOfCalls(t, "UpdateSellingHistory", 1)
+}
+
+func TestGetOnboardingWeight(t *testing.T) {
+
+	assert.Equal(t, 4, controllers.GetOnboardingWeight("business"))
+	assert.Equal(t, controllers.DefaultOnboardingWeight, controllers.GetOnboardingWeight("free"))
+}
+
+func TestOnboardingQueueShutdownReturnsUnfinishedJobs(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	queue.Start(1, func(job *controllers.OnboardingJob) {
+		job.Task.CurrentPage = 99
+		close(started)
+		<-release
+	})
+
+	AccountID := uuid.New()
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID, Task: &models.TaskSchedule{CurrentPage: 3}})
+	<-started
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, AccountID: AccountID})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	remaining := queue.Shutdown(ctx)
+	close(release)
+
+	assert.Len(t, remaining, 2)
+	for _, job := range remaining {
+		if job.Kind == controllers.OnboardingHistoryJob {
+			assert.Equal(t, 3, job.Task.CurrentPage)
+		}
+	}
+
+	err := queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingProfileJob, AccountID: AccountID})
+	assert.ErrorIs(t, err, controllers.ErrOnboardingQueueClosed)
+}
+
+func TestOnboardingQueueShutdownKeepsContinuations(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	AccountID := uuid.New()
+
+	queue.Start(1, func(job *controllers.OnboardingJob) {
+		close(started)
+		<-release
+		queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID, Task: &models.TaskSchedule{CurrentPage: 4}})
+	})
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID, Task: &models.TaskSchedule{CurrentPage: 2}})
+	<-started
+	close(release)
+
+	remaining := queue.Shutdown(context.Background())
+
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, 4, remaining[0].Task.CurrentPage)
+}
+
+func TestOnboardingQueueShutdownDropsContinuedSnapshot(t *testing.T) {
+
+	queue := controllers.NewOnboardingQueue()
+	pushed := make(chan struct{})
+	release := make(chan struct{})
+	AccountID := uuid.New()
+	ShopRequest := &models.ShopRequest{}
+	ShopRequest.ID = 7
+
+	queue.Start(1, func(job *controllers.OnboardingJob) {
+		queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID, ShopRequest: ShopRequest, Task: &models.TaskSchedule{CurrentPage: 4}, NotBefore: time.Now().Add(time.Hour)})
+		close(pushed)
+		<-release
+	})
+
+	queue.Push(&controllers.OnboardingJob{Kind: controllers.OnboardingHistoryJob, AccountID: AccountID, ShopRequest: ShopRequest, Task: &models.TaskSchedule{CurrentPage: 2}})
+	<-pushed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	remaining := queue.Shutdown(ctx)
+	close(release)
+
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, 4, remaining[0].Task.CurrentPage)
+}
+
+func TestCheckpointOnboardingJobs(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRequest := &models.ShopRequest{}
+	ShopRequest.ID = 7
+	Shop := &models.Shop{}
+	Shop.ID = 3
+
+	jobs := []*controllers.OnboardingJob{
+		{Kind: controllers.OnboardingProfileJob, ShopRequest: ShopRequest},
+		{Kind: controllers.OnboardingHistoryJob, ShopRequest: ShopRequest, Shop: Shop, Task: &models.TaskSchedule{CurrentPage: 12, LastPage: 40}},
+	}
+
+	expected := []models.ScrapeCheckpoint{
+		{ShopRequestID: 7, Kind: controllers.OnboardingProfileJob},
+		{ShopRequestID: 7, Kind: controllers.OnboardingHistoryJob, ShopID: 3, Task: models.TaskSchedule{CurrentPage: 12, LastPage: 40}},
+	}
+	ShopRepo.On("SaveScrapeCheckpoints", expected).Return(nil)
+
+	err := implShop.CheckpointOnboardingJobs(jobs)
+
+	assert.NoError(t, err)
+	ShopRepo.AssertNumberOfCalls(t, "SaveScrapeCheckpoints", 1)
+}
+
+func TestCheckpointOnboardingJobsNothingToSave(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	err := implShop.CheckpointOnboardingJobs(nil)
+
+	assert.NoError(t, err)
+	ShopRepo.AssertNotCalled(t, "SaveScrapeCheckpoints", mock.Anything)
+}
+
+func TestRecoverOnboardingResumesCheckpointsAndOrphans(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	checkpointedRequest := models.ShopRequest{ShopName: "resumedShop", Status: "Pending"}
+	checkpointedRequest.ID = 7
+	orphanedRequest := models.ShopRequest{ShopName: "orphanedShop", Status: "Pending"}
+	orphanedRequest.ID = 8
+
+	checkpoint := models.ScrapeCheckpoint{ShopRequestID: 7, Kind: controllers.OnboardingHistoryJob, ShopID: 3, Task: models.TaskSchedule{CurrentPage: 12}}
+	checkpoint.ID = 1
+
+	ShopRepo.On("GetScrapeCheckpoints").Return([]models.ScrapeCheckpoint{checkpoint}, nil)
+	ShopRepo.On("GetShopRequestByID").Return(&checkpointedRequest, nil)
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{Name: "resumedShop"}, nil)
+	ShopRepo.On("DeleteScrapeCheckpoint").Return(nil)
+	ShopRepo.On("GetShopRequestsByStatus").Return([]models.ShopRequest{checkpointedRequest, orphanedRequest}, nil)
+	ShopRepo.On("ClaimShopRequest", uint(7)).Return(true, nil)
+	ShopRepo.On("ClaimShopRequest", uint(8)).Return(true, nil)
+	ShopRepo.On("GetShopByName").Return(nil, fmt.Errorf("no Shop was Found ,error: %w", gorm.ErrRecordNotFound))
+	TestShop.On("EnqueueSellingHistory").Return(nil)
+	TestShop.On("EnqueueShopOnboarding").Return(nil)
+
+	err := implShop.RecoverOnboarding()
+
+	assert.NoError(t, err)
+	TestShop.AssertNumberOfCalls(t, "EnqueueSellingHistory", 1)
+	TestShop.AssertNumberOfCalls(t, "EnqueueShopOnboarding", 1)
+	ShopRepo.AssertNumberOfCalls(t, "DeleteScrapeCheckpoint", 1)
+	ShopRepo.AssertNumberOfCalls(t, "GetShopByName", 1)
+}
+
+func TestRecoverOnboardingSkipsRequestsClaimedElsewhere(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	claimedRequest := models.ShopRequest{ShopName: "busyShop", Status: "Pending"}
+	claimedRequest.ID = 8
+	checkpoint := models.ScrapeCheckpoint{ShopRequestID: 7, Kind: controllers.OnboardingHistoryJob, ShopID: 3}
+	checkpoint.ID = 1
+
+	ShopRepo.On("GetScrapeCheckpoints").Return([]models.ScrapeCheckpoint{checkpoint}, nil)
+	ShopRepo.On("GetShopRequestsByStatus").Return([]models.ShopRequest{claimedRequest}, nil)
+	ShopRepo.On("ClaimShopRequest", uint(7)).Return(false, nil)
+	ShopRepo.On("ClaimShopRequest", uint(8)).Return(false, nil)
+
+	err := implShop.RecoverOnboarding()
+
+	assert.NoError(t, err)
+	TestShop.AssertNotCalled(t, "EnqueueSellingHistory")
+	TestShop.AssertNotCalled(t, "EnqueueShopOnboarding")
+	ShopRepo.AssertNotCalled(t, "DeleteScrapeCheckpoint")
+	ShopRepo.AssertNotCalled(t, "GetShopByName")
+}
+
+func TestReleaseOnboardingClaims(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, Onboarding: controllers.NewOnboardingQueue()}
+
+	ShopRepo.On("ReleaseShopRequestClaims").Return(nil)
+
+	assert.NoError(t, implShop.ReleaseOnboardingClaims())
+	assert.NotEmpty(t, implShop.OnboardingInstanceID())
+	ShopRepo.AssertNumberOfCalls(t, "ReleaseShopRequestClaims", 1)
+}
+
+func TestRecoverShopRequestWithoutSoldHistoryMarksDone(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRequest := &models.ShopRequest{AccountID: uuid.New(), ShopName: "exampleShop", Status: "Pending"}
+
+	ShopRepo.On("GetShopByName").Return(&models.Shop{Name: "exampleShop"}, nil)
+	TestShop.On("CreateShopRequest").Return(nil)
+
+	err := implShop.RecoverShopRequest(ShopRequest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "done", ShopRequest.Status)
+	TestShop.AssertNotCalled(t, "EnqueueShopOnboarding")
+}
+
+func TestRecoverShopRequestRestartsSellingHistory(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRequest := &models.ShopRequest{AccountID: uuid.New(), ShopName: "exampleShop", Status: "Pending"}
+
+	ShopRepo.On("GetShopByName").Return(&models.Shop{Name: "exampleShop", HasSoldHistory: true, TotalSales: 40}, nil)
+	TestShop.On("EnqueueSellingHistory").Return(nil)
+
+	err := implShop.RecoverShopRequest(ShopRequest)
+
+	assert.NoError(t, err)
+	TestShop.AssertNumberOfCalls(t, "EnqueueSellingHistory", 1)
+}
+
+func TestCreateNewShopRequestCoalescesSameShop(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	ShopRepo.On("GetShopByName").Return(nil, errors.New("no Shop was Found ,error: record not found"))
+	TestShop.On("CreateShopRequest").Return(nil)
+	TestShop.On("EnqueueShopOnboarding").Return(nil)
+
+	router.POST("/create_shop", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", uuid.New())
+	}, implShop.CreateNewShopRequest)
+
+	for _, name := range []string{"CoalescedShop", " coalescedshop "} {
+		body := []byte(fmt.Sprintf(`{"new_shop_name":"%s"}`, name))
+		req, _ := http.NewRequest("POST", "/create_shop", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 2)
+	TestShop.AssertNumberOfCalls(t, "EnqueueShopOnboarding", 1)
+}
+
+func TestResolveCoalescedRequestsFollowsEveryAccount(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	AccountID := uuid.New()
+	Leader := &models.ShopRequest{AccountID: AccountID, ShopName: "ResolvedShop"}
+	Follower := &models.ShopRequest{AccountID: uuid.New(), ShopName: "resolvedshop"}
+	SameAccount := &models.ShopRequest{AccountID: AccountID, ShopName: "ResolvedShop"}
+
+	assert.False(t, controllers.AttachToInFlightShopRequest(Leader))
+	assert.True(t, controllers.AttachToInFlightShopRequest(Follower))
+	assert.True(t, controllers.AttachToInFlightShopRequest(SameAccount))
+
+	TestShop.On("EstablishAccountShopRelation").Return(nil)
+	TestShop.On("CreateShopRequest").Return(nil)
+
+	implShop.ResolveCoalescedRequests(Leader, &models.Shop{Name: "ResolvedShop"})
+
+	TestShop.AssertNumberOfCalls(t, "EstablishAccountShopRelation", 2)
+	TestShop.AssertNumberOfCalls(t, "CreateShopRequest", 2)
+	assert.Equal(t, "done", Follower.Status)
+	assert.Equal(t, "done", SameAccount.Status)
+
+	assert.False(t, controllers.AttachToInFlightShopRequest(Follower))
+	assert.Empty(t, controllers.ReleaseInFlightShopRequest(Follower))
+}
+
+func TestResolveCoalescedRequestsFailsFollowers(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	Leader := &models.ShopRequest{AccountID: uuid.New(), ShopName: "FailedShop"}
+	Follower := &models.ShopRequest{AccountID: uuid.New(), ShopName: "FailedShop"}
+
+	controllers.AttachToInFlightShopRequest(Leader)
+	controllers.AttachToInFlightShopRequest(Follower)
+
+	TestShop.On("CreateShopRequest").Return(nil)
+
+	implShop.ResolveCoalescedRequests(Leader, nil)
+
+	assert.Equal(t, "failed", Follower.Status)
+	TestShop.AssertNotCalled(t, "EstablishAccountShopRelation")
+}
+
+func TestReleaseInFlightShopRequestIgnoresFollower(t *testing.T) {
+
+	Leader := &models.ShopRequest{AccountID: uuid.New(), ShopName: "ReleasedShop"}
+	Follower := &models.ShopRequest{AccountID: uuid.New(), ShopName: "ReleasedShop"}
+
+	controllers.AttachToInFlightShopRequest(Leader)
+	controllers.AttachToInFlightShopRequest(Follower)
+
+	assert.Nil(t, controllers.ReleaseInFlightShopRequest(Follower))
+	assert.Equal(t, []*models.ShopRequest{Follower}, controllers.ReleaseInFlightShopRequest(Leader))
+}
+
+func TestPlanShopMerge(t *testing.T) {
+
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+
+	soldItem := func(ID uint, createdAt time.Time) models.SoldItems {
+		soldItem := models.SoldItems{}
+		soldItem.ID = ID
+		soldItem.CreatedAt = createdAt
+		return soldItem
+	}
+
+	targetItem := models.Item{ListingID: 100, SoldUnits: []models.SoldItems{soldItem(1, day)}}
+	targetItem.ID = 11
+	targetMenu := models.MenuItem{Category: "Rings", SectionID: "555", Items: []models.Item{targetItem}}
+	targetMenu.ID = 21
+	target := &models.Shop{Name: "MyShop", ShopMenu: models.ShopMenu{Menu: []models.MenuItem{targetMenu}}}
+	target.ID = 1
+	target.ShopMenu.ID = 31
+
+	duplicatedItem := models.Item{ListingID: 100, SoldUnits: []models.SoldItems{soldItem(2, day.Add(time.Hour)), soldItem(3, day), soldItem(4, nextDay)}}
+	duplicatedItem.ID = 12
+	newItem := models.Item{ListingID: 200}
+	newItem.ID = 13
+	sourceRings := models.MenuItem{Category: "Rings renamed", SectionID: "555", Items: []models.Item{duplicatedItem, newItem}}
+	sourceRings.ID = 22
+	sourceNecklaces := models.MenuItem{Category: "Necklaces", SectionID: "777"}
+	sourceNecklaces.ID = 23
+	source := &models.Shop{Name: "myshop", ShopMenu: models.ShopMenu{Menu: []models.MenuItem{sourceRings, sourceNecklaces}}}
+	source.ID = 2
+
+	targetSales := []models.DailyShopSales{{ShopID: 1}}
+	targetSales[0].ID = 41
+	targetSales[0].CreatedAt = day
+	sourceSales := []models.DailyShopSales{{ShopID: 2}, {ShopID: 2}}
+	sourceSales[0].ID = 42
+	sourceSales[0].CreatedAt = day.Add(3 * time.Hour)
+	sourceSales[1].ID = 43
+	sourceSales[1].CreatedAt = nextDay
+
+	merge := controllers.PlanShopMerge(target, source, targetSales, sourceSales)
+
+	assert.Equal(t, uint(1), merge.TargetShopID)
+	assert.Equal(t, uint(2), merge.SourceShopID)
+	assert.Equal(t, uint(31), merge.TargetShopMenuID)
+	assert.Equal(t, []uint{23}, merge.MovedMenus)
+	assert.Equal(t, []uint{22}, merge.DroppedMenus)
+	assert.Equal(t, map[uint]uint{22: 21}, merge.MergedMenus)
+	assert.Equal(t, map[uint]uint{13: 21}, merge.MovedItems)
+	assert.Equal(t, map[uint]uint{12: 11}, merge.MergedItems)
+	assert.Equal(t, []uint{2}, merge.DroppedSoldItems)
+	assert.Equal(t, []uint{42}, merge.DroppedDailySales)
+	assert.Equal(t, []uint{43}, merge.MovedDailySales)
+}
+
+func TestPlanDailyReviewsMerge(t *testing.T) {
+
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	targetReviews := []models.DailyShopReviews{{ShopID: 1}}
+	targetReviews[0].ID = 51
+	targetReviews[0].CreatedAt = day
+	sourceReviews := []models.DailyShopReviews{{ShopID: 2}, {ShopID: 2}}
+	sourceReviews[0].ID = 52
+	sourceReviews[0].CreatedAt = day.Add(5 * time.Hour)
+	sourceReviews[1].ID = 53
+	sourceReviews[1].CreatedAt = day.AddDate(0, 0, -1)
+
+	merge := &repository.ShopMerge{TargetShopID: 1, SourceShopID: 2}
+	controllers.PlanDailyReviewsMerge(merge, targetReviews, sourceReviews)
+
+	assert.Equal(t, []uint{52}, merge.DroppedDailyReviews)
+	assert.Equal(t, []uint{53}, merge.MovedDailyReviews)
+}
+
+func TestMergeDuplicateShopsSameShop(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	_, err := implShop.MergeDuplicateShops(1, 1)
+
+	assert.True(t, errors.Is(err, controllers.ErrMergeSameShop))
+	ShopRepo.AssertNotCalled(t, "GetShopWithSoldItemsByShopID", mock.Anything)
+}
+
+func TestMergeDuplicateShopsNotDuplicates(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("LockShop", mock.Anything).Return(true, nil)
+	ShopRepo.On("UnlockShop", mock.Anything).Return(nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(1)).Return(&models.Shop{Name: "MyShop"}, nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(2)).Return(&models.Shop{Name: "OtherShop"}, nil)
+
+	_, err := implShop.MergeDuplicateShops(1, 2)
+
+	assert.True(t, errors.Is(err, controllers.ErrShopsNotDuplicates))
+	ShopRepo.AssertNotCalled(t, "MergeShops", mock.Anything)
+}
+
+func TestMergeDuplicateShopsSuccess(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	target := &models.Shop{Name: "MyShop"}
+	target.ID = 1
+	source := &models.Shop{Name: " myshop"}
+	source.ID = 2
+
+	ShopRepo.On("LockShop", uint(1)).Return(true, nil).Once()
+	ShopRepo.On("LockShop", uint(2)).Return(true, nil).Once()
+	ShopRepo.On("UnlockShop", uint(1)).Return(nil).Once()
+	ShopRepo.On("UnlockShop", uint(2)).Return(nil).Once()
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(1)).Return(target, nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(2)).Return(source, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{}, nil)
+	ShopRepo.On("MergeShops", mock.AnythingOfType("*repository.ShopMerge")).Return(nil)
+
+	merge, err := implShop.MergeDuplicateShops(1, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), merge.TargetShopID)
+	assert.Equal(t, uint(2), merge.SourceShopID)
+	ShopRepo.AssertNumberOfCalls(t, "MergeShops", 1)
+	ShopRepo.AssertNumberOfCalls(t, "LockShop", 2)
+	ShopRepo.AssertNumberOfCalls(t, "UnlockShop", 2)
+}
+
+func TestMergeDuplicateShopsWhileShopIsLocked(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	defer func(wait, retry time.Duration) {
+		controllers.ShopLockWait, controllers.ShopLockRetryInterval = wait, retry
+	}(controllers.ShopLockWait, controllers.ShopLockRetryInterval)
+	controllers.ShopLockWait = 20 * time.Millisecond
+	controllers.ShopLockRetryInterval = 5 * time.Millisecond
+
+	ShopRepo.On("LockShop", uint(1)).Return(true, nil).Once()
+	ShopRepo.On("LockShop", uint(2)).Return(false, nil)
+	ShopRepo.On("UnlockShop", uint(1)).Return(nil).Once()
+
+	_, err := implShop.MergeDuplicateShops(2, 1)
+
+	assert.True(t, errors.Is(err, controllers.ErrShopLocked))
+	ShopRepo.AssertNotCalled(t, "GetShopWithSoldItemsByShopID", mock.Anything)
+	ShopRepo.AssertNotCalled(t, "MergeShops", mock.Anything)
+	ShopRepo.AssertNumberOfCalls(t, "UnlockShop", 1)
+}
+
+func TestMergeDuplicateShopNames(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	target := &models.Shop{Name: "MyShop"}
+	target.ID = 1
+	source := &models.Shop{Name: "myshop "}
+	source.ID = 4
+
+	ShopRepo.On("GetDuplicateShopIDs").Return([][]uint{{1, 4}}, nil)
+	ShopRepo.On("LockShop", mock.Anything).Return(true, nil)
+	ShopRepo.On("UnlockShop", mock.Anything).Return(nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(1)).Return(target, nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(4)).Return(source, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{}, nil)
+	ShopRepo.On("MergeShops", mock.MatchedBy(func(merge *repository.ShopMerge) bool {
+		return merge.TargetShopID == 1 && merge.SourceShopID == 4
+	})).Return(nil)
+	ShopRepo.On("CreateShopNameIndex").Return(nil)
+
+	err := implShop.MergeDuplicateShopNames()
+
+	assert.NoError(t, err)
+	ShopRepo.AssertNumberOfCalls(t, "MergeShops", 1)
+	ShopRepo.AssertNumberOfCalls(t, "CreateShopNameIndex", 1)
+}
+
+func TestMergeDuplicateShopNamesStopsOnMergeError(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("GetDuplicateShopIDs").Return([][]uint{{1, 4}}, nil)
+	ShopRepo.On("LockShop", mock.Anything).Return(true, nil)
+	ShopRepo.On("UnlockShop", mock.Anything).Return(nil)
+	ShopRepo.On("GetShopWithSoldItemsByShopID", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+
+	err := implShop.MergeDuplicateShopNames()
+
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	ShopRepo.AssertNotCalled(t, "CreateShopNameIndex")
+}
+
+func TestHandleMergeShopsInvalidBody(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	router.POST("/admin/shops/merge", implShop.HandleMergeShops)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/merge", bytes.NewBufferString(`{"target_shop_id": 1}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	TestShop.AssertNotCalled(t, "MergeDuplicateShops")
+}
+
+func TestHandleMergeShopsNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	TestShop.On("MergeDuplicateShops").Return(nil, fmt.Errorf("no Shop was Found: %w", gorm.ErrRecordNotFound))
+
+	router.POST("/admin/shops/merge", implShop.HandleMergeShops)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/merge", bytes.NewBufferString(`{"target_shop_id": 1, "source_shop_id": 2}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleMergeShopsNotDuplicates(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	TestShop.On("MergeDuplicateShops").Return(nil, fmt.Errorf("error: %w", controllers.ErrShopsNotDuplicates))
+
+	router.POST("/admin/shops/merge", implShop.HandleMergeShops)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/merge", bytes.NewBufferString(`{"target_shop_id": 1, "source_shop_id": 2}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "shops do not share the same name")
+}
+
+func TestHandleMergeShopsSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	TestShop.On("MergeDuplicateShops").Return(&repository.ShopMerge{
+		TargetShopID:    1,
+		SourceShopID:    2,
+		MergedItems:     map[uint]uint{12: 11},
+		MovedDailySales: []uint{43},
+	}, nil)
+
+	router.POST("/admin/shops/merge", implShop.HandleMergeShops)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/merge", bytes.NewBufferString(`{"target_shop_id": 1, "source_shop_id": 2}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"merged_items":1`)
+	assert.Contains(t, w.Body.String(), `"moved_daily_sales":1`)
+}
+
+func TestCalculateItemStockouts(t *testing.T) {
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := created.AddDate(0, 0, 20)
+
+	change := func(createdAt time.Time, oldAvailable, newAvailable, newItem bool) models.ItemHistoryChange {
+		change := models.ItemHistoryChange{OldAvailable: oldAvailable, NewAvailable: newAvailable, NewItemCreated: newItem}
+		change.CreatedAt = createdAt
+		return change
+	}
+
+	item := models.Item{Name: "Lamp", ListingID: 100, PriceHistory: []models.ItemHistoryChange{
+		change(created, false, true, true),
+		change(created.AddDate(0, 0, 2), true, false, false),
+		change(created.AddDate(0, 0, 5), false, true, false),
+		change(created.AddDate(0, 0, 8), true, true, false),
+		change(created.AddDate(0, 0, 17), true, false, false),
+	}}
+	item.ID = 4
+
+	stockouts := controllers.CalculateItemStockouts(item, now)
+
+	assert.Equal(t, uint(4), stockouts.ItemID)
+	assert.True(t, stockouts.OutOfStock)
+	assert.Len(t, stockouts.Periods, 2)
+	assert.Equal(t, float64(3), stockouts.Periods[0].Days)
+	assert.NotNil(t, stockouts.Periods[0].EndedAt)
+	assert.Equal(t, float64(3), stockouts.Periods[1].Days)
+	assert.Nil(t, stockouts.Periods[1].EndedAt)
+	assert.Equal(t, float64(6), stockouts.TotalDays)
+}
+
+func TestCalculateItemStockoutsCreatedSoldOut(t *testing.T) {
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	item := models.Item{Available: false}
+	item.CreatedAt = created
+
+	stockouts := controllers.CalculateItemStockouts(item, created.Add(36*time.Hour))
+
+	assert.True(t, stockouts.OutOfStock)
+	assert.Len(t, stockouts.Periods, 1)
+	assert.Equal(t, 1.5, stockouts.TotalDays)
+}
+
+func TestGetStockoutsByShopIDSkipsItemsAlwaysAvailable(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	soldOut := models.Item{Available: false}
+	soldOut.ID = 1
+	available := models.Item{Available: true}
+	available.ID = 2
+
+	ShopRepo.On("GetItemsWithHistoryByShopID").Return([]models.Item{soldOut, available}, nil)
+
+	stockouts, err := implShop.GetStockoutsByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, stockouts, 1)
+	assert.Equal(t, uint(1), stockouts[0].ItemID)
+}
+
+func TestHandleGetStockoutsByShopIDFail(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("GetItemsWithHistoryByShopID").Return(nil, errors.New("error while retrieving items"))
+
+	router.GET("/shop/:shopID/stockouts", implShop.HandleGetStockoutsByShopID)
+
+	req, _ := http.NewRequest("GET", "/shop/1/stockouts", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "error while handling stockouts")
+}
+
+func TestGetCategoryTimeline(t *testing.T) {
+
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	renamed := time.Date(2024, 2, 1, 15, 12, 0, 0, time.UTC)
+
+	Menu := models.MenuItem{Category: "wall shelves", SectionID: "46696458", Amount: 46}
+	Menu.ID = 3
+	Shop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{Menu}}}
+
+	change := models.MenuHistoryChange{MenuItemID: 3, SectionID: "46696458", Event: models.MenuRenamed, OldCategory: "shelving", NewCategory: "wall shelves", OldAmount: 45, NewAmount: 46}
+	change.CreatedAt = renamed
+
+	ShopRepo.On("FetchShopByID").Return(Shop, nil)
+	ShopRepo.On("GetMenuHistoryByShopID").Return([]models.MenuHistoryChange{change}, nil)
+
+	timeline, err := implShop.GetCategoryTimeline(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []controllers.CategoryState{{MenuItemID: 3, SectionID: "46696458", Category: "wall shelves", Amount: 46}}, timeline.Categories)
+	assert.Equal(t, []controllers.CategoryChange{{Date: renamed, Event: "renamed", MenuItemID: 3, SectionID: "46696458", OldCategory: "shelving", NewCategory: "wall shelves", OldAmount: 45, NewAmount: 46}}, timeline.Changes)
+}
+
+func TestHandleGetCategoryTimelineShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, fmt.Errorf("no Shop was Found : %w", gorm.ErrRecordNotFound))
+
+	router.GET("/shop/:shopID/categories", implShop.HandleGetCategoryTimeline)
+
+	req, _ := http.NewRequest("GET", "/shop/1/categories", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	ShopRepo.AssertNotCalled(t, "GetMenuHistoryByShopID")
+}
+
+func TestCreateProfileTimelineFiltersByField(t *testing.T) {
+
+	moved := time.Date(2024, 3, 1, 15, 12, 0, 0, time.UTC)
+	location := models.ShopHistoryChange{ShopID: 1, Field: models.ShopFieldLocation, OldValue: "London", NewValue: "Leeds"}
+	location.CreatedAt = moved
+	rating := models.ShopHistoryChange{ShopID: 1, Field: models.ShopFieldRating, OldValue: "4.8", NewValue: "4.9"}
+	rating.CreatedAt = moved
+
+	all := controllers.CreateProfileTimeline([]models.ShopHistoryChange{location, rating}, "")
+	filtered := controllers.CreateProfileTimeline([]models.ShopHistoryChange{location, rating}, models.ShopFieldLocation)
+
+	assert.Len(t, all, 2)
+	assert.Equal(t, []controllers.ProfileChange{{Date: moved, Field: "location", OldValue: "London", NewValue: "Leeds"}}, filtered)
+	assert.Equal(t, []controllers.ProfileChange{}, controllers.CreateProfileTimeline(nil, ""))
+}
+
+func TestHandleGetProfileTimelineSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	change := models.ShopHistoryChange{ShopID: 1, Field: models.ShopFieldOnVacation, OldValue: "false", NewValue: "true"}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetShopHistoryByShopID").Return([]models.ShopHistoryChange{change}, nil)
+
+	router.GET("/shop/:shopID/timeline", implShop.HandleGetProfileTimeline)
+
+	req, _ := http.NewRequest("GET", "/shop/1/timeline?field=on_vacation", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"on_vacation","old_value":"false","new_value":"true"`)
+}
+
+func TestHandleGetProfileTimelineUnknownField(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/:shopID/timeline", implShop.HandleGetProfileTimeline)
+
+	req, _ := http.NewRequest("GET", "/shop/1/timeline?field=name", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown profile field")
+	ShopRepo.AssertNotCalled(t, "GetShopHistoryByShopID")
+}
+
+func TestHandleGetProfileTimelineShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/timeline", implShop.HandleGetProfileTimeline)
+
+	req, _ := http.NewRequest("GET", "/shop/1/timeline", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	ShopRepo.AssertNotCalled(t, "GetShopHistoryByShopID")
+}
+
+func TestIsVacationDay(t *testing.T) {
+
+	started := time.Date(2024, 5, 2, 15, 12, 0, 0, time.UTC)
+	ended := time.Date(2024, 5, 4, 15, 12, 0, 0, time.UTC)
+	Periods := []models.VacationPeriod{{StartedAt: started, EndedAt: &ended}}
+
+	assert.False(t, controllers.IsVacationDay(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Periods))
+	assert.True(t, controllers.IsVacationDay(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), Periods))
+	assert.True(t, controllers.IsVacationDay(time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), Periods))
+	assert.False(t, controllers.IsVacationDay(time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), Periods))
+
+	open := []models.VacationPeriod{{StartedAt: started}}
+	assert.True(t, controllers.IsVacationDay(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), open))
+}
+
+func TestCalculateSalesVelocitySkipsVacationDays(t *testing.T) {
+
+	stats := map[string]controllers.DailySoldStats{
+		"2024-05-01": {TotalSales: 100},
+		"2024-05-02": {TotalSales: 106},
+		"2024-05-03": {TotalSales: 106, OnVacation: true},
+		"2024-05-04": {TotalSales: 106, OnVacation: true},
+		"2024-05-05": {TotalSales: 110},
+	}
+
+	assert.Equal(t, 5.0, controllers.CalculateSalesVelocity(stats))
+	assert.Equal(t, 0.0, controllers.CalculateSalesVelocity(map[string]controllers.DailySoldStats{}))
+}
+
+func TestGetSellingStatsByPeriodMarksVacationDays(t *testing.T) {
+
+	TestShop := &MockedShop{}
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Operations: TestShop, Shop: ShopRepo}
+
+	started := time.Date(2024, 5, 2, 15, 12, 0, 0, time.UTC)
+
+	ShopRepo.On("FetchStatsByPeriod").Return([]models.DailyShopSales{}, nil)
+	TestShop.On("CreateSoldStats").Return(map[string]controllers.DailySoldStats{
+		"2024-05-01": {TotalSales: 100},
+		"2024-05-02": {TotalSales: 100},
+	}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{{StartedAt: started, Message: "Back soon"}}, nil)
+
+	stats, err := implShop.GetSellingStatsByPeriod(2, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.False(t, stats["2024-05-01"].OnVacation)
+	assert.True(t, stats["2024-05-02"].OnVacation)
+}
+
+func TestHandleGetVacationPeriodsSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	started := time.Date(2024, 5, 2, 15, 12, 0, 0, time.UTC)
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{{StartedAt: started, Message: "Back on May 20th"}}, nil)
+
+	router.GET("/shop/:shopID/vacations", implShop.HandleGetVacationPeriods)
+
+	req, _ := http.NewRequest("GET", "/shop/1/vacations", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"started_at":"2024-05-02T15:12:00Z","ended_at":null,"message":"Back on May 20th"`)
+}
+
+func TestHandleGetVacationPeriodsShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/vacations", implShop.HandleGetVacationPeriods)
+
+	req, _ := http.NewRequest("GET", "/shop/1/vacations", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateItemChangesTimeline(t *testing.T) {
+
+	firstChange := time.Date(2024, 6, 1, 15, 12, 0, 0, time.UTC)
+	secondChange := firstChange.AddDate(0, 0, 1)
+
+	renamed := models.ItemAttributeChange{Attribute: models.ItemAttributeName, OldValue: "Oak shelf", NewValue: "Solid oak wall shelf"}
+	renamed.CreatedAt = secondChange
+	discounted := models.ItemAttributeChange{Attribute: models.ItemAttributeDiscount, OldValue: "", NewValue: "(20% off)"}
+	discounted.CreatedAt = firstChange
+
+	shelf := models.Item{Name: "Solid oak wall shelf", ListingID: 100, AttributeHistory: []models.ItemAttributeChange{renamed}}
+	shelf.ID = 1
+	lamp := models.Item{Name: "Lamp", ListingID: 200, AttributeHistory: []models.ItemAttributeChange{discounted}}
+	lamp.ID = 2
+
+	timeline := controllers.CreateItemChangesTimeline([]models.Item{shelf, lamp}, "")
+
+	assert.Equal(t, []controllers.ItemAttributeChangeInfo{
+		{Date: firstChange, ItemID: 2, ListingID: 200, Name: "Lamp", Attribute: "discount_percent", OldValue: "", NewValue: "(20% off)"},
+		{Date: secondChange, ItemID: 1, ListingID: 100, Name: "Solid oak wall shelf", Attribute: "name", OldValue: "Oak shelf", NewValue: "Solid oak wall shelf"},
+	}, timeline)
+
+	titles := controllers.CreateItemChangesTimeline([]models.Item{shelf, lamp}, models.ItemAttributeName)
+	assert.Len(t, titles, 1)
+	assert.Equal(t, uint(1), titles[0].ItemID)
+}
+
+func TestHandleGetItemChangesByShopIDUnknownAttribute(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/:shopID/item_changes", implShop.HandleGetItemChangesByShopID)
+
+	req, _ := http.NewRequest("GET", "/shop/1/item_changes?attribute=price", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown item attribute")
+	ShopRepo.AssertNotCalled(t, "GetItemsWithAttributeHistoryByShopID")
+}
+
+func TestHandleGetItemChangesByShopIDFail(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("GetItemsWithAttributeHistoryByShopID").Return(nil, errors.New("error while retrieving items"))
+
+	router.GET("/shop/:shopID/item_changes", implShop.HandleGetItemChangesByShopID)
+
+	req, _ := http.NewRequest("GET", "/shop/1/item_changes?attribute=name", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "error while handling item changes")
+}
+
+func TestHandleGetListingRankHistorySuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	refreshed := time.Date(2024, 6, 1, 15, 12, 0, 0, time.UTC)
+	featured := models.ListingPosition{ListingID: 1563984521, Position: 2, Featured: true}
+	featured.CreatedAt = refreshed
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetListingPositions").Return([]models.ListingPosition{featured}, nil)
+
+	router.GET("/shop/:shopID/listings/:listingID/positions", implShop.HandleGetListingRankHistory)
+
+	req, _ := http.NewRequest("GET", "/shop/1/listings/1563984521/positions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"positions":[{"date":"2024-06-01T15:12:00Z","position":2,"featured":true}]`)
+}
+
+func TestHandleGetListingRankHistoryInvalidListingID(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/:shopID/listings/:listingID/positions", implShop.HandleGetListingRankHistory)
+
+	req, _ := http.NewRequest("GET", "/shop/1/listings/abc/positions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "failed to get listing id")
+	ShopRepo.AssertNotCalled(t, "GetListingPositions")
+}
+
+func TestHandleGetListingRankHistoryShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/listings/:listingID/positions", implShop.HandleGetListingRankHistory)
+
+	req, _ := http.NewRequest("GET", "/shop/1/listings/1563984521/positions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRankBestSellers(t *testing.T) {
+
+	sales := []repository.ItemSales{
+		{ItemID: 1, Name: "Oak shelf", Category: "Shelves", OriginalPrice: 40, SoldQuantity: 2},
+		{ItemID: 2, Name: "Lamp", Category: "Lamps", OriginalPrice: 0, SoldQuantity: 5},
+		{ItemID: 3, Name: "Pine shelf", Category: "Shelves", OriginalPrice: 60, SoldQuantity: 2},
+		{ItemID: 4, Name: "Coat rack", Category: "Racks", OriginalPrice: 10, SoldQuantity: 1},
+	}
+	// the oak shelf sold once before a raise to 40, so it earned less than the pine shelf.
+	revenues := map[uint]float64{1: 70, 2: 125, 3: 120, 4: 10}
+
+	bestSellers := controllers.RankBestSellers(sales, revenues, "")
+
+	assert.Len(t, bestSellers, 4)
+	assert.Equal(t, []uint{2, 3, 1, 4}, []uint{bestSellers[0].ItemID, bestSellers[1].ItemID, bestSellers[2].ItemID, bestSellers[3].ItemID})
+	assert.Equal(t, []int{1, 2, 3, 4}, []int{bestSellers[0].Rank, bestSellers[1].Rank, bestSellers[2].Rank, bestSellers[3].Rank})
+	assert.Equal(t, 125.0, bestSellers[0].EstimatedRevenue)
+	assert.Equal(t, 50.0, bestSellers[0].SalesShare)
+	assert.Equal(t, 120.0, bestSellers[1].EstimatedRevenue)
+
+	shelves := controllers.RankBestSellers(sales, revenues, "shelves")
+
+	assert.Len(t, shelves, 2)
+	assert.Equal(t, uint(3), shelves[0].ItemID)
+	assert.Equal(t, 1, shelves[0].Rank)
+	assert.Equal(t, 20.0, shelves[0].SalesShare)
+}
+
+func TestRollupRevenues(t *testing.T) {
+	day := func(dayOfMonth int) time.Time {
+		return time.Date(2024, 6, dayOfMonth, 0, 0, 0, 0, time.UTC)
+	}
+	rollup := func(ItemID uint, date time.Time, units int) models.ItemDailyRollup {
+		return models.ItemDailyRollup{ItemID: ItemID, Date: date, SoldUnits: units}
+	}
+
+	raise := models.ItemHistoryChange{OldPrice: 30, NewPrice: 40}
+	raise.CreatedAt = day(3).Add(12 * time.Hour)
+	shelf := models.Item{OriginalPrice: 40, SalePrice: -1, PriceHistory: []models.ItemHistoryChange{raise}}
+	shelf.ID = 1
+	lamp := models.Item{}
+	lamp.ID = 2
+
+	Rollups := []models.ItemDailyRollup{
+		rollup(1, day(1), 5),
+		rollup(1, day(2), 2),
+		rollup(1, day(3), 1),
+		rollup(2, day(3), 1),
+		rollup(1, day(4), 3),
+	}
+
+	revenues := controllers.RollupRevenues(Rollups, []models.Item{shelf, lamp}, day(2), day(4), 25)
+
+	assert.Equal(t, map[uint]float64{1: 100, 2: 25}, revenues, "every day's units are priced at the end of the day")
+}
+
+func TestAddRankChanges(t *testing.T) {
+
+	bestSellers := []controllers.BestSeller{{ItemID: 2, Rank: 1}, {ItemID: 1, Rank: 2}, {ItemID: 5, Rank: 3}}
+	previous := []controllers.BestSeller{{ItemID: 1, Rank: 1}, {ItemID: 3, Rank: 2}, {ItemID: 2, Rank: 3}}
+
+	controllers.AddRankChanges(bestSellers, previous)
+
+	assert.Equal(t, 3, *bestSellers[0].PreviousRank)
+	assert.Equal(t, 2, *bestSellers[0].RankChange)
+	assert.Equal(t, 1, *bestSellers[1].PreviousRank)
+	assert.Equal(t, -1, *bestSellers[1].RankChange)
+	assert.Nil(t, bestSellers[2].PreviousRank)
+	assert.Nil(t, bestSellers[2].RankChange)
+}
+
+func TestHandleGetBestSellersSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+	ShopRepo.On("GetItemSalesByPeriod").Return([]repository.ItemSales{
+		{ItemID: 1, Name: "Oak shelf", Category: "Shelves", OriginalPrice: 40, SoldQuantity: 3},
+		{ItemID: 2, Name: "Lamp", Category: "Lamps", OriginalPrice: 30, SoldQuantity: 1},
+	}, nil).Once()
+	ShopRepo.On("GetItemSalesByPeriod").Return([]repository.ItemSales{
+		{ItemID: 2, Name: "Lamp", Category: "Lamps", OriginalPrice: 30, SoldQuantity: 4},
+		{ItemID: 1, Name: "Oak shelf", Category: "Shelves", OriginalPrice: 40, SoldQuantity: 1},
+	}, nil).Once()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	raise := models.ItemHistoryChange{OldPrice: 30, NewPrice: 40}
+	raise.CreatedAt = today.AddDate(0, 0, -1)
+	shelf := models.Item{OriginalPrice: 40, SalePrice: -1, PriceHistory: []models.ItemHistoryChange{raise}}
+	shelf.ID = 1
+	lamp := models.Item{OriginalPrice: 30, SalePrice: -1}
+	lamp.ID = 2
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{
+		{ItemID: 1, Date: today.AddDate(0, 0, -2), SoldUnits: 2},
+		{ItemID: 1, Date: today, SoldUnits: 1},
+		{ItemID: 2, Date: today, SoldUnits: 1},
+	}, nil)
+	ShopRepo.On("GetItemPriceHistoryByIDs").Return([]models.Item{shelf, lamp}, nil)
+
+	router.GET("/shop/:shopID/bestsellers", implShop.HandleGetBestSellers)
+
+	req, _ := http.NewRequest("GET", "/shop/1/bestsellers?period=lastSevenDays&limit=1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"bestsellers":[{"rank":1,"previous_rank":2,"rank_change":1,"item_id":1`)
+	assert.Contains(t, w.Body.String(), `"sold_quantity":3,"estimated_revenue":100,"sales_share":75}]`)
+	ShopRepo.AssertNumberOfCalls(t, "GetItemSalesByPeriod", 2)
+}
+
+func TestHandleGetBestSellersInvalidParameters(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{name: "unknown period", query: "period=lastWeek", message: "invalid period provided"},
+		{name: "limit is not a number", query: "limit=ten", message: "limit must be a number between 1 and 100"},
+		{name: "limit out of range", query: "limit=0", message: "limit must be a number between 1 and 100"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, router, w := setupMockServer.SetGinTestMode()
+			ShopRepo := &MockedShopRepository{}
+			implShop := controllers.Shop{Shop: ShopRepo}
+
+			router.GET("/shop/:shopID/bestsellers", implShop.HandleGetBestSellers)
+
+			req, _ := http.NewRequest("GET", "/shop/1/bestsellers?"+tc.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.message)
+			ShopRepo.AssertNotCalled(t, "GetItemSalesByPeriod")
+		})
+	}
+}
+
+func TestItemPriceAt(t *testing.T) {
+	created := time.Date(2024, 4, 1, 15, 0, 0, 0, time.UTC)
+	raised := time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC)
+	saleStarted := time.Date(2024, 5, 20, 15, 0, 0, 0, time.UTC)
+	saleEnded := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	newItem := models.ItemHistoryChange{NewItemCreated: true, OldPrice: 0, NewPrice: 20}
+	newItem.CreatedAt = created
+	raise := models.ItemHistoryChange{OldPrice: 20, NewPrice: 25}
+	raise.CreatedAt = raised
+	sale := models.ItemAttributeChange{Attribute: models.ItemAttributeSalePrice, OldValue: "-1", NewValue: "18.75"}
+	sale.CreatedAt = saleStarted
+	saleEnd := models.ItemAttributeChange{Attribute: models.ItemAttributeSalePrice, OldValue: "18.75", NewValue: "-1"}
+	saleEnd.CreatedAt = saleEnded
+
+	item := models.Item{
+		OriginalPrice:    25,
+		SalePrice:        -1,
+		PriceHistory:     []models.ItemHistoryChange{newItem, raise},
+		AttributeHistory: []models.ItemAttributeChange{sale, saleEnd},
+	}
+
+	assert.Equal(t, 20.0, controllers.ItemPriceAt(item, created.AddDate(0, 0, -1)))
+	assert.Equal(t, 20.0, controllers.ItemPriceAt(item, created.AddDate(0, 0, 10)))
+	assert.Equal(t, 25.0, controllers.ItemPriceAt(item, raised.AddDate(0, 0, 1)))
+	assert.Equal(t, 18.75, controllers.ItemPriceAt(item, saleStarted.AddDate(0, 0, 1)))
+	assert.Equal(t, 25.0, controllers.ItemPriceAt(item, saleEnded.AddDate(0, 0, 1)))
+
+	priceDropped := models.ItemHistoryChange{OldPrice: 40, NewPrice: 35}
+	priceDropped.CreatedAt = raised
+	tracked := models.Item{OriginalPrice: 35, SalePrice: -1, PriceHistory: []models.ItemHistoryChange{priceDropped}}
+
+	assert.Equal(t, 40.0, controllers.ItemPriceAt(tracked, created), "the old price is used before the first change")
+
+	tracked.SalePrice = 30
+	assert.Equal(t, 30.0, controllers.ItemPriceAt(tracked, created), "the current sale price is used without sale price changes")
+}
+
+func TestCalculateDailyRevenue(t *testing.T) {
+	firstDay := time.Date(2024, 6, 1, 15, 0, 0, 0, time.Local)
+
+	dailySales := []models.DailyShopSales{
+		{DailyRevenue: 0},
+		{DailyRevenue: 50, Estimated: true},
+		{DailyRevenue: 50},
+		{DailyRevenue: 30},
+	}
+	for i := range dailySales {
+		dailySales[i].ID = uint(i + 1)
+		dailySales[i].CreatedAt = firstDay.AddDate(0, 0, i)
+	}
+
+	soldAt := func(at time.Time) models.SoldItems {
+		soldItem := models.SoldItems{}
+		soldItem.CreatedAt = at
+		return soldItem
+	}
+	// the selling history was scraped on the third day, after the first snapshot.
+	history := soldAt(firstDay.AddDate(0, 0, 2).Add(time.Minute))
+	history.FromHistory = true
+
+	raise := models.ItemHistoryChange{OldPrice: 20, NewPrice: 40}
+	raise.CreatedAt = firstDay.AddDate(0, 0, 3)
+
+	Items := []models.Item{
+		{OriginalPrice: 40, SalePrice: -1, PriceHistory: []models.ItemHistoryChange{raise}, SoldUnits: []models.SoldItems{
+			history,
+			soldAt(firstDay.AddDate(0, 0, 2).Add(time.Minute)),
+			soldAt(firstDay.AddDate(0, 0, 2).Add(time.Minute)),
+			soldAt(firstDay.AddDate(0, 0, 3).Add(time.Minute)),
+		}},
+		{OriginalPrice: 0, SoldUnits: []models.SoldItems{
+			soldAt(firstDay.AddDate(0, 0, 1).Add(time.Minute)),
+			soldAt(firstDay.AddDate(0, 0, 2).Add(time.Minute)),
+		}},
+	}
+
+	changed := controllers.CalculateDailyRevenue(dailySales, Items, 10)
+
+	// the unit saved on the estimated day is shared with the snapshot closing it.
+	assert.Len(t, changed, 3)
+	assert.Equal(t, uint(2), changed[0].ID)
+	assert.Equal(t, 30.0, changed[0].DailyRevenue)
+	assert.Equal(t, uint(3), changed[1].ID)
+	assert.Equal(t, 30.0, changed[1].DailyRevenue)
+	assert.Equal(t, uint(4), changed[2].ID)
+	assert.Equal(t, 40.0, changed[2].DailyRevenue)
+
+	assert.Empty(t, controllers.CalculateDailyRevenue(nil, Items, 10))
+}
+
+func TestHandleRecomputeRevenueSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	firstDay := time.Date(2024, 6, 1, 15, 0, 0, 0, time.Local)
+	firstSnapshot := models.DailyShopSales{}
+	firstSnapshot.ID = 1
+	firstSnapshot.CreatedAt = firstDay
+	snapshot := models.DailyShopSales{DailyRevenue: 99}
+	snapshot.ID = 2
+	snapshot.CreatedAt = firstDay.AddDate(0, 0, 1)
+	soldItem := models.SoldItems{}
+	soldItem.CreatedAt = snapshot.CreatedAt.Add(time.Minute)
+
+	recomputed := snapshot
+	recomputed.DailyRevenue = 15
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(20.0, nil)
+	ShopRepo.On("GetItemsWithPriceHistoryByShopID").Return([]models.Item{{OriginalPrice: 15, SoldUnits: []models.SoldItems{soldItem}}}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{firstSnapshot, snapshot}, nil)
+	ShopRepo.On("UpdateDailyRevenue", []models.DailyShopSales{recomputed}).Return(nil)
+
+	router.POST("/admin/shops/:shopID/recompute_revenue", implShop.HandleRecomputeRevenue)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/1/recompute_revenue", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"updated_days":1`)
+	ShopRepo.AssertExpectations(t)
+}
+
+func TestHandleRecomputeRevenueShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.POST("/admin/shops/:shopID/recompute_revenue", implShop.HandleRecomputeRevenue)
+
+	req, _ := http.NewRequest("POST", "/admin/shops/1/recompute_revenue", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func syntheticSeries(start time.Time, values []float64) []controllers.SeriesPoint {
+	series := []controllers.SeriesPoint{}
+	for i, value := range values {
+		series = append(series, controllers.SeriesPoint{Day: start.AddDate(0, 0, i), Value: value})
+	}
+	return series
+}
+
+func TestFitSeasonalModelConstantSeries(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := make([]float64, 28)
+	for i := range values {
+		values[i] = 5
+	}
+
+	model := controllers.FitSeasonalModel(syntheticSeries(start, values))
+
+	assert.Equal(t, 5.0, model.Level)
+	assert.Equal(t, 0.0, model.Sigma)
+	for _, index := range model.Season {
+		assert.InDelta(t, 0, index, 0.0001)
+	}
+	assert.Equal(t, controllers.ForecastRange{Value: 35, Lower: 35, Upper: 35}, model.Forecast(start.AddDate(0, 0, 28), 7))
+}
+
+func TestFitSeasonalModelWeeklySeasonality(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	weekdayEffect := map[time.Weekday]float64{time.Saturday: 5, time.Sunday: 5}
+	values := make([]float64, 56)
+	for i := range values {
+		effect, ok := weekdayEffect[start.AddDate(0, 0, i).Weekday()]
+		if !ok {
+			effect = -2
+		}
+		values[i] = 10 + effect
+	}
+
+	model := controllers.FitSeasonalModel(syntheticSeries(start, values))
+
+	assert.InDelta(t, 10, model.Level, 0.0001)
+	assert.InDelta(t, 0, model.Sigma, 0.0001)
+	assert.InDelta(t, 5, model.Season[time.Saturday], 0.0001)
+	assert.InDelta(t, 5, model.Season[time.Sunday], 0.0001)
+	assert.InDelta(t, -2, model.Season[time.Wednesday], 0.0001)
+
+	saturday := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	assert.InDelta(t, 15, model.Predict(saturday), 0.0001)
+	assert.InDelta(t, 8, model.Predict(saturday.AddDate(0, 0, 2)), 0.0001)
+	assert.InDelta(t, 70, model.Forecast(saturday, 7).Value, 0.01)
+}
+
+func TestFitSeasonalModelSkipsMissingDays(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	weekdayEffect := map[time.Weekday]float64{time.Saturday: 5, time.Sunday: 5}
+	values := make([]float64, 56)
+	for i := range values {
+		effect, ok := weekdayEffect[start.AddDate(0, 0, i).Weekday()]
+		if !ok {
+			effect = -2
+		}
+		values[i] = 10 + effect
+	}
+
+	series := syntheticSeries(start, values)
+	missingDays := map[int]bool{10: true, 25: true, 52: true}
+	withGaps := []controllers.SeriesPoint{}
+	for i, point := range series {
+		if !missingDays[i] {
+			withGaps = append(withGaps, point)
+		}
+	}
+
+	model := controllers.FitSeasonalModel(withGaps)
+
+	assert.InDelta(t, 10, model.Level, 0.0001)
+	assert.InDelta(t, 0, model.Sigma, 0.0001)
+	assert.InDelta(t, 5, model.Season[time.Saturday], 0.0001)
+	assert.InDelta(t, -2, model.Season[time.Wednesday], 0.0001)
+}
+
+func TestFitSeasonalModelNoisySeries(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	noise := []float64{2, -1, 0, -2, 1, 3, -3, 1, 0, -1}
+	values := make([]float64, 90)
+	for i := range values {
+		values[i] = 20 + noise[i%len(noise)]
+	}
+
+	model := controllers.FitSeasonalModel(syntheticSeries(start, values))
+
+	assert.InDelta(t, 20, model.Level, 2)
+	assert.Greater(t, model.Sigma, 0.0)
+
+	week := model.Forecast(start.AddDate(0, 0, 90), 7)
+	quarter := model.Forecast(start.AddDate(0, 0, 90), 90)
+	assert.InDelta(t, 140, week.Value, 14)
+	assert.Less(t, week.Lower, week.Value)
+	assert.Greater(t, week.Upper, week.Value)
+	assert.Greater(t, quarter.Upper-quarter.Lower, week.Upper-week.Lower)
+}
+
+func TestFitSeasonalModelShortSeries(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	model := controllers.FitSeasonalModel(syntheticSeries(start, []float64{1, 3, 1, 3}))
+
+	assert.Equal(t, 2.0, model.Level)
+	assert.Equal(t, 1.0, model.Sigma)
+	assert.Equal(t, [7]float64{}, model.Season)
+	assert.Equal(t, 0.0, model.Forecast(start, 1).Lower)
+
+	empty := controllers.FitSeasonalModel(nil)
+	assert.Equal(t, controllers.ForecastRange{}, empty.Forecast(start, 7))
+}
+
+func TestShopSalesSeries(t *testing.T) {
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := func(dayOffset, hour, totalSales int, revenue float64) models.DailyShopSales {
+		dailySales := models.DailyShopSales{TotalSales: totalSales, DailyRevenue: revenue}
+		dailySales.CreatedAt = day.AddDate(0, 0, dayOffset).Add(time.Duration(hour) * time.Hour)
+		return dailySales
+	}
+	vacationEnd := day.AddDate(0, 0, 5).Add(time.Hour)
+
+	dailySales := []models.DailyShopSales{
+		snapshot(2, 10, 14, 40),
+		snapshot(0, 10, 10, 0),
+		snapshot(1, 10, 12, 20),
+		snapshot(1, 8, 11, 10),
+		snapshot(4, 10, 20, 60),
+		snapshot(5, 10, 22, 20),
+		snapshot(6, 10, 21, 0),
+	}
+	Periods := []models.VacationPeriod{{StartedAt: day.AddDate(0, 0, 5), EndedAt: &vacationEnd}}
+
+	sales, revenue := controllers.ShopSalesSeries(dailySales, Periods, time.UTC)
+
+	assert.Equal(t, []controllers.SeriesPoint{
+		{Day: day.AddDate(0, 0, 1), Value: 2},
+		{Day: day.AddDate(0, 0, 2), Value: 2},
+		{Day: day.AddDate(0, 0, 6), Value: 0},
+	}, sales)
+	assert.Equal(t, []controllers.SeriesPoint{
+		{Day: day.AddDate(0, 0, 1), Value: 20},
+		{Day: day.AddDate(0, 0, 2), Value: 40},
+		{Day: day.AddDate(0, 0, 6), Value: 0},
+	}, revenue)
+}
+
+func TestItemSalesSeries(t *testing.T) {
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	soldItem := func(dayOffset int) models.SoldItems {
+		sold := models.SoldItems{ItemID: 4}
+		sold.CreatedAt = day.AddDate(0, 0, dayOffset).Add(3 * time.Hour)
+		return sold
+	}
+
+	history := soldItem(2)
+	history.FromHistory = true
+
+	series := controllers.ItemSalesSeries([]models.SoldItems{soldItem(1), soldItem(1), history, soldItem(3), soldItem(9)}, []time.Time{day.AddDate(0, 0, 1), day.AddDate(0, 0, 2), day.AddDate(0, 0, 3)}, time.UTC)
+
+	assert.Equal(t, []controllers.SeriesPoint{
+		{Day: day.AddDate(0, 0, 1), Value: 2},
+		{Day: day.AddDate(0, 0, 2), Value: 0},
+		{Day: day.AddDate(0, 0, 3), Value: 1},
+	}, series)
+}
+
+func TestHandleGetSalesForecastSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	dailySales := []models.DailyShopSales{}
+	SoldItems := []models.SoldItems{}
+	Rollups := []models.ItemDailyRollup{}
+	for i := 19; i >= 0; i-- {
+		snapshot := models.DailyShopSales{TotalSales: 100 - 3*i, DailyRevenue: 30}
+		snapshot.CreatedAt = today.AddDate(0, 0, -i).Add(time.Hour)
+		dailySales = append(dailySales, snapshot)
+
+		soldItem := models.SoldItems{ItemID: 4}
+		soldItem.CreatedAt = snapshot.CreatedAt.Add(-time.Minute)
+		SoldItems = append(SoldItems, soldItem)
+		Rollups = append(Rollups, models.ItemDailyRollup{ItemID: 4, Date: today.AddDate(0, 0, -i), SoldUnits: 1})
+	}
+	// the selling history scraped yesterday neither adds sales nor changes the price.
+	for i := 0; i < 5; i++ {
+		history := models.SoldItems{ItemID: 4, FromHistory: true}
+		history.CreatedAt = today.AddDate(0, 0, -1).Add(2 * time.Hour)
+		SoldItems = append(SoldItems, history)
+	}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("FetchStatsByPeriod").Return(dailySales, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+	ShopRepo.On("GetItemSalesByPeriod").Return([]repository.ItemSales{
+		{ItemID: 4, ListingID: 1563984521, Name: "Lamp", OriginalPrice: 10, SoldQuantity: 20},
+	}, nil)
+	lamp := models.Item{OriginalPrice: 10}
+	lamp.ID = 4
+	ShopRepo.On("GetItemRollupsByPeriod").Return(Rollups, nil)
+	ShopRepo.On("GetItemPriceHistoryByIDs").Return([]models.Item{lamp}, nil)
+	ShopRepo.On("GetSoldItemsByItemIDs").Return(SoldItems, nil)
+
+	router.GET("/shop/stats/:shopID/forecast", implShop.HandleGetSalesForecast)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/forecast", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"history_days":19,"horizons":[{"days":7,"sales":{"value":21,"lower":21,"upper":21},"revenue":{"value":210,"lower":210,"upper":210}}`)
+	assert.Contains(t, w.Body.String(), `{"days":90,"sales":{"value":270,"lower":270,"upper":270}`)
+	assert.Contains(t, w.Body.String(), `"daily":[{"date":"`+today.AddDate(0, 0, 1).Format("2006-01-02")+`","sales":{"value":3,"lower":3,"upper":3}`)
+	assert.Contains(t, w.Body.String(), `"items":[{"item_id":4,"listing_id":1563984521,"name":"Lamp","horizons":[{"days":7,"sales":{"value":7,"lower":7,"upper":7},"revenue":{"value":70,"lower":70,"upper":70}}`)
+}
+
+func TestHandleGetSalesForecastNotEnoughHistory(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("FetchStatsByPeriod").Return([]models.DailyShopSales{{TotalSales: 3}}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+
+	router.GET("/shop/stats/:shopID/forecast", implShop.HandleGetSalesForecast)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/forecast", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), controllers.ErrNotEnoughHistory.Error())
+	ShopRepo.AssertNotCalled(t, "GetItemSalesByPeriod")
+}
+
+func TestHandleGetSalesForecastShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/stats/:shopID/forecast", implShop.HandleGetSalesForecast)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/forecast", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func priceImpactItem(changedAt time.Time) models.Item {
+	item := models.Item{Name: "Oak shelf", ListingID: 1563984521, OriginalPrice: 25}
+	item.ID = 4
+
+	availabilityChange := models.ItemHistoryChange{ItemID: 4, OldPrice: 20, NewPrice: 20, OldAvailable: true}
+	availabilityChange.CreatedAt = changedAt.AddDate(0, 0, -40)
+	priceChange := models.ItemHistoryChange{ItemID: 4, OldPrice: 20, NewPrice: 25}
+	priceChange.CreatedAt = changedAt
+	item.PriceHistory = []models.ItemHistoryChange{availabilityChange, priceChange}
+
+	for day := -30; day < 30; day++ {
+		units := 2
+		if day >= 0 {
+			units = 1
+		}
+		for unit := 0; unit < units; unit++ {
+			soldItem := models.SoldItems{ItemID: 4}
+			soldItem.CreatedAt = changedAt.AddDate(0, 0, day).Add(time.Duration(unit+1) * time.Hour)
+			item.SoldUnits = append(item.SoldUnits, soldItem)
+		}
+	}
+
+	history := models.SoldItems{ItemID: 4, FromHistory: true}
+	history.CreatedAt = changedAt.AddDate(0, 0, 1)
+	item.SoldUnits = append(item.SoldUnits, history)
+	return item
+}
+
+func TestAnalyzeItemPriceImpact(t *testing.T) {
+
+	changedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := priceImpactItem(changedAt)
+
+	impact := controllers.AnalyzeItemPriceImpact(item, nil, time.Time{}, changedAt.AddDate(0, 0, 60))
+
+	assert.Equal(t, uint(4), impact.ItemID)
+	assert.Len(t, impact.Changes, 1)
+
+	change := impact.Changes[0]
+	assert.Equal(t, 25.0, change.PriceChange)
+	assert.Equal(t, 60, change.Before.UnitsSold)
+	assert.Equal(t, 2.0, change.Before.Velocity)
+	assert.Equal(t, 30, change.After.UnitsSold)
+	assert.Equal(t, 1.0, change.After.Velocity)
+	assert.Equal(t, -50.0, *change.VelocityChange)
+	assert.Equal(t, -3.0, *change.Elasticity)
+	assert.Equal(t, -3.16, change.ZScore)
+	assert.True(t, change.Significant)
+	assert.Equal(t, -3.0, *impact.Elasticity)
+}
+
+func TestAnalyzeItemPriceImpactShortWindows(t *testing.T) {
+
+	changedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := priceImpactItem(changedAt)
+
+	impact := controllers.AnalyzeItemPriceImpact(item, nil, changedAt.AddDate(0, 0, -10), changedAt.AddDate(0, 0, 3))
+
+	change := impact.Changes[0]
+	assert.Equal(t, changedAt.AddDate(0, 0, -10), change.Before.From)
+	assert.Equal(t, 10.0, change.Before.Days)
+	assert.Equal(t, 20, change.Before.UnitsSold)
+	assert.Equal(t, 3.0, change.After.Days)
+	assert.Equal(t, 3, change.After.UnitsSold)
+	assert.False(t, change.Significant)
+}
+
+func TestAnalyzeItemPriceImpactWithoutSales(t *testing.T) {
+
+	changedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := priceImpactItem(changedAt)
+	item.SoldUnits = nil
+
+	impact := controllers.AnalyzeItemPriceImpact(item, nil, time.Time{}, changedAt.AddDate(0, 0, 60))
+
+	assert.Nil(t, impact.Changes[0].VelocityChange)
+	assert.Nil(t, impact.Changes[0].Elasticity)
+	assert.Equal(t, 0.0, impact.Changes[0].ZScore)
+	assert.False(t, impact.Changes[0].Significant)
+	assert.Nil(t, impact.Elasticity)
+}
+
+func TestAnalyzeItemPriceImpactLeavesOutVacation(t *testing.T) {
+
+	changedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := priceImpactItem(changedAt)
+
+	var afterSales []models.SoldItems
+	for _, soldItem := range item.SoldUnits {
+		if soldItem.CreatedAt.Before(changedAt) || soldItem.CreatedAt.After(changedAt.AddDate(0, 0, 10)) {
+			afterSales = append(afterSales, soldItem)
+		}
+	}
+	item.SoldUnits = afterSales
+
+	vacationEnd := changedAt.AddDate(0, 0, 10)
+	Periods := []models.VacationPeriod{{StartedAt: changedAt, EndedAt: &vacationEnd}}
+
+	impact := controllers.AnalyzeItemPriceImpact(item, Periods, time.Time{}, changedAt.AddDate(0, 0, 60))
+
+	change := impact.Changes[0]
+	assert.Equal(t, 30.0, change.Before.Days)
+	assert.Equal(t, 2.0, change.Before.Velocity)
+	assert.Equal(t, 20.0, change.After.Days)
+	assert.Equal(t, 20, change.After.UnitsSold)
+	assert.Equal(t, 1.0, change.After.Velocity)
+}
+
+func TestVacationOverlapDays(t *testing.T) {
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10)
+	ended := from.AddDate(0, 0, 2)
+	Periods := []models.VacationPeriod{
+		{StartedAt: from.AddDate(0, 0, -3), EndedAt: &ended},
+		{StartedAt: from.AddDate(0, 0, 8)},
+	}
+
+	assert.Equal(t, 4.0, controllers.VacationOverlapDays(Periods, from, to))
+	assert.Equal(t, 0.0, controllers.VacationOverlapDays(nil, from, to))
+}
+
+func TestAnalyzeShopPriceImpact(t *testing.T) {
+
+	changedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	olderItem := priceImpactItem(changedAt.AddDate(0, 0, -5))
+	olderItem.ID = 5
+	unchangedItem := models.Item{Name: "Lamp", OriginalPrice: 30}
+	newItem := models.ItemHistoryChange{NewItemCreated: true, NewPrice: 30}
+	unchangedItem.PriceHistory = []models.ItemHistoryChange{newItem}
+
+	report := controllers.AnalyzeShopPriceImpact([]models.Item{olderItem, unchangedItem, priceImpactItem(changedAt)}, nil, time.Time{}, changedAt.AddDate(0, 0, 60))
+
+	assert.Equal(t, 2, report.PriceChanges)
+	assert.Equal(t, 2, report.SignificantChanges)
+	assert.Equal(t, -3.0, *report.Elasticity)
+	assert.Len(t, report.Items, 2)
+	assert.Equal(t, uint(4), report.Items[0].ItemID)
+	assert.Equal(t, uint(5), report.Items[1].ItemID)
+}
+
+func TestHandleGetPriceImpactSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	changedAt := time.Now().UTC().AddDate(0, 0, -60)
+	item := priceImpactItem(changedAt)
+	SoldUnits := item.SoldUnits
+	item.SoldUnits = nil
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{item}, nil)
+	ShopRepo.On("GetSoldItemsByShopID").Return(SoldUnits, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+
+	router.GET("/shop/:shopID/price_impact", implShop.HandleGetPriceImpact)
+
+	req, _ := http.NewRequest("GET", "/shop/1/price_impact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"price_impact":{"price_changes":1,"significant_changes":1,"elasticity":-3,"items":[{"item_id":4`)
+	assert.Contains(t, w.Body.String(), `"velocity_change":-50,"elasticity":-3,"z_score":-3.16,"significant":true`)
+}
+
+func TestHandleGetItemPriceImpactSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	changedAt := time.Now().UTC().AddDate(0, 0, -60)
+	item := priceImpactItem(changedAt)
+	SoldUnits := item.SoldUnits
+	item.SoldUnits = nil
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{item}, nil)
+	ShopRepo.On("GetSoldItemsByShopID").Return(SoldUnits, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+
+	router.GET("/shop/:shopID/listings/:listingID/price_impact", implShop.HandleGetItemPriceImpact)
+
+	req, _ := http.NewRequest("GET", "/shop/1/listings/1563984521/price_impact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"price_impact":{"item_id":4,"listing_id":1563984521,"name":"Oak shelf","currency_symbol":"","elasticity":-3`)
+}
+
+func TestHandleGetItemPriceImpactListingNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{priceImpactItem(time.Now())}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetVacationPeriodsByShopID").Return([]models.VacationPeriod{}, nil)
+
+	router.GET("/shop/:shopID/listings/:listingID/price_impact", implShop.HandleGetItemPriceImpact)
+
+	req, _ := http.NewRequest("GET", "/shop/1/listings/42/price_impact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), controllers.ErrListingNotFound.Error())
+}
+
+func TestHandleGetPriceImpactShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/price_impact", implShop.HandleGetPriceImpact)
+
+	req, _ := http.NewRequest("GET", "/shop/1/price_impact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateCategoryStats(t *testing.T) {
+
+	day := func(dayOfMonth, hour int) time.Time {
+		return time.Date(2024, 6, dayOfMonth, hour, 0, 0, 0, time.UTC)
+	}
+	rollup := func(itemID uint, dayOfMonth, soldUnits int) models.ItemDailyRollup {
+		return models.ItemDailyRollup{ShopID: 1, ItemID: itemID, Date: day(dayOfMonth, 0), SoldUnits: soldUnits}
+	}
+
+	Menu := []models.MenuItem{{Category: "Shelves"}, {Category: "Lamps"}, {Category: "Out Of Production"}}
+	for i := range Menu {
+		Menu[i].ID = uint(i + 1)
+	}
+
+	shelf := models.Item{OriginalPrice: 40, Available: true, MenuItemID: 1}
+	shelf.ID = 1
+
+	lamp := models.Item{OriginalPrice: 30, MenuItemID: 3}
+	lamp.ID = 2
+	movedToOutOfProduction := models.ItemHistoryChange{OldPrice: 30, NewPrice: 30, OldAvailable: true, OldMenuItemID: 2, NewMenuItemID: 3}
+	movedToOutOfProduction.CreatedAt = day(9, 12)
+	lamp.PriceHistory = []models.ItemHistoryChange{movedToOutOfProduction}
+
+	newShelf := models.Item{OriginalPrice: 20, Available: true, MenuItemID: 1}
+	newShelf.ID = 3
+	created := models.ItemHistoryChange{NewItemCreated: true, NewPrice: 20, NewAvailable: true, NewMenuItemID: 1}
+	created.CreatedAt = day(9, 10)
+	newShelf.PriceHistory = []models.ItemHistoryChange{created}
+
+	Rollups := []models.ItemDailyRollup{
+		rollup(1, 6, 1), rollup(1, 8, 2), rollup(1, 9, 1), rollup(2, 8, 1), rollup(3, 9, 1),
+		// before the previous period and of an item no longer listed.
+		rollup(1, 1, 5), rollup(4, 8, 3),
+	}
+
+	statsRange := controllers.StatsRange{From: day(8, 0), To: day(10, 0), Granularity: controllers.GranularityDay}
+	stats := controllers.CreateCategoryStats(Menu, []models.Item{lamp, shelf, newShelf}, Rollups, statsRange, day(10, 12), 25)
+
+	assert.Len(t, stats.Categories, 2)
+	shelves, lamps := stats.Categories[0], stats.Categories[1]
+
+	assert.Equal(t, "Shelves", shelves.Category)
+	assert.Equal(t, 4, shelves.Units)
+	assert.Equal(t, 140.0, shelves.Revenue)
+	assert.Equal(t, 35.0, shelves.AveragePrice)
+	assert.Equal(t, 2, shelves.ActiveListings)
+	assert.Equal(t, 80.0, shelves.SalesShare)
+	assert.Equal(t, 1, shelves.PreviousUnits)
+	assert.Equal(t, 40.0, shelves.PreviousRevenue)
+	assert.Equal(t, 300.0, *shelves.UnitsChange)
+	assert.Equal(t, 250.0, *shelves.RevenueChange)
+
+	assert.Equal(t, "Lamps", lamps.Category)
+	assert.Equal(t, 1, lamps.Units)
+	assert.Equal(t, 30.0, lamps.Revenue)
+	assert.Equal(t, 0, lamps.ActiveListings)
+	assert.Equal(t, 1, lamps.OutOfProductionListings)
+	assert.Nil(t, lamps.UnitsChange)
+
+	assert.Len(t, stats.Series, 3)
+	assert.Equal(t, controllers.CategoryStatsDay{Date: "2024-06-08", EndDate: "2024-06-08", Categories: []controllers.CategoryDayStats{
+		{Category: "Shelves", Units: 2, Revenue: 80, AveragePrice: 40, ActiveListings: 1},
+		{Category: "Lamps", Units: 1, Revenue: 30, AveragePrice: 30, ActiveListings: 1},
+	}}, stats.Series[0])
+	assert.Equal(t, controllers.CategoryStatsDay{Date: "2024-06-09", EndDate: "2024-06-09", Categories: []controllers.CategoryDayStats{
+		{Category: "Shelves", Units: 2, Revenue: 60, AveragePrice: 30, ActiveListings: 2},
+		{Category: "Lamps", OutOfProductionListings: 1},
+	}}, stats.Series[1])
+	assert.Equal(t, controllers.CategoryStatsDay{Date: "2024-06-10", EndDate: "2024-06-10", Categories: []controllers.CategoryDayStats{
+		{Category: "Shelves", ActiveListings: 2},
+		{Category: "Lamps", OutOfProductionListings: 1},
+	}}, stats.Series[2])
+
+	statsRange.Granularity = controllers.GranularityWeek
+	weekly := controllers.CreateCategoryStats(Menu, []models.Item{lamp, shelf, newShelf}, Rollups, statsRange, day(10, 12), 25)
+
+	assert.Equal(t, "week", weekly.Granularity)
+	assert.Len(t, weekly.Series, 2)
+	assert.Equal(t, controllers.CategoryStatsDay{Date: "2024-06-08", EndDate: "2024-06-09", Categories: []controllers.CategoryDayStats{
+		{Category: "Shelves", Units: 4, Revenue: 140, AveragePrice: 35, ActiveListings: 2},
+		{Category: "Lamps", Units: 1, Revenue: 30, AveragePrice: 30, OutOfProductionListings: 1},
+	}}, weekly.Series[0])
+	assert.Equal(t, "2024-06-10", weekly.Series[1].Date)
+	assert.Equal(t, stats.Categories, weekly.Categories)
+}
+
+func TestHandleGetCategoryStatsSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	Shelves := models.MenuItem{Category: "Shelves"}
+	Shelves.ID = 1
+	item := models.Item{OriginalPrice: 40, Available: true, MenuItemID: 1}
+	item.ID = 1
+	rollup := models.ItemDailyRollup{ShopID: 1, ItemID: 1, Date: time.Now().UTC().Truncate(24 * time.Hour), SoldUnits: 1}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{Shelves}}}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{item}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{rollup}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+
+	router.GET("/shop/stats/:shopID/:period/categories", implShop.HandleGetCategoryStats)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/lastSevenDays/categories", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"categories":[{"category":"Shelves","units":1,"revenue":40,"average_price":40,"active_listings":1,"out_of_production_listings":0,"sales_share":100`)
+	assert.Equal(t, 7, strings.Count(w.Body.String(), `"date":`))
+}
+
+func TestHandleGetCategoryStatsDateRange(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{}, nil)
+	ShopRepo.On("GetItemRollupsByPeriod").Return([]models.ItemDailyRollup{}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+
+	router.GET("/shop/stats/:shopID/categories", implShop.HandleGetCategoryStats)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/categories?from=2024-06-03&to=2024-06-16&granularity=week", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"from":"2024-06-03","to":"2024-06-16","granularity":"week"`)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), `"date":`))
+}
+
+func TestHandleGetCategoryStatsInvalidPeriod(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/stats/:shopID/:period/categories", implShop.HandleGetCategoryStats)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/lastDecade/categories", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	ShopRepo.AssertNotCalled(t, "FetchShopByID")
+}
+
+func TestHandleGetCategoryStatsShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/stats/:shopID/:period/categories", implShop.HandleGetCategoryStats)
+
+	req, _ := http.NewRequest("GET", "/shop/stats/1/lastSevenDays/categories", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestParseShopIDs(t *testing.T) {
+
+	ShopIDs, err := controllers.ParseShopIDs("3, 1,3,2")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{3, 1, 2}, ShopIDs)
+
+	for _, ids := range []string{"", "1,abc", "0", "1,2,3,4,5,6,7,8,9,10,11"} {
+		_, err := controllers.ParseShopIDs(ids)
+		assert.ErrorIs(t, err, controllers.ErrInvalidShopIDs, ids)
+	}
+}
+
+func TestCreateComparedShop(t *testing.T) {
+
+	day := func(dayOfMonth int) time.Time {
+		return time.Date(2024, 6, dayOfMonth, 0, 0, 0, 0, time.UTC)
+	}
+	snapshot := func(dayOfMonth, totalSales, admirers int, revenue float64) models.DailyShopSales {
+		sales := models.DailyShopSales{TotalSales: totalSales, Admirers: admirers, DailyRevenue: revenue}
+		sales.CreatedAt = day(dayOfMonth).Add(10 * time.Hour)
+		return sales
+	}
+	reviews := func(dayOfMonth int, rating float64) models.DailyShopReviews {
+		dailyReviews := models.DailyShopReviews{ShopRating: rating}
+		dailyReviews.CreatedAt = day(dayOfMonth).Add(11 * time.Hour)
+		return dailyReviews
+	}
+	intPointer := func(value int) *int { return &value }
+	floatPointer := func(value float64) *float64 { return &value }
+
+	Shop := &models.Shop{Name: "ExampleShop", Admirers: 56}
+	Shop.ID = 7
+	dailySales := []models.DailyShopSales{snapshot(10, 110, 55, 70), snapshot(7, 100, 50, 20), snapshot(8, 103, 52, 30)}
+	dailyReviews := []models.DailyShopReviews{reviews(7, 4.5), reviews(10, 4.8)}
+
+	compared := controllers.CreateComparedShop(Shop, dailySales, dailyReviews, controllers.StatsRange{From: day(8), To: day(10), Granularity: controllers.GranularityDay})
+
+	assert.Equal(t, uint(7), compared.ShopID)
+	assert.Equal(t, 10, compared.KPIs.Sales)
+	assert.Equal(t, 100.0, compared.KPIs.Revenue)
+	assert.Equal(t, 55, compared.KPIs.Admirers)
+	assert.Equal(t, 5, compared.KPIs.AdmirersGrowth)
+	assert.Equal(t, 10.0, *compared.KPIs.AdmirersGrowthRate)
+	assert.Equal(t, 4.8, compared.KPIs.Rating)
+	assert.Equal(t, 0.3, compared.KPIs.RatingChange)
+
+	assert.Equal(t, []*int{intPointer(3), nil, nil}, compared.Series.Sales)
+	assert.Equal(t, []*float64{floatPointer(30), nil, floatPointer(70)}, compared.Series.Revenue)
+	assert.Equal(t, []*int{intPointer(52), nil, intPointer(55)}, compared.Series.Admirers)
+	assert.Equal(t, []*float64{nil, nil, floatPointer(4.8)}, compared.Series.Rating)
+}
+
+func TestCreateComparedShopByWeek(t *testing.T) {
+
+	day := func(dayOfMonth int) time.Time {
+		return time.Date(2024, 6, dayOfMonth, 0, 0, 0, 0, time.UTC)
+	}
+	snapshot := func(dayOfMonth, totalSales, admirers int, revenue float64) models.DailyShopSales {
+		sales := models.DailyShopSales{TotalSales: totalSales, Admirers: admirers, DailyRevenue: revenue}
+		sales.CreatedAt = day(dayOfMonth).Add(10 * time.Hour)
+		return sales
+	}
+	intPointer := func(value int) *int { return &value }
+	floatPointer := func(value float64) *float64 { return &value }
+
+	Shop := &models.Shop{Name: "ExampleShop"}
+	dailySales := []models.DailyShopSales{snapshot(2, 100, 50, 0), snapshot(3, 102, 51, 20), snapshot(4, 105, 53, 30), snapshot(5, 105, 53, 0), snapshot(10, 111, 60, 60), snapshot(11, 112, 61, 10)}
+	statsRange := controllers.StatsRange{From: day(3), To: day(16), Granularity: controllers.GranularityWeek}
+
+	compared := controllers.CreateComparedShop(Shop, dailySales, nil, statsRange)
+
+	assert.Equal(t, []time.Time{day(3), day(10)}, controllers.ComparisonDates(statsRange))
+	assert.Equal(t, 12, compared.KPIs.Sales)
+	assert.Equal(t, []*int{intPointer(5), intPointer(1)}, compared.Series.Sales)
+	assert.Equal(t, []*float64{floatPointer(50), floatPointer(70)}, compared.Series.Revenue)
+	assert.Equal(t, []*int{intPointer(53), intPointer(61)}, compared.Series.Admirers)
+}
+
+func TestCreateComparedShopWithoutSnapshots(t *testing.T) {
+
+	Shop := &models.Shop{Admirers: 12, Reviews: models.Reviews{ShopRating: 4.9}}
+	statsRange := controllers.StatsRange{From: time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC), Granularity: controllers.GranularityDay}
+
+	compared := controllers.CreateComparedShop(Shop, nil, nil, statsRange)
+
+	assert.Equal(t, 12, compared.KPIs.Admirers)
+	assert.Equal(t, 4.9, compared.KPIs.Rating)
+	assert.Nil(t, compared.KPIs.AdmirersGrowthRate)
+	assert.Equal(t, []*int{nil, nil}, compared.Series.Sales)
+}
+
+func TestHandleCompareShopsSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo, Operations: TestShop}
+
+	currentUserUUID := uuid.New()
+	first, second := models.Shop{Name: "OurShop"}, models.Shop{Name: "Competitor"}
+	first.ID, second.ID = 1, 2
+
+	UserRepo.On("GetAccountWithShops").Return(&models.Account{ShopsFollowing: []models.Shop{first, second}}, nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{}, nil)
+	ShopRepo.On("FetchShopByID").Return(&first, nil).Once()
+	ShopRepo.On("FetchShopByID").Return(&second, nil).Once()
+	ShopRepo.On("FetchStatsByPeriod").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("FetchReviewsStatsByPeriod").Return([]models.DailyShopReviews{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+	TestShop.On("GetItemsByShopID").Return([]models.Item{{Available: true}, {Available: false}}, nil)
+
+	router.GET("/shop/compare", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, implShop.HandleCompareShops)
+
+	req, _ := http.NewRequest("GET", "/shop/compare?ids=1,2&period=lastSevenDays", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"sales":[null,null,null,null,null,null,null]`)
+	assert.Contains(t, w.Body.String(), `"shops":[{"shop_id":1,"shop_name":"OurShop","kpis":{"sales":0,"revenue":0,"admirers":0,"admirers_growth":0,"admirers_growth_rate":null,"average_price":25,"item_count":1`)
+	assert.Contains(t, w.Body.String(), `{"shop_id":2,"shop_name":"Competitor"`)
+	ShopRepo.AssertNumberOfCalls(t, "FetchShopByID", 2)
+}
+
+func TestHandleCompareShopsNotFollowed(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	UserRepo := &MockedUserRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo, User: UserRepo}
+
+	followed := models.Shop{}
+	followed.ID = 1
+	UserRepo.On("GetAccountWithShops").Return(&models.Account{ShopsFollowing: []models.Shop{followed}}, nil)
+	UserRepo.On("GetAccountByID").Return(&models.Account{}, nil)
+
+	router.GET("/shop/compare", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", uuid.New())
+	}, implShop.HandleCompareShops)
+
+	req, _ := http.NewRequest("GET", "/shop/compare?ids=1,2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "no permission")
+	ShopRepo.AssertNotCalled(t, "FetchShopByID")
+}
+
+func TestHandleCompareShopsInvalidParameters(t *testing.T) {
+
+	for _, query := range []string{"", "?ids=1,x", "?ids=1,2&period=lastDecade", "?ids=1,2&from=2024-06-03&granularity=year"} {
+		_, router, w := setupMockServer.SetGinTestMode()
+		UserRepo := &MockedUserRepository{}
+		implShop := controllers.Shop{User: UserRepo}
+
+		UserRepo.On("GetAccountByID").Return(&models.Account{}, nil)
+
+		router.GET("/shop/compare", func(ctx *gin.Context) {
+			ctx.Set("currentUserUUID", uuid.New())
+		}, implShop.HandleCompareShops)
+
+		req, _ := http.NewRequest("GET", "/shop/compare"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestParseStatsRangePeriod(t *testing.T) {
+
+	now := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+
+	statsRange, err := controllers.ParseStatsRange("lastSevenDays", "", "", controllers.GranularityDay, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC), statsRange.From)
+	assert.Equal(t, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), statsRange.To)
+	assert.Equal(t, 7, statsRange.Days())
+	assert.Equal(t, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), statsRange.Previous().From)
+	assert.Equal(t, time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC), statsRange.Previous().To)
+}
+
+func TestParseStatsRangeCustom(t *testing.T) {
+
+	now := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+
+	statsRange, err := controllers.ParseStatsRange("", "2024-05-01", "2024-06-30", controllers.GranularityWeek, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), statsRange.From)
+	assert.Equal(t, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), statsRange.To)
+	assert.Equal(t, controllers.GranularityWeek, statsRange.Granularity)
+
+	statsRange, err = controllers.ParseStatsRange("", "2024-05-01", "2024-05-03", controllers.GranularityDay, now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), statsRange.To)
+}
+
+func TestParseStatsRangeInvalid(t *testing.T) {
+
+	now := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		period, from, to, granularity string
+		err                           error
+	}{
+		{"InvalidPeriod", "", "", controllers.GranularityDay, controllers.ErrInvalidPeriod},
+		{"", "", "", controllers.GranularityDay, controllers.ErrInvalidDateRange},
+		{"", "01-05-2024", "", controllers.GranularityDay, controllers.ErrInvalidDateRange},
+		{"", "2024-05-01", "tomorrow", controllers.GranularityDay, controllers.ErrInvalidDateRange},
+		{"", "2024-05-10", "2024-05-01", controllers.GranularityDay, controllers.ErrInvalidDateRange},
+		{"", "2024-05-16", "", controllers.GranularityDay, controllers.ErrInvalidDateRange},
+		{"", "2020-01-01", "", controllers.GranularityDay, controllers.ErrStatsRangeTooLong},
+		{"lastSevenDays", "", "", "year", controllers.ErrInvalidGranularity},
+	}
+
+	for _, tc := range tests {
+		_, err := controllers.ParseStatsRange(tc.period, tc.from, tc.to, tc.granularity, now)
+		assert.ErrorIs(t, err, tc.err)
+	}
+}
+
+func TestCreateStatsReportWeeks(t *testing.T) {
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityWeek,
+	}
+	stats := map[string]controllers.DailySoldStats{
+		"2024-04-30": {TotalSales: 10, DailyRevenue: 15},
+		"2024-05-01": {TotalSales: 12, DailyRevenue: 20, Items: []models.Item{{Name: "a"}}},
+		"2024-05-03": {TotalSales: 15, DailyRevenue: 30, Estimated: true},
+		"2024-05-06": {ShopRating: 4.8, ReviewsCount: 40, NewReviews: 2, RatingChange: -0.1},
+		"2024-05-07": {TotalSales: 20, DailyRevenue: 50, OnVacation: true},
+		"2024-05-15": {TotalSales: 30, DailyRevenue: 90},
+	}
+
+	report := controllers.CreateStatsReport(stats, statsRange, false)
+
+	assert.Equal(t, "week", report.Granularity)
+	assert.Equal(t, "2024-05-01", report.From)
+	assert.Equal(t, "2024-05-14", report.To)
+	assert.Len(t, report.Stats, 2)
+
+	assert.Equal(t, "2024-05-01", report.Stats[0].Date)
+	assert.Equal(t, "2024-05-05", report.Stats[0].EndDate)
+	assert.Equal(t, 5, report.Stats[0].Sales)
+	assert.Equal(t, 15, report.Stats[0].TotalSales)
+	assert.Equal(t, 50.0, report.Stats[0].Revenue)
+	assert.True(t, report.Stats[0].Estimated)
+	assert.Len(t, report.Stats[0].Items, 1)
+
+	assert.Equal(t, "2024-05-06", report.Stats[1].Date)
+	assert.Equal(t, "2024-05-12", report.Stats[1].EndDate)
+	assert.Equal(t, 5, report.Stats[1].Sales)
+	assert.Equal(t, 20, report.Stats[1].TotalSales)
+	assert.Equal(t, 2, report.Stats[1].NewReviews)
+	assert.Equal(t, 4.8, report.Stats[1].ShopRating)
+	assert.Equal(t, 1, report.Stats[1].VacationDays)
+
+	assert.Equal(t, 10, report.Sales)
+	assert.Equal(t, 100.0, report.Revenue)
+	assert.Equal(t, 2, report.NewReviews)
+	assert.Nil(t, report.Previous)
+	assert.Nil(t, report.Comparison)
+}
+
+func TestCreateStatsReportMonthsCompare(t *testing.T) {
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityMonth,
+	}
+	stats := map[string]controllers.DailySoldStats{
+		"2024-03-30": {TotalSales: 5},
+		"2024-04-10": {TotalSales: 8, DailyRevenue: 30},
+		"2024-04-30": {TotalSales: 10, DailyRevenue: 20},
+		"2024-05-10": {TotalSales: 16, DailyRevenue: 60},
+		"2024-05-20": {TotalSales: 18, DailyRevenue: 20},
+	}
+
+	report := controllers.CreateStatsReport(stats, statsRange, true)
+
+	assert.Len(t, report.Stats, 1)
+	assert.Equal(t, "2024-05-01", report.Stats[0].Date)
+	assert.Equal(t, "2024-05-31", report.Stats[0].EndDate)
+	assert.Equal(t, 8, report.Sales)
+	assert.Equal(t, 80.0, report.Revenue)
+
+	assert.NotNil(t, report.Previous)
+	assert.Equal(t, "2024-03-31", report.Previous.From)
+	assert.Equal(t, "2024-04-30", report.Previous.To)
+	assert.Len(t, report.Previous.Stats, 1)
+	assert.Equal(t, "2024-04-01", report.Previous.Stats[0].Date)
+	assert.Equal(t, 5, report.Previous.Sales)
+	assert.Equal(t, 50.0, report.Previous.Revenue)
+
+	assert.Equal(t, 60.0, *report.Comparison.SalesChange)
+	assert.Equal(t, 60.0, *report.Comparison.RevenueChange)
+	assert.Nil(t, report.Comparison.NewReviewsChange)
+}
+
+func TestProcessStatsRequestCustomRange(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats := map[string]controllers.DailySoldStats{
+		today.AddDate(0, 0, -3).Format("2006-01-02"): {TotalSales: 10},
+		today.AddDate(0, 0, -1).Format("2006-01-02"): {TotalSales: 14, DailyRevenue: 40},
+	}
+	TestShop.On("GetSellingStatsByPeriod").Return(stats, nil)
+
+	router.GET("/stats/:shopID", func(ctx *gin.Context) {
+		implShop.ProcessStatsRequest(ctx)
+	})
+
+	route := fmt.Sprintf("/stats/2?from=%s&granularity=week&compare=true", today.AddDate(0, 0, -2).Format("2006-01-02"))
+	req, _ := http.NewRequest("GET", route, nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	TestShop.AssertNumberOfCalls(t, "GetSellingStatsByPeriod", 1)
+
+	report := controllers.StatsReport{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "week", report.Granularity)
+	assert.Equal(t, today.Format("2006-01-02"), report.To)
+	assert.Equal(t, 4, report.Sales)
+	assert.Equal(t, 40.0, report.Revenue)
+	assert.NotNil(t, report.Previous)
+	assert.NotNil(t, report.Comparison)
+}
+
+func TestProcessStatsRequestInvalidRange(t *testing.T) {
+
+	_, router, _ := setupMockServer.SetGinTestMode()
+
+	TestShop := &MockedShop{}
+	implShop := controllers.Shop{Operations: TestShop}
+
+	router.GET("/stats/:shopID", func(ctx *gin.Context) {
+		implShop.ProcessStatsRequest(ctx)
+	})
+
+	for _, query := range []string{"", "from=2024-05-10&to=2024-05-01", "from=2024-05-01&granularity=year", "from=2024-05-01&compare=maybe"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stats/2?"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	TestShop.AssertNumberOfCalls(t, "GetSellingStatsByPeriod", 0)
+}
+
+// at is 10 o'clock UTC on a day of 2024, when the lifecycle and promotion tests place their changes and sales.
+func at(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 10, 0, 0, 0, time.UTC)
+}
+
+// salePrice is a change of an item's sale price saved at changedAt.
+func salePrice(changedAt time.Time, oldValue, newValue string) models.ItemAttributeChange {
+	change := models.ItemAttributeChange{Attribute: models.ItemAttributeSalePrice, OldValue: oldValue, NewValue: newValue}
+	change.CreatedAt = changedAt
+	return change
+}
+
+// soldAt is a tracked sold unit for every time.
+func soldAt(times ...time.Time) []models.SoldItems {
+	soldUnits := []models.SoldItems{}
+	for _, createdAt := range times {
+		soldItem := models.SoldItems{}
+		soldItem.CreatedAt = createdAt
+		soldUnits = append(soldUnits, soldItem)
+	}
+	return soldUnits
+}
+
+func TestCreateListingLifecycleReport(t *testing.T) {
+
+	change := func(createdAt time.Time, created, oldAvailable, newAvailable bool, oldMenu, newMenu uint, price float64) models.ItemHistoryChange {
+		change := models.ItemHistoryChange{NewItemCreated: created, OldPrice: price, NewPrice: price, OldAvailable: oldAvailable, NewAvailable: newAvailable, OldMenuItemID: oldMenu, NewMenuItemID: newMenu}
+		change.CreatedAt = createdAt
+		return change
+	}
+
+	Shelves := models.MenuItem{Category: "Shelves"}
+	Shelves.ID = 1
+	OutOfProduction := models.MenuItem{Category: controllers.OutOfProductionCategory}
+	OutOfProduction.ID = 9
+
+	newItem := models.Item{OriginalPrice: 20, Available: true, MenuItemID: 1}
+	newItem.PriceHistory = []models.ItemHistoryChange{change(at(6, 3), true, false, true, 0, 1, 20)}
+	newItem.SoldUnits = soldAt(at(6, 4), at(6, 5), at(6, 20))
+	newItem.SoldUnits[0].FromHistory = true
+
+	discontinuedItem := models.Item{OriginalPrice: 30, Available: false, MenuItemID: 9}
+	discontinuedItem.PriceHistory = []models.ItemHistoryChange{
+		change(at(5, 10), true, false, true, 0, 1, 30),
+		change(at(6, 12), false, true, false, 1, 9, 30),
+	}
+	discontinuedItem.SoldUnits = soldAt(at(6, 1))
+
+	onboardedItem := models.Item{OriginalPrice: 50, Available: true, MenuItemID: 1}
+	onboardedItem.SoldUnits = soldAt(at(6, 2), at(6, 10))
+	onboardedItem.SoldUnits[0].FromHistory = true
+
+	unsoldItem := models.Item{OriginalPrice: 10, Available: true, MenuItemID: 1}
+	unsoldItem.PriceHistory = []models.ItemHistoryChange{change(at(6, 25), true, false, true, 0, 1, 10)}
+
+	onboardedDiscontinued := models.Item{OriginalPrice: 15, Available: false, MenuItemID: 9}
+	onboardedDiscontinued.PriceHistory = []models.ItemHistoryChange{change(at(6, 15), false, true, false, 1, 9, 15)}
+
+	relistedItem := models.Item{OriginalPrice: 15, Available: true, MenuItemID: 1}
+	relistedItem.PriceHistory = []models.ItemHistoryChange{
+		change(at(6, 5), false, true, false, 1, 9, 15),
+		change(at(6, 8), false, false, true, 9, 1, 15),
+	}
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityWeek,
+	}
+	Items := []models.Item{newItem, discontinuedItem, onboardedItem, unsoldItem, onboardedDiscontinued, relistedItem}
+
+	report := controllers.CreateListingLifecycleReport([]models.MenuItem{Shelves, OutOfProduction}, Items, statsRange, at(7, 1), 25)
+
+	summary := report.Summary
+	assert.Equal(t, 2, summary.NewListings)
+	assert.Equal(t, 2, summary.DiscontinuedListings)
+	assert.Equal(t, 4, summary.ActiveListings)
+	assert.Equal(t, 1, summary.MeasuredLifetimes)
+	assert.Equal(t, 33.0, *summary.AverageLifetimeDays)
+	assert.Equal(t, 2.0, *summary.AverageDaysToFirstSale)
+	assert.Equal(t, 2.0, *summary.MedianDaysToFirstSale)
+	assert.Equal(t, 1, summary.SoldNewListings)
+	assert.Equal(t, 1, summary.UnsoldNewListings)
+	assert.Equal(t, 120.0, summary.Revenue)
+	assert.Equal(t, 70.0, summary.YoungListingsRevenue)
+	assert.Equal(t, 58.33, *summary.YoungListingsRevenueShare)
+
+	assert.Len(t, report.Series, 5)
+	assert.Equal(t, controllers.ListingLifecycleBucket{Date: "2024-06-01", EndDate: "2024-06-02"}, report.Series[0])
+	assert.Equal(t, controllers.ListingLifecycleBucket{Date: "2024-06-03", EndDate: "2024-06-09", NewListings: 1}, report.Series[1])
+	assert.Equal(t, controllers.ListingLifecycleBucket{Date: "2024-06-10", EndDate: "2024-06-16", DiscontinuedListings: 2}, report.Series[2])
+	assert.Equal(t, controllers.ListingLifecycleBucket{Date: "2024-06-24", EndDate: "2024-06-30", NewListings: 1}, report.Series[4])
+}
+
+func TestCreateListingLifecycleReportNoListings(t *testing.T) {
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityDay,
+	}
+
+	report := controllers.CreateListingLifecycleReport(nil, nil, statsRange, time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC), 0)
+
+	assert.Len(t, report.Series, 3)
+	assert.Nil(t, report.Summary.AverageLifetimeDays)
+	assert.Nil(t, report.Summary.AverageDaysToFirstSale)
+	assert.Nil(t, report.Summary.YoungListingsRevenueShare)
+}
+
+func TestHandleGetListingLifecycleSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	item := models.Item{OriginalPrice: 40, Available: true, MenuItemID: 1}
+	created := models.ItemHistoryChange{NewItemCreated: true, NewPrice: 40, NewAvailable: true, NewMenuItemID: 1}
+	created.CreatedAt = time.Now().Add(-48 * time.Hour)
+	item.PriceHistory = []models.ItemHistoryChange{created}
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{item}, nil)
+	ShopRepo.On("GetSoldItemsByShopID").Return([]models.SoldItems{}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+
+	router.GET("/shop/:shopID/lifecycle", implShop.HandleGetListingLifecycle)
+
+	req, _ := http.NewRequest("GET", "/shop/1/lifecycle?period=lastSevenDays&granularity=day", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"granularity":"day"`)
+	assert.Contains(t, w.Body.String(), `"new_listings":1,"discontinued_listings":0,"active_listings":1`)
+	assert.Equal(t, 7, strings.Count(w.Body.String(), `"date":`))
+}
+
+func TestHandleGetListingLifecycleInvalidRange(t *testing.T) {
+
+	_, router, _ := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/:shopID/lifecycle", implShop.HandleGetListingLifecycle)
+
+	for _, query := range []string{"period=lastDecade", "granularity=year", "from=2024-06-10&to=2024-06-01"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/shop/1/lifecycle?"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	ShopRepo.AssertNotCalled(t, "FetchShopByID")
+}
+
+func TestHandleGetListingLifecycleShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/lifecycle", implShop.HandleGetListingLifecycle)
+
+	req, _ := http.NewRequest("GET", "/shop/1/lifecycle", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestItemPromotions(t *testing.T) {
+
+	item := models.Item{Name: "Oak shelf", ListingID: 100, OriginalPrice: 20, SalePrice: 12, Available: false}
+	item.ID = 1
+	item.CreatedAt = at(5, 1)
+	takenOff := models.ItemHistoryChange{OldPrice: 20, NewPrice: 20, OldAvailable: true, NewAvailable: false}
+	takenOff.CreatedAt = at(6, 20)
+	item.PriceHistory = []models.ItemHistoryChange{takenOff}
+	item.AttributeHistory = []models.ItemAttributeChange{
+		salePrice(at(6, 5), "15", "-1"),
+		salePrice(at(6, 10), "-1", "12"),
+	}
+
+	promotions := controllers.ItemPromotions(item)
+
+	assert.Len(t, promotions, 2)
+	assert.Equal(t, at(5, 1), promotions[0].StartedAt)
+	assert.Equal(t, at(6, 5), *promotions[0].EndedAt)
+	assert.True(t, promotions[0].StartedBeforeTracking)
+	assert.Equal(t, 15.0, promotions[0].SalePrice)
+	assert.Equal(t, 25.0, promotions[0].DiscountPercent)
+	assert.Equal(t, at(6, 10), promotions[1].StartedAt)
+	assert.Equal(t, at(6, 20), *promotions[1].EndedAt)
+	assert.False(t, promotions[1].StartedBeforeTracking)
+	assert.Equal(t, 40.0, promotions[1].DiscountPercent)
+}
+
+func TestCreatePromotionReport(t *testing.T) {
+
+	lamp := models.Item{Name: "Lamp", OriginalPrice: 20, SalePrice: -1, Available: true}
+	lamp.ID = 1
+	lamp.CreatedAt = at(4, 1)
+	lamp.AttributeHistory = []models.ItemAttributeChange{salePrice(at(6, 10), "-1", "15"), salePrice(at(6, 20), "15", "-1")}
+	lamp.SoldUnits = soldAt(at(5, 15), at(5, 30), at(6, 5), at(6, 12), at(6, 14), at(6, 18))
+
+	vase := models.Item{Name: "Vase", OriginalPrice: 10, SalePrice: 0, Available: true}
+	vase.ID = 2
+	vase.CreatedAt = at(4, 1)
+	vase.AttributeHistory = []models.ItemAttributeChange{salePrice(at(6, 15), "0", "8"), salePrice(at(6, 25), "8", "0")}
+	vase.SoldUnits = soldAt(at(6, 16), at(6, 22), at(6, 24), at(6, 17))
+	// scraped from the selling history during the promotion, not sold then.
+	vase.SoldUnits[3].FromHistory = true
+
+	shelf := models.Item{Name: "Shelf", OriginalPrice: 30, SalePrice: -1, Available: true}
+	shelf.ID = 3
+	shelf.CreatedAt = at(4, 1)
+	shelf.AttributeHistory = []models.ItemAttributeChange{salePrice(at(5, 24), "-1", "25"), salePrice(at(5, 27), "25", "-1")}
+	shelf.SoldUnits = soldAt(at(4, 20), at(5, 20), at(6, 1), at(6, 8), at(6, 20))
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityDay,
+	}
+
+	report := controllers.CreatePromotionReport([]models.Item{lamp, vase, shelf}, at(5, 1), statsRange, at(7, 1), 25)
+
+	assert.Len(t, report.Promotions, 1)
+	promotion := report.Promotions[0]
+	assert.Equal(t, at(6, 10), promotion.StartedAt)
+	assert.Equal(t, at(6, 25), *promotion.EndedAt)
+	assert.Equal(t, 15.0, promotion.Days)
+	assert.Equal(t, 2, promotion.ItemsCount)
+	assert.Equal(t, 22.5, promotion.AverageDiscountPercent)
+	assert.Equal(t, 25.0, promotion.MaxDiscountPercent)
+	assert.Equal(t, 7, promotion.Sales)
+	assert.Equal(t, 99.0, promotion.Revenue)
+	assert.Equal(t, 0.47, promotion.SalesPerDay)
+	assert.Equal(t, 0.22, *promotion.BaselineSalesPerDay)
+	assert.Equal(t, 113.64, *promotion.SalesLift)
+	assert.Equal(t, 6, promotion.PromotedItemsSales)
+	assert.Equal(t, 0.4, promotion.PromotedItemsSalesPerDay)
+	assert.Equal(t, 0.11, *promotion.PromotedItemsBaselineSalesPerDay)
+	assert.Equal(t, 263.64, *promotion.PromotedItemsSalesLift)
+
+	assert.Equal(t, 1, report.Summary.Promotions)
+	assert.Equal(t, 0, report.Summary.OngoingPromotions)
+	assert.Equal(t, 15.0, report.Summary.PromotionDays)
+	assert.Equal(t, 22.5, *report.Summary.AverageDiscountPercent)
+	assert.Equal(t, 113.64, *report.Summary.AverageSalesLift)
+}
+
+func TestCreatePromotionReportOngoingWithoutBaseline(t *testing.T) {
+
+	now := time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)
+	item := models.Item{OriginalPrice: 40, SalePrice: 30, Available: true}
+	item.CreatedAt = time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	statsRange := controllers.StatsRange{
+		From:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+		Granularity: controllers.GranularityDay,
+	}
+
+	report := controllers.CreatePromotionReport([]models.Item{item}, item.CreatedAt, statsRange, now, 0)
+
+	assert.Len(t, report.Promotions, 1)
+	assert.Nil(t, report.Promotions[0].EndedAt)
+	assert.True(t, report.Promotions[0].StartedBeforeTracking)
+	assert.Equal(t, 29.0, report.Promotions[0].Days)
+	assert.Nil(t, report.Promotions[0].BaselineSalesPerDay)
+	assert.Nil(t, report.Promotions[0].SalesLift)
+	assert.Equal(t, 1, report.Summary.OngoingPromotions)
+	assert.Equal(t, 25.0, *report.Summary.AverageDiscountPercent)
+	assert.Nil(t, report.Summary.AverageSalesLift)
+}
+
+func TestPromotionSalesFrom(t *testing.T) {
+
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	statsRange := controllers.StatsRange{From: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)}
+
+	item := models.Item{OriginalPrice: 40, Available: true, SalePrice: 30}
+	item.AttributeHistory = []models.ItemAttributeChange{
+		// ended before the range, its baseline is not looked at.
+		salePrice(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "-1", "35"),
+		salePrice(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), "35", "-1"),
+		salePrice(time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC), "-1", "30"),
+	}
+
+	assert.Equal(t, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), controllers.PromotionSalesFrom([]models.Item{item}, statsRange, now))
+	assert.Equal(t, statsRange.From, controllers.PromotionSalesFrom([]models.Item{{OriginalPrice: 40}}, statsRange, now))
+}
+
+func TestHandleGetPromotionsSuccess(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	item := models.Item{OriginalPrice: 40, Available: true}
+	item.CreatedAt = time.Now().Add(-72 * time.Hour)
+	sale := models.ItemAttributeChange{Attribute: models.ItemAttributeSalePrice, OldValue: "-1", NewValue: "30"}
+	sale.CreatedAt = time.Now().Add(-48 * time.Hour)
+	item.AttributeHistory = []models.ItemAttributeChange{sale}
+	item.SalePrice = 30
+
+	ShopRepo.On("FetchShopByID").Return(&models.Shop{}, nil)
+	ShopRepo.On("GetItemPriceHistoryByShopID").Return([]models.Item{item}, nil)
+	ShopRepo.On("GetSoldItemsByShopID").Return([]models.SoldItems{}, nil)
+	ShopRepo.On("GetDailySalesByShopID").Return([]models.DailyShopSales{}, nil)
+	ShopRepo.On("GetAverageItemPrice").Return(25.0, nil)
+
+	router.GET("/shop/:shopID/promotions", implShop.HandleGetPromotions)
+
+	req, _ := http.NewRequest("GET", "/shop/1/promotions?period=lastSevenDays", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ended_at":null`)
+	assert.Contains(t, w.Body.String(), `"max_discount_percent":25`)
+	assert.Contains(t, w.Body.String(), `"promotions":1,"ongoing_promotions":1`)
+}
+
+func TestHandleGetPromotionsInvalidRange(t *testing.T) {
+
+	_, router, _ := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	router.GET("/shop/:shopID/promotions", implShop.HandleGetPromotions)
+
+	for _, query := range []string{"period=lastDecade", "from=2024-06-10&to=2024-06-01", "from=June"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/shop/1/promotions?"+query, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	ShopRepo.AssertNotCalled(t, "FetchShopByID")
+}
+
+func TestHandleGetPromotionsShopNotFound(t *testing.T) {
+
+	_, router, w := setupMockServer.SetGinTestMode()
+	ShopRepo := &MockedShopRepository{}
+	implShop := controllers.Shop{Shop: ShopRepo}
+
+	ShopRepo.On("FetchShopByID").Return(nil, gorm.ErrRecordNotFound)
+
+	router.GET("/shop/:shopID/promotions", implShop.HandleGetPromotions)
+
+	req, _ := http.NewRequest("GET", "/shop/1/promotions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
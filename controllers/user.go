@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"reflect"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,6 +30,9 @@ type UserControllerInterface interface {
 	ForgotPassReq(c *gin.Context)
 	ChangePass(c *gin.Context)
 	ResetPass(c *gin.Context)
+	ChangeTimeZone(c *gin.Context)
+	GetNotifications(c *gin.Context)
+	ReadNotifications(c *gin.Context)
 }
 
 func NewUserController(Process utils.UtilsProcess, UserDB repository.UserRepository, config initializer.Config) *User {
@@ -46,6 +50,7 @@ type RegisterAccount struct {
 	Password         string `json:"password" binding:"required,min=8"`
 	PasswordConfirm  string `json:"password_confirm" binding:"required"`
 	SubscriptionType string `json:"subscription_type"`
+	TimeZone         string `json:"time_zone"`
 }
 
 type LoginRequest struct {
@@ -72,6 +77,10 @@ type ReqPassChange struct {
 	ConfirmPass string `json:"confirm_password"`
 }
 
+type ReqTimeZoneChange struct {
+	TimeZone string `json:"time_zone" binding:"required"`
+}
+
 type UserReqPassChange struct {
 	RCP         string `json:"rcp"`
 	NewPass     string `json:"new_password"`
@@ -96,6 +105,13 @@ func (s *User) RegisterUser(ctx *gin.Context) {
 		return
 	}
 
+	if account.TimeZone != "" {
+		if _, err := time.LoadLocation(account.TimeZone); err != nil {
+			HandleResponse(ctx, err, http.StatusBadRequest, "invalid time zone", nil)
+			return
+		}
+	}
+
 	passwardHashed, err := s.utils.HashPass(account.Password)
 	if err != nil {
 		HandleResponse(ctx, err, http.StatusConflict, "error while hashing password", nil)
@@ -290,6 +306,62 @@ func (s *User) ChangePass(ctx *gin.Context) {
 	s.LogOutAccount(ctx)
 }
 
+func (s *User) ChangeTimeZone(ctx *gin.Context) {
+
+	reqTimeZoneChange := ReqTimeZoneChange{}
+	currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+
+	if err := ctx.ShouldBindJSON(&reqTimeZoneChange); err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "failed to fetch time zone request", nil)
+		return
+	}
+
+	if _, err := time.LoadLocation(reqTimeZoneChange.TimeZone); err != nil {
+		HandleResponse(ctx, err, http.StatusBadRequest, "invalid time zone", nil)
+		return
+	}
+
+	Account, err := s.User.GetAccountByID(currentUserUUID)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusNotFound, "user not found", nil)
+		return
+	}
+
+	Account.TimeZone = reqTimeZoneChange.TimeZone
+	if err := s.User.SaveAccount(Account); err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "internal error", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "time zone changed", nil)
+}
+
+func (s *User) GetNotifications(ctx *gin.Context) {
+
+	currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+	UnreadOnly := ctx.Query("unread") == "true"
+
+	Notifications, err := s.User.GetNotificationsByAccountID(currentUserUUID, UnreadOnly)
+	if err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "internal error", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "", gin.H{"notifications": Notifications})
+}
+
+func (s *User) ReadNotifications(ctx *gin.Context) {
+
+	currentUserUUID := ctx.MustGet("currentUserUUID").(uuid.UUID)
+
+	if err := s.User.MarkNotificationsRead(currentUserUUID); err != nil {
+		HandleResponse(ctx, err, http.StatusInternalServerError, "internal error", nil)
+		return
+	}
+
+	HandleResponse(ctx, nil, http.StatusOK, "notifications marked as read", nil)
+}
+
 func (s *User) ForgotPassReq(ctx *gin.Context) {
 	ForgotAccountPass := &UserReqForgotPassword{}
 	if err := ctx.ShouldBindJSON(&ForgotAccountPass); err != nil {
@@ -390,6 +462,11 @@ func (s *User) GenerateLoginResponse(Account *models.Account, AccessToken, Refre
 func (s *User) CreateNewAccountRecord(account *RegisterAccount, passwardHashed, EmailVerificationToken string) (*models.Account, error) {
 	newUUID := uuid.New()
 
+	timeZone := account.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+
 	newAccount := &models.Account{
 		ID:                     newUUID,
 		FirstName:              account.FirstName,
@@ -397,6 +474,7 @@ func (s *User) CreateNewAccountRecord(account *RegisterAccount, passwardHashed,
 		Email:                  account.Email,
 		PasswordHashed:         passwardHashed,
 		SubscriptionType:       account.SubscriptionType,
+		TimeZone:               timeZone,
 		EmailVerificationToken: EmailVerificationToken,
 	}
 
@@ -162,6 +162,19 @@ func (mr *MockedUserRepository) CreateAccount(account *models.Account) (*models.
 	return Account, args.Error(1)
 
 }
+func (mr *MockedUserRepository) GetNotificationsByAccountID(AccountID uuid.UUID, UnreadOnly bool) ([]models.Notification, error) {
+	args := mr.Called()
+	notificationsInterface := args.Get(0)
+	var Notifications []models.Notification
+	if notificationsInterface != nil {
+		Notifications = notificationsInterface.([]models.Notification)
+	}
+	return Notifications, args.Error(1)
+}
+func (mr *MockedUserRepository) MarkNotificationsRead(AccountID uuid.UUID) error {
+	args := mr.Called()
+	return args.Error(0)
+}
 func (mr *MockedUserRepository) JoinShopFollowing(Account *models.Account) (*models.Account, error) {
 
 	args := mr.Called()
@@ -1034,6 +1047,73 @@ func TestChangePassSuccess(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestChangeTimeZoneInvalidZone(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	UserRepo := &MockedUserRepository{}
+	User := controllers.NewUserController(&mockUtils{}, UserRepo, MockedConfig)
+
+	router.POST("/timezone", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, User.ChangeTimeZone)
+
+	c.Request, _ = http.NewRequest("POST", "/timezone", bytes.NewBuffer([]byte(`{"time_zone":"Mars/Olympus"}`)))
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid time zone")
+	UserRepo.AssertNotCalled(t, "SaveAccount")
+}
+
+func TestChangeTimeZoneUserNotFound(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	UserRepo := &MockedUserRepository{}
+	User := controllers.NewUserController(&mockUtils{}, UserRepo, MockedConfig)
+
+	UserRepo.On("GetAccountByID").Return(nil, errors.New("record not found"))
+
+	router.POST("/timezone", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, User.ChangeTimeZone)
+
+	c.Request, _ = http.NewRequest("POST", "/timezone", bytes.NewBuffer([]byte(`{"time_zone":"UTC"}`)))
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestChangeTimeZoneSuccess(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	UserRepo := &MockedUserRepository{}
+	User := controllers.NewUserController(&mockUtils{}, UserRepo, MockedConfig)
+
+	Account := &models.Account{ID: currentUserUUID, TimeZone: "UTC"}
+	UserRepo.On("GetAccountByID").Return(Account, nil)
+	UserRepo.On("SaveAccount").Return(nil)
+
+	router.POST("/timezone", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, User.ChangeTimeZone)
+
+	c.Request, _ = http.NewRequest("POST", "/timezone", bytes.NewBuffer([]byte(`{"time_zone":"Europe/Berlin"}`)))
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Europe/Berlin", Account.TimeZone)
+	UserRepo.AssertNumberOfCalls(t, "SaveAccount", 1)
+}
+
 func TestForgotPassReqFailedBindJson(t *testing.T) {
 
 	c, router, w := setupMockServer.SetGinTestMode()
@@ -1324,3 +1404,47 @@ func TestCreateNewAccountRecordFail(t *testing.T) {
 	assert.Error(t, err)
 
 }
+
+func TestGetNotificationsSuccess(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	UserRepo := &MockedUserRepository{}
+	User := controllers.NewUserController(&mockUtils{}, UserRepo, MockedConfig)
+
+	Notifications := []models.Notification{{ID: 1, ShopID: 2, Kind: models.NotificationVacationStarted, Message: "Shop 2 went on vacation"}}
+	UserRepo.On("GetNotificationsByAccountID").Return(Notifications, nil)
+
+	router.GET("/notifications", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, User.GetNotifications)
+
+	c.Request, _ = http.NewRequest("GET", "/notifications?unread=true", nil)
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"kind":"vacation_started","message":"Shop 2 went on vacation"`)
+}
+
+func TestReadNotificationsFail(t *testing.T) {
+
+	c, router, w := setupMockServer.SetGinTestMode()
+
+	currentUserUUID := uuid.New()
+	UserRepo := &MockedUserRepository{}
+	User := controllers.NewUserController(&mockUtils{}, UserRepo, MockedConfig)
+
+	UserRepo.On("MarkNotificationsRead").Return(errors.New("error while updating notifications"))
+
+	router.POST("/notifications/read", func(ctx *gin.Context) {
+		ctx.Set("currentUserUUID", currentUserUUID)
+	}, User.ReadNotifications)
+
+	c.Request, _ = http.NewRequest("POST", "/notifications/read", nil)
+
+	router.ServeHTTP(w, c.Request)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
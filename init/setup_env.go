@@ -34,6 +34,13 @@ type Config struct {
 	ProxyHostURL1 string `mapstructure:"PROXY_HOST_URL1"`
 	ProxyHostURL2 string `mapstructure:"PROXY_HOST_URL2"`
 	ProxyHostURL3 string `mapstructure:"PROXY_HOST_URL3"`
+
+	SchedulerLeaseTTL time.Duration `mapstructure:"SCHEDULER_LEASE_TTL"`
+	SnapshotTimeUTC   string        `mapstructure:"SCHEDULER_SNAPSHOT_TIME_UTC"`
+
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+
+	AdminEmails string `mapstructure:"ADMIN_EMAILS"`
 }
 
 func LoadProjConfig(path string) (config Config) {
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -13,6 +19,7 @@ import (
 	"EtsyScraper/models"
 	"EtsyScraper/repository"
 	"EtsyScraper/routes"
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
 	scrap "EtsyScraper/scraping"
 	"EtsyScraper/utils"
 )
@@ -21,7 +28,9 @@ func init() {
 	config := initializer.LoadProjConfig(".")
 	initializer.DataBaseConnect(&config)
 	initializer.RedisDBConnect(&config)
-	initializer.DB.AutoMigrate(models.ModelsGroup...)
+	if err := initializer.DB.AutoMigrate(models.ModelsGroup...); err != nil {
+		log.Fatal("failed to migrate the database: ", err)
+	}
 	fmt.Println("Migration is completed")
 
 }
@@ -44,6 +53,21 @@ func main() {
 	Repository := &repository.DataBase{DB: initializer.DB}
 	implShop := controllers.Shop{Scraper: Scraper, User: Repository, Shop: Repository}
 	implShop.Operations = &implShop
+	implShop.Onboarding = controllers.NewOnboardingQueue()
+	implShop.Refresher = scheduleUpdates.NewUpdateDB(initializer.DB, implShop)
+
+	if err := implShop.MergeDuplicateShopNames(); err != nil {
+		log.Fatal("failed to merge duplicate shops: ", err)
+	}
+	if err := implShop.RecoverOnboarding(); err != nil {
+		log.Println("failed to recover shop onboarding: ", err)
+	}
+	if err := implShop.FailStaleRefreshJobs(); err != nil {
+		log.Println("failed to clean up refresh jobs: ", err)
+	}
+	implShop.Onboarding.Start(controllers.OnboardingWorkers, implShop.ProcessOnboardingJob)
+	stopOnboardingClaims := implShop.KeepOnboardingClaims(controllers.OnboardingClaimInterval)
+	stopRefreshClaims := implShop.KeepRefreshClaims(controllers.RefreshClaimInterval)
 
 	// scheduleUpdates.StartScheduleScrapUpdate(implShop)
 
@@ -52,11 +76,52 @@ func main() {
 
 	shopRoutes := routes.NewShopRouteController(&implShop)
 	shopRoutes.GeneralShopRoutes(server, controllers.AuthMiddleWare(utils, Repository), controllers.Authorization(Repository), controllers.IsAccountFollowingShop(Repository))
+	shopRoutes.AdminShopRoutes(server, controllers.AuthMiddleWare(utils, Repository), controllers.Authorization(Repository), controllers.IsAdmin(Repository, config.AdminEmails))
 
 	templatesFilesPath := "./static/templates/*"
 	htmlRoutes := routes.NewHTMLRouter()
 	htmlRoutes.GeneralHTMLRoutes(server, controllers.AuthMiddleWare(utils, Repository), controllers.Authorization(Repository), templatesFilesPath)
 
-	log.Fatal(server.Run(":" + config.ServerPort))
+	srv := &http.Server{
+		Addr:    ":" + config.ServerPort,
+		Handler: server,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("shutting down server")
+
+	shutdownTimeout := config.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("server forced to shutdown: ", err)
+	}
+
+	if err := implShop.DrainRefreshJobs(ctx); err != nil {
+		log.Println("failed to drain refresh jobs: ", err)
+	}
+	stopRefreshClaims()
+
+	stopOnboardingClaims()
+	unfinishedJobs := implShop.Onboarding.Shutdown(ctx)
+	if err := implShop.CheckpointOnboardingJobs(unfinishedJobs); err != nil {
+		log.Println("failed to checkpoint shop onboarding: ", err)
+	} else if err := implShop.ReleaseOnboardingClaims(); err != nil {
+		log.Println("failed to release shop request claims: ", err)
+	}
+
+	log.Println("server exited")
 
 }
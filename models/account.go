@@ -15,6 +15,7 @@ type Account struct {
 	Email                  string        `gorm:"type:varchar(255) ;uniqueIndex;not null"`
 	PasswordHashed         string        `gorm:"type:varchar(155)"`
 	SubscriptionType       string        `gorm:"type:varchar(55)"`
+	TimeZone               string        `gorm:"type:varchar(64);default:'UTC'"`
 	EmailVerified          bool          `gorm:"default:false"`
 	EmailVerificationToken string        `gorm:"type:varchar(255)"`
 	RequestChangePass      bool          `gorm:"default:false"`
@@ -24,3 +25,18 @@ type Account struct {
 	ShopsFollowing         []Shop        `gorm:"many2many:account_shop_following;"`
 	Requests               []ShopRequest `gorm:"foreignKey:AccountID;references:ID"`
 }
+
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AccountID uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	ShopID    uint      `json:"shop_id"`
+	Kind      string    `json:"kind" gorm:"type:varchar(30)"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	NotificationVacationStarted = "vacation_started"
+	NotificationVacationEnded   = "vacation_ended"
+)
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -18,22 +20,42 @@ var ModelsGroup = []interface{}{
 	&ShopRequest{},
 	&DailyShopSales{},
 	&ItemHistoryChange{},
+	&ShopRefreshJob{},
+	&ScrapeCheckpoint{},
+	&MenuHistoryChange{},
+	&ShopHistoryChange{},
+	&DailyShopReviews{},
+	&DailyReviewsTopic{},
+	&VacationPeriod{},
+	&Notification{},
+	&ItemAttributeChange{},
+	&ListingPosition{},
+	&ShopDailyRollup{},
+	&ItemDailyRollup{},
+	&SchedulerFence{},
+	&ShopLock{},
 }
 
+// ShopNameIndex keeps one live shop per name, compared the way utils.NormalizeShopName
+// compares them. It is created after duplicate shops are merged, not by AutoMigrate.
+const ShopNameIndex = "idx_shops_unique_name"
+
 type Shop struct {
 	gorm.Model
-	Name              string    `json:"shop_name" gorm:"type:varchar(100);not null"`
-	Description       string    `json:"shop_description" gorm:"type:varchar(255);not null"`
-	Location          string    `json:"location" gorm:"type:varchar(50);not null"`
-	TotalSales        int       `json:"shop_total_sales" gorm:"not null"`
-	JoinedSince       string    `json:"joined_since" gorm:"type:varchar(100);not null"`
-	LastUpdateTime    string    `json:"last_update_time" gorm:"type:varchar(155);not null"`
-	Admirers          int       `json:"admirers" gorm:"not null"`
-	HasSoldHistory    bool      `json:"-" `
-	OnVacation        bool      `json:"-" `
-	Revenue           float64   `json:"revenue" gorm:"-"`
-	AverageItemsPrice float64   `json:"average_item_price" gorm:"-"`
-	CreatedByUserID   uuid.UUID `json:"-" gorm:"type:uuid"`
+	Name              string            `json:"shop_name" gorm:"type:varchar(100);not null"`
+	Description       string            `json:"shop_description" gorm:"type:varchar(255);not null"`
+	Location          string            `json:"location" gorm:"type:varchar(50);not null"`
+	TotalSales        int               `json:"shop_total_sales" gorm:"not null"`
+	JoinedSince       string            `json:"joined_since" gorm:"type:varchar(100);not null"`
+	LastUpdateTime    string            `json:"last_update_time" gorm:"type:varchar(155);not null"`
+	Admirers          int               `json:"admirers" gorm:"not null"`
+	HasSoldHistory    bool              `json:"-" `
+	OnVacation        bool              `json:"-" `
+	VacationMessage   string            `json:"-" gorm:"-"`
+	ListingPositions  []ListingPosition `json:"-" gorm:"-"`
+	Revenue           float64           `json:"revenue" gorm:"-"`
+	AverageItemsPrice float64           `json:"average_item_price" gorm:"-"`
+	CreatedByUserID   uuid.UUID         `json:"-" gorm:"type:uuid"`
 
 	SocialMediaLinks []SocialMediaLinks `json:"social_media_links" gorm:"foreignKey:ShopID;references:ID;constraint:OnDelete:CASCADE;"`
 	Member           []ShopMember       `json:"shop_member" gorm:"foreignKey:ShopID;references:ID;constraint:OnDelete:CASCADE;"`
@@ -53,6 +75,9 @@ type ShopRequest struct {
 	AccountID uuid.UUID
 	ShopName  string `json:"shop_name"`
 	Status    string
+	// ClaimedBy is the instance working on the request, ClaimedAt when it last renewed the claim.
+	ClaimedBy string     `json:"-" gorm:"type:varchar(100);index"`
+	ClaimedAt *time.Time `json:"-"`
 }
 
 type SoldItems struct {
@@ -62,22 +87,26 @@ type SoldItems struct {
 	ItemID     uint   `gorm:"index"`
 	ListingID  uint
 	DataShopID string
+	// FromHistory marks units scraped from the selling history when the shop was added. They
+	// were sold before the shop was tracked, their CreatedAt is only when they were saved.
+	FromHistory bool `gorm:"default:false"`
 }
 
 type Item struct {
-	gorm.Model     `json:"-"`
-	Name           string
-	OriginalPrice  float64
-	CurrencySymbol string
-	SalePrice      float64
-	DiscoutPercent string
-	Available      bool
-	ItemLink       string
-	MenuItemID     uint `json:"-"`
-	ListingID      uint
-	DataShopID     string      `json:"-"`
-	SoldUnits      []SoldItems `json:"-" gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;"`
-	PriceHistory   []ItemHistoryChange
+	gorm.Model       `json:"-"`
+	Name             string
+	OriginalPrice    float64
+	CurrencySymbol   string
+	SalePrice        float64
+	DiscoutPercent   string
+	Available        bool
+	ItemLink         string
+	MenuItemID       uint `json:"-"`
+	ListingID        uint
+	DataShopID       string      `json:"-"`
+	SoldUnits        []SoldItems `json:"-" gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;"`
+	PriceHistory     []ItemHistoryChange
+	AttributeHistory []ItemAttributeChange `json:"-" gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;"`
 }
 
 type MenuItem struct {
@@ -132,9 +161,45 @@ type DailyShopSales struct {
 	TotalSales   int
 	Admirers     int
 	DailyRevenue float64
+	Estimated    bool `gorm:"default:false"`
 	Shop         Shop `gorm:"foreignKey:ShopID;constraint:OnDelete:CASCADE;"`
 }
 
+// ShopDailyRollup is the number of sold items saved for a shop on one UTC day. Rollups are
+// kept up to date as sold items are saved so stats do not have to count them.
+type ShopDailyRollup struct {
+	gorm.Model
+	ShopID    uint      `gorm:"uniqueIndex:idx_shop_daily_rollup"`
+	Date      time.Time `gorm:"type:date;uniqueIndex:idx_shop_daily_rollup"`
+	SoldUnits int
+	Shop      Shop `gorm:"foreignKey:ShopID;constraint:OnDelete:CASCADE;"`
+}
+
+// ItemDailyRollup is the number of units of one item saved as sold on one UTC day.
+type ItemDailyRollup struct {
+	gorm.Model
+	ShopID    uint      `gorm:"index"`
+	ItemID    uint      `gorm:"uniqueIndex:idx_item_daily_rollup"`
+	Date      time.Time `gorm:"type:date;uniqueIndex:idx_item_daily_rollup"`
+	SoldUnits int
+	Item      Item `gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;"`
+}
+
+type DailyShopReviews struct {
+	gorm.Model
+	ShopID       uint `gorm:"index"`
+	ShopRating   float64
+	ReviewsCount int
+	Topics       []DailyReviewsTopic `gorm:"foreignKey:DailyShopReviewsID;constraint:OnDelete:CASCADE;"`
+}
+
+type DailyReviewsTopic struct {
+	gorm.Model
+	DailyShopReviewsID uint `gorm:"index"`
+	Keyword            string
+	KeywordCount       int
+}
+
 type ItemHistoryChange struct {
 	gorm.Model
 	ItemID         uint
@@ -147,6 +212,116 @@ type ItemHistoryChange struct {
 	NewMenuItemID  uint
 }
 
+type ItemAttributeChange struct {
+	gorm.Model
+	ItemID    uint   `gorm:"index"`
+	Attribute string `gorm:"type:varchar(30)"`
+	OldValue  string
+	NewValue  string
+}
+
+// ListingPosition is the place of a listing on the shop home page at one refresh. Featured
+// listings are counted within the featured row, the others within the default listing grid.
+type ListingPosition struct {
+	gorm.Model
+	ShopID    uint `gorm:"index"`
+	ListingID uint `gorm:"index"`
+	Position  int
+	Featured  bool
+}
+
+type MenuHistoryChange struct {
+	gorm.Model
+	ShopID      uint `gorm:"index"`
+	MenuItemID  uint `gorm:"index"`
+	SectionID   string
+	Event       string `gorm:"type:varchar(20)"`
+	OldCategory string
+	NewCategory string
+	OldAmount   int
+	NewAmount   int
+}
+
+type ShopHistoryChange struct {
+	gorm.Model
+	ShopID   uint   `gorm:"index"`
+	Field    string `gorm:"type:varchar(30)"`
+	OldValue string
+	NewValue string
+}
+
+type VacationPeriod struct {
+	gorm.Model `json:"-"`
+	ShopID     uint       `json:"-" gorm:"index"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+	Message    string     `json:"message"`
+}
+
+type ShopRefreshJob struct {
+	gorm.Model  `json:"-"`
+	JobID       uuid.UUID  `json:"job_id" gorm:"type:uuid;uniqueIndex"`
+	ShopID      uint       `json:"shop_id" gorm:"index"`
+	AccountID   uuid.UUID  `json:"-" gorm:"type:uuid;index"`
+	FullRefresh bool       `json:"full_refresh"`
+	Status      string     `json:"status" gorm:"type:varchar(20)"`
+	Error       string     `json:"error,omitempty"`
+	RequestedAt time.Time  `json:"requested_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// ClaimedBy is the instance running the job, ClaimedAt when it last renewed the claim.
+	ClaimedBy string     `json:"-" gorm:"type:varchar(100);index"`
+	ClaimedAt *time.Time `json:"-"`
+}
+
+// SchedulerFence holds the highest scheduler fencing token a write was accepted with.
+type SchedulerFence struct {
+	Name      string `gorm:"primaryKey;type:varchar(50)"`
+	Token     int64
+	UpdatedAt time.Time
+}
+
+// ShopLock is held by the job writing a shop's sales, menus and items. Owner is empty
+// while the lock is free.
+type ShopLock struct {
+	ShopID   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string `gorm:"type:varchar(100)"`
+	LockedAt time.Time
+}
+
+type ScrapeCheckpoint struct {
+	gorm.Model
+	ShopRequestID uint   `gorm:"index"`
+	Kind          string `gorm:"type:varchar(20)"`
+	ShopID        uint
+	Task          TaskSchedule `gorm:"embedded;embeddedPrefix:task_"`
+}
+
+const (
+	MenuCreated = "created"
+	MenuRenamed = "renamed"
+	MenuResized = "resized"
+	MenuDeleted = "deleted"
+)
+
+const (
+	ItemAttributeName          = "name"
+	ItemAttributeOriginalPrice = "original_price"
+	ItemAttributeAvailable     = "available"
+	ItemAttributeSalePrice     = "sale_price"
+	ItemAttributeDiscount      = "discount_percent"
+	ItemAttributeCurrency      = "currency_symbol"
+)
+
+const (
+	ShopFieldDescription  = "description"
+	ShopFieldLocation     = "location"
+	ShopFieldOnVacation   = "on_vacation"
+	ShopFieldMembers      = "members"
+	ShopFieldSocialLinks  = "social_links"
+	ShopFieldRating       = "rating"
+	ShopFieldReviewsCount = "reviews_count"
+)
+
 func CreateMenuItem(menuItem MenuItem) MenuItem {
 	newMenuItem := MenuItem{
 		ShopMenuID: menuItem.ShopMenuID,
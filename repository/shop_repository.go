@@ -3,29 +3,43 @@ package repository
 import (
 	"EtsyScraper/models"
 	"EtsyScraper/utils"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var SchedulerFenceName = "scheduler"
+
+var ErrStaleFencingToken = errors.New("fencing token is older than the last accepted one")
+
+var ErrShopNameTaken = errors.New("a shop with the same name already exists")
+
+const uniqueViolationCode = "23505"
+
 type ShopRepository interface {
 	CreateShop(scrappedShop *models.Shop) error
 	SaveShop(Shop *models.Shop) error
-	SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems) error
+	SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems, ShopID uint) error
 	UpdateDailySales(ScrappedSoldItems []models.SoldItems, ShopID uint, dailyRevenue float64) error
 	SaveMenu(Menus models.MenuItem) error
 	FetchShopByID(ID uint) (*models.Shop, error)
 	FetchStatsByPeriod(ShopID uint, timePeriod time.Time) ([]models.DailyShopSales, error)
 	FetchSoldItemsByListingID(listingIDs []uint) ([]models.SoldItems, error)
-	FetchItemsBySoldItems(soldItemID uint) (models.Item, error)
-	GetSoldItemsInRange(fromDate time.Time, ShopID uint) ([]models.SoldItems, error)
+	GetShopRollupsByPeriod(ShopID uint, From time.Time) ([]models.ShopDailyRollup, error)
+	GetItemRollupsByPeriod(ShopID uint, From time.Time) ([]models.ItemDailyRollup, error)
+	RebuildSalesRollups(ShopID uint) error
+	TagSellingHistory(ShopID uint) (int64, error)
 	UpdateAccountShopRelation(requestedShop *models.Shop, UserID uuid.UUID) error
 	GetAverageItemPrice(ShopID uint) (float64, error)
 	SaveShopRequestToDB(ShopRequest *models.ShopRequest) error
 	GetShopWithItemsByShopID(ID uint) (*models.Shop, error)
 	GetShopByName(ShopName string) (shop *models.Shop, err error)
 	GetAllShops() (*[]models.Shop, error)
-	CreateDailySales(ShopID uint, TotalSales, Admirers int) error
+	CreateDailySales(ShopID uint, TotalSales, Admirers int, FencingToken int64) error
 	UpdateColumnsInShop(Shop models.Shop, updateData map[string]interface{}) error
 	CreateMenu(Menus models.MenuItem) (models.MenuItem, error)
 	GetItemByListingID(ID uint) (*models.Item, error)
@@ -33,6 +47,95 @@ type ShopRepository interface {
 	UpdateItem(existingItem models.Item, changes map[string]interface{}) error
 	GetAllItemsByDataShopID(dataShopID string) ([]models.Item, error)
 	CreateNewItem(item models.Item) (models.Item, error)
+	CreateRefreshJob(job *models.ShopRefreshJob) error
+	SaveRefreshJob(job *models.ShopRefreshJob) error
+	GetLatestRefreshJob(ShopID uint) (*models.ShopRefreshJob, error)
+	GetRefreshJobByJobID(JobID uuid.UUID) (*models.ShopRefreshJob, error)
+	CountRefreshJobsSince(AccountID uuid.UUID, since time.Time) (int64, error)
+	FailStaleRefreshJobs(before, claimedBefore time.Time, reason string) (int64, error)
+	RenewRefreshJobClaims(InstanceID string) error
+	GetDailySalesByShopID(ShopID uint) ([]models.DailyShopSales, error)
+	SaveDailySales(dailySales *models.DailyShopSales) error
+	GetShopRequestByID(ID uint) (*models.ShopRequest, error)
+	GetShopRequestsByStatus(Status string) ([]models.ShopRequest, error)
+	ClaimShopRequest(ID uint, InstanceID string, staleBefore time.Time) (bool, error)
+	RenewShopRequestClaims(InstanceID string) error
+	ReleaseShopRequestClaims(InstanceID string) error
+	SaveScrapeCheckpoints(checkpoints []models.ScrapeCheckpoint) error
+	GetScrapeCheckpoints() ([]models.ScrapeCheckpoint, error)
+	DeleteScrapeCheckpoint(ID uint) error
+	GetShopWithSoldItemsByShopID(ID uint) (*models.Shop, error)
+	MergeShops(merge *ShopMerge) error
+	LockShop(ShopID uint, Owner string, staleBefore time.Time) (bool, error)
+	UnlockShop(ShopID uint, Owner string) error
+	GetShopSalesByID(ID uint) (*models.Shop, error)
+	GetDuplicateShopIDs() ([][]uint, error)
+	CreateShopNameIndex() error
+	GetItemsWithHistoryByShopID(ShopID uint) ([]models.Item, error)
+	UpdateMenu(Menu models.MenuItem, changes map[string]interface{}) error
+	DeleteMenu(MenuID uint) error
+	CreateMenuHistoryChange(Change models.MenuHistoryChange) error
+	GetMenuHistoryByShopID(ShopID uint) ([]models.MenuHistoryChange, error)
+	GetShopProfileByID(ID uint) (*models.Shop, error)
+	UpdateShopProfile(Shop *models.Shop, Changes []models.ShopHistoryChange) error
+	GetShopHistoryByShopID(ShopID uint) ([]models.ShopHistoryChange, error)
+	CreateDailyReviews(DailyReviews *models.DailyShopReviews) error
+	FetchReviewsStatsByPeriod(ShopID uint, timePeriod time.Time) ([]models.DailyShopReviews, error)
+	RecordVacationChange(Change VacationChange) (bool, error)
+	GetVacationPeriodsByShopID(ShopID uint) ([]models.VacationPeriod, error)
+	CreateShopNotifications(ShopID uint, Kind, Message string) error
+	CreateItemAttributeChanges(Changes []models.ItemAttributeChange) error
+	GetItemsWithAttributeHistoryByShopID(ShopID uint) ([]models.Item, error)
+	CreateListingPositions(Positions []models.ListingPosition) error
+	GetListingPositions(ShopID, ListingID uint) ([]models.ListingPosition, error)
+	GetItemSalesByPeriod(ShopID uint, From, To time.Time) ([]ItemSales, error)
+	GetItemsWithPriceHistoryByShopID(ShopID uint) ([]models.Item, error)
+	GetItemPriceHistoryByIDs(ItemIDs []uint) ([]models.Item, error)
+	GetItemPriceHistoryByShopID(ShopID uint) ([]models.Item, error)
+	GetSoldItemsByShopID(ShopID uint, From time.Time) ([]models.SoldItems, error)
+	UpdateDailyRevenue(dailySales []models.DailyShopSales) error
+	GetSoldItemsByItemIDs(ItemIDs []uint, From time.Time) ([]models.SoldItems, error)
+}
+
+// ItemSales is the number of units of one item sold in a period.
+type ItemSales struct {
+	ItemID         uint
+	ListingID      uint
+	Name           string
+	OriginalPrice  float64
+	CurrencySymbol string
+	Category       string
+	SoldQuantity   int
+}
+
+// VacationChange is a shop going on vacation or coming back from it, with the
+// notification its followers get.
+type VacationChange struct {
+	ShopID          uint
+	OnVacation      bool
+	At              time.Time
+	VacationMessage string
+	Kind            string
+	Message         string
+}
+
+// ShopMerge lists the rows of a duplicate (source) shop and where each of them
+// goes in the shop it duplicates (target). Rows the target already recorded for
+// the same item and day are dropped instead of moved so sales are not counted twice.
+type ShopMerge struct {
+	TargetShopID        uint
+	SourceShopID        uint
+	TargetShopMenuID    uint
+	MovedMenus          []uint
+	DroppedMenus        []uint
+	MergedMenus         map[uint]uint
+	MovedItems          map[uint]uint
+	MergedItems         map[uint]uint
+	DroppedSoldItems    []uint
+	MovedDailySales     []uint
+	DroppedDailySales   []uint
+	MovedDailyReviews   []uint
+	DroppedDailyReviews []uint
 }
 
 func (d *DataBase) CreateItemHistoryChange(Change models.ItemHistoryChange) error {
@@ -43,6 +146,13 @@ func (d *DataBase) CreateItemHistoryChange(Change models.ItemHistoryChange) erro
 
 	return nil
 }
+func (d *DataBase) CreateItemAttributeChanges(Changes []models.ItemAttributeChange) error {
+	if err := d.DB.Create(&Changes).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
 func (d *DataBase) CreateNewItem(item models.Item) (models.Item, error) {
 
 	if err := d.DB.Create(&item).Error; err != nil {
@@ -80,20 +190,94 @@ func (d *DataBase) UpdateColumnsInShop(Shop models.Shop, updateData map[string]i
 	return nil
 }
 
-func (d *DataBase) CreateDailySales(ShopID uint, TotalSales, Admirers int) error {
+// CreateDailySales saves the daily snapshot of a shop. A FencingToken above 0 is checked
+// in the same transaction, a writer whose token is older than the last accepted one is
+// rejected with ErrStaleFencingToken.
+func (d *DataBase) CreateDailySales(ShopID uint, TotalSales, Admirers int, FencingToken int64) error {
 	dailySales := models.DailyShopSales{
 		ShopID:     ShopID,
 		TotalSales: TotalSales,
 		Admirers:   Admirers,
 	}
 
-	if err := d.DB.Create(&dailySales).Error; err != nil {
+	if FencingToken == 0 {
+		if err := d.DB.Create(&dailySales).Error; err != nil {
+			return utils.HandleError(err)
+		}
+		return nil
+	}
+
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := advanceFence(tx, FencingToken); err != nil {
+			return err
+		}
+		return tx.Create(&dailySales).Error
+	})
+	if err != nil {
 		return utils.HandleError(err)
 	}
 	return nil
 }
+
+// advanceFence moves the scheduler fence up to token. The upsert only updates when token
+// is not older than the stored one and the row stays locked until the transaction ends,
+// so a stale writer can not slip in between the check and its write.
+func advanceFence(tx *gorm.DB, token int64) error {
+	fence := models.SchedulerFence{Name: SchedulerFenceName, Token: token}
+
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: `"scheduler_fences"."token" <= "excluded"."token"`}}},
+	}).Create(&fence)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleFencingToken
+	}
+	return nil
+}
+func (d *DataBase) GetDailySalesByShopID(ShopID uint) ([]models.DailyShopSales, error) {
+	dailyShopSales := []models.DailyShopSales{}
+
+	if err := d.DB.Where("shop_id = ?", ShopID).Order("created_at asc").Find(&dailyShopSales).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return dailyShopSales, nil
+}
+
+func (d *DataBase) CreateDailyReviews(DailyReviews *models.DailyShopReviews) error {
+	if err := d.DB.Create(DailyReviews).Error; err != nil {
+		return utils.HandleError(err, "error while saving daily reviews")
+	}
+	return nil
+}
+
+func (d *DataBase) FetchReviewsStatsByPeriod(ShopID uint, timePeriod time.Time) ([]models.DailyShopReviews, error) {
+	dailyReviews := []models.DailyShopReviews{}
+
+	if err := d.DB.Where("shop_id = ? AND created_at > ?", ShopID, timePeriod).Order("created_at asc").Find(&dailyReviews).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving daily reviews")
+	}
+	return dailyReviews, nil
+}
+
+func (d *DataBase) SaveDailySales(dailySales *models.DailyShopSales) error {
+	if err := d.DB.Omit("Shop").Save(dailySales).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+// CreateShop inserts a new shop. When another instance already inserted a shop with the
+// same normalized name it returns ErrShopNameTaken.
 func (d *DataBase) CreateShop(scrappedShop *models.Shop) error {
 	if err := d.DB.Create(scrappedShop).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == models.ShopNameIndex {
+			return utils.HandleError(ErrShopNameTaken, scrappedShop.Name)
+		}
 		return utils.HandleError(err)
 	}
 	return nil
@@ -106,8 +290,22 @@ func (d *DataBase) SaveShop(Shop *models.Shop) error {
 	return nil
 }
 
-func (d *DataBase) SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems) error {
-	if err := d.DB.Create(&ScrappedSoldItems).Error; err != nil {
+// SaveSoldItemsToDB saves the sold items of a shop and adds them to the shop's daily rollups
+// in the same transaction. Sold items that could not be matched with an item or that come from
+// the selling history are not rolled up.
+func (d *DataBase) SaveSoldItemsToDB(ScrappedSoldItems []models.SoldItems, ShopID uint) error {
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ScrappedSoldItems).Error; err != nil {
+			return err
+		}
+
+		SoldItemIDs := make([]uint, 0, len(ScrappedSoldItems))
+		for _, soldItem := range ScrappedSoldItems {
+			SoldItemIDs = append(SoldItemIDs, soldItem.ID)
+		}
+		return addSalesRollups(tx, ShopID, SoldItemIDs)
+	})
+	if err != nil {
 		return utils.HandleError(err, "Shop's selling history failed while saving to database")
 	}
 	return nil
@@ -138,6 +336,193 @@ func (d *DataBase) CreateMenu(Menus models.MenuItem) (models.MenuItem, error) {
 	return Menus, nil
 }
 
+func (d *DataBase) UpdateMenu(Menu models.MenuItem, changes map[string]interface{}) error {
+	if err := d.DB.Model(&Menu).Updates(changes).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) DeleteMenu(MenuID uint) error {
+	if err := d.DB.Delete(&models.MenuItem{}, MenuID).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) CreateMenuHistoryChange(Change models.MenuHistoryChange) error {
+	if err := d.DB.Create(&Change).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) GetMenuHistoryByShopID(ShopID uint) ([]models.MenuHistoryChange, error) {
+	changes := []models.MenuHistoryChange{}
+
+	if err := d.DB.Where("shop_id = ?", ShopID).Order("created_at asc").Find(&changes).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return changes, nil
+}
+
+func (d *DataBase) GetShopProfileByID(ID uint) (*models.Shop, error) {
+	shop := models.Shop{}
+	if err := d.DB.Preload("Member").Preload("SocialMediaLinks").Preload("Reviews").Where("id = ?", ID).First(&shop).Error; err != nil {
+		return nil, utils.HandleError(err, "no Shop was Found ")
+	}
+	return &shop, nil
+}
+
+// UpdateShopProfile records the changes and writes the new value of every changed
+// field, members and social links are replaced as a whole.
+func (d *DataBase) UpdateShopProfile(Shop *models.Shop, Changes []models.ShopHistoryChange) error {
+	if len(Changes) == 0 {
+		return nil
+	}
+
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&Changes).Error; err != nil {
+			return err
+		}
+
+		shopColumns := map[string]interface{}{}
+		reviewsColumns := map[string]interface{}{}
+
+		for _, change := range Changes {
+			switch change.Field {
+			case models.ShopFieldDescription:
+				shopColumns["description"] = Shop.Description
+			case models.ShopFieldLocation:
+				shopColumns["location"] = Shop.Location
+			case models.ShopFieldOnVacation:
+				shopColumns["on_vacation"] = Shop.OnVacation
+			case models.ShopFieldRating:
+				reviewsColumns["shop_rating"] = Shop.Reviews.ShopRating
+			case models.ShopFieldReviewsCount:
+				reviewsColumns["reviews_count"] = Shop.Reviews.ReviewsCount
+			case models.ShopFieldMembers:
+				if err := tx.Where("shop_id = ?", Shop.ID).Delete(&models.ShopMember{}).Error; err != nil {
+					return err
+				}
+				Members := []models.ShopMember{}
+				for _, member := range Shop.Member {
+					Members = append(Members, models.ShopMember{ShopID: Shop.ID, Name: member.Name, Role: member.Role})
+				}
+				if len(Members) > 0 {
+					if err := tx.Create(&Members).Error; err != nil {
+						return err
+					}
+				}
+			case models.ShopFieldSocialLinks:
+				if err := tx.Where("shop_id = ?", Shop.ID).Delete(&models.SocialMediaLinks{}).Error; err != nil {
+					return err
+				}
+				Links := []models.SocialMediaLinks{}
+				for _, link := range Shop.SocialMediaLinks {
+					Links = append(Links, models.SocialMediaLinks{ShopID: Shop.ID, Link: link.Link})
+				}
+				if len(Links) > 0 {
+					if err := tx.Create(&Links).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if len(shopColumns) > 0 {
+			if err := tx.Model(&models.Shop{}).Where("id = ?", Shop.ID).Updates(shopColumns).Error; err != nil {
+				return err
+			}
+		}
+		if len(reviewsColumns) > 0 {
+			if err := tx.Model(&models.Reviews{}).Where("shop_id = ?", Shop.ID).Updates(reviewsColumns).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.HandleError(err, "error while updating shop profile")
+	}
+	return nil
+}
+
+func (d *DataBase) GetShopHistoryByShopID(ShopID uint) ([]models.ShopHistoryChange, error) {
+	changes := []models.ShopHistoryChange{}
+
+	if err := d.DB.Where("shop_id = ?", ShopID).Order("created_at asc").Find(&changes).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return changes, nil
+}
+
+// RecordVacationChange opens or closes the vacation period of a shop, sets its on_vacation
+// flag and notifies its followers in one transaction. The shop row is locked and its open
+// period is read again inside it, so when the scheduled update and a refresh see the same
+// change only the first one records it, the other gets false.
+func (d *DataBase) RecordVacationChange(Change VacationChange) (bool, error) {
+	recorded := false
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", Change.ShopID).First(&models.Shop{}).Error; err != nil {
+			return err
+		}
+
+		var openPeriods int64
+		if err := tx.Model(&models.VacationPeriod{}).Where("shop_id = ? AND ended_at IS NULL", Change.ShopID).Count(&openPeriods).Error; err != nil {
+			return err
+		}
+		if Change.OnVacation == (openPeriods > 0) {
+			return nil
+		}
+
+		if Change.OnVacation {
+			Period := &models.VacationPeriod{ShopID: Change.ShopID, StartedAt: Change.At, Message: Change.VacationMessage}
+			if err := tx.Create(Period).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Model(&models.VacationPeriod{}).Where("shop_id = ? AND ended_at IS NULL", Change.ShopID).Update("ended_at", Change.At).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.Shop{}).Where("id = ?", Change.ShopID).Update("on_vacation", Change.OnVacation).Error; err != nil {
+			return err
+		}
+		if err := createShopNotifications(tx, Change.ShopID, Change.Kind, Change.Message); err != nil {
+			return err
+		}
+		recorded = true
+		return nil
+	})
+	if err != nil {
+		return false, utils.HandleError(err, "error while recording vacation change")
+	}
+	return recorded, nil
+}
+
+func (d *DataBase) GetVacationPeriodsByShopID(ShopID uint) ([]models.VacationPeriod, error) {
+	periods := []models.VacationPeriod{}
+
+	if err := d.DB.Where("shop_id = ?", ShopID).Order("started_at asc").Find(&periods).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving vacation periods")
+	}
+	return periods, nil
+}
+
+// CreateShopNotifications adds the same notification for every account following the shop.
+func (d *DataBase) CreateShopNotifications(ShopID uint, Kind, Message string) error {
+	if err := createShopNotifications(d.DB, ShopID, Kind, Message); err != nil {
+		return utils.HandleError(err, "error while notifying followers")
+	}
+	return nil
+}
+
+func createShopNotifications(tx *gorm.DB, ShopID uint, Kind, Message string) error {
+	return tx.Exec("INSERT INTO notifications (account_id, shop_id, kind, message, read, created_at) SELECT account_id, ?, ?, ?, false, ? FROM account_shop_following WHERE shop_id = ?", ShopID, Kind, Message, time.Now(), ShopID).Error
+}
+
 func (d *DataBase) FetchShopByID(ID uint) (*models.Shop, error) {
 	shop := models.Shop{}
 	if err := d.DB.Preload("Member").Preload("ShopMenu.Menu").Preload("Reviews.ReviewsTopic").Where("id = ?", ID).First(&shop).Error; err != nil {
@@ -146,6 +531,16 @@ func (d *DataBase) FetchShopByID(ID uint) (*models.Shop, error) {
 	return &shop, nil
 }
 
+// GetShopSalesByID reads only the counters of a shop, for a job that must not work on a copy
+// read before it took the shop's lock.
+func (d *DataBase) GetShopSalesByID(ID uint) (*models.Shop, error) {
+	shop := models.Shop{}
+	if err := d.DB.Select("id", "total_sales", "admirers", "on_vacation").Where("id = ?", ID).First(&shop).Error; err != nil {
+		return nil, utils.HandleError(err, "no Shop was Found ")
+	}
+	return &shop, nil
+}
+
 func (d *DataBase) FetchStatsByPeriod(ShopID uint, timePeriod time.Time) ([]models.DailyShopSales, error) {
 	dailyShopSales := []models.DailyShopSales{}
 
@@ -164,32 +559,83 @@ func (d *DataBase) FetchSoldItemsByListingID(listingIDs []uint) ([]models.SoldIt
 	return Solditems, nil
 }
 
-func (d *DataBase) FetchItemsBySoldItems(soldItemID uint) (models.Item, error) {
+// soldItemDay is the UTC day a sold item was saved on, the day its rollups are kept under.
+const soldItemDay = "DATE(sold_items.created_at AT TIME ZONE 'UTC')"
 
-	item := models.Item{}
-	if err := d.DB.Raw("SELECT items.* FROM items JOIN sold_items ON items.id = sold_items.item_id WHERE sold_items.id = (?)", soldItemID).Scan(&item).Error; err != nil {
-		return item, utils.HandleError(err, "error parsing sold items")
+func addSalesRollups(tx *gorm.DB, ShopID uint, SoldItemIDs []uint) error {
+	if len(SoldItemIDs) == 0 {
+		return nil
 	}
 
-	return item, nil
+	if err := tx.Exec("INSERT INTO item_daily_rollups (created_at, updated_at, shop_id, item_id, date, sold_units) "+
+		"SELECT NOW(), NOW(), ?, sold_items.item_id, "+soldItemDay+", COUNT(sold_items.id) FROM sold_items "+
+		"WHERE sold_items.id IN ? AND sold_items.item_id <> 0 AND sold_items.from_history = false GROUP BY sold_items.item_id, "+soldItemDay+" "+
+		"ON CONFLICT (item_id, date) DO UPDATE SET sold_units = item_daily_rollups.sold_units + excluded.sold_units, updated_at = excluded.updated_at",
+		ShopID, SoldItemIDs).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec("INSERT INTO shop_daily_rollups (created_at, updated_at, shop_id, date, sold_units) "+
+		"SELECT NOW(), NOW(), ?, "+soldItemDay+", COUNT(sold_items.id) FROM sold_items "+
+		"WHERE sold_items.id IN ? AND sold_items.item_id <> 0 AND sold_items.from_history = false GROUP BY "+soldItemDay+" "+
+		"ON CONFLICT (shop_id, date) DO UPDATE SET sold_units = shop_daily_rollups.sold_units + excluded.sold_units, updated_at = excluded.updated_at",
+		ShopID, SoldItemIDs).Error
 }
 
-func (d *DataBase) GetSoldItemsInRange(fromDate time.Time, ShopID uint) ([]models.SoldItems, error) {
-	soldItems := []models.SoldItems{}
-	tillDate := fromDate.Add(24 * time.Hour)
-
-	if err := d.DB.Table("shops").
-		Select("sold_items.*").
-		Joins("JOIN shop_menus ON shops.id = shop_menus.shop_id").
-		Joins("JOIN menu_items ON shop_menus.id = menu_items.shop_menu_id").
-		Joins("JOIN items ON menu_items.id = items.menu_item_id").
-		Joins("JOIN sold_items ON items.id = sold_items.item_id").
-		Where("shops.id = ? AND sold_items.created_at BETWEEN ? AND ?", ShopID, fromDate, tillDate).
-		Find(&soldItems).Error; err != nil {
-		return nil, utils.HandleError(err)
+// rebuildSalesRollups counts the rollups of a shop again from all of its sold items tracked as
+// they sold.
+func rebuildSalesRollups(tx *gorm.DB, ShopID uint) error {
+	if err := tx.Unscoped().Where("shop_id = ?", ShopID).Delete(&models.ItemDailyRollup{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("shop_id = ?", ShopID).Delete(&models.ShopDailyRollup{}).Error; err != nil {
+		return err
 	}
 
-	return soldItems, nil
+	if err := tx.Exec("INSERT INTO item_daily_rollups (created_at, updated_at, shop_id, item_id, date, sold_units) "+
+		"SELECT NOW(), NOW(), shop_menus.shop_id, sold_items.item_id, "+soldItemDay+", COUNT(sold_items.id) FROM sold_items "+
+		"JOIN items ON sold_items.item_id = items.id "+
+		"JOIN menu_items ON items.menu_item_id = menu_items.id "+
+		"JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id "+
+		"WHERE shop_menus.shop_id = ? AND sold_items.deleted_at IS NULL AND sold_items.from_history = false "+
+		"GROUP BY shop_menus.shop_id, sold_items.item_id, "+soldItemDay,
+		ShopID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec("INSERT INTO shop_daily_rollups (created_at, updated_at, shop_id, date, sold_units) "+
+		"SELECT NOW(), NOW(), shop_id, date, SUM(sold_units) FROM item_daily_rollups "+
+		"WHERE shop_id = ? AND deleted_at IS NULL GROUP BY shop_id, date",
+		ShopID).Error
+}
+
+// RebuildSalesRollups replaces the daily rollups of a shop with ones counted from its sold items,
+// for sold items saved before rollups were kept or after they got out of step.
+func (d *DataBase) RebuildSalesRollups(ShopID uint) error {
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		return rebuildSalesRollups(tx, ShopID)
+	}); err != nil {
+		return utils.HandleError(err, "error while rebuilding sales rollups")
+	}
+	return nil
+}
+
+// GetShopRollupsByPeriod returns the rollups from the day of From on. From is passed as a date
+// since comparing a date column with a time converts the date in the session time zone.
+func (d *DataBase) GetShopRollupsByPeriod(ShopID uint, From time.Time) ([]models.ShopDailyRollup, error) {
+	rollups := []models.ShopDailyRollup{}
+	if err := d.DB.Where("shop_id = ? AND date >= ?", ShopID, From.Format("2006-01-02")).Order("date asc").Find(&rollups).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving sales rollups")
+	}
+	return rollups, nil
+}
+
+func (d *DataBase) GetItemRollupsByPeriod(ShopID uint, From time.Time) ([]models.ItemDailyRollup, error) {
+	rollups := []models.ItemDailyRollup{}
+	if err := d.DB.Preload("Item").Where("shop_id = ? AND date >= ?", ShopID, From.Format("2006-01-02")).Order("date asc, item_id asc").Find(&rollups).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving sales rollups")
+	}
+	return rollups, nil
 }
 
 func (d *DataBase) UpdateAccountShopRelation(requestedShop *models.Shop, UserID uuid.UUID) error {
@@ -242,11 +688,186 @@ func (d *DataBase) GetShopWithItemsByShopID(ID uint) (*models.Shop, error) {
 
 func (d *DataBase) GetShopByName(ShopName string) (shop *models.Shop, err error) {
 
-	if err = d.DB.Preload("Member").Preload("ShopMenu.Menu.Items").Preload("Reviews.ReviewsTopic").Where("name = ?", ShopName).First(&shop).Error; err != nil {
+	if err = d.DB.Preload("Member").Preload("ShopMenu.Menu.Items").Preload("Reviews.ReviewsTopic").Where("lower(btrim(name)) = ?", utils.NormalizeShopName(ShopName)).First(&shop).Error; err != nil {
 		return nil, utils.HandleError(err, "no Shop was Found ,error")
 	}
 	return
 }
+
+func (d *DataBase) GetShopWithSoldItemsByShopID(ID uint) (*models.Shop, error) {
+	shop := &models.Shop{}
+	if err := d.DB.Preload("ShopMenu.Menu.Items.SoldUnits").Where("id = ?", ID).First(shop).Error; err != nil {
+		return nil, utils.HandleError(err, "no Shop was Found")
+	}
+	return shop, nil
+}
+
+func (d *DataBase) GetItemsWithHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	items := []models.Item{}
+
+	if err := d.DB.Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Joins("JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id").
+		Where("shop_menus.shop_id = ?", ShopID).
+		Preload("PriceHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at asc")
+		}).
+		Find(&items).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return items, nil
+}
+
+func (d *DataBase) GetItemsWithAttributeHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	items := []models.Item{}
+
+	if err := d.DB.Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Joins("JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id").
+		Where("shop_menus.shop_id = ?", ShopID).
+		Preload("AttributeHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at asc")
+		}).
+		Find(&items).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return items, nil
+}
+
+func (d *DataBase) CreateListingPositions(Positions []models.ListingPosition) error {
+	if err := d.DB.Create(&Positions).Error; err != nil {
+		return utils.HandleError(err, "error while saving listing positions")
+	}
+	return nil
+}
+
+func (d *DataBase) GetListingPositions(ShopID, ListingID uint) ([]models.ListingPosition, error) {
+	positions := []models.ListingPosition{}
+
+	if err := d.DB.Where("shop_id = ? AND listing_id = ?", ShopID, ListingID).Order("created_at asc").Find(&positions).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving listing positions")
+	}
+	return positions, nil
+}
+
+// GetItemSalesByPeriod counts the units of every item of the shop sold on the days from From
+// up to but not including To, from the daily rollups.
+func (d *DataBase) GetItemSalesByPeriod(ShopID uint, From, To time.Time) ([]ItemSales, error) {
+	sales := []ItemSales{}
+
+	if err := d.DB.Table("item_daily_rollups").
+		Select("items.id AS item_id, items.listing_id, items.name, items.original_price, items.currency_symbol, menu_items.category, SUM(item_daily_rollups.sold_units) AS sold_quantity").
+		Joins("JOIN items ON item_daily_rollups.item_id = items.id").
+		Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Where("item_daily_rollups.shop_id = ? AND item_daily_rollups.date >= ? AND item_daily_rollups.date < ? AND item_daily_rollups.deleted_at IS NULL", ShopID, From.Format("2006-01-02"), To.Format("2006-01-02")).
+		Group("items.id, menu_items.category").
+		Scan(&sales).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving item sales")
+	}
+	return sales, nil
+}
+
+// preloadPrices loads the price and sale price changes needed to tell what an item cost on
+// the day it was sold, both oldest first.
+func preloadPrices(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("PriceHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at asc")
+		}).
+		Preload("AttributeHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Where("attribute = ?", models.ItemAttributeSalePrice).Order("created_at asc")
+		})
+}
+
+// GetItemsWithPriceHistoryByShopID loads the shop's items with all of their sold units and with
+// their price history.
+func (d *DataBase) GetItemsWithPriceHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	items := []models.Item{}
+
+	if err := d.DB.Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Joins("JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id").
+		Where("shop_menus.shop_id = ?", ShopID).
+		Preload("SoldUnits").
+		Scopes(preloadPrices).
+		Find(&items).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving item prices")
+	}
+	return items, nil
+}
+
+// GetItemPriceHistoryByShopID loads the shop's items with their price history but without
+// their sold units.
+func (d *DataBase) GetItemPriceHistoryByShopID(ShopID uint) ([]models.Item, error) {
+	items := []models.Item{}
+
+	if err := d.DB.Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Joins("JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id").
+		Where("shop_menus.shop_id = ?", ShopID).
+		Scopes(preloadPrices).
+		Find(&items).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving item prices")
+	}
+	return items, nil
+}
+
+// GetItemPriceHistoryByIDs loads items with only their price history, to price units that
+// were counted elsewhere.
+func (d *DataBase) GetItemPriceHistoryByIDs(ItemIDs []uint) ([]models.Item, error) {
+	items := []models.Item{}
+
+	if err := d.DB.Where("id IN ?", ItemIDs).Scopes(preloadPrices).Find(&items).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving item prices")
+	}
+	return items, nil
+}
+
+// GetSoldItemsByShopID loads the units of the shop's items saved from From on, oldest first,
+// leaving out the units scraped from its selling history.
+func (d *DataBase) GetSoldItemsByShopID(ShopID uint, From time.Time) ([]models.SoldItems, error) {
+	soldItems := []models.SoldItems{}
+
+	ShopItems := d.DB.Table("items").Select("items.id").
+		Joins("JOIN menu_items ON items.menu_item_id = menu_items.id").
+		Joins("JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id").
+		Where("shop_menus.shop_id = ?", ShopID)
+
+	if err := d.DB.Where("item_id IN (?) AND from_history = false AND created_at >= ?", ShopItems, From).
+		Order("created_at asc").Find(&soldItems).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving sold items")
+	}
+	return soldItems, nil
+}
+
+// TagSellingHistory marks the sold units of a shop saved before its first daily snapshot as
+// scraped from its selling history, for units saved before FromHistory was set on ingestion.
+func (d *DataBase) TagSellingHistory(ShopID uint) (int64, error) {
+	result := d.DB.Exec("UPDATE sold_items SET from_history = true "+
+		"WHERE sold_items.from_history = false AND sold_items.deleted_at IS NULL "+
+		"AND sold_items.item_id IN (SELECT items.id FROM items "+
+		"JOIN menu_items ON items.menu_item_id = menu_items.id "+
+		"JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = ?) "+
+		"AND NOT EXISTS (SELECT 1 FROM daily_shop_sales WHERE daily_shop_sales.shop_id = ? "+
+		"AND daily_shop_sales.deleted_at IS NULL AND daily_shop_sales.created_at < sold_items.created_at)",
+		ShopID, ShopID)
+	if result.Error != nil {
+		return 0, utils.HandleError(result.Error, "error while tagging the selling history")
+	}
+	return result.RowsAffected, nil
+}
+
+func (d *DataBase) UpdateDailyRevenue(dailySales []models.DailyShopSales) error {
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		for _, sales := range dailySales {
+			if err := tx.Model(&models.DailyShopSales{}).Where("id = ?", sales.ID).Update("daily_revenue", sales.DailyRevenue).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.HandleError(err, "error while updating daily revenue")
+	}
+	return nil
+}
+
 func (d *DataBase) GetAllShops() (*[]models.Shop, error) {
 	AllShops := &[]models.Shop{}
 
@@ -256,3 +877,399 @@ func (d *DataBase) GetAllShops() (*[]models.Shop, error) {
 
 	return AllShops, nil
 }
+
+func (d *DataBase) CreateRefreshJob(job *models.ShopRefreshJob) error {
+	if err := d.DB.Create(job).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) SaveRefreshJob(job *models.ShopRefreshJob) error {
+	if err := d.DB.Save(job).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) GetLatestRefreshJob(ShopID uint) (*models.ShopRefreshJob, error) {
+	jobs := []models.ShopRefreshJob{}
+
+	if err := d.DB.Where("shop_id = ?", ShopID).Order("requested_at desc").Limit(1).Find(&jobs).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+func (d *DataBase) GetRefreshJobByJobID(JobID uuid.UUID) (*models.ShopRefreshJob, error) {
+	job := &models.ShopRefreshJob{}
+	if err := d.DB.Where("job_id = ?", JobID).First(job).Error; err != nil {
+		return nil, utils.HandleError(err, "no refresh job was Found")
+	}
+	return job, nil
+}
+
+func (d *DataBase) CountRefreshJobsSince(AccountID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+
+	if err := d.DB.Model(&models.ShopRefreshJob{}).Where("account_id = ? AND requested_at > ? AND status <> ?", AccountID, since, "failed").Count(&count).Error; err != nil {
+		return 0, utils.HandleError(err)
+	}
+	return count, nil
+}
+
+// FailStaleRefreshJobs marks the jobs still queued or running that were requested before
+// before, or whose claim was last renewed before claimedBefore, as failed and returns how
+// many it marked.
+func (d *DataBase) FailStaleRefreshJobs(before, claimedBefore time.Time, reason string) (int64, error) {
+	result := d.DB.Model(&models.ShopRefreshJob{}).
+		Where("status IN ? AND (requested_at < ? OR COALESCE(claimed_at, requested_at) < ?)", []string{"queued", "running"}, before, claimedBefore).
+		Updates(map[string]interface{}{"status": "failed", "error": reason, "finished_at": time.Now()})
+	if result.Error != nil {
+		return 0, utils.HandleError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (d *DataBase) RenewRefreshJobClaims(InstanceID string) error {
+	if err := d.DB.Model(&models.ShopRefreshJob{}).Where("claimed_by = ? AND status IN ?", InstanceID, []string{"queued", "running"}).Update("claimed_at", time.Now()).Error; err != nil {
+		return utils.HandleError(err, "error while renewing refresh job claims")
+	}
+	return nil
+}
+
+func (d *DataBase) GetShopRequestByID(ID uint) (*models.ShopRequest, error) {
+	ShopRequest := &models.ShopRequest{}
+	if err := d.DB.Where("id = ?", ID).First(ShopRequest).Error; err != nil {
+		return nil, utils.HandleError(err, "no ShopRequest was Found")
+	}
+	return ShopRequest, nil
+}
+
+func (d *DataBase) GetShopRequestsByStatus(Status string) ([]models.ShopRequest, error) {
+	ShopRequests := []models.ShopRequest{}
+
+	if err := d.DB.Where("status = ?", Status).Order("created_at asc").Find(&ShopRequests).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return ShopRequests, nil
+}
+
+// ClaimShopRequest hands the request to InstanceID unless another instance holds a claim
+// renewed after staleBefore. It returns whether the claim was taken.
+func (d *DataBase) ClaimShopRequest(ID uint, InstanceID string, staleBefore time.Time) (bool, error) {
+	result := d.DB.Model(&models.ShopRequest{}).
+		Where("id = ? AND (claimed_by IS NULL OR claimed_by = '' OR claimed_at IS NULL OR claimed_at < ?)", ID, staleBefore).
+		Updates(map[string]interface{}{"claimed_by": InstanceID, "claimed_at": time.Now()})
+	if result.Error != nil {
+		return false, utils.HandleError(result.Error, "error while claiming shop request")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (d *DataBase) RenewShopRequestClaims(InstanceID string) error {
+	if err := d.DB.Model(&models.ShopRequest{}).Where("claimed_by = ? AND status = ?", InstanceID, "Pending").Update("claimed_at", time.Now()).Error; err != nil {
+		return utils.HandleError(err, "error while renewing shop request claims")
+	}
+	return nil
+}
+
+func (d *DataBase) ReleaseShopRequestClaims(InstanceID string) error {
+	if err := d.DB.Model(&models.ShopRequest{}).Where("claimed_by = ?", InstanceID).Update("claimed_by", "").Error; err != nil {
+		return utils.HandleError(err, "error while releasing shop request claims")
+	}
+	return nil
+}
+
+// LockShop hands the lock of the shop to Owner unless another owner took it after
+// staleBefore. It returns whether the lock was taken.
+func (d *DataBase) LockShop(ShopID uint, Owner string, staleBefore time.Time) (bool, error) {
+	lock := models.ShopLock{ShopID: ShopID, Owner: Owner, LockedAt: time.Now()}
+
+	result := d.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "shop_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner", "locked_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: `"shop_locks"."owner" = '' OR "shop_locks"."locked_at" < ?`, Vars: []interface{}{staleBefore}}}},
+	}).Create(&lock)
+	if result.Error != nil {
+		return false, utils.HandleError(result.Error, "error while locking shop")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (d *DataBase) UnlockShop(ShopID uint, Owner string) error {
+	if err := d.DB.Model(&models.ShopLock{}).Where("shop_id = ? AND owner = ?", ShopID, Owner).Update("owner", "").Error; err != nil {
+		return utils.HandleError(err, "error while unlocking shop")
+	}
+	return nil
+}
+
+func (d *DataBase) SaveScrapeCheckpoints(checkpoints []models.ScrapeCheckpoint) error {
+	if err := d.DB.Create(&checkpoints).Error; err != nil {
+		return utils.HandleError(err, "error while saving scrape checkpoints")
+	}
+	return nil
+}
+
+func (d *DataBase) GetScrapeCheckpoints() ([]models.ScrapeCheckpoint, error) {
+	checkpoints := []models.ScrapeCheckpoint{}
+
+	if err := d.DB.Order("id asc").Find(&checkpoints).Error; err != nil {
+		return nil, utils.HandleError(err)
+	}
+	return checkpoints, nil
+}
+
+func (d *DataBase) DeleteScrapeCheckpoint(ID uint) error {
+	if err := d.DB.Unscoped().Delete(&models.ScrapeCheckpoint{}, ID).Error; err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+func (d *DataBase) MergeShops(merge *ShopMerge) error {
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+
+		if len(merge.MovedMenus) > 0 {
+			if err := tx.Model(&models.MenuItem{}).Where("id IN ?", merge.MovedMenus).Update("shop_menu_id", merge.TargetShopMenuID).Error; err != nil {
+				return err
+			}
+		}
+
+		for itemID, menuID := range merge.MovedItems {
+			if err := tx.Model(&models.Item{}).Where("id = ?", itemID).Update("menu_item_id", menuID).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(merge.DroppedSoldItems) > 0 {
+			if err := tx.Delete(&models.SoldItems{}, merge.DroppedSoldItems).Error; err != nil {
+				return err
+			}
+		}
+
+		for sourceItemID, targetItemID := range merge.MergedItems {
+			if err := tx.Model(&models.SoldItems{}).Where("item_id = ?", sourceItemID).Update("item_id", targetItemID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ItemHistoryChange{}).Where("item_id = ?", sourceItemID).Update("item_id", targetItemID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ItemAttributeChange{}).Where("item_id = ?", sourceItemID).Update("item_id", targetItemID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Item{}, sourceItemID).Error; err != nil {
+				return err
+			}
+		}
+
+		for sourceMenuID, targetMenuID := range merge.MergedMenus {
+			if err := tx.Model(&models.MenuHistoryChange{}).Where("menu_item_id = ?", sourceMenuID).Update("menu_item_id", targetMenuID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ItemHistoryChange{}).Where("old_menu_item_id = ?", sourceMenuID).Update("old_menu_item_id", targetMenuID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ItemHistoryChange{}).Where("new_menu_item_id = ?", sourceMenuID).Update("new_menu_item_id", targetMenuID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.MenuHistoryChange{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+
+		if len(merge.DroppedMenus) > 0 {
+			if err := tx.Delete(&models.MenuItem{}, merge.DroppedMenus).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(merge.DroppedDailySales) > 0 {
+			if err := tx.Delete(&models.DailyShopSales{}, merge.DroppedDailySales).Error; err != nil {
+				return err
+			}
+		}
+		if len(merge.MovedDailySales) > 0 {
+			if err := tx.Model(&models.DailyShopSales{}).Where("id IN ?", merge.MovedDailySales).Update("shop_id", merge.TargetShopID).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(merge.DroppedDailyReviews) > 0 {
+			if err := tx.Delete(&models.DailyShopReviews{}, merge.DroppedDailyReviews).Error; err != nil {
+				return err
+			}
+		}
+		if len(merge.MovedDailyReviews) > 0 {
+			if err := tx.Model(&models.DailyShopReviews{}).Where("id IN ?", merge.MovedDailyReviews).Update("shop_id", merge.TargetShopID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("INSERT INTO account_shop_following (account_id, shop_id) SELECT account_id, ? FROM account_shop_following WHERE shop_id = ? ON CONFLICT DO NOTHING", merge.TargetShopID, merge.SourceShopID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM account_shop_following WHERE shop_id = ?", merge.SourceShopID).Error; err != nil {
+			return err
+		}
+
+		// both shops were scraped from the same Etsy shop, so changes and positions the
+		// target already recorded on the same day are dropped from the source.
+		if err := tx.Where("shop_id = ? AND EXISTS (SELECT 1 FROM shop_history_changes AS target WHERE target.shop_id = ? AND target.deleted_at IS NULL AND target.field = shop_history_changes.field AND target.old_value = shop_history_changes.old_value AND target.new_value = shop_history_changes.new_value AND target.created_at::date = shop_history_changes.created_at::date)",
+			merge.SourceShopID, merge.TargetShopID).Delete(&models.ShopHistoryChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ShopHistoryChange{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("shop_id = ? AND EXISTS (SELECT 1 FROM listing_positions AS target WHERE target.shop_id = ? AND target.deleted_at IS NULL AND target.listing_id = listing_positions.listing_id AND target.created_at::date = listing_positions.created_at::date)",
+			merge.SourceShopID, merge.TargetShopID).Delete(&models.ListingPosition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ListingPosition{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.VacationPeriod{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+		if err := mergeVacationPeriods(tx, merge.TargetShopID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Notification{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ShopRefreshJob{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ScrapeCheckpoint{}).Where("shop_id = ?", merge.SourceShopID).Update("shop_id", merge.TargetShopID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("shop_id = ?", merge.SourceShopID).Delete(&models.ItemDailyRollup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("shop_id = ?", merge.SourceShopID).Delete(&models.ShopDailyRollup{}).Error; err != nil {
+			return err
+		}
+		if err := rebuildSalesRollups(tx, merge.TargetShopID); err != nil {
+			return err
+		}
+
+		// the target keeps its own scraped menu, members, reviews and links, the source's
+		// are dropped with it. Its menu items were moved or dropped above.
+		if err := tx.Where("shop_id = ?", merge.SourceShopID).Delete(&models.ShopMenu{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shop_id = ?", merge.SourceShopID).Delete(&models.ShopMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("reviews_id IN (?)", tx.Model(&models.Reviews{}).Select("id").Where("shop_id = ?", merge.SourceShopID)).Delete(&models.ReviewsTopic{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shop_id = ?", merge.SourceShopID).Delete(&models.Reviews{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shop_id = ?", merge.SourceShopID).Delete(&models.SocialMediaLinks{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Shop{}, merge.SourceShopID).Error
+	})
+	if err != nil {
+		return utils.HandleError(err, "error while merging shops")
+	}
+	return nil
+}
+
+// mergeVacationPeriods folds vacation periods of a shop that overlap into the earliest of
+// them, so a shop merged from two records of the same shop has at most one open period.
+func mergeVacationPeriods(tx *gorm.DB, ShopID uint) error {
+	periods := []models.VacationPeriod{}
+	if err := tx.Where("shop_id = ?", ShopID).Order("started_at asc, id asc").Find(&periods).Error; err != nil {
+		return err
+	}
+
+	droppedPeriods := []uint{}
+	for i := 0; i < len(periods); {
+		kept := periods[i]
+		endChanged := false
+
+		next := i + 1
+		for ; next < len(periods); next++ {
+			period := periods[next]
+			if kept.EndedAt != nil && period.StartedAt.After(*kept.EndedAt) {
+				break
+			}
+			if kept.EndedAt != nil && (period.EndedAt == nil || period.EndedAt.After(*kept.EndedAt)) {
+				kept.EndedAt = period.EndedAt
+				endChanged = true
+			}
+			droppedPeriods = append(droppedPeriods, period.ID)
+		}
+
+		if endChanged {
+			if err := tx.Model(&models.VacationPeriod{}).Where("id = ?", kept.ID).Update("ended_at", kept.EndedAt).Error; err != nil {
+				return err
+			}
+		}
+		i = next
+	}
+
+	if len(droppedPeriods) > 0 {
+		if err := tx.Delete(&models.VacationPeriod{}, droppedPeriods).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDuplicateShopIDs groups the IDs of live shops whose names differ only in case or
+// surrounding spaces, oldest shop first in each group.
+func (d *DataBase) GetDuplicateShopIDs() ([][]uint, error) {
+	rows := []struct {
+		ID             uint
+		NormalizedName string
+	}{}
+
+	if err := d.DB.Model(&models.Shop{}).Select("id, lower(btrim(name)) AS normalized_name").
+		Where("lower(btrim(name)) IN (?)", d.DB.Model(&models.Shop{}).Select("lower(btrim(name))").Group("lower(btrim(name))").Having("COUNT(*) > 1")).
+		Order("normalized_name, id").Scan(&rows).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving duplicate shops")
+	}
+
+	groups := [][]uint{}
+	for i, row := range rows {
+		if i == 0 || rows[i-1].NormalizedName != row.NormalizedName {
+			groups = append(groups, []uint{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], row.ID)
+	}
+	return groups, nil
+}
+
+// CreateShopNameIndex adds the unique index on the normalized shop name and drops the
+// older one built on lower(name), it fails while duplicate shops are left.
+func (d *DataBase) CreateShopNameIndex() error {
+	if err := d.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + models.ShopNameIndex + " ON shops (lower(btrim(name))) WHERE deleted_at IS NULL").Error; err != nil {
+		return utils.HandleError(err, "error while creating the shop name index")
+	}
+	if err := d.DB.Exec("DROP INDEX IF EXISTS idx_shops_normalized_name").Error; err != nil {
+		return utils.HandleError(err, "error while dropping the old shop name index")
+	}
+	return nil
+}
+
+func (d *DataBase) GetSoldItemsByItemIDs(ItemIDs []uint, From time.Time) ([]models.SoldItems, error) {
+	soldItems := []models.SoldItems{}
+	if err := d.DB.Where("item_id IN ? AND created_at >= ?", ItemIDs, From).Order("created_at asc").Find(&soldItems).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving sold items")
+	}
+	return soldItems, nil
+}
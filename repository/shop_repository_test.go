@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 	"gorm.io/gorm"
@@ -62,6 +63,29 @@ func TestCreateShopToDBFail(t *testing.T) {
 	assert.Contains(t, err.Error(), "Failed to save shop")
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 
+}
+func TestCreateShopToDBNameTaken(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	ShopExample := &models.Shop{
+		Name: "exampleShop",
+	}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shops"`)).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: models.ShopNameIndex})
+	sqlMock.ExpectRollback()
+
+	err := ShopRepo.CreateShop(ShopExample)
+
+	assert.ErrorIs(t, err, repository.ErrShopNameTaken)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+
 }
 func TestSaveShopToDB(t *testing.T) {
 
@@ -122,11 +146,15 @@ func TestSaveSoldItemsToDB(t *testing.T) {
 	SoldItems := []models.SoldItems{{Name: "Example", ItemID: 1, ListingID: 12, DataShopID: "1122"}, {Name: "Example2", ItemID: 2, ListingID: 13, DataShopID: "1122"}}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sold_items" ("created_at","updated_at","deleted_at","item_id","listing_id","data_shop_id") VALUES ($1,$2,$3,$4,$5,$6),($7,$8,$9,$10,$11,$12) RETURNING "id"`)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 12, "1122", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 13, "1122").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sold_items" ("created_at","updated_at","deleted_at","item_id","listing_id","data_shop_id","from_history") VALUES ($1,$2,$3,$4,$5,$6,$7),($8,$9,$10,$11,$12,$13,$14) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 12, "1122", false, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 13, "1122", false).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO item_daily_rollups (created_at, updated_at, shop_id, item_id, date, sold_units) SELECT NOW(), NOW(), $1, sold_items.item_id, DATE(sold_items.created_at AT TIME ZONE 'UTC'), COUNT(sold_items.id) FROM sold_items WHERE sold_items.id IN ($2,$3) AND sold_items.item_id <> 0 AND sold_items.from_history = false`)).
+		WithArgs(3, 1, 2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO shop_daily_rollups (created_at, updated_at, shop_id, date, sold_units) SELECT NOW(), NOW(), $1, DATE(sold_items.created_at AT TIME ZONE 'UTC'), COUNT(sold_items.id) FROM sold_items WHERE sold_items.id IN ($2,$3) AND sold_items.item_id <> 0 AND sold_items.from_history = false`)).
+		WithArgs(3, 1, 2).WillReturnResult(sqlmock.NewResult(1, 1))
 	sqlMock.ExpectCommit()
 
-	err := ShopRepo.SaveSoldItemsToDB(SoldItems)
+	err := ShopRepo.SaveSoldItemsToDB(SoldItems, 3)
 
 	assert.NoError(t, err)
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
@@ -143,16 +171,38 @@ func TestSaveSoldItemsToDBFail(t *testing.T) {
 	SoldItems := []models.SoldItems{{Name: "Example", ItemID: 1, ListingID: 12, DataShopID: "1122"}, {Name: "Example2", ItemID: 2, ListingID: 13, DataShopID: "1122"}}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sold_items" ("created_at","updated_at","deleted_at","item_id","listing_id","data_shop_id") VALUES ($1,$2,$3,$4,$5,$6),($7,$8,$9,$10,$11,$12) RETURNING "id"`)).WillReturnError(errors.New("error while saving sold item"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sold_items" ("created_at","updated_at","deleted_at","item_id","listing_id","data_shop_id","from_history") VALUES ($1,$2,$3,$4,$5,$6,$7),($8,$9,$10,$11,$12,$13,$14) RETURNING "id"`)).WillReturnError(errors.New("error while saving sold item"))
 	sqlMock.ExpectRollback()
 
-	err := ShopRepo.SaveSoldItemsToDB(SoldItems)
+	err := ShopRepo.SaveSoldItemsToDB(SoldItems, 3)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "error while saving sold item")
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
+func TestSaveSoldItemsToDBRollupFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	SoldItems := []models.SoldItems{{Name: "Example", ItemID: 1, ListingID: 12, DataShopID: "1122"}}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sold_items"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO item_daily_rollups`)).WillReturnError(errors.New("error while rolling up sales"))
+	sqlMock.ExpectRollback()
+
+	err := ShopRepo.SaveSoldItemsToDB(SoldItems, 3)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while rolling up sales")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
 func TestUpdateDailySalesSuccess(t *testing.T) {
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -373,7 +423,7 @@ func TestFetchSoldItemsByListingIDFail(t *testing.T) {
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
-func TestFetchItemsBySoldItems(t *testing.T) {
+func TestGetShopRollupsByPeriod(t *testing.T) {
 
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -381,18 +431,19 @@ func TestFetchItemsBySoldItems(t *testing.T) {
 
 	ShopRepo := repository.DataBase{DB: MockedDataBase}
 
-	SolditemID := uint(2)
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT items.* FROM items JOIN sold_items ON items.id = sold_items.item_id WHERE sold_items.id = ($1)`)).
-		WithArgs(SolditemID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	From := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_daily_rollups" WHERE (shop_id = $1 AND date >= $2) AND "shop_daily_rollups"."deleted_at" IS NULL ORDER BY date asc`)).
+		WithArgs(2, "2024-04-01").WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "date", "sold_units"}).AddRow(1, 2, From, 4))
 
-	_, err := ShopRepo.FetchItemsBySoldItems(uint(2))
+	rollups, err := ShopRepo.GetShopRollupsByPeriod(2, From)
 
 	assert.NoError(t, err)
+	assert.Len(t, rollups, 1)
+	assert.Equal(t, 4, rollups[0].SoldUnits)
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
-
 }
 
-func TestFetchItemsBySoldItemsFail(t *testing.T) {
+func TestGetShopRollupsByPeriodFail(t *testing.T) {
 
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -400,18 +451,16 @@ func TestFetchItemsBySoldItemsFail(t *testing.T) {
 
 	ShopRepo := repository.DataBase{DB: MockedDataBase}
 
-	SolditemID := uint(2)
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT items.* FROM items JOIN sold_items ON items.id = sold_items.item_id WHERE sold_items.id = ($1)`)).
-		WithArgs(SolditemID).WillReturnError(errors.New("error while hanfdling data"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_daily_rollups"`)).WillReturnError(errors.New("internal error"))
 
-	_, err := ShopRepo.FetchItemsBySoldItems(uint(2))
+	_, err := ShopRepo.GetShopRollupsByPeriod(2, time.Now())
 
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while retrieving sales rollups")
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
-
 }
 
-func TestGetSoldItemsInRange(t *testing.T) {
+func TestGetItemRollupsByPeriod(t *testing.T) {
 
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -419,33 +468,63 @@ func TestGetSoldItemsInRange(t *testing.T) {
 
 	ShopRepo := repository.DataBase{DB: MockedDataBase}
 
-	ShopId := uint(2)
-	fromDate := utils.TruncateDate(time.Now())
+	From := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_daily_rollups" WHERE (shop_id = $1 AND date >= $2) AND "item_daily_rollups"."deleted_at" IS NULL ORDER BY date asc, item_id asc`)).
+		WithArgs(2, "2024-04-01").WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "item_id", "date", "sold_units"}).AddRow(1, 2, 7, From, 3))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE "items"."id" = $1 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "item1"))
 
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT sold_items.* FROM "shops" JOIN shop_menus ON shops.id = shop_menus.shop_id JOIN menu_items ON shop_menus.id = menu_items.shop_menu_id JOIN items ON menu_items.id = items.menu_item_id JOIN sold_items ON items.id = sold_items.item_id WHERE (shops.id = $1 AND sold_items.created_at BETWEEN $2 AND $3) AND "shops"."deleted_at" IS NULL`)).
-		WithArgs(ShopId, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rollups, err := ShopRepo.GetItemRollupsByPeriod(2, From)
 
-	_, err := ShopRepo.GetSoldItemsInRange(fromDate, ShopId)
 	assert.NoError(t, err)
+	assert.Len(t, rollups, 1)
+	assert.Equal(t, 3, rollups[0].SoldUnits)
+	assert.Equal(t, "item1", rollups[0].Item.Name)
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRebuildSalesRollups(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "item_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 5))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "shop_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO item_daily_rollups (created_at, updated_at, shop_id, item_id, date, sold_units) SELECT NOW(), NOW(), shop_menus.shop_id, sold_items.item_id, DATE(sold_items.created_at AT TIME ZONE 'UTC'), COUNT(sold_items.id) FROM sold_items JOIN items ON sold_items.item_id = items.id JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1 AND sold_items.deleted_at IS NULL AND sold_items.from_history = false`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 6))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO shop_daily_rollups (created_at, updated_at, shop_id, date, sold_units) SELECT NOW(), NOW(), shop_id, date, SUM(sold_units) FROM item_daily_rollups WHERE shop_id = $1 AND deleted_at IS NULL GROUP BY shop_id, date`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 4))
+	sqlMock.ExpectCommit()
 
+	err := ShopRepo.RebuildSalesRollups(2)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
-func TestGetSoldItemsInRangeFail(t *testing.T) {
+func TestRebuildSalesRollupsFail(t *testing.T) {
+
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
 	defer testDB.Close()
 
 	ShopRepo := repository.DataBase{DB: MockedDataBase}
 
-	ShopId := uint(2)
-	fromDate := utils.TruncateDate(time.Now())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "item_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnError(errors.New("internal error"))
+	sqlMock.ExpectRollback()
 
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT sold_items.* FROM "shops" JOIN shop_menus ON shops.id = shop_menus.shop_id JOIN menu_items ON shop_menus.id = menu_items.shop_menu_id JOIN items ON menu_items.id = items.menu_item_id JOIN sold_items ON items.id = sold_items.item_id WHERE (shops.id = $1 AND sold_items.created_at BETWEEN $2 AND $3) AND "shops"."deleted_at" IS NULL`)).
-		WithArgs(ShopId, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnError(errors.New("internal error"))
+	err := ShopRepo.RebuildSalesRollups(2)
 
-	_, err := ShopRepo.GetSoldItemsInRange(fromDate, ShopId)
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while rebuilding sales rollups")
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
 func TestUpdateAccountShopRelation(t *testing.T) {
@@ -567,7 +646,7 @@ func TestSaveShopRequestToDB(t *testing.T) {
 		Status:    "Pending",
 	}
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_requests" ("created_at","updated_at","deleted_at","account_id","shop_name","status") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`)).WillReturnError(errors.New("Failed to save ShopRequest"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_requests" ("created_at","updated_at","deleted_at","account_id","shop_name","status","claimed_by","claimed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).WillReturnError(errors.New("Failed to save ShopRequest"))
 	sqlMock.ExpectRollback()
 
 	err := ShopRepo.SaveShopRequestToDB(ShopRequest)
@@ -576,6 +655,101 @@ func TestSaveShopRequestToDB(t *testing.T) {
 	assert.Contains(t, err.Error(), "Failed to save ShopRequest")
 }
 
+func TestClaimShopRequest(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	staleBefore := time.Now().Add(-5 * time.Minute)
+
+	query := regexp.QuoteMeta(`UPDATE "shop_requests" SET "claimed_at"=$1,"claimed_by"=$2,"updated_at"=$3 WHERE (id = $4 AND (claimed_by IS NULL OR claimed_by = '' OR claimed_at IS NULL OR claimed_at < $5)) AND "shop_requests"."deleted_at" IS NULL`)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(query).WithArgs(sqlmock.AnyArg(), "instance-1", sqlmock.AnyArg(), uint(7), staleBefore).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(query).WithArgs(sqlmock.AnyArg(), "instance-1", sqlmock.AnyArg(), uint(8), staleBefore).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectCommit()
+
+	claimed, err := ShopRepo.ClaimShopRequest(7, "instance-1", staleBefore)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = ShopRepo.ClaimShopRequest(8, "instance-1", staleBefore)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestLockShop(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	staleBefore := time.Now().Add(-time.Hour)
+
+	query := regexp.QuoteMeta(`INSERT INTO "shop_locks" ("shop_id","owner","locked_at") VALUES ($1,$2,$3) ON CONFLICT ("shop_id") DO UPDATE SET "owner"="excluded"."owner","locked_at"="excluded"."locked_at" WHERE "shop_locks"."owner" = '' OR "shop_locks"."locked_at" < $4`)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(query).WithArgs(uint(7), "owner-1", sqlmock.AnyArg(), staleBefore).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(query).WithArgs(uint(7), "owner-2", sqlmock.AnyArg(), staleBefore).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectCommit()
+
+	locked, err := ShopRepo.LockShop(7, "owner-1", staleBefore)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	locked, err = ShopRepo.LockShop(7, "owner-2", staleBefore)
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUnlockShop(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_locks" SET "owner"=$1 WHERE shop_id = $2 AND owner = $3`)).
+		WithArgs("", uint(7), "owner-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	assert.NoError(t, ShopRepo.UnlockShop(7, "owner-1"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRenewAndReleaseShopRequestClaims(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_requests" SET "claimed_at"=$1,"updated_at"=$2 WHERE (claimed_by = $3 AND status = $4) AND "shop_requests"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "instance-1", "Pending").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_requests" SET "claimed_by"=$1,"updated_at"=$2 WHERE claimed_by = $3 AND "shop_requests"."deleted_at" IS NULL`)).
+		WithArgs("", sqlmock.AnyArg(), "instance-1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	assert.NoError(t, ShopRepo.RenewShopRequestClaims("instance-1"))
+	assert.NoError(t, ShopRepo.ReleaseShopRequestClaims("instance-1"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
 func TestCreateShopRequestTypeShopSuccess(t *testing.T) {
 
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
@@ -590,7 +764,7 @@ func TestCreateShopRequestTypeShopSuccess(t *testing.T) {
 		Status:    "Pending",
 	}
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_requests" ("created_at","updated_at","deleted_at","account_id","shop_name","status") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_requests" ("created_at","updated_at","deleted_at","account_id","shop_name","status","claimed_by","claimed_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	sqlMock.ExpectCommit()
 
 	err := ShopRepo.SaveShopRequestToDB(ShopRequest)
@@ -660,8 +834,8 @@ func TestGetShopByNameTypeShopSuccess(t *testing.T) {
 	ShopExample := models.Shop{Name: "ExampleShop"}
 	ShopExample.ID = uint(2)
 
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops" WHERE name = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2`)).
-		WithArgs(ShopExample.Name, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(ShopExample.ID, ShopExample.Name))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops" WHERE lower(btrim(name)) = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2`)).
+		WithArgs("exampleshop", 1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(ShopExample.ID, ShopExample.Name))
 
 	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_members" WHERE "shop_members"."shop_id" = $1 AND "shop_members"."deleted_at" IS NULL`)).
 		WithArgs(ShopExample.ID).WillReturnRows(sqlmock.NewRows([]string{"id", "ShopID", "name"}).AddRow(10, ShopExample.ID, "Owner"))
@@ -681,7 +855,7 @@ func TestGetShopByNameTypeShopSuccess(t *testing.T) {
 	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE "items"."menu_item_id" = $1 AND "items"."deleted_at" IS NULL`)).
 		WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"id", "Name", "Available", "MenuItemID"}).AddRow(8, "ItemName", true, 8))
 
-	ShopRepo.GetShopByName("ExampleShop")
+	ShopRepo.GetShopByName(" exampleSHOP ")
 
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
@@ -696,8 +870,8 @@ func TestGetShopByNameTypeShopfail(t *testing.T) {
 	ShopExample := models.Shop{Name: "ExampleShop"}
 	ShopExample.ID = uint(2)
 
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops" WHERE name = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2`)).
-		WithArgs(ShopExample.Name, 1).WillReturnError(errors.New("Error getting shop data"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops" WHERE lower(btrim(name)) = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2`)).
+		WithArgs("exampleshop", 1).WillReturnError(errors.New("Error getting shop data"))
 
 	_, err := ShopRepo.GetShopByName("ExampleShop")
 
@@ -769,11 +943,11 @@ func TestCreateDailySales(t *testing.T) {
 	Admirers := 90
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), ShopID, TotalSales, Admirers, float64(0)).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue","estimated") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), ShopID, TotalSales, Admirers, float64(0), false).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
 	sqlMock.ExpectCommit()
 
-	ShopRepo.CreateDailySales(ShopID, TotalSales, Admirers)
+	ShopRepo.CreateDailySales(ShopID, TotalSales, Admirers, 0)
 
 	assert.Nil(t, sqlMock.ExpectationsWereMet())
 
@@ -791,17 +965,55 @@ func TestCreateDailySalesFail(t *testing.T) {
 	Admirers := 90
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue","estimated") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
 		WillReturnError(errors.New("error while handling database operation"))
 	sqlMock.ExpectRollback()
 
-	err := ShopRepo.CreateDailySales(ShopID, TotalSales, Admirers)
+	err := ShopRepo.CreateDailySales(ShopID, TotalSales, Admirers, 0)
 
 	assert.Contains(t, err.Error(), "error while handling database operation")
 
 	assert.Nil(t, sqlMock.ExpectationsWereMet())
 
 }
+func TestCreateDailySalesAdvancesFence(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "scheduler_fences" ("name","token","updated_at") VALUES ($1,$2,$3) ON CONFLICT ("name") DO UPDATE SET "token"="excluded"."token","updated_at"="excluded"."updated_at" WHERE "scheduler_fences"."token" <= "excluded"."token"`)).
+		WithArgs(repository.SchedulerFenceName, int64(7), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), uint(10), 100, 90, float64(0), false).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.CreateDailySales(10, 100, 90, 7)
+
+	assert.NoError(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateDailySalesStaleFencingToken(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "scheduler_fences"`)).
+		WithArgs(repository.SchedulerFenceName, int64(6), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectRollback()
+
+	err := ShopRepo.CreateDailySales(10, 100, 90, 6)
+
+	assert.True(t, errors.Is(err, repository.ErrStaleFencingToken))
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
 func TestUpdateColumnsInShopSuccess(t *testing.T) {
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -1241,3 +1453,894 @@ func TestCreateNewItemSuccess(t *testing.T) {
 
 	assert.Nil(t, sqlMock.ExpectationsWereMet())
 }
+
+func TestGetLatestRefreshJobNoJob(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_refresh_jobs" WHERE shop_id = $1 AND "shop_refresh_jobs"."deleted_at" IS NULL ORDER BY requested_at desc LIMIT $2`)).WithArgs(uint(2), 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	job, err := ShopRepo.GetLatestRefreshJob(2)
+
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetLatestRefreshJobSuccess(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	rows := sqlmock.NewRows([]string{"id", "shop_id", "status"}).AddRow(4, 2, "running")
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_refresh_jobs" WHERE shop_id = $1 AND "shop_refresh_jobs"."deleted_at" IS NULL ORDER BY requested_at desc LIMIT $2`)).WithArgs(uint(2), 1).WillReturnRows(rows)
+
+	job, err := ShopRepo.GetLatestRefreshJob(2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "running", job.Status)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestFailStaleRefreshJobs(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	before := time.Now().Add(-2 * time.Hour)
+	claimedBefore := time.Now().Add(-5 * time.Minute)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_refresh_jobs" SET "error"=$1,"finished_at"=$2,"status"=$3,"updated_at"=$4 WHERE (status IN ($5,$6) AND (requested_at < $7 OR COALESCE(claimed_at, requested_at) < $8)) AND "shop_refresh_jobs"."deleted_at" IS NULL`)).
+		WithArgs("timed out", sqlmock.AnyArg(), "failed", sqlmock.AnyArg(), "queued", "running", before, claimedBefore).WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	failed, err := ShopRepo.FailStaleRefreshJobs(before, claimedBefore, "timed out")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failed)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRenewRefreshJobClaims(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_refresh_jobs" SET "claimed_at"=$1,"updated_at"=$2 WHERE (claimed_by = $3 AND status IN ($4,$5)) AND "shop_refresh_jobs"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "instance-1", "queued", "running").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	assert.NoError(t, ShopRepo.RenewRefreshJobClaims("instance-1"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetRefreshJobByJobIDFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	JobID := uuid.New()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_refresh_jobs" WHERE job_id = $1 AND "shop_refresh_jobs"."deleted_at" IS NULL ORDER BY "shop_refresh_jobs"."id" LIMIT $2`)).WithArgs(JobID, 1).WillReturnError(gorm.ErrRecordNotFound)
+
+	job, err := ShopRepo.GetRefreshJobByJobID(JobID)
+
+	assert.Nil(t, job)
+	assert.Contains(t, err.Error(), "no refresh job was Found")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCountRefreshJobsSince(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	AccountID := uuid.New()
+	since := time.Now().Add(-24 * time.Hour)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "shop_refresh_jobs" WHERE (account_id = $1 AND requested_at > $2 AND status <> $3) AND "shop_refresh_jobs"."deleted_at" IS NULL`)).WithArgs(AccountID, since, "failed").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	count, err := ShopRepo.CountRefreshJobsSince(AccountID, since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetDailySalesByShopIDFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "daily_shop_sales" WHERE shop_id = $1 AND "daily_shop_sales"."deleted_at" IS NULL ORDER BY created_at asc`)).WithArgs(uint(3)).WillReturnError(errors.New("error while handling db"))
+
+	_, err := ShopRepo.GetDailySalesByShopID(3)
+
+	assert.Error(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSaveDailySalesCreatesEstimatedEntry(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	dailySales := &models.DailyShopSales{ShopID: 3, TotalSales: 10, Admirers: 2, DailyRevenue: 5.5, Estimated: true}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue","estimated") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 10, 2, 5.5, true).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.SaveDailySales(dailySales)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetShopRequestsByStatus(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	rows := sqlmock.NewRows([]string{"id", "shop_name", "status"}).AddRow(7, "exampleShop", "Pending")
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_requests" WHERE status = $1 AND "shop_requests"."deleted_at" IS NULL ORDER BY created_at asc`)).WithArgs("Pending").WillReturnRows(rows)
+
+	ShopRequests, err := ShopRepo.GetShopRequestsByStatus("Pending")
+
+	assert.NoError(t, err)
+	assert.Len(t, ShopRequests, 1)
+	assert.Equal(t, "exampleShop", ShopRequests[0].ShopName)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetShopRequestByIDFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_requests" WHERE id = $1 AND "shop_requests"."deleted_at" IS NULL ORDER BY "shop_requests"."id" LIMIT $2`)).WithArgs(uint(7), 1).WillReturnError(gorm.ErrRecordNotFound)
+
+	ShopRequest, err := ShopRepo.GetShopRequestByID(7)
+
+	assert.Nil(t, ShopRequest)
+	assert.Contains(t, err.Error(), "no ShopRequest was Found")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSaveScrapeCheckpoints(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	checkpoints := []models.ScrapeCheckpoint{
+		{ShopRequestID: 7, Kind: "history", ShopID: 3, Task: models.TaskSchedule{IsPaginationScrapped: true, CurrentPage: 12, LastPage: 40}},
+	}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "scrape_checkpoints" ("created_at","updated_at","deleted_at","shop_request_id","kind","shop_id","task_is_scrape_finished","task_is_pagination_scrapped","task_current_page","task_last_page","task_update_sold_items") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7, "history", 3, false, true, 12, 40, 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.SaveScrapeCheckpoints(checkpoints)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetScrapeCheckpoints(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	rows := sqlmock.NewRows([]string{"id", "shop_request_id", "kind", "task_current_page"}).AddRow(1, 7, "history", 12)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "scrape_checkpoints" WHERE "scrape_checkpoints"."deleted_at" IS NULL ORDER BY id asc`)).WillReturnRows(rows)
+
+	checkpoints, err := ShopRepo.GetScrapeCheckpoints()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 12, checkpoints[0].Task.CurrentPage)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteScrapeCheckpoint(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "scrape_checkpoints" WHERE "scrape_checkpoints"."id" = $1`)).WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.DeleteScrapeCheckpoint(1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetDuplicateShopIDs(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT id, lower(btrim(name)) AS normalized_name FROM "shops" WHERE lower(btrim(name)) IN (SELECT lower(btrim(name)) FROM "shops" WHERE "shops"."deleted_at" IS NULL GROUP BY lower(btrim(name)) HAVING COUNT(*) > 1) AND "shops"."deleted_at" IS NULL ORDER BY normalized_name, id`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "normalized_name"}).
+			AddRow(1, "myshop").AddRow(4, "myshop").AddRow(2, "othershop").AddRow(3, "othershop").AddRow(5, "othershop"))
+
+	groups, err := ShopRepo.GetDuplicateShopIDs()
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]uint{{1, 4}, {2, 3, 5}}, groups)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateShopNameIndex(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_unique_name ON shops (lower(btrim(name))) WHERE deleted_at IS NULL`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS idx_shops_normalized_name`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := ShopRepo.CreateShopNameIndex()
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateShopNameIndexFailsOnDuplicates(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_unique_name`)).
+		WillReturnError(errors.New("could not create unique index"))
+
+	err := ShopRepo.CreateShopNameIndex()
+
+	assert.Error(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMergeShopsSuccess(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	merge := &repository.ShopMerge{
+		TargetShopID:        1,
+		SourceShopID:        2,
+		TargetShopMenuID:    10,
+		MovedMenus:          []uint{21},
+		DroppedMenus:        []uint{22},
+		MergedMenus:         map[uint]uint{22: 20},
+		MovedItems:          map[uint]uint{31: 11},
+		MergedItems:         map[uint]uint{32: 12},
+		DroppedSoldItems:    []uint{41},
+		MovedDailySales:     []uint{51},
+		DroppedDailySales:   []uint{52},
+		MovedDailyReviews:   []uint{61},
+		DroppedDailyReviews: []uint{62},
+	}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_items" SET "shop_menu_id"=$1,"updated_at"=$2 WHERE id IN ($3) AND "menu_items"."deleted_at" IS NULL`)).
+		WithArgs(10, sqlmock.AnyArg(), 21).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "menu_item_id"=$1,"updated_at"=$2 WHERE id = $3 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(11, sqlmock.AnyArg(), 31).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "sold_items" SET "deleted_at"=$1 WHERE "sold_items"."id" = $2 AND "sold_items"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 41).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "sold_items" SET "item_id"=$1,"updated_at"=$2 WHERE item_id = $3 AND "sold_items"."deleted_at" IS NULL`)).
+		WithArgs(12, sqlmock.AnyArg(), 32).WillReturnResult(sqlmock.NewResult(1, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "item_history_changes" SET "item_id"=$1,"updated_at"=$2 WHERE item_id = $3 AND "item_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(12, sqlmock.AnyArg(), 32).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "item_attribute_changes" SET "item_id"=$1,"updated_at"=$2 WHERE item_id = $3 AND "item_attribute_changes"."deleted_at" IS NULL`)).
+		WithArgs(12, sqlmock.AnyArg(), 32).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "deleted_at"=$1 WHERE "items"."id" = $2 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 32).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_history_changes" SET "menu_item_id"=$1,"updated_at"=$2 WHERE menu_item_id = $3 AND "menu_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(20, sqlmock.AnyArg(), 22).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "item_history_changes" SET "old_menu_item_id"=$1,"updated_at"=$2 WHERE old_menu_item_id = $3 AND "item_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(20, sqlmock.AnyArg(), 22).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "item_history_changes" SET "new_menu_item_id"=$1,"updated_at"=$2 WHERE new_menu_item_id = $3 AND "item_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(20, sqlmock.AnyArg(), 22).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_history_changes" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "menu_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_items" SET "deleted_at"=$1 WHERE "menu_items"."id" = $2 AND "menu_items"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 22).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_sales" SET "deleted_at"=$1 WHERE "daily_shop_sales"."id" = $2 AND "daily_shop_sales"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 52).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_sales" SET "shop_id"=$1,"updated_at"=$2 WHERE id IN ($3) AND "daily_shop_sales"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 51).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_reviews" SET "deleted_at"=$1 WHERE "daily_shop_reviews"."id" = $2 AND "daily_shop_reviews"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 62).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_reviews" SET "shop_id"=$1,"updated_at"=$2 WHERE id IN ($3) AND "daily_shop_reviews"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 61).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO account_shop_following (account_id, shop_id) SELECT account_id, $1 FROM account_shop_following WHERE shop_id = $2 ON CONFLICT DO NOTHING`)).
+		WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM account_shop_following WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_history_changes" SET "deleted_at"=$1 WHERE (shop_id = $2 AND EXISTS (SELECT 1 FROM shop_history_changes AS target WHERE target.shop_id = $3`)).
+		WithArgs(sqlmock.AnyArg(), 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_history_changes" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "shop_history_changes"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "listing_positions" SET "deleted_at"=$1 WHERE (shop_id = $2 AND EXISTS (SELECT 1 FROM listing_positions AS target WHERE target.shop_id = $3`)).
+		WithArgs(sqlmock.AnyArg(), 2, 1).WillReturnResult(sqlmock.NewResult(1, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "listing_positions" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "listing_positions"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 4))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "vacation_periods" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "vacation_periods"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vacation_periods" WHERE shop_id = $1 AND "vacation_periods"."deleted_at" IS NULL ORDER BY started_at asc, id asc`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "started_at", "ended_at"}).
+		AddRow(70, 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)).
+		AddRow(71, 1, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), nil).
+		AddRow(72, 1, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), nil))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "vacation_periods" SET "ended_at"=$1,"updated_at"=$2 WHERE id = $3 AND "vacation_periods"."deleted_at" IS NULL`)).
+		WithArgs(nil, sqlmock.AnyArg(), 70).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "vacation_periods" SET "deleted_at"=$1 WHERE "vacation_periods"."id" IN ($2,$3) AND "vacation_periods"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 71, 72).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "notifications" SET "shop_id"=$1 WHERE shop_id = $2`)).
+		WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(1, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_refresh_jobs" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "shop_refresh_jobs"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 0))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "scrape_checkpoints" SET "shop_id"=$1,"updated_at"=$2 WHERE shop_id = $3 AND "scrape_checkpoints"."deleted_at" IS NULL`)).
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 0))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "item_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "shop_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "item_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 4))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "shop_daily_rollups" WHERE shop_id = $1`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO item_daily_rollups`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 5))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO shop_daily_rollups`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_menus" SET "deleted_at"=$1 WHERE shop_id = $2 AND "shop_menus"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_members" SET "deleted_at"=$1 WHERE shop_id = $2 AND "shop_members"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "reviews_topics" SET "deleted_at"=$1 WHERE reviews_id IN (SELECT "id" FROM "reviews" WHERE shop_id = $2 AND "reviews"."deleted_at" IS NULL) AND "reviews_topics"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 4))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "reviews" SET "deleted_at"=$1 WHERE shop_id = $2 AND "reviews"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "social_media_links" SET "deleted_at"=$1 WHERE shop_id = $2 AND "social_media_links"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 2))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops" SET "deleted_at"=$1 WHERE "shops"."id" = $2 AND "shops"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.MergeShops(merge)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMergeShopsRollsBackOnError(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	merge := &repository.ShopMerge{
+		TargetShopID:     1,
+		SourceShopID:     2,
+		TargetShopMenuID: 10,
+		MovedMenus:       []uint{21},
+	}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_items" SET "shop_menu_id"=$1,"updated_at"=$2 WHERE id IN ($3) AND "menu_items"."deleted_at" IS NULL`)).
+		WithArgs(10, sqlmock.AnyArg(), 21).WillReturnError(errors.New("error while moving menus"))
+	sqlMock.ExpectRollback()
+
+	err := ShopRepo.MergeShops(merge)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while merging shops")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetShopWithSoldItemsByShopIDNotFound(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops" WHERE id = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2`)).
+		WithArgs(3, 1).WillReturnError(gorm.ErrRecordNotFound)
+
+	_, err := ShopRepo.GetShopWithSoldItemsByShopID(3)
+
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemsWithHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "items"."id","items"."created_at","items"."updated_at","items"."deleted_at","items"."name","items"."original_price","items"."currency_symbol","items"."sale_price","items"."discout_percent","items"."available","items"."item_link","items"."menu_item_id","items"."listing_id","items"."data_shop_id" FROM "items" JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "available"}).AddRow(4, false))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_history_changes" WHERE "item_history_changes"."item_id" = $1 AND "item_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "new_available"}).AddRow(1, 4, false))
+
+	items, err := ShopRepo.GetItemsWithHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, items[0].PriceHistory, 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetMenuHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "menu_history_changes" WHERE shop_id = $1 AND "menu_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "event"}).AddRow(1, 1, "renamed").AddRow(2, 1, "deleted"))
+
+	changes, err := ShopRepo.GetMenuHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetShopHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_history_changes" WHERE shop_id = $1 AND "shop_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "field"}).AddRow(1, 1, "location").AddRow(2, 1, "rating"))
+
+	changes, err := ShopRepo.GetShopHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateShopProfileReplacesMembers(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	Shop := &models.Shop{Member: []models.ShopMember{{Name: "Tom", Role: "Maker"}}}
+	Shop.ID = 1
+	Changes := []models.ShopHistoryChange{{ShopID: 1, Field: models.ShopFieldMembers, OldValue: "Jane (Owner)", NewValue: "Tom (Maker)"}}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_history_changes"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_members" SET "deleted_at"=$1 WHERE shop_id = $2`)).
+		WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_members"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "Tom", "Maker").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.UpdateShopProfile(Shop, Changes)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateShopProfileRollsBackOnError(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	Shop := &models.Shop{Location: "Leeds"}
+	Shop.ID = 1
+	Changes := []models.ShopHistoryChange{{ShopID: 1, Field: models.ShopFieldLocation, OldValue: "London", NewValue: "Leeds"}}
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_history_changes"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops"`)).WillReturnError(errors.New("update failed"))
+	sqlMock.ExpectRollback()
+
+	err := ShopRepo.UpdateShopProfile(Shop, Changes)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while updating shop profile")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateDailyReviewsWithTopics(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	DailyReviews := &models.DailyShopReviews{
+		ShopID:       1,
+		ShopRating:   4.9,
+		ReviewsCount: 123,
+		Topics:       []models.DailyReviewsTopic{{Keyword: "quality", KeywordCount: 40}},
+	}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_reviews" ("created_at","updated_at","deleted_at","shop_id","shop_rating","reviews_count") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 4.9, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_reviews_topics"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7, "quality", 40).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.CreateDailyReviews(DailyReviews)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestFetchReviewsStatsByPeriod(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	Period := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "daily_shop_reviews" WHERE (shop_id = $1 AND created_at > $2) AND "daily_shop_reviews"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(1, Period).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "shop_rating", "reviews_count"}).AddRow(1, 1, 4.8, 120).AddRow(2, 1, 4.9, 123))
+
+	dailyReviews, err := ShopRepo.FetchReviewsStatsByPeriod(1, Period)
+
+	assert.NoError(t, err)
+	assert.Len(t, dailyReviews, 2)
+	assert.Equal(t, 123, dailyReviews[1].ReviewsCount)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetVacationPeriodsByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	started := time.Date(2024, 5, 2, 15, 12, 0, 0, time.UTC)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vacation_periods" WHERE shop_id = $1 AND "vacation_periods"."deleted_at" IS NULL ORDER BY started_at asc`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "started_at", "ended_at", "message"}).AddRow(1, 1, started, nil, "Back soon"))
+
+	periods, err := ShopRepo.GetVacationPeriodsByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, periods, 1)
+	assert.Nil(t, periods[0].EndedAt)
+	assert.Equal(t, "Back soon", periods[0].Message)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateShopNotificationsFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notifications`)).WillReturnError(errors.New("insert failed"))
+
+	err := ShopRepo.CreateShopNotifications(1, models.NotificationVacationEnded, "Shop 1 is back from vacation")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while notifying followers")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemsWithAttributeHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "items"."id","items"."created_at","items"."updated_at","items"."deleted_at","items"."name","items"."original_price","items"."currency_symbol","items"."sale_price","items"."discout_percent","items"."available","items"."item_link","items"."menu_item_id","items"."listing_id","items"."data_shop_id" FROM "items" JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Solid oak wall shelf"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_attribute_changes" WHERE "item_attribute_changes"."item_id" = $1 AND "item_attribute_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "attribute"}).AddRow(1, 4, "name"))
+
+	items, err := ShopRepo.GetItemsWithAttributeHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, items[0].AttributeHistory, 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetListingPositions(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "listing_positions" WHERE (shop_id = $1 AND listing_id = $2) AND "listing_positions"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(1, 1563984521).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "listing_id", "position", "featured"}).AddRow(1, 1, 1563984521, 3, false))
+
+	positions, err := ShopRepo.GetListingPositions(1, 1563984521)
+
+	assert.NoError(t, err)
+	assert.Len(t, positions, 1)
+	assert.Equal(t, 3, positions[0].Position)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemSalesByPeriod(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT items.id AS item_id, items.listing_id, items.name, items.original_price, items.currency_symbol, menu_items.category, SUM(item_daily_rollups.sold_units) AS sold_quantity FROM "item_daily_rollups" JOIN items ON item_daily_rollups.item_id = items.id JOIN menu_items ON items.menu_item_id = menu_items.id WHERE item_daily_rollups.shop_id = $1 AND item_daily_rollups.date >= $2 AND item_daily_rollups.date < $3 AND item_daily_rollups.deleted_at IS NULL GROUP BY items.id, menu_items.category`)).
+		WithArgs(1, "2024-06-01", "2024-06-08").
+		WillReturnRows(sqlmock.NewRows([]string{"item_id", "listing_id", "name", "original_price", "currency_symbol", "category", "sold_quantity"}).
+			AddRow(4, 1563984521, "Oak shelf", 40.5, "€", "Shelves", 3))
+
+	sales, err := ShopRepo.GetItemSalesByPeriod(1, from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []repository.ItemSales{{ItemID: 4, ListingID: 1563984521, Name: "Oak shelf", OriginalPrice: 40.5, CurrencySymbol: "€", Category: "Shelves", SoldQuantity: 3}}, sales)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemsWithPriceHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.MatchExpectationsInOrder(false)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "items"."id","items"."created_at","items"."updated_at","items"."deleted_at","items"."name","items"."original_price","items"."currency_symbol","items"."sale_price","items"."discout_percent","items"."available","items"."item_link","items"."menu_item_id","items"."listing_id","items"."data_shop_id" FROM "items" JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "original_price"}).AddRow(4, 30))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sold_items" WHERE "sold_items"."item_id" = $1 AND "sold_items"."deleted_at" IS NULL`)).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(1, 4).AddRow(2, 4))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_history_changes" WHERE "item_history_changes"."item_id" = $1 AND "item_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "old_price", "new_price"}).AddRow(1, 4, 20, 30))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_attribute_changes" WHERE attribute = $1 AND "item_attribute_changes"."item_id" = $2 AND "item_attribute_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(models.ItemAttributeSalePrice, 4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "attribute"}))
+
+	items, err := ShopRepo.GetItemsWithPriceHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, items[0].SoldUnits, 2)
+	assert.Len(t, items[0].PriceHistory, 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemPriceHistoryByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.MatchExpectationsInOrder(false)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "items"."id","items"."created_at","items"."updated_at","items"."deleted_at","items"."name","items"."original_price","items"."currency_symbol","items"."sale_price","items"."discout_percent","items"."available","items"."item_link","items"."menu_item_id","items"."listing_id","items"."data_shop_id" FROM "items" JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1 AND "items"."deleted_at" IS NULL`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "original_price"}).AddRow(4, 30))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_history_changes" WHERE "item_history_changes"."item_id" = $1 AND "item_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "old_price", "new_price"}).AddRow(1, 4, 20, 30))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_attribute_changes" WHERE attribute = $1 AND "item_attribute_changes"."item_id" = $2 AND "item_attribute_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(models.ItemAttributeSalePrice, 4).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "attribute"}))
+
+	items, err := ShopRepo.GetItemPriceHistoryByShopID(1)
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Len(t, items[0].PriceHistory, 1)
+	assert.Empty(t, items[0].SoldUnits)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetItemPriceHistoryByIDs(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.MatchExpectationsInOrder(false)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE id IN ($1,$2) AND "items"."deleted_at" IS NULL`)).
+		WithArgs(4, 5).WillReturnRows(sqlmock.NewRows([]string{"id", "original_price"}).AddRow(4, 30).AddRow(5, 12))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_history_changes" WHERE "item_history_changes"."item_id" IN ($1,$2) AND "item_history_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4, 5).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "old_price", "new_price"}).AddRow(1, 4, 20, 30))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "item_attribute_changes" WHERE attribute = $1 AND "item_attribute_changes"."item_id" IN ($2,$3) AND "item_attribute_changes"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(models.ItemAttributeSalePrice, 4, 5).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id", "attribute"}))
+
+	items, err := ShopRepo.GetItemPriceHistoryByIDs([]uint{4, 5})
+
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Len(t, items[0].PriceHistory, 1)
+	assert.Empty(t, items[0].SoldUnits)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTagSellingHistory(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE sold_items SET from_history = true WHERE sold_items.from_history = false AND sold_items.deleted_at IS NULL AND sold_items.item_id IN (SELECT items.id FROM items JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1) AND NOT EXISTS (SELECT 1 FROM daily_shop_sales WHERE daily_shop_sales.shop_id = $2 AND daily_shop_sales.deleted_at IS NULL AND daily_shop_sales.created_at < sold_items.created_at)`)).
+		WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(0, 40))
+
+	tagged, err := ShopRepo.TagSellingHistory(2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(40), tagged)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTagSellingHistoryFail(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE sold_items SET from_history = true`)).
+		WithArgs(2, 2).WillReturnError(errors.New("internal error"))
+
+	_, err := ShopRepo.TagSellingHistory(2)
+
+	assert.ErrorContains(t, err, "error while tagging the selling history")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateDailyRevenue(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	dailySales := models.DailyShopSales{DailyRevenue: 45.5}
+	dailySales.ID = 3
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_sales" SET "daily_revenue"=$1,"updated_at"=$2 WHERE id = $3 AND "daily_shop_sales"."deleted_at" IS NULL`)).
+		WithArgs(45.5, sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := ShopRepo.UpdateDailyRevenue([]models.DailyShopSales{dailySales})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetSoldItemsByItemIDs(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	from := utils.TruncateDate(time.Now())
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sold_items" WHERE (item_id IN ($1,$2) AND created_at >= $3) AND "sold_items"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(4, 5, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(1, 4).AddRow(2, 5))
+
+	soldItems, err := ShopRepo.GetSoldItemsByItemIDs([]uint{4, 5}, from)
+
+	assert.NoError(t, err)
+	assert.Len(t, soldItems, 2)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetSoldItemsByShopID(t *testing.T) {
+
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := repository.DataBase{DB: MockedDataBase}
+
+	from := utils.TruncateDate(time.Now())
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sold_items" WHERE (item_id IN (SELECT items.id FROM "items" JOIN menu_items ON items.menu_item_id = menu_items.id JOIN shop_menus ON menu_items.shop_menu_id = shop_menus.id WHERE shop_menus.shop_id = $1) AND from_history = false AND created_at >= $2) AND "sold_items"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(2, from).WillReturnRows(sqlmock.NewRows([]string{"id", "item_id"}).AddRow(1, 4).AddRow(2, 5))
+
+	soldItems, err := ShopRepo.GetSoldItemsByShopID(2, from)
+
+	assert.NoError(t, err)
+	assert.Len(t, soldItems, 2)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -27,6 +27,8 @@ type UserRepository interface {
 	CreateAccount(newAccount *models.Account) (*models.Account, error)
 	InsertTokenForAccount(column, token string, VerifyUser *models.Account) (*models.Account, error)
 	GetAccountWithShops(accountID uuid.UUID) (*models.Account, error)
+	GetNotificationsByAccountID(AccountID uuid.UUID, UnreadOnly bool) ([]models.Notification, error)
+	MarkNotificationsRead(AccountID uuid.UUID) error
 }
 
 func (d *DataBase) GetAccountByID(ID uuid.UUID) (account *models.Account, err error) {
@@ -143,3 +145,23 @@ func (s *DataBase) GetAccountWithShops(accountID uuid.UUID) (*models.Account, er
 	}
 	return account, nil
 }
+
+func (d *DataBase) GetNotificationsByAccountID(AccountID uuid.UUID, UnreadOnly bool) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+
+	query := d.DB.Where("account_id = ?", AccountID)
+	if UnreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	if err := query.Order("created_at desc").Find(&notifications).Error; err != nil {
+		return nil, utils.HandleError(err, "error while retrieving notifications")
+	}
+	return notifications, nil
+}
+
+func (d *DataBase) MarkNotificationsRead(AccountID uuid.UUID) error {
+	if err := d.DB.Model(&models.Notification{}).Where("account_id = ? AND read = ?", AccountID, false).Update("read", true).Error; err != nil {
+		return utils.HandleError(err, "error while updating notifications")
+	}
+	return nil
+}
@@ -351,7 +351,7 @@ func TestSaveAccount(t *testing.T) {
 	User := repository.DataBase{DB: MockedDataBase}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "accounts" SET "created_at"=$1,"updated_at"=$2,"deleted_at"=$3,"first_name"=$4,"last_name"=$5,"email"=$6,"password_hashed"=$7,"subscription_type"=$8,"email_verified"=$9,"email_verification_token"=$10,"request_change_pass"=$11,"account_pass_reset_token"=$12,"last_time_logged_in"=$13,"last_time_logged_out"=$14 WHERE "accounts"."deleted_at" IS NULL AND "id" = $15`)).
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "accounts" SET "created_at"=$1,"updated_at"=$2,"deleted_at"=$3,"first_name"=$4,"last_name"=$5,"email"=$6,"password_hashed"=$7,"subscription_type"=$8,"time_zone"=$9,"email_verified"=$10,"email_verification_token"=$11,"request_change_pass"=$12,"account_pass_reset_token"=$13,"last_time_logged_in"=$14,"last_time_logged_out"=$15 WHERE "accounts"."deleted_at" IS NULL AND "id" = $16`)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	sqlMock.ExpectCommit()
 
@@ -371,7 +371,7 @@ func TestSaveAccountFail(t *testing.T) {
 	User := repository.DataBase{DB: MockedDataBase}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "accounts" SET "created_at"=$1,"updated_at"=$2,"deleted_at"=$3,"first_name"=$4,"last_name"=$5,"email"=$6,"password_hashed"=$7,"subscription_type"=$8,"email_verified"=$9,"email_verification_token"=$10,"request_change_pass"=$11,"account_pass_reset_token"=$12,"last_time_logged_in"=$13,"last_time_logged_out"=$14 WHERE "accounts"."deleted_at" IS NULL AND "id" = $15`)).
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "accounts" SET "created_at"=$1,"updated_at"=$2,"deleted_at"=$3,"first_name"=$4,"last_name"=$5,"email"=$6,"password_hashed"=$7,"subscription_type"=$8,"time_zone"=$9,"email_verified"=$10,"email_verification_token"=$11,"request_change_pass"=$12,"account_pass_reset_token"=$13,"last_time_logged_in"=$14,"last_time_logged_out"=$15 WHERE "accounts"."deleted_at" IS NULL AND "id" = $16`)).
 		WillReturnError(errors.New("error while saving to database"))
 	sqlMock.ExpectRollback()
 
@@ -401,8 +401,8 @@ func TestCreateAccount(t *testing.T) {
 	User := repository.DataBase{DB: MockedDataBase}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "accounts" ("id","created_at","updated_at","deleted_at","first_name","last_name","email","password_hashed","subscription_type","email_verified","email_verification_token","request_change_pass","account_pass_reset_token","last_time_logged_in","last_time_logged_out") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING "id"`)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "Example", "Test", "Example@Exampleemail.com", "asdasdasd", "free", false, "JustAnotherToken", false, "", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"1", "15"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "accounts" ("id","created_at","updated_at","deleted_at","first_name","last_name","email","password_hashed","subscription_type","time_zone","email_verified","email_verification_token","request_change_pass","account_pass_reset_token","last_time_logged_in","last_time_logged_out") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "Example", "Test", "Example@Exampleemail.com", "asdasdasd", "free", "UTC", false, "JustAnotherToken", false, "", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"1", "15"}))
 	sqlMock.ExpectCommit()
 
 	_, err := User.CreateAccount(newAccount)
@@ -430,7 +430,7 @@ func TestCreateAccountFail(t *testing.T) {
 	User := repository.DataBase{DB: MockedDataBase}
 
 	sqlMock.ExpectBegin()
-	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "accounts" ("id","created_at","updated_at","deleted_at","first_name","last_name","email","password_hashed","subscription_type","email_verified","email_verification_token","request_change_pass","account_pass_reset_token","last_time_logged_in","last_time_logged_out") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15) RETURNING "id"`)).
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "accounts" ("id","created_at","updated_at","deleted_at","first_name","last_name","email","password_hashed","subscription_type","time_zone","email_verified","email_verification_token","request_change_pass","account_pass_reset_token","last_time_logged_in","last_time_logged_out") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING "id"`)).
 		WillReturnError(errors.New("error while creating account"))
 	sqlMock.ExpectRollback()
 
@@ -522,3 +522,40 @@ func TestGetAccountWithShopsFail(t *testing.T) {
 
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
+
+func TestGetNotificationsByAccountIDUnreadOnly(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	User := repository.DataBase{DB: MockedDataBase}
+	AccountID := uuid.New()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "notifications" WHERE account_id = $1 AND read = $2 ORDER BY created_at desc`)).
+		WithArgs(AccountID, false).WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "kind"}).AddRow(1, 2, models.NotificationVacationEnded))
+
+	notifications, err := User.GetNotificationsByAccountID(AccountID, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	User := repository.DataBase{DB: MockedDataBase}
+	AccountID := uuid.New()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "notifications" SET "read"=$1 WHERE account_id = $2 AND read = $3`)).
+		WithArgs(true, AccountID, false).WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectCommit()
+
+	err := User.MarkNotificationsRead(AccountID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
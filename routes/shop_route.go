@@ -26,14 +26,60 @@ func (us *ShopRoutes) GeneralShopRoutes(server *gin.Engine, authentication, auth
 	getAllSoldItemsByShopID := us.ShopController.HandleGetSoldItemsByShopID
 	getShopStats := us.ShopController.ProcessStatsRequest
 	getItemsCountByShopID := us.ShopController.HandleGetItemsCountByShopID
+	refreshShop := us.ShopController.RefreshShop
+	getRefreshJob := us.ShopController.HandleGetRefreshJob
+	getStockouts := us.ShopController.HandleGetStockoutsByShopID
+	getCategoryTimeline := us.ShopController.HandleGetCategoryTimeline
+	getProfileTimeline := us.ShopController.HandleGetProfileTimeline
+	getVacationPeriods := us.ShopController.HandleGetVacationPeriods
+	getItemChanges := us.ShopController.HandleGetItemChangesByShopID
+	getListingRankHistory := us.ShopController.HandleGetListingRankHistory
+	getBestSellers := us.ShopController.HandleGetBestSellers
+	getSalesForecast := us.ShopController.HandleGetSalesForecast
+	getPriceImpact := us.ShopController.HandleGetPriceImpact
+	getItemPriceImpact := us.ShopController.HandleGetItemPriceImpact
+	getCategoryStats := us.ShopController.HandleGetCategoryStats
+	compareShops := us.ShopController.HandleCompareShops
+	getListingLifecycle := us.ShopController.HandleGetListingLifecycle
+	getPromotions := us.ShopController.HandleGetPromotions
 
 	shopRoute.POST("/create_shop", authentication, authorization, createNewShopRequest)
 	shopRoute.POST("/follow_shop", authentication, authorization, followShop)
 	shopRoute.POST("/unfollow_shop", authentication, authorization, unFollowShop)
+	shopRoute.GET("/compare", authentication, authorization, compareShops)
 	shopRoute.GET("/:shopID", authentication, authorization, isfollowingShop, getShopByID)
 	shopRoute.GET("/:shopID/all_items", authentication, authorization, isfollowingShop, getAllItemsByShopID)
 	shopRoute.GET("/:shopID/all_sold_items", authentication, authorization, isfollowingShop, getAllSoldItemsByShopID)
 	shopRoute.GET("/:shopID/items_count", authentication, authorization, isfollowingShop, getItemsCountByShopID)
+	shopRoute.GET("/stats/:shopID", authentication, authorization, isfollowingShop, getShopStats)
 	shopRoute.GET("/stats/:shopID/:period", authentication, authorization, isfollowingShop, getShopStats)
+	shopRoute.GET("/stats/:shopID/forecast", authentication, authorization, isfollowingShop, getSalesForecast)
+	shopRoute.GET("/stats/:shopID/categories", authentication, authorization, isfollowingShop, getCategoryStats)
+	shopRoute.GET("/stats/:shopID/:period/categories", authentication, authorization, isfollowingShop, getCategoryStats)
+	shopRoute.POST("/:shopID/refresh", authentication, authorization, isfollowingShop, refreshShop)
+	shopRoute.GET("/:shopID/refresh/:jobID", authentication, authorization, isfollowingShop, getRefreshJob)
+	shopRoute.GET("/:shopID/stockouts", authentication, authorization, isfollowingShop, getStockouts)
+	shopRoute.GET("/:shopID/categories", authentication, authorization, isfollowingShop, getCategoryTimeline)
+	shopRoute.GET("/:shopID/timeline", authentication, authorization, isfollowingShop, getProfileTimeline)
+	shopRoute.GET("/:shopID/vacations", authentication, authorization, isfollowingShop, getVacationPeriods)
+	shopRoute.GET("/:shopID/item_changes", authentication, authorization, isfollowingShop, getItemChanges)
+	shopRoute.GET("/:shopID/listings/:listingID/positions", authentication, authorization, isfollowingShop, getListingRankHistory)
+	shopRoute.GET("/:shopID/bestsellers", authentication, authorization, isfollowingShop, getBestSellers)
+	shopRoute.GET("/:shopID/price_impact", authentication, authorization, isfollowingShop, getPriceImpact)
+	shopRoute.GET("/:shopID/listings/:listingID/price_impact", authentication, authorization, isfollowingShop, getItemPriceImpact)
+	shopRoute.GET("/:shopID/lifecycle", authentication, authorization, isfollowingShop, getListingLifecycle)
+	shopRoute.GET("/:shopID/promotions", authentication, authorization, isfollowingShop, getPromotions)
+
+}
+
+func (us *ShopRoutes) AdminShopRoutes(server *gin.Engine, authentication, authorization, isAdmin gin.HandlerFunc) {
+
+	adminRoute := server.Group("/admin")
+
+	mergeShops := us.ShopController.HandleMergeShops
+	recomputeRevenue := us.ShopController.HandleRecomputeRevenue
+
+	adminRoute.POST("/shops/merge", authentication, authorization, isAdmin, mergeShops)
+	adminRoute.POST("/shops/:shopID/recompute_revenue", authentication, authorization, isAdmin, recomputeRevenue)
 
 }
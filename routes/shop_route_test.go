@@ -20,6 +20,24 @@ type MockShopRoute struct {
 	isHandleGetSoldItemsByShopID  bool
 	isProcessStatsRequest         bool
 	isHandleGetItemsCountByShopID bool
+	isRefreshShop                 bool
+	isHandleGetRefreshJob         bool
+	isHandleMergeShops            bool
+	isHandleGetStockoutsByShopID  bool
+	isHandleGetCategoryTimeline   bool
+	isHandleGetProfileTimeline    bool
+	isHandleGetVacationPeriods    bool
+	isHandleGetItemChanges        bool
+	isHandleGetListingRankHistory bool
+	isHandleGetBestSellers        bool
+	isHandleRecomputeRevenue      bool
+	isHandleGetSalesForecast      bool
+	isHandleGetPriceImpact        bool
+	isHandleGetItemPriceImpact    bool
+	isHandleGetCategoryStats      bool
+	isHandleCompareShops          bool
+	isHandleGetListingLifecycle   bool
+	isHandleGetPromotions         bool
 }
 
 func (m *MockShopRoute) CreateNewShopRequest(ctx *gin.Context) {
@@ -47,6 +65,77 @@ func (m *MockShopRoute) ProcessStatsRequest(ctx *gin.Context) {
 	m.isProcessStatsRequest = true
 }
 
+func (m *MockShopRoute) RefreshShop(ctx *gin.Context) {
+	m.isRefreshShop = true
+}
+func (m *MockShopRoute) HandleGetRefreshJob(ctx *gin.Context) {
+	m.isHandleGetRefreshJob = true
+}
+
+func (m *MockShopRoute) HandleMergeShops(ctx *gin.Context) {
+	m.isHandleMergeShops = true
+}
+
+func (m *MockShopRoute) HandleGetStockoutsByShopID(ctx *gin.Context) {
+	m.isHandleGetStockoutsByShopID = true
+}
+
+func (m *MockShopRoute) HandleGetCategoryTimeline(ctx *gin.Context) {
+	m.isHandleGetCategoryTimeline = true
+}
+
+func (m *MockShopRoute) HandleGetProfileTimeline(ctx *gin.Context) {
+	m.isHandleGetProfileTimeline = true
+}
+
+func (m *MockShopRoute) HandleGetVacationPeriods(ctx *gin.Context) {
+	m.isHandleGetVacationPeriods = true
+}
+
+func (m *MockShopRoute) HandleGetItemChangesByShopID(ctx *gin.Context) {
+	m.isHandleGetItemChanges = true
+}
+
+func (m *MockShopRoute) HandleGetListingRankHistory(ctx *gin.Context) {
+	m.isHandleGetListingRankHistory = true
+}
+
+func (m *MockShopRoute) HandleGetBestSellers(ctx *gin.Context) {
+	m.isHandleGetBestSellers = true
+}
+
+func (m *MockShopRoute) HandleRecomputeRevenue(ctx *gin.Context) {
+	m.isHandleRecomputeRevenue = true
+}
+
+func (m *MockShopRoute) HandleGetSalesForecast(ctx *gin.Context) {
+	m.isHandleGetSalesForecast = true
+}
+
+func (m *MockShopRoute) HandleGetPriceImpact(ctx *gin.Context) {
+	m.isHandleGetPriceImpact = true
+}
+
+func (m *MockShopRoute) HandleGetItemPriceImpact(ctx *gin.Context) {
+	m.isHandleGetItemPriceImpact = true
+}
+
+func (m *MockShopRoute) HandleGetCategoryStats(ctx *gin.Context) {
+	m.isHandleGetCategoryStats = true
+}
+
+func (m *MockShopRoute) HandleCompareShops(ctx *gin.Context) {
+	m.isHandleCompareShops = true
+}
+
+func (m *MockShopRoute) HandleGetListingLifecycle(ctx *gin.Context) {
+	m.isHandleGetListingLifecycle = true
+}
+
+func (m *MockShopRoute) HandleGetPromotions(ctx *gin.Context) {
+	m.isHandleGetPromotions = true
+}
+
 func TestGeneralShopRoutes(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
@@ -97,6 +186,12 @@ func TestGeneralShopRoutes(t *testing.T) {
 			isCalled: func() bool { return MockedShop.isHandleGetSoldItemsByShopID },
 		},
 
+		{
+			name:     "Check if ProcessStatsRequest was called for a custom range",
+			method:   "GET",
+			path:     "/shop/stats/1?from=2024-01-01&to=2024-01-31&granularity=week",
+			isCalled: func() bool { return MockedShop.isProcessStatsRequest },
+		},
 		{
 			name:     "Check if ProcessStatsRequest was called",
 			method:   "GET",
@@ -109,6 +204,108 @@ func TestGeneralShopRoutes(t *testing.T) {
 			path:     "/shop/1/items_count",
 			isCalled: func() bool { return MockedShop.isHandleGetItemsCountByShopID },
 		},
+		{
+			name:     "Check if RefreshShop was called",
+			method:   "POST",
+			path:     "/shop/1/refresh",
+			isCalled: func() bool { return MockedShop.isRefreshShop },
+		},
+		{
+			name:     "Check if HandleGetRefreshJob was called",
+			method:   "GET",
+			path:     "/shop/1/refresh/7d6f4f1e-3f0c-4a55-9d2e-0f5b7c1a2b3c",
+			isCalled: func() bool { return MockedShop.isHandleGetRefreshJob },
+		},
+		{
+			name:     "Check if HandleGetStockoutsByShopID was called",
+			method:   "GET",
+			path:     "/shop/1/stockouts",
+			isCalled: func() bool { return MockedShop.isHandleGetStockoutsByShopID },
+		},
+		{
+			name:     "Check if HandleGetCategoryTimeline was called",
+			method:   "GET",
+			path:     "/shop/1/categories",
+			isCalled: func() bool { return MockedShop.isHandleGetCategoryTimeline },
+		},
+		{
+			name:     "Check if HandleGetProfileTimeline was called",
+			method:   "GET",
+			path:     "/shop/1/timeline",
+			isCalled: func() bool { return MockedShop.isHandleGetProfileTimeline },
+		},
+		{
+			name:     "Check if HandleGetVacationPeriods was called",
+			method:   "GET",
+			path:     "/shop/1/vacations",
+			isCalled: func() bool { return MockedShop.isHandleGetVacationPeriods },
+		},
+		{
+			name:     "Check if HandleGetItemChangesByShopID was called",
+			method:   "GET",
+			path:     "/shop/1/item_changes",
+			isCalled: func() bool { return MockedShop.isHandleGetItemChanges },
+		},
+		{
+			name:     "Check if HandleGetListingRankHistory was called",
+			method:   "GET",
+			path:     "/shop/1/listings/1563984521/positions",
+			isCalled: func() bool { return MockedShop.isHandleGetListingRankHistory },
+		},
+		{
+			name:     "Check if HandleGetBestSellers was called",
+			method:   "GET",
+			path:     "/shop/1/bestsellers",
+			isCalled: func() bool { return MockedShop.isHandleGetBestSellers },
+		},
+		{
+			name:     "Check if HandleGetSalesForecast was called",
+			method:   "GET",
+			path:     "/shop/stats/1/forecast",
+			isCalled: func() bool { return MockedShop.isHandleGetSalesForecast },
+		},
+		{
+			name:     "Check if HandleGetPriceImpact was called",
+			method:   "GET",
+			path:     "/shop/1/price_impact",
+			isCalled: func() bool { return MockedShop.isHandleGetPriceImpact },
+		},
+		{
+			name:     "Check if HandleGetItemPriceImpact was called",
+			method:   "GET",
+			path:     "/shop/1/listings/1563984521/price_impact",
+			isCalled: func() bool { return MockedShop.isHandleGetItemPriceImpact },
+		},
+		{
+			name:     "Check if HandleGetCategoryStats was called",
+			method:   "GET",
+			path:     "/shop/stats/1/lastThirtyDays/categories",
+			isCalled: func() bool { return MockedShop.isHandleGetCategoryStats },
+		},
+		{
+			name:     "Check if HandleGetCategoryStats was called with a date range",
+			method:   "GET",
+			path:     "/shop/stats/1/categories?from=2024-01-01&to=2024-03-31&granularity=week",
+			isCalled: func() bool { return MockedShop.isHandleGetCategoryStats },
+		},
+		{
+			name:     "Check if HandleCompareShops was called",
+			method:   "GET",
+			path:     "/shop/compare?ids=1,2&period=lastSevenDays",
+			isCalled: func() bool { return MockedShop.isHandleCompareShops },
+		},
+		{
+			name:     "Check if HandleGetListingLifecycle was called",
+			method:   "GET",
+			path:     "/shop/1/lifecycle?period=lastThreeMonths&granularity=month",
+			isCalled: func() bool { return MockedShop.isHandleGetListingLifecycle },
+		},
+		{
+			name:     "Check if HandleGetPromotions was called",
+			method:   "GET",
+			path:     "/shop/1/promotions?from=2024-01-01&to=2024-03-31",
+			isCalled: func() bool { return MockedShop.isHandleGetPromotions },
+		},
 	}
 
 	ShopRoute := routes.NewShopRouteController(MockedShop)
@@ -126,3 +323,27 @@ func TestGeneralShopRoutes(t *testing.T) {
 	}
 
 }
+
+func TestAdminShopRoutes(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	_, router := gin.CreateTestContext(w)
+
+	MockedShop := &MockShopRoute{}
+
+	ShopRoute := routes.NewShopRouteController(MockedShop)
+	ShopRoute.AdminShopRoutes(router, MiddleWare(), SecondMiddleWare(), SecondMiddleWare())
+
+	req, _ := http.NewRequest("POST", "/admin/shops/merge", nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	router.ServeHTTP(w, req)
+
+	assert.True(t, MockedShop.isHandleMergeShops)
+
+	req, _ = http.NewRequest("POST", "/admin/shops/1/recompute_revenue", nil)
+	router.ServeHTTP(w, req)
+
+	assert.True(t, MockedShop.isHandleRecomputeRevenue)
+}
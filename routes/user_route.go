@@ -25,6 +25,9 @@ func (ur *UserRoute) GeneraluserRoutes(server *gin.Engine, authentication, autho
 	forgotPass := ur.UserController.ForgotPassReq
 	changePass := ur.UserController.ChangePass
 	resetPass := ur.UserController.ResetPass
+	changeTimeZone := ur.UserController.ChangeTimeZone
+	getNotifications := ur.UserController.GetNotifications
+	readNotifications := ur.UserController.ReadNotifications
 
 	router.POST("/register", register)
 	router.POST("/login", login)
@@ -33,4 +36,7 @@ func (ur *UserRoute) GeneraluserRoutes(server *gin.Engine, authentication, autho
 	router.POST("/forgotpassword", forgotPass)
 	router.POST("/resetpassword", resetPass)
 	router.POST("/changepassword", authentication, authorization, changePass)
+	router.POST("/timezone", authentication, authorization, changeTimeZone)
+	router.GET("/notifications", authentication, authorization, getNotifications)
+	router.POST("/notifications/read", authentication, authorization, readNotifications)
 }
@@ -19,6 +19,9 @@ type MockUserRoute struct {
 	isForgotPassReqCalled bool
 	isChangePassCalled    bool
 	isResetPass           bool
+	isChangeTimeZone      bool
+	isGetNotifications    bool
+	isReadNotifications   bool
 }
 
 func (m *MockUserRoute) RegisterUser(c *gin.Context) {
@@ -43,6 +46,15 @@ func (m *MockUserRoute) ChangePass(c *gin.Context) {
 func (m *MockUserRoute) ResetPass(c *gin.Context) {
 	m.isResetPass = true
 }
+func (m *MockUserRoute) ChangeTimeZone(c *gin.Context) {
+	m.isChangeTimeZone = true
+}
+func (m *MockUserRoute) GetNotifications(c *gin.Context) {
+	m.isGetNotifications = true
+}
+func (m *MockUserRoute) ReadNotifications(c *gin.Context) {
+	m.isReadNotifications = true
+}
 
 func MiddleWare() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -110,6 +122,24 @@ func TestGeneraluserRoutes(t *testing.T) {
 			path:     "/auth/changepassword",
 			isCalled: func() bool { return MockedUSer.isChangePassCalled },
 		},
+		{
+			name:     "Check if ChangeTimeZone was called",
+			method:   "POST",
+			path:     "/auth/timezone",
+			isCalled: func() bool { return MockedUSer.isChangeTimeZone },
+		},
+		{
+			name:     "Check if GetNotifications was called",
+			method:   "GET",
+			path:     "/auth/notifications",
+			isCalled: func() bool { return MockedUSer.isGetNotifications },
+		},
+		{
+			name:     "Check if ReadNotifications was called",
+			method:   "POST",
+			path:     "/auth/notifications/read",
+			isCalled: func() bool { return MockedUSer.isReadNotifications },
+		},
 	}
 
 	User := &routes.UserRoute{UserController: MockedUSer}
@@ -0,0 +1,103 @@
+package scheduleUpdates
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// DetectDailySalesGaps expects the shop's snapshots ordered by creation time. For every run of
+// missing days it returns interpolated entries, and the snapshot right after the gap with its
+// revenue reduced by the share that was moved onto the missing days. Gaps closed by a snapshot
+// taken at or after before are left for a later run: the sold items of that day may still be
+// ingested and its revenue is not final yet.
+func DetectDailySalesGaps(dailySales []models.DailyShopSales, before time.Time) (estimated []models.DailyShopSales, adjusted []models.DailyShopSales) {
+
+	for i := 1; i < len(dailySales); i++ {
+		previous := dailySales[i-1]
+		next := dailySales[i]
+		if !next.CreatedAt.Before(before) {
+			break
+		}
+
+		previousDay := utils.TruncateDate(previous.CreatedAt)
+		nextDay := utils.TruncateDate(next.CreatedAt)
+		missingDays := int(math.Round(nextDay.Sub(previousDay).Hours()/24)) - 1
+		if missingDays < 1 {
+			continue
+		}
+
+		steps := float64(missingDays + 1)
+		salesStep := float64(next.TotalSales-previous.TotalSales) / steps
+		admirersStep := float64(next.Admirers-previous.Admirers) / steps
+		revenueShare := utils.RoundToTwoDecimalDigits(next.DailyRevenue / steps)
+
+		for day := 1; day <= missingDays; day++ {
+			entry := models.DailyShopSales{
+				ShopID:       previous.ShopID,
+				TotalSales:   previous.TotalSales + int(math.Round(salesStep*float64(day))),
+				Admirers:     previous.Admirers + int(math.Round(admirersStep*float64(day))),
+				DailyRevenue: revenueShare,
+				Estimated:    true,
+			}
+			entry.CreatedAt = previous.CreatedAt.AddDate(0, 0, day)
+			estimated = append(estimated, entry)
+		}
+
+		if next.DailyRevenue != 0 {
+			next.DailyRevenue = utils.RoundToTwoDecimalDigits(next.DailyRevenue - revenueShare*float64(missingDays))
+			adjusted = append(adjusted, next)
+		}
+	}
+	return
+}
+
+func (u *UpdateDB) BackfillDailySalesGaps() error {
+
+	Shops, err := u.Repo.GetAllShops()
+	if err != nil {
+		return utils.HandleError(err, "error while retrieving Shops rows.")
+	}
+
+	for _, Shop := range *Shops {
+		if err := u.BackfillShopDailySalesGaps(Shop.ID); err != nil {
+			return utils.HandleError(err)
+		}
+	}
+	return nil
+}
+
+func (u *UpdateDB) BackfillShopDailySalesGaps(ShopID uint) error {
+
+	dailySales, err := u.Repo.GetDailySalesByShopID(ShopID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	estimated, adjusted := DetectDailySalesGaps(dailySales, utils.TruncateDate(time.Now()))
+	if len(estimated) == 0 {
+		return nil
+	}
+
+	if err := u.CheckLease(); err != nil {
+		return err
+	}
+
+	for index := range estimated {
+		if err := u.Repo.SaveDailySales(&estimated[index]); err != nil {
+			return utils.HandleError(err)
+		}
+	}
+
+	for index := range adjusted {
+		if err := u.Repo.SaveDailySales(&adjusted[index]); err != nil {
+			return utils.HandleError(err)
+		}
+	}
+
+	log.Printf("backfilled %v missing days of DailyShopSales for Shop.ID: %v\n", len(estimated), ShopID)
+	return nil
+}
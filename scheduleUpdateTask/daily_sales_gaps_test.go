@@ -0,0 +1,124 @@
+package scheduleUpdates_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
+	setupMockServer "EtsyScraper/setupTests"
+)
+
+func createDailySales(ShopID uint, createdAt time.Time, TotalSales, Admirers int, DailyRevenue float64) models.DailyShopSales {
+	sales := models.DailyShopSales{ShopID: ShopID, TotalSales: TotalSales, Admirers: Admirers, DailyRevenue: DailyRevenue}
+	sales.CreatedAt = createdAt
+	return sales
+}
+
+func TestDetectDailySalesGapsNoGap(t *testing.T) {
+	firstDay := time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	dailySales := []models.DailyShopSales{
+		createDailySales(1, firstDay, 100, 10, 50),
+		createDailySales(1, firstDay.AddDate(0, 0, 1), 110, 11, 60),
+		createDailySales(1, firstDay.AddDate(0, 0, 2), 115, 11, 30),
+	}
+
+	estimated, adjusted := scheduleUpdates.DetectDailySalesGaps(dailySales, time.Now())
+
+	assert.Empty(t, estimated)
+	assert.Empty(t, adjusted)
+}
+
+func TestDetectDailySalesGapsInterpolatesMissingDays(t *testing.T) {
+	firstDay := time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	dailySales := []models.DailyShopSales{
+		createDailySales(1, firstDay, 100, 10, 50),
+		createDailySales(1, firstDay.AddDate(0, 0, 3), 130, 16, 90),
+	}
+	dailySales[1].ID = 7
+
+	estimated, adjusted := scheduleUpdates.DetectDailySalesGaps(dailySales, time.Now())
+
+	assert.Len(t, estimated, 2)
+	assert.Equal(t, 110, estimated[0].TotalSales)
+	assert.Equal(t, 12, estimated[0].Admirers)
+	assert.Equal(t, 120, estimated[1].TotalSales)
+	assert.Equal(t, 14, estimated[1].Admirers)
+	for index, entry := range estimated {
+		assert.True(t, entry.Estimated)
+		assert.Equal(t, uint(1), entry.ShopID)
+		assert.Equal(t, float64(30), entry.DailyRevenue)
+		assert.Equal(t, firstDay.AddDate(0, 0, index+1), entry.CreatedAt)
+	}
+
+	assert.Len(t, adjusted, 1)
+	assert.Equal(t, uint(7), adjusted[0].ID)
+	assert.Equal(t, float64(30), adjusted[0].DailyRevenue)
+	assert.False(t, adjusted[0].Estimated)
+}
+
+func TestDetectDailySalesGapsWithoutRevenueKeepsNextDay(t *testing.T) {
+	firstDay := time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	dailySales := []models.DailyShopSales{
+		createDailySales(1, firstDay, 100, 10, 0),
+		createDailySales(1, firstDay.AddDate(0, 0, 2), 104, 10, 0),
+	}
+
+	estimated, adjusted := scheduleUpdates.DetectDailySalesGaps(dailySales, time.Now())
+
+	assert.Len(t, estimated, 1)
+	assert.Equal(t, 102, estimated[0].TotalSales)
+	assert.Empty(t, adjusted)
+}
+
+func TestDetectDailySalesGapsLeavesGapClosedToday(t *testing.T) {
+	today := time.Date(2024, 4, 4, 0, 0, 0, 0, time.UTC)
+	dailySales := []models.DailyShopSales{
+		createDailySales(1, today.AddDate(0, 0, -5).Add(15*time.Hour), 100, 10, 50),
+		createDailySales(1, today.AddDate(0, 0, -3).Add(15*time.Hour), 110, 10, 40),
+		createDailySales(1, today.Add(15*time.Hour), 130, 10, 0),
+	}
+
+	estimated, adjusted := scheduleUpdates.DetectDailySalesGaps(dailySales, today)
+
+	assert.Len(t, estimated, 1)
+	assert.Equal(t, today.AddDate(0, 0, -4).Add(15*time.Hour), estimated[0].CreatedAt)
+	assert.Len(t, adjusted, 1)
+	assert.Equal(t, float64(20), adjusted[0].DailyRevenue)
+}
+
+func TestBackfillShopDailySalesGapsSavesEntries(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	firstDay := time.Date(2024, 4, 1, 15, 12, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "created_at", "shop_id", "total_sales", "admirers", "daily_revenue"}).
+		AddRow(1, firstDay, 1, 100, 10, 50).
+		AddRow(2, firstDay.AddDate(0, 0, 2), 1, 120, 12, 40)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "daily_shop_sales" WHERE shop_id = $1 AND "daily_shop_sales"."deleted_at" IS NULL ORDER BY created_at asc`)).
+		WithArgs(1).WillReturnRows(rows)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales"`)).
+		WithArgs(firstDay.AddDate(0, 0, 1), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 110, 11, float64(20), true).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "daily_shop_sales"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 120, 12, float64(20), false, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.BackfillShopDailySalesGaps(1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,218 @@
+package scheduleUpdates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"EtsyScraper/utils"
+)
+
+var SchedulerLeaseKey = "scheduler:lease"
+var SchedulerFencingKey = "scheduler:fencing_token"
+var SchedulerRunKeyPrefix = "scheduler:ran:"
+var DefaultLeaseTTL = 2 * time.Minute
+
+// RunMarkerTTL keeps the marker of a day's run past the end of that day, so a replica
+// whose cron fires late still finds it.
+var RunMarkerTTL = 48 * time.Hour
+
+var ErrLeaseLost = errors.New("scheduler lease is no longer held by this instance")
+
+// acquireScript takes the lease when it is free and hands out a new fencing token.
+// A holder asking again keeps its current token.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return tonumber(redis.call("GET", KEYS[2]))
+end
+return 0
+`)
+
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] and redis.call("GET", KEYS[2]) == ARGV[3] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+var validateScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] and redis.call("GET", KEYS[2]) == ARGV[2] then
+	return 1
+end
+return 0
+`)
+
+type LeaderLease struct {
+	Client     *redis.Client
+	Key        string
+	FencingKey string
+	InstanceID string
+	TTL        time.Duration
+
+	mu    sync.Mutex
+	token int64
+}
+
+type SchedulerLease interface {
+	Acquire(ctx context.Context) (bool, error)
+	Renew(ctx context.Context) error
+	Release(ctx context.Context) error
+	ValidateFencingToken(ctx context.Context) error
+	FencingToken() int64
+	LeaseTTL() time.Duration
+	ClaimRun(ctx context.Context, Day time.Time) (bool, error)
+}
+
+func ConfiguredLeaseTTL() time.Duration {
+	if Config.SchedulerLeaseTTL > 0 {
+		return Config.SchedulerLeaseTTL
+	}
+	return DefaultLeaseTTL
+}
+
+func NewLeaderLease(client *redis.Client, ttl time.Duration) *LeaderLease {
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = "scheduler"
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+
+	return &LeaderLease{
+		Client:     client,
+		Key:        SchedulerLeaseKey,
+		FencingKey: SchedulerFencingKey,
+		InstanceID: fmt.Sprintf("%s-%s", hostName, uuid.New().String()),
+		TTL:        ttl,
+	}
+}
+
+func (l *LeaderLease) Acquire(ctx context.Context) (bool, error) {
+	token, err := acquireScript.Run(ctx, l.Client, []string{l.Key, l.FencingKey}, l.InstanceID, l.TTL.Milliseconds()).Int64()
+	if err != nil {
+		return false, utils.HandleError(err, "error while acquiring scheduler lease")
+	}
+
+	l.mu.Lock()
+	l.token = token
+	l.mu.Unlock()
+
+	return token > 0, nil
+}
+
+func (l *LeaderLease) Renew(ctx context.Context) error {
+	token := l.FencingToken()
+	if token == 0 {
+		return utils.HandleError(ErrLeaseLost)
+	}
+
+	renewed, err := renewScript.Run(ctx, l.Client, []string{l.Key, l.FencingKey}, l.InstanceID, l.TTL.Milliseconds(), token).Int64()
+	if err != nil {
+		return utils.HandleError(err, "error while renewing scheduler lease")
+	}
+	if renewed == 0 {
+		l.dropToken()
+		return utils.HandleError(ErrLeaseLost)
+	}
+	return nil
+}
+
+func (l *LeaderLease) Release(ctx context.Context) error {
+	defer l.dropToken()
+
+	if err := releaseScript.Run(ctx, l.Client, []string{l.Key}, l.InstanceID).Err(); err != nil {
+		return utils.HandleError(err, "error while releasing scheduler lease")
+	}
+	return nil
+}
+
+// ValidateFencingToken stops a leader that has lost the lease before it starts its next
+// write, even if it has not noticed yet. It does not make the write itself safe, a leader
+// pausing between the check and the write can still get through; the writes that must
+// not happen twice also pass FencingToken to the database, which rejects stale tokens.
+func (l *LeaderLease) ValidateFencingToken(ctx context.Context) error {
+	token := l.FencingToken()
+	if token == 0 {
+		return utils.HandleError(ErrLeaseLost)
+	}
+
+	valid, err := validateScript.Run(ctx, l.Client, []string{l.Key, l.FencingKey}, l.InstanceID, token).Int64()
+	if err != nil {
+		return utils.HandleError(err, "error while validating fencing token")
+	}
+	if valid == 0 {
+		l.dropToken()
+		return utils.HandleError(ErrLeaseLost)
+	}
+	return nil
+}
+
+func (l *LeaderLease) FencingToken() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// LeaseTTL is how long the lease lasts without being renewed.
+func (l *LeaderLease) LeaseTTL() time.Duration {
+	return l.TTL
+}
+
+// ClaimRun marks the scheduled job of Day as started. Only the first claim of a day
+// succeeds, so a replica taking the lease after the job finished does not run it again.
+func (l *LeaderLease) ClaimRun(ctx context.Context, Day time.Time) (bool, error) {
+	key := SchedulerRunKeyPrefix + Day.UTC().Format("2006-01-02")
+
+	claimed, err := l.Client.SetNX(ctx, key, l.InstanceID, RunMarkerTTL).Result()
+	if err != nil {
+		return false, utils.HandleError(err, "error while claiming the scheduled run")
+	}
+	return claimed, nil
+}
+
+func (l *LeaderLease) dropToken() {
+	l.mu.Lock()
+	l.token = 0
+	l.mu.Unlock()
+}
+
+func KeepLeaseAlive(lease SchedulerLease, interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lease.Renew(ctx); err != nil {
+					log.Println("scheduler lease renewal failed: ", err)
+					return
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
@@ -0,0 +1,205 @@
+package scheduleUpdates_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
+	setupMockServer "EtsyScraper/setupTests"
+)
+
+func TestLeaderLeaseAcquireOnlyOneInstance(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	firstInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	secondInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	isLeader, err := firstInstance.Acquire(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+	assert.Equal(t, int64(1), firstInstance.FencingToken())
+
+	isLeader, err = secondInstance.Acquire(ctx)
+	assert.NoError(t, err)
+	assert.False(t, isLeader)
+	assert.Equal(t, int64(0), secondInstance.FencingToken())
+}
+
+func TestLeaderLeaseReleaseLetsNextInstanceAcquire(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	firstInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	secondInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	firstInstance.Acquire(ctx)
+	assert.NoError(t, firstInstance.Release(ctx))
+
+	isLeader, err := secondInstance.Acquire(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+	assert.Equal(t, int64(2), secondInstance.FencingToken())
+}
+
+func TestLeaderLeaseReleaseDoesNotDropForeignLease(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	firstInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	secondInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	firstInstance.Acquire(ctx)
+	secondInstance.Release(ctx)
+
+	assert.NoError(t, firstInstance.ValidateFencingToken(ctx))
+}
+
+func TestLeaderLeaseStaleLeaderFailsFencing(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	pausedLeader := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	newLeader := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	pausedLeader.Acquire(ctx)
+	server.FastForward(2 * time.Minute)
+
+	isLeader, err := newLeader.Acquire(ctx)
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+
+	err = pausedLeader.ValidateFencingToken(ctx)
+	assert.True(t, errors.Is(err, scheduleUpdates.ErrLeaseLost))
+	assert.Equal(t, int64(0), pausedLeader.FencingToken())
+
+	assert.NoError(t, newLeader.ValidateFencingToken(ctx))
+}
+
+func TestLeaderLeaseRenewAfterExpiryFails(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	lease := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	lease.Acquire(ctx)
+	assert.NoError(t, lease.Renew(ctx))
+
+	server.FastForward(2 * time.Minute)
+
+	err := lease.Renew(ctx)
+	assert.True(t, errors.Is(err, scheduleUpdates.ErrLeaseLost))
+}
+
+func TestLeaderLeaseRedisDownReturnsError(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	server.Close()
+
+	lease := scheduleUpdates.NewLeaderLease(client, time.Minute)
+
+	isLeader, err := lease.Acquire(context.Background())
+	assert.Error(t, err)
+	assert.False(t, isLeader)
+}
+
+func TestRunAsLeaderSkipsJobWhenLeaseIsTaken(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	otherInstance := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	otherInstance.Acquire(context.Background())
+
+	updateDB := &scheduleUpdates.UpdateDB{Lease: scheduleUpdates.NewLeaderLease(client, time.Minute)}
+
+	jobExecuted := false
+	err := updateDB.RunAsLeader(func() error {
+		jobExecuted = true
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, jobExecuted)
+}
+
+func TestRunAsLeaderExecutesJobAndReleasesLease(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	updateDB := &scheduleUpdates.UpdateDB{Lease: scheduleUpdates.NewLeaderLease(client, time.Minute)}
+
+	jobExecuted := false
+	err := updateDB.RunAsLeader(func() error {
+		jobExecuted = true
+		return updateDB.CheckLease()
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, jobExecuted)
+	assert.False(t, server.Exists(scheduleUpdates.SchedulerLeaseKey))
+}
+
+func TestRunAsLeaderRunsOncePerDay(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	firstReplica := &scheduleUpdates.UpdateDB{Lease: scheduleUpdates.NewLeaderLease(client, time.Minute)}
+	lateReplica := &scheduleUpdates.UpdateDB{Lease: scheduleUpdates.NewLeaderLease(client, time.Minute)}
+
+	runs := 0
+	job := func() error {
+		runs++
+		return nil
+	}
+
+	assert.NoError(t, firstReplica.RunAsLeader(job))
+	assert.NoError(t, lateReplica.RunAsLeader(job))
+
+	assert.Equal(t, 1, runs)
+	assert.True(t, server.Exists(scheduleUpdates.SchedulerRunKeyPrefix+time.Now().UTC().Format("2006-01-02")))
+}
+
+func TestLeaderLeaseClaimRunOnlyOncePerDay(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	ctx := context.Background()
+	lease := scheduleUpdates.NewLeaderLease(client, time.Minute)
+	today := time.Date(2024, 3, 10, 15, 12, 0, 0, time.UTC)
+
+	claimed, err := lease.ClaimRun(ctx, today)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = lease.ClaimRun(ctx, today.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	claimed, err = lease.ClaimRun(ctx, today.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestCheckLeaseWithoutLeaseIsAllowed(t *testing.T) {
+	updateDB := &scheduleUpdates.UpdateDB{}
+
+	assert.NoError(t, updateDB.CheckLease())
+}
+
+func TestLeaderLeaseTTLIsTheLeaseDuration(t *testing.T) {
+	server, client := setupMockServer.StartMockedRedis()
+	defer server.Close()
+
+	lease := scheduleUpdates.NewLeaderLease(client, 45*time.Second)
+
+	assert.Equal(t, 45*time.Second, lease.LeaseTTL())
+	assert.Equal(t, scheduleUpdates.DefaultLeaseTTL, scheduleUpdates.NewLeaderLease(client, 0).LeaseTTL())
+}
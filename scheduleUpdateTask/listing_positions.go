@@ -0,0 +1,25 @@
+package scheduleUpdates
+
+import (
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// RecordListingPositions stores where each listing was shown on the shop home page
+// during this refresh.
+func (u *UpdateDB) RecordListingPositions(ShopID uint, updatedShop *models.Shop) error {
+	if len(updatedShop.ListingPositions) == 0 {
+		return nil
+	}
+
+	Positions := make([]models.ListingPosition, 0, len(updatedShop.ListingPositions))
+	for _, Position := range updatedShop.ListingPositions {
+		Position.ShopID = ShopID
+		Positions = append(Positions, Position)
+	}
+
+	if err := u.Repo.CreateListingPositions(Positions); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package scheduleUpdates_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
+	setupMockServer "EtsyScraper/setupTests"
+)
+
+func TestRecordListingPositionsSkipsEmptyPage(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	err := updateDB.RecordListingPositions(1, &models.Shop{TotalSales: 10})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRecordListingPositionsSavesShopID(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	updatedShop := &models.Shop{ListingPositions: []models.ListingPosition{
+		{ListingID: 300, Position: 1, Featured: true},
+		{ListingID: 100, Position: 1},
+	}}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "listing_positions" ("created_at","updated_at","deleted_at","shop_id","listing_id","position","featured") VALUES ($1,$2,$3,$4,$5,$6,$7),($8,$9,$10,$11,$12,$13,$14) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7, 300, 1, true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7, 100, 1, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.RecordListingPositions(7, updatedShop)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Zero(t, updatedShop.ListingPositions[0].ShopID)
+}
@@ -1,8 +1,11 @@
 package scheduleUpdates
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -16,9 +19,20 @@ import (
 	"EtsyScraper/utils"
 )
 
+var Config = initializer.LoadProjConfig(".")
+
+var DefaultSnapshotTimeUTC = "15:12"
+
+// MinScrapedMenuShare is the share of a shop's existing sections a menu scrape has to
+// return before the sections it misses are deleted. A scrape below it is taken as
+// blocked or partly rendered.
+var MinScrapedMenuShare = 0.5
+
 type UpdateDB struct {
-	Repo repository.ShopRepository
-	Shop controllers.ShopOperations
+	Repo    repository.ShopRepository
+	Shop    controllers.ShopOperations
+	Lease   SchedulerLease
+	Scraper scrap.ScrapeUpdateProcess
 }
 
 type UpdateSoldItemsQueue struct {
@@ -29,7 +43,7 @@ type UpdateSoldItemsQueue struct {
 func NewUpdateDB(DB *gorm.DB, Shop controllers.Shop) *UpdateDB {
 	Repository := &repository.DataBase{DB: DB}
 
-	return &UpdateDB{Repo: Repository, Shop: &Shop}
+	return &UpdateDB{Repo: Repository, Shop: &Shop, Scraper: &scrap.Scraper{}}
 }
 
 type CustomCronJob struct {
@@ -62,18 +76,43 @@ type CronJob interface {
 func StartScheduleScrapUpdate(Shop controllers.Shop) {
 	c := NewCustomCronJob()
 	UpdateShop := NewUpdateDB(initializer.DB, Shop)
+	UpdateShop.Lease = NewLeaderLease(initializer.RedisClient, ConfiguredLeaseTTL())
 	ScheduleScrapUpdate(c, UpdateShop)
 }
+func SnapshotCronSpec(snapshotTime string) (string, error) {
+	if snapshotTime == "" {
+		snapshotTime = DefaultSnapshotTimeUTC
+	}
+	parsedTime, err := time.Parse("15:04", snapshotTime)
+	if err != nil {
+		return "", utils.HandleError(err, "invalid snapshot time, expected HH:MM in UTC")
+	}
+	return fmt.Sprintf("CRON_TZ=UTC %d %d * * *", parsedTime.Minute(), parsedTime.Hour()), nil
+}
+
 func ScheduleScrapUpdate(c CronJob, UpdateShop *UpdateDB) error {
 	scraper := &scrap.Scraper{}
 	var FuncError error
-	c.AddFunc("12 15 * * *", func() {
+
+	spec, err := SnapshotCronSpec(Config.SnapshotTimeUTC)
+	if err != nil {
+		return err
+	}
+
+	c.AddFunc(spec, func() {
 		log.Println("ScheduleScrapUpdate executed at", time.Now())
 		needUpdateItems := false
 		if time.Now().Weekday() == time.Tuesday {
 			needUpdateItems = false
 		}
-		if err := UpdateShop.StartShopUpdate(needUpdateItems, scraper); err != nil {
+		if err := UpdateShop.RunAsLeader(func() error {
+			if err := UpdateShop.StartShopUpdate(needUpdateItems, scraper); err != nil {
+				return err
+			}
+			// only gaps closed before today are filled, today's revenue is still being
+			// ingested and its gap is split on the next run.
+			return UpdateShop.BackfillDailySalesGaps()
+		}); err != nil {
 			FuncError = err
 		}
 	})
@@ -84,6 +123,59 @@ func ScheduleScrapUpdate(c CronJob, UpdateShop *UpdateDB) error {
 	return nil
 }
 
+func (u *UpdateDB) RunAsLeader(job func() error) error {
+	if u.Lease == nil {
+		return job()
+	}
+
+	ctx := context.Background()
+	isLeader, err := u.Lease.Acquire(ctx)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+	if !isLeader {
+		log.Println("scheduled job skipped, another instance holds the scheduler lease")
+		return nil
+	}
+	defer u.Lease.Release(ctx)
+
+	stopRenewal := KeepLeaseAlive(u.Lease, u.Lease.LeaseTTL()/3)
+	defer stopRenewal()
+
+	// the lease is released when the job ends, the run marker is what keeps a replica
+	// whose cron fires a little later from running the same day again.
+	claimed, err := u.Lease.ClaimRun(ctx, time.Now())
+	if err != nil {
+		return utils.HandleError(err)
+	}
+	if !claimed {
+		log.Println("scheduled job skipped, it already ran today")
+		return nil
+	}
+
+	log.Println("scheduler lease acquired with fencing token: ", u.Lease.FencingToken())
+	return job()
+}
+
+// CheckLease stops a scheduled job that lost the scheduler lease, see ValidateFencingToken.
+func (u *UpdateDB) CheckLease() error {
+	if u.Lease == nil {
+		return nil
+	}
+	if err := u.Lease.ValidateFencingToken(context.Background()); err != nil {
+		return utils.HandleError(err, "aborting scheduled update")
+	}
+	return nil
+}
+
+// FencingToken is the token of the held scheduler lease, or 0 when the job runs without one.
+func (u *UpdateDB) FencingToken() int64 {
+	if u.Lease == nil {
+		return 0
+	}
+	return u.Lease.FencingToken()
+}
+
 func (u *UpdateDB) StartShopUpdate(needUpdateItems bool, scraper scrap.ScrapeUpdateProcess) error {
 
 	SoldItemsQueueList := []UpdateSoldItemsQueue{}
@@ -95,56 +187,199 @@ func (u *UpdateDB) StartShopUpdate(needUpdateItems bool, scraper scrap.ScrapeUpd
 
 	for _, Shop := range *Shops {
 
-		updatedShop, err := scraper.CheckForUpdates(Shop.Name, needUpdateItems)
+		unlock, err := controllers.LockShop(u.Repo, Shop.ID)
 		if err != nil {
-			return utils.HandleError(err, "error while scraping Shop. error")
+			log.Printf("skipped update of Shop.ID %v: %v\n", Shop.ID, err)
+			continue
+		}
+		NewSoldItems, err := u.UpdateShop(&Shop, needUpdateItems, scraper)
+		unlock()
+		if err != nil {
+			return err
 		}
-
-		NewSoldItems := updatedShop.TotalSales - Shop.TotalSales
-		NewAdmirers := updatedShop.Admirers - Shop.Admirers
 
 		if NewSoldItems > 0 && Shop.HasSoldHistory {
 			SoldItemsQueueList = AddSoldItemsQueueList(SoldItemsQueueList, NewSoldItems, Shop)
 		}
+	}
+	if len(SoldItemsQueueList) > 0 {
+		for _, queue := range SoldItemsQueueList {
+			if err := u.CheckLease(); err != nil {
+				return err
+			}
+			unlock, err := controllers.LockShop(u.Repo, queue.Shop.ID)
+			if err != nil {
+				log.Printf("skipped SoldItems of Shop.ID %v: %v\n", queue.Shop.ID, err)
+				continue
+			}
+			u.UpdateSoldItems(queue)
+			unlock()
+			log.Printf("added %v new SoldItems to Shop: %s\n", queue.Task.UpdateSoldItems, queue.Shop.Name)
+		}
+	}
+	log.Println("finished updating Shops")
+
+	return nil
+}
+
+// UpdateShop takes the daily snapshot of a shop and returns how many units it sold since
+// the last one. The caller holds the shop's lock; the counters are read again under it,
+// a refresh may have moved them since the shops were listed.
+func (u *UpdateDB) UpdateShop(Shop *models.Shop, needUpdateItems bool, scraper scrap.ScrapeUpdateProcess) (int, error) {
+
+	current, err := u.Repo.GetShopSalesByID(Shop.ID)
+	if err != nil {
+		return 0, utils.HandleError(err)
+	}
+	Shop.TotalSales = current.TotalSales
+	Shop.Admirers = current.Admirers
+	Shop.OnVacation = current.OnVacation
+
+	updatedShop, err := scraper.CheckForUpdates(Shop.Name, needUpdateItems)
+	if err != nil {
+		return 0, utils.HandleError(err, "error while scraping Shop. error")
+	}
+
+	NewSoldItems := updatedShop.TotalSales - Shop.TotalSales
+	NewAdmirers := updatedShop.Admirers - Shop.Admirers
+
+	if updatedShop.OnVacation {
+		updatedShop.TotalSales = Shop.TotalSales
+		updatedShop.Admirers = Shop.Admirers
+	}
 
-		if updatedShop.OnVacation {
-			updatedShop.TotalSales = Shop.TotalSales
-			updatedShop.Admirers = Shop.Admirers
+	if err := u.CheckLease(); err != nil {
+		return 0, err
+	}
+
+	updateData := map[string]interface{}{
+		"total_sales": updatedShop.TotalSales,
+		"admirers":    updatedShop.Admirers,
+	}
+
+	if err := u.Repo.CreateDailySales(Shop.ID, updatedShop.TotalSales, updatedShop.Admirers, u.FencingToken()); err != nil {
+		return 0, utils.HandleError(err)
+	}
+
+	u.RecordShopChanges(*Shop, updatedShop)
+
+	// units a refresh saved earlier today are only priced once today's snapshot exists,
+	// without new units there is no ingestion below to do it.
+	if NewSoldItems <= 0 && Shop.HasSoldHistory {
+		if err := u.RecordDailyRevenue(Shop.ID); err != nil {
+			log.Printf("failed to record daily revenue of Shop.ID %v: %v\n", Shop.ID, err)
+		}
+	}
+
+	if NewAdmirers > 0 || NewSoldItems > 0 {
+		log.Printf("Shop's name: %s , TotalSales was: %v , TotalSales now: %v \n", Shop.Name, Shop.TotalSales, updatedShop.TotalSales)
+		if err := u.Repo.UpdateColumnsInShop(*Shop, updateData); err != nil {
+			return 0, utils.HandleError(err)
 		}
+	}
+
+	if needUpdateItems {
+		log.Println("ShopItemsUpdate executed at", time.Now())
+		u.ShopItemsUpdate(Shop, updatedShop, scraper)
+	}
+
+	return NewSoldItems, nil
+}
+
+// RecordShopChanges adds the history kept next to the sales: review snapshots, listing
+// positions, profile changes and vacations. One of them failing is logged and the update
+// goes on, the sales of the shop are still written.
+func (u *UpdateDB) RecordShopChanges(Shop models.Shop, updatedShop *models.Shop) {
+	if err := u.SnapshotShopReviews(Shop.ID, updatedShop); err != nil {
+		log.Printf("failed to snapshot reviews of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if err := u.RecordListingPositions(Shop.ID, updatedShop); err != nil {
+		log.Printf("failed to record listing positions of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if err := u.UpdateShopProfile(Shop.ID, updatedShop); err != nil {
+		log.Printf("failed to update profile of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if err := u.TrackVacation(Shop, updatedShop); err != nil {
+		log.Printf("failed to track vacation of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+}
 
+// RefreshShop runs an on-demand refresh under the shop's lock, so it never overlaps the
+// scheduled update, a merge or another refresh of the same shop on any instance.
+func (u *UpdateDB) RefreshShop(ShopID uint, needUpdateItems bool) error {
+
+	unlock, err := controllers.LockShop(u.Repo, ShopID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+	defer unlock()
+
+	Shop, err := u.Repo.FetchShopByID(ShopID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	updatedShop, err := u.Scraper.CheckForUpdates(Shop.Name, needUpdateItems)
+	if err != nil {
+		return utils.HandleError(err, "error while scraping Shop. error")
+	}
+
+	if err := u.RecordListingPositions(Shop.ID, updatedShop); err != nil {
+		log.Printf("failed to record listing positions of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if err := u.UpdateShopProfile(Shop.ID, updatedShop); err != nil {
+		log.Printf("failed to update profile of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if err := u.TrackVacation(*Shop, updatedShop); err != nil {
+		log.Printf("failed to track vacation of Shop.ID %v: %v\n", Shop.ID, err)
+	}
+
+	if updatedShop.OnVacation {
+		log.Printf("Shop's name: %s is on vacation, refresh skipped\n", Shop.Name)
+		return nil
+	}
+
+	NewSoldItems := updatedShop.TotalSales - Shop.TotalSales
+	NewAdmirers := updatedShop.Admirers - Shop.Admirers
+
+	if NewAdmirers != 0 || NewSoldItems > 0 {
 		updateData := map[string]interface{}{
 			"total_sales": updatedShop.TotalSales,
 			"admirers":    updatedShop.Admirers,
 		}
-
-		if err := u.Repo.CreateDailySales(Shop.ID, updatedShop.TotalSales, updatedShop.Admirers); err != nil {
+		if err := u.Repo.UpdateColumnsInShop(*Shop, updateData); err != nil {
 			return utils.HandleError(err)
 		}
+	}
 
-		if NewAdmirers > 0 || NewSoldItems > 0 {
-			log.Printf("Shop's name: %s , TotalSales was: %v , TotalSales now: %v \n", Shop.Name, Shop.TotalSales, updatedShop.TotalSales)
-			if err := u.Repo.UpdateColumnsInShop(Shop, updateData); err != nil {
-				return utils.HandleError(err)
-			}
-		}
-
-		if needUpdateItems {
-			log.Println("ShopItemsUpdate executed at", time.Now())
-			u.ShopItemsUpdate(&Shop, updatedShop, scraper)
+	if needUpdateItems {
+		if err := u.ShopItemsUpdate(Shop, updatedShop, u.Scraper); err != nil {
+			return utils.HandleError(err)
 		}
-
 	}
-	if len(SoldItemsQueueList) > 0 {
-		for _, queue := range SoldItemsQueueList {
-			u.UpdateSoldItems(queue)
-			log.Printf("added %v new SoldItems to Shop: %s\n", queue.Task.UpdateSoldItems, queue.Shop.Name)
-		}
+
+	if NewSoldItems > 0 && Shop.HasSoldHistory {
+		SoldItemsQueueList := AddSoldItemsQueueList([]UpdateSoldItemsQueue{}, NewSoldItems, *Shop)
+		u.UpdateSoldItems(SoldItemsQueueList[0])
+		log.Printf("added %v new SoldItems to Shop: %s\n", NewSoldItems, Shop.Name)
 	}
-	log.Println("finished updating Shops")
 
 	return nil
 }
 
+func (u *UpdateDB) RecordDailyRevenue(ShopID uint) error {
+	Items, err := u.Shop.GetItemsByShopID(ShopID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+	return u.Shop.RecordDailyRevenue(ShopID, Items)
+}
+
 func (u *UpdateDB) UpdateSoldItems(queue UpdateSoldItemsQueue) {
 	ShopRequest := &models.ShopRequest{}
 	u.Shop.UpdateSellingHistory(&queue.Shop, &queue.Task, ShopRequest)
@@ -164,33 +399,51 @@ func (u *UpdateDB) ShopItemsUpdate(Shop, updatedShop *models.Shop, scraper scrap
 
 	dataShopID := ""
 	existingItemMap := make(map[uint]bool)
-	ListOfMenus := []string{}
-	var OutOfProductionID uint
+	OutOfProductionID := GetOutOfProductionMenuID(Shop.ShopMenu.Menu)
 
-	updatedShop = scraper.ScrapAllMenuItems(updatedShop)
-	for _, UpdatedMenu := range updatedShop.ShopMenu.Menu {
-		for _, Menu := range Shop.ShopMenu.Menu {
+	updatedShop = controllers.ScrapMenuItems(scraper, updatedShop)
 
-			ListOfMenus = append(ListOfMenus, Menu.Category)
-
-			if Menu.Category == "Out Of Production" {
-				OutOfProductionID = Menu.ID
-				continue
-			}
-			if Menu.Category == UpdatedMenu.Category {
-				UpdatedMenu.ID = Menu.ID
+	matchedMenus := MatchMenus(Shop.ShopMenu.Menu, updatedShop.ShopMenu.Menu)
+	deletedMenus := GetDeletedMenus(Shop.ShopMenu.Menu, matchedMenus)
 
-			}
+	partialScrape := IsPartialMenuScrape(Shop.ShopMenu.Menu, updatedShop.ShopMenu.Menu)
+	if partialScrape {
+		log.Printf("menu scrape of Shop.ID %v returned %v of %v sections, no section or item is removed\n",
+			Shop.ID, len(updatedShop.ShopMenu.Menu), len(Shop.ShopMenu.Menu))
+		deletedMenus = nil
+	}
+	deletedMenuIDs := make(map[uint]bool)
+	for _, Menu := range deletedMenus {
+		deletedMenuIDs[Menu.ID] = true
+	}
 
-		}
+	for index, UpdatedMenu := range updatedShop.ShopMenu.Menu {
 
-		if Exists := MenuExists(UpdatedMenu.Category, ListOfMenus); !Exists {
+		if existingIndex, Exists := matchedMenus[index]; Exists {
+			ExistingMenu := Shop.ShopMenu.Menu[existingIndex]
+			UpdatedMenu.ID = ExistingMenu.ID
+			if err := u.UpdateMatchedMenu(Shop.ID, ExistingMenu, UpdatedMenu); err != nil {
+				return utils.HandleError(err)
+			}
+		} else {
 			NewMenu := models.CreateMenuItem(UpdatedMenu)
 			NewMenu.ShopMenuID = Shop.ShopMenu.ID
-			if _, err := u.Repo.CreateMenu(NewMenu); err != nil {
+			NewMenu, err := u.Repo.CreateMenu(NewMenu)
+			if err != nil {
 				return utils.HandleError(err)
 			}
 			UpdatedMenu.ID = NewMenu.ID
+
+			if err := u.Repo.CreateMenuHistoryChange(models.MenuHistoryChange{
+				ShopID:      Shop.ID,
+				MenuItemID:  NewMenu.ID,
+				SectionID:   NewMenu.SectionID,
+				Event:       models.MenuCreated,
+				NewCategory: NewMenu.Category,
+				NewAmount:   NewMenu.Amount,
+			}); err != nil {
+				return utils.HandleError(err)
+			}
 		}
 
 		for _, item := range UpdatedMenu.Items {
@@ -206,16 +459,194 @@ func (u *UpdateDB) ShopItemsUpdate(Shop, updatedShop *models.Shop, scraper scrap
 				item.MenuItemID = UpdatedMenu.ID
 				u.AddNewItem(item)
 
-			} else if ShouldUpdateItem(existingItem.OriginalPrice, item.OriginalPrice) {
+			} else if IsItemReactivated(*existingItem, OutOfProductionID) {
+				if err := u.ReactivateItem(*existingItem, item, UpdatedMenu.ID); err != nil {
+					return utils.HandleError(err)
+				}
+
+			} else if deletedMenuIDs[existingItem.MenuItemID] || ShouldUpdateItem(existingItem.OriginalPrice, item.OriginalPrice) {
 				u.ApplyItemUpdates(*existingItem, item, UpdatedMenu.ID)
 			}
+
+			if existingItem.ID != 0 {
+				if err := u.UpdateItemAttributes(*existingItem, item); err != nil {
+					return utils.HandleError(err)
+				}
+			}
 		}
 
 	}
-	if OutOfProductionID != 0 {
+	if !partialScrape && (OutOfProductionID != 0 || len(deletedMenus) > 0) {
 		u.HandleOutOfProductionItems(dataShopID, OutOfProductionID, Shop.ShopMenu.ID, existingItemMap)
 
 	}
+
+	for _, Menu := range deletedMenus {
+		if err := u.DeleteMenu(Shop.ID, Menu); err != nil {
+			return utils.HandleError(err)
+		}
+	}
+	return nil
+}
+
+func GetOutOfProductionMenuID(Menus []models.MenuItem) uint {
+	for _, Menu := range Menus {
+		if Menu.Category == "Out Of Production" {
+			return Menu.ID
+		}
+	}
+	return 0
+}
+
+// MatchMenus pairs every scraped menu with the existing menu it updates, keyed by
+// their indexes. A menu is matched by its section id first, so a renamed section
+// keeps its row, and by its category only when that section id is not scraped
+// anymore. The Out Of Production menu is never matched.
+func MatchMenus(ExistingMenus, UpdatedMenus []models.MenuItem) map[int]int {
+	matched := make(map[int]int)
+	usedMenus := make(map[int]bool)
+	scrapedSections := make(map[string]bool)
+
+	for _, UpdatedMenu := range UpdatedMenus {
+		if UpdatedMenu.SectionID != "" {
+			scrapedSections[UpdatedMenu.SectionID] = true
+		}
+	}
+
+	for updatedIndex, UpdatedMenu := range UpdatedMenus {
+		if UpdatedMenu.SectionID == "" {
+			continue
+		}
+		for existingIndex, ExistingMenu := range ExistingMenus {
+			if usedMenus[existingIndex] || ExistingMenu.Category == "Out Of Production" {
+				continue
+			}
+			if ExistingMenu.SectionID == UpdatedMenu.SectionID {
+				matched[updatedIndex] = existingIndex
+				usedMenus[existingIndex] = true
+				break
+			}
+		}
+	}
+
+	for updatedIndex, UpdatedMenu := range UpdatedMenus {
+		if _, ok := matched[updatedIndex]; ok {
+			continue
+		}
+		for existingIndex, ExistingMenu := range ExistingMenus {
+			if usedMenus[existingIndex] || ExistingMenu.Category == "Out Of Production" {
+				continue
+			}
+			if ExistingMenu.SectionID != "" && scrapedSections[ExistingMenu.SectionID] {
+				continue
+			}
+			if ExistingMenu.Category == UpdatedMenu.Category {
+				matched[updatedIndex] = existingIndex
+				usedMenus[existingIndex] = true
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// IsPartialMenuScrape reports whether a menu scrape returned no section, or fewer than
+// MinScrapedMenuShare of the shop's existing sections. The Out Of Production menu is
+// not a scraped section and is left out of both counts.
+func IsPartialMenuScrape(ExistingMenus, UpdatedMenus []models.MenuItem) bool {
+	existingSections, scrapedSections := 0, 0
+	for _, Menu := range ExistingMenus {
+		if Menu.Category != "Out Of Production" {
+			existingSections++
+		}
+	}
+	for _, Menu := range UpdatedMenus {
+		if Menu.Category != "Out Of Production" {
+			scrapedSections++
+		}
+	}
+
+	if existingSections == 0 {
+		return false
+	}
+	return scrapedSections == 0 || float64(scrapedSections) < float64(existingSections)*MinScrapedMenuShare
+}
+
+// GetDeletedMenus returns the existing menus no scraped menu was matched with,
+// the sections the seller removed.
+func GetDeletedMenus(ExistingMenus []models.MenuItem, matchedMenus map[int]int) []models.MenuItem {
+	usedMenus := make(map[int]bool)
+	for _, existingIndex := range matchedMenus {
+		usedMenus[existingIndex] = true
+	}
+
+	deletedMenus := []models.MenuItem{}
+	for existingIndex, ExistingMenu := range ExistingMenus {
+		if usedMenus[existingIndex] || ExistingMenu.Category == "Out Of Production" {
+			continue
+		}
+		deletedMenus = append(deletedMenus, ExistingMenu)
+	}
+	return deletedMenus
+}
+
+func (u *UpdateDB) UpdateMatchedMenu(ShopID uint, ExistingMenu, UpdatedMenu models.MenuItem) error {
+	if ExistingMenu.Category == UpdatedMenu.Category && ExistingMenu.Amount == UpdatedMenu.Amount {
+		return nil
+	}
+
+	Event := models.MenuResized
+	if ExistingMenu.Category != UpdatedMenu.Category {
+		Event = models.MenuRenamed
+	}
+
+	if err := u.Repo.CreateMenuHistoryChange(models.MenuHistoryChange{
+		ShopID:      ShopID,
+		MenuItemID:  ExistingMenu.ID,
+		SectionID:   ExistingMenu.SectionID,
+		Event:       Event,
+		OldCategory: ExistingMenu.Category,
+		NewCategory: UpdatedMenu.Category,
+		OldAmount:   ExistingMenu.Amount,
+		NewAmount:   UpdatedMenu.Amount,
+	}); err != nil {
+		return utils.HandleError(err)
+	}
+
+	menuUpdate := map[string]interface{}{
+		"category": UpdatedMenu.Category,
+		"amount":   UpdatedMenu.Amount,
+	}
+	if UpdatedMenu.Link != "" {
+		menuUpdate["link"] = UpdatedMenu.Link
+	}
+
+	if err := u.Repo.UpdateMenu(ExistingMenu, menuUpdate); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+// DeleteMenu removes a section that is gone from the shop. Its items were either
+// moved to the section they are listed in now or to Out Of Production before.
+func (u *UpdateDB) DeleteMenu(ShopID uint, Menu models.MenuItem) error {
+	if err := u.Repo.CreateMenuHistoryChange(models.MenuHistoryChange{
+		ShopID:      ShopID,
+		MenuItemID:  Menu.ID,
+		SectionID:   Menu.SectionID,
+		Event:       models.MenuDeleted,
+		OldCategory: Menu.Category,
+		OldAmount:   Menu.Amount,
+	}); err != nil {
+		return utils.HandleError(err)
+	}
+
+	if err := u.Repo.DeleteMenu(Menu.ID); err != nil {
+		return utils.HandleError(err)
+	}
+
+	log.Printf("menu %s was removed from Shop.ID %v\n", Menu.Category, ShopID)
 	return nil
 }
 
@@ -250,6 +681,106 @@ func (u *UpdateDB) ApplyItemUpdates(existingItem, item models.Item, UpdatedMenuI
 
 }
 
+// ItemAttributeChanges diffs every tracked attribute of a listing. The regular price and
+// availability are diffed exactly, the price history keeps only the moves above 3%. An
+// empty scraped title or currency is left out, it means the listing card was not fully rendered.
+func ItemAttributeChanges(existingItem, item models.Item) []models.ItemAttributeChange {
+	changes := []models.ItemAttributeChange{}
+
+	addChange := func(Attribute, OldValue, NewValue string) {
+		if OldValue == NewValue {
+			return
+		}
+		changes = append(changes, models.ItemAttributeChange{
+			ItemID:    existingItem.ID,
+			Attribute: Attribute,
+			OldValue:  OldValue,
+			NewValue:  NewValue,
+		})
+	}
+
+	if item.Name != "" {
+		addChange(models.ItemAttributeName, existingItem.Name, item.Name)
+	}
+	addChange(models.ItemAttributeOriginalPrice, strconv.FormatFloat(existingItem.OriginalPrice, 'f', -1, 64), strconv.FormatFloat(item.OriginalPrice, 'f', -1, 64))
+	addChange(models.ItemAttributeAvailable, strconv.FormatBool(existingItem.Available), strconv.FormatBool(item.Available))
+	addChange(models.ItemAttributeSalePrice, strconv.FormatFloat(existingItem.SalePrice, 'f', -1, 64), strconv.FormatFloat(item.SalePrice, 'f', -1, 64))
+	addChange(models.ItemAttributeDiscount, existingItem.DiscoutPercent, item.DiscoutPercent)
+	if item.CurrencySymbol != "" {
+		addChange(models.ItemAttributeCurrency, existingItem.CurrencySymbol, item.CurrencySymbol)
+	}
+
+	return changes
+}
+
+func (u *UpdateDB) UpdateItemAttributes(existingItem, item models.Item) error {
+	changes := ItemAttributeChanges(existingItem, item)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := u.Repo.CreateItemAttributeChanges(changes); err != nil {
+		return utils.HandleError(err)
+	}
+
+	itemUpdate := map[string]interface{}{}
+	for _, change := range changes {
+		switch change.Attribute {
+		case models.ItemAttributeName:
+			itemUpdate["name"] = item.Name
+		case models.ItemAttributeOriginalPrice:
+			itemUpdate["original_price"] = item.OriginalPrice
+		case models.ItemAttributeAvailable:
+			itemUpdate["available"] = item.Available
+		case models.ItemAttributeSalePrice:
+			itemUpdate["sale_price"] = item.SalePrice
+		case models.ItemAttributeDiscount:
+			itemUpdate["discout_percent"] = item.DiscoutPercent
+		case models.ItemAttributeCurrency:
+			itemUpdate["currency_symbol"] = item.CurrencySymbol
+		}
+	}
+
+	if err := u.Repo.UpdateItem(existingItem, itemUpdate); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
+
+// IsItemReactivated reports whether a listing found in one of the shop's sections
+// was parked in the Out Of Production menu, meaning the seller relisted or restocked it.
+func IsItemReactivated(existingItem models.Item, OutOfProductionID uint) bool {
+	return OutOfProductionID != 0 && existingItem.MenuItemID == OutOfProductionID
+}
+
+func (u *UpdateDB) ReactivateItem(existingItem, item models.Item, UpdatedMenuID uint) error {
+
+	Change := models.ItemHistoryChange{
+		ItemID:        existingItem.ID,
+		OldPrice:      existingItem.OriginalPrice,
+		NewPrice:      item.OriginalPrice,
+		OldAvailable:  existingItem.Available,
+		NewAvailable:  true,
+		OldMenuItemID: existingItem.MenuItemID,
+		NewMenuItemID: UpdatedMenuID,
+	}
+	if err := u.Repo.CreateItemHistoryChange(Change); err != nil {
+		return utils.HandleError(err)
+	}
+
+	itemUpdate := map[string]interface{}{
+		"original_price": item.OriginalPrice,
+		"available":      true,
+		"menu_item_id":   UpdatedMenuID,
+	}
+	if err := u.Repo.UpdateItem(existingItem, itemUpdate); err != nil {
+		return utils.HandleError(err)
+	}
+
+	log.Println("item is available again: ", existingItem.ListingID)
+	return nil
+}
+
 func (u *UpdateDB) HandleOutOfProductionItems(dataShopID string, OutOfProductionID, ShopMenuID uint, existingItemMap map[uint]bool) {
 	existingItems, err := u.Repo.GetAllItemsByDataShopID(dataShopID)
 	log.Println("error is :", err)
@@ -62,17 +62,8 @@ func (m *MockShopUpdater) GetItemsByShopID(ID uint) ([]models.Item, error) {
 	}
 	return Items, args.Error(1)
 }
-func (m *MockShopUpdater) GetItemsBySoldItems(SoldItems []models.SoldItems) ([]models.Item, error) {
-	args := m.Called()
-	shopInterface := args.Get(0)
-	var Items []models.Item
-	if shopInterface != nil {
-		Items = shopInterface.([]models.Item)
-	}
-	return Items, args.Error(1)
-}
 
-func (m *MockShopUpdater) CreateSoldStats(dailyShopSales []models.DailyShopSales) (map[string]controllers.DailySoldStats, error) {
+func (m *MockShopUpdater) CreateSoldStats(dailyShopSales []models.DailyShopSales, loc *time.Location) (map[string]controllers.DailySoldStats, error) {
 	args := m.Called()
 
 	return args.Get(0).(map[string]controllers.DailySoldStats), args.Error(1)
@@ -125,6 +116,11 @@ func (m *MockShopUpdater) UpdateSellingHistory(Shop *models.Shop, Task *models.T
 	args := m.Called()
 	return args.Error(0)
 }
+func (m *MockShopUpdater) RecordDailyRevenue(ShopID uint, Items []models.Item) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m *MockShopUpdater) UpdateDiscontinuedItems(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest) ([]models.SoldItems, error) {
 	args := m.Called()
 	shopInterface := args.Get(0)
@@ -144,6 +140,35 @@ func (m *MockShopUpdater) UpdateShopMenuToDB(Shop *models.Shop, ShopRequest *mod
 	return args.Error(0)
 }
 
+func (m *MockShopUpdater) EnqueueShopRefresh(ShopID uint, AccountID uuid.UUID, FullRefresh bool) (*models.ShopRefreshJob, bool, error) {
+	args := m.Called()
+	jobInterface := args.Get(0)
+	var job *models.ShopRefreshJob
+	if jobInterface != nil {
+		job = jobInterface.(*models.ShopRefreshJob)
+	}
+	return job, args.Bool(1), args.Error(2)
+}
+func (m *MockShopUpdater) RunShopRefresh(job *models.ShopRefreshJob) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockShopUpdater) EnqueueShopOnboarding(ShopRequest *models.ShopRequest) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockShopUpdater) EnqueueSellingHistory(Shop *models.Shop, Task *models.TaskSchedule, ShopRequest *models.ShopRequest, delay time.Duration) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockShopUpdater) MergeDuplicateShops(TargetShopID, SourceShopID uint) (*repository.ShopMerge, error) {
+	args := m.Called()
+	return nil, args.Error(1)
+}
+
 func TestScheduleScrapUpdateSchedulesCronJob(t *testing.T) {
 
 	cronJob := &MockCronJob{}
@@ -155,7 +180,31 @@ func TestScheduleScrapUpdateSchedulesCronJob(t *testing.T) {
 
 	assert.True(t, cronJob.AddFuncCalled)
 	assert.True(t, cronJob.StartCalled)
-	assert.Equal(t, "12 15 * * *", cronJob.AddFuncArg1)
+	assert.Equal(t, "CRON_TZ=UTC 12 15 * * *", cronJob.AddFuncArg1)
+}
+
+func TestSnapshotCronSpec(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"", "CRON_TZ=UTC 12 15 * * *", false},
+		{"00:05", "CRON_TZ=UTC 5 0 * * *", false},
+		{"23:30", "CRON_TZ=UTC 30 23 * * *", false},
+		{"25:00", "", true},
+		{"noon", "", true},
+	}
+
+	for _, test := range tests {
+		spec, err := scheduleUpdates.SnapshotCronSpec(test.input)
+		if test.hasError {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, spec)
+	}
 }
 
 func TestUpdateSoldItemsShopParameterNil(t *testing.T) {
@@ -226,6 +275,25 @@ func (m *MockScrapper) ScrapSalesHistory(ShopName string, Task *models.TaskSched
 	return args.Get(0).([]models.SoldItems), args.Get(1).(*models.TaskSchedule)
 }
 
+func expectShopLock(sqlMock sqlmock.Sqlmock, ShopID uint) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "shop_locks" ("shop_id","owner","locked_at") VALUES ($1,$2,$3) ON CONFLICT ("shop_id") DO UPDATE SET "owner"="excluded"."owner","locked_at"="excluded"."locked_at" WHERE "shop_locks"."owner" = '' OR "shop_locks"."locked_at" < $4`)).
+		WithArgs(ShopID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+}
+
+func expectShopUnlock(sqlMock sqlmock.Sqlmock, ShopID uint) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shop_locks" SET "owner"=$1 WHERE shop_id = $2 AND owner = $3`)).
+		WithArgs("", ShopID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+}
+
+func expectShopSales(sqlMock sqlmock.Sqlmock, ShopID uint, TotalSales, Admirers int) {
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","total_sales","admirers","on_vacation" FROM "shops" WHERE id = $1`)).
+		WithArgs(ShopID, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "total_sales", "admirers", "on_vacation"}).AddRow(ShopID, TotalSales, Admirers, false))
+}
+
 func TestStartShopUpdateUpdatesSuccess(t *testing.T) {
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -262,9 +330,12 @@ func TestStartShopUpdateUpdatesSuccess(t *testing.T) {
 		WillReturnRows(menuRows)
 
 	for i := 1; i < 3; i++ {
+		expectShopLock(sqlMock, uint(i))
+		expectShopSales(sqlMock, uint(i), 100, 2)
+
 		sqlMock.ExpectBegin()
-		sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), i, 101, 10, float64(0)).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue","estimated") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), i, 101, 10, float64(0), false).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
 		sqlMock.ExpectCommit()
 
 		sqlMock.ExpectBegin()
@@ -278,6 +349,8 @@ func TestStartShopUpdateUpdatesSuccess(t *testing.T) {
 			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"1", "2", "3", "4"}))
 
 		sqlMock.ExpectCommit()
+
+		expectShopUnlock(sqlMock, uint(i))
 	}
 	err := updateDB.StartShopUpdate(false, MockedScrapper)
 	if err != nil {
@@ -286,6 +359,89 @@ func TestStartShopUpdateUpdatesSuccess(t *testing.T) {
 	assert.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
+func TestStartShopUpdateLogsRecorderErrors(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	MockedScrapper := &MockScrapper{}
+	expectedShop := &models.Shop{TotalSales: 101, Admirers: 10, ListingPositions: []models.ListingPosition{{ListingID: 300, Position: 1}}}
+	MockedScrapper.On("CheckForUpdates").Return(expectedShop, nil)
+
+	sqlMock.MatchExpectationsInOrder(true)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM \"shops\"")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "total_sales", "admirers"}).AddRow(1, "Shop 1", 100, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_menus" WHERE "shop_menus"."shop_id" = $1 AND "shop_menus"."deleted_at" IS NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shop_id", "total_items_amount"}).AddRow(1, 1, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "menu_items" WHERE "menu_items"."shop_menu_id" = $1 AND "menu_items"."deleted_at" IS NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shop_menu_id", "category"}).AddRow(1, 1, "Category 1"))
+
+	expectShopLock(sqlMock, 1)
+	expectShopSales(sqlMock, 1, 100, 2)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "listing_positions"`)).
+		WillReturnError(errors.New("error while saving listing positions"))
+	sqlMock.ExpectRollback()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops"`)).
+		WithArgs(10, 101, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_menus"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "menu_items"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	expectShopUnlock(sqlMock, 1)
+
+	err := updateDB.StartShopUpdate(false, MockedScrapper)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateShopPricesUnitsOfEarlierRefresh(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopUpdater := &MockShopUpdater{}
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo, Shop: ShopUpdater}
+
+	// a refresh already moved the shop to 105 sales, the snapshot finds no new units.
+	MockedScrapper := &MockScrapper{}
+	MockedScrapper.On("CheckForUpdates").Return(&models.Shop{TotalSales: 105, Admirers: 2}, nil)
+	ShopUpdater.On("GetItemsByShopID").Return([]models.Item{{}}, nil)
+	ShopUpdater.On("RecordDailyRevenue").Return(nil)
+
+	sqlMock.MatchExpectationsInOrder(true)
+	expectShopSales(sqlMock, 1, 105, 2)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 105, 2, float64(0), false).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	Shop := &models.Shop{Name: "Shop 1", TotalSales: 100, Admirers: 2, HasSoldHistory: true}
+	Shop.ID = 1
+	NewSoldItems, err := updateDB.UpdateShop(Shop, false, MockedScrapper)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, NewSoldItems)
+	ShopUpdater.AssertNumberOfCalls(t, "RecordDailyRevenue", 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
 func TestStartShopUpdateOneUpdate(t *testing.T) {
 	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
 	testDB.Begin()
@@ -321,10 +477,15 @@ func TestStartShopUpdateOneUpdate(t *testing.T) {
 	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "menu_items" WHERE "menu_items"."shop_menu_id" IN ($1,$2) AND "menu_items"."deleted_at" IS NULL`)).
 		WillReturnRows(menuRows)
 	for i := 1; i < 3; i++ {
+		expectShopLock(sqlMock, uint(i))
+		expectShopSales(sqlMock, uint(i), 100, 2)
+
 		sqlMock.ExpectBegin()
-		sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
-			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), i, 100, 2, float64(0)).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_sales" ("created_at","updated_at","deleted_at","shop_id","total_sales","admirers","daily_revenue","estimated") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), i, 100, 2, float64(0), false).WillReturnRows(sqlmock.NewRows([]string{"1", "2"}))
 		sqlMock.ExpectCommit()
+
+		expectShopUnlock(sqlMock, uint(i))
 	}
 	err := updateDB.StartShopUpdate(false, MockedScrapper)
 	if err != nil {
@@ -557,6 +718,14 @@ func TestShopItemsUpdateFewUpdates(t *testing.T) {
 							sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "available"=$1,"menu_item_id"=$2,"original_price"=$3,"updated_at"=$4 WHERE "items"."deleted_at" IS NULL AND "id" = $5`)).
 								WithArgs(UpdatedItem.Available, ExistingItem.MenuItemID, UpdatedItem.OriginalPrice, sqlmock.AnyArg(), ExistingItem.ID).WillReturnResult(sqlmock.NewResult(1, 1))
 							sqlMock.ExpectCommit()
+							sqlMock.ExpectBegin()
+							sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "item_attribute_changes" ("created_at","updated_at","deleted_at","item_id","attribute","old_value","new_value") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+								WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), ExistingItem.ID, models.ItemAttributeOriginalPrice, "10", "20").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+							sqlMock.ExpectCommit()
+							sqlMock.ExpectBegin()
+							sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "original_price"=$1,"updated_at"=$2 WHERE "items"."deleted_at" IS NULL AND "id" = $3`)).
+								WithArgs(UpdatedItem.OriginalPrice, sqlmock.AnyArg(), ExistingItem.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+							sqlMock.ExpectCommit()
 						}
 					}
 				}
@@ -820,10 +989,17 @@ func TestShopItemsUpdateCreateNewMenu(t *testing.T) {
 
 	sqlMock.ExpectBegin()
 	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "menu_items" ("created_at","updated_at","deleted_at","shop_menu_id","category","section_id","link","amount") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 0, "chairs", "46704599", "", 46).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 0, "chairs", "46704599", "", 46).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
 	sqlMock.ExpectCommit()
 
-	updateDB.ShopItemsUpdate(ExistingShop, UpdatedShop, MockedScrapper)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "menu_history_changes" ("created_at","updated_at","deleted_at","shop_id","menu_item_id","section_id","event","old_category","new_category","old_amount","new_amount") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 0, 12, "46704599", "created", "", "chairs", 0, 46).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.ShopItemsUpdate(ExistingShop, UpdatedShop, MockedScrapper)
+
+	assert.NoError(t, err)
 
 	assert.Nil(t, sqlMock.ExpectationsWereMet())
 }
@@ -1147,3 +1323,389 @@ func TestAddSoldItemsQueueList(t *testing.T) {
 	assert.Equal(t, Shop, SoldItemsQueueList[0].Shop)
 	assert.Equal(t, NewSoldItems, SoldItemsQueueList[0].Task.UpdateSoldItems)
 }
+
+func TestRefreshShopFetchShopFails(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	MockedScrapper := &MockScrapper{}
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo, Scraper: MockedScrapper}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	expectShopLock(sqlMock, 1)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops"`)).WillReturnError(errors.New("record not found"))
+	expectShopUnlock(sqlMock, 1)
+
+	err := updateDB.RefreshShop(1, false)
+
+	assert.Error(t, err)
+	MockedScrapper.AssertNotCalled(t, "CheckForUpdates")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshShopUpdatesShopColumns(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	MockedScrapper := &MockScrapper{}
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo, Scraper: MockedScrapper}
+
+	MockedScrapper.On("CheckForUpdates").Return(&models.Shop{TotalSales: 100, Admirers: 12}, nil)
+
+	sqlMock.MatchExpectationsInOrder(false)
+	expectShopLock(sqlMock, 1)
+	expectShopUnlock(sqlMock, 1)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops"`)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "total_sales", "admirers"}).AddRow(1, "Shop 1", 100, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_members"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_menus"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "reviews"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops"`)).
+		WithArgs(12, 100, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.RefreshShop(1, false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshShopLogsRecorderErrors(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	MockedScrapper := &MockScrapper{}
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo, Scraper: MockedScrapper}
+
+	MockedScrapper.On("CheckForUpdates").Return(&models.Shop{TotalSales: 100, Admirers: 12, ListingPositions: []models.ListingPosition{{ListingID: 300, Position: 1}}}, nil)
+
+	sqlMock.MatchExpectationsInOrder(false)
+	expectShopLock(sqlMock, 1)
+	expectShopUnlock(sqlMock, 1)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops"`)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "total_sales", "admirers"}).AddRow(1, "Shop 1", 100, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_members"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_menus"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "reviews"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "listing_positions"`)).
+		WillReturnError(errors.New("error while saving listing positions"))
+	sqlMock.ExpectRollback()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops"`)).
+		WithArgs(12, 100, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.RefreshShop(1, false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRefreshShopOnVacationSkipsUpdate(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	MockedScrapper := &MockScrapper{}
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo, Scraper: MockedScrapper}
+
+	MockedScrapper.On("CheckForUpdates").Return(&models.Shop{TotalSales: 0, Admirers: 0, OnVacation: true}, nil)
+
+	sqlMock.MatchExpectationsInOrder(false)
+	expectShopLock(sqlMock, 1)
+	expectShopUnlock(sqlMock, 1)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops"`)).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "total_sales", "admirers", "on_vacation"}).AddRow(1, "Shop 1", 100, 2, true))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_members"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_menus"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "reviews"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	err := updateDB.RefreshShop(1, false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestIsItemReactivated(t *testing.T) {
+	assert.True(t, scheduleUpdates.IsItemReactivated(models.Item{MenuItemID: 7}, 7))
+	assert.False(t, scheduleUpdates.IsItemReactivated(models.Item{MenuItemID: 3}, 7))
+	assert.False(t, scheduleUpdates.IsItemReactivated(models.Item{MenuItemID: 0}, 0))
+}
+
+func TestShopItemsUpdateReactivatesOutOfProductionItem(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	MockedScrapper := &MockScrapper{}
+
+	shelving := models.MenuItem{Category: "shelving", SectionID: "46696458"}
+	shelving.ID = 3
+	outOfProduction := models.MenuItem{Category: "Out Of Production", SectionID: "0"}
+	outOfProduction.ID = 9
+	ExistingShop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{shelving, outOfProduction}}}
+	ExistingShop.ShopMenu.ID = 2
+
+	UpdatedShop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{
+		{Category: "shelving", SectionID: "46696458", Items: []models.Item{{ListingID: 1, DataShopID: "101", OriginalPrice: 12, Available: true}}},
+	}}}
+
+	MockedScrapper.On("ScrapAllMenuItems").Return(UpdatedShop, nil)
+
+	sqlMock.MatchExpectationsInOrder(true)
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE Listing_id = $1 AND "items"."deleted_at" IS NULL ORDER BY "items"."id" LIMIT $2`)).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_price", "available", "menu_item_id", "listing_id", "data_shop_id"}).
+			AddRow(5, 10, false, outOfProduction.ID, 1, "101"))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "item_history_changes" ("created_at","updated_at","deleted_at","item_id","new_item_created","old_price","new_price","old_available","new_available","old_menu_item_id","new_menu_item_id") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5, false, float64(10), float64(12), false, true, outOfProduction.ID, shelving.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "available"=$1,"menu_item_id"=$2,"original_price"=$3,"updated_at"=$4 WHERE "items"."deleted_at" IS NULL AND "id" = $5`)).
+		WithArgs(true, shelving.ID, float64(12), sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "item_attribute_changes" ("created_at","updated_at","deleted_at","item_id","attribute","old_value","new_value") VALUES ($1,$2,$3,$4,$5,$6,$7),($8,$9,$10,$11,$12,$13,$14) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 5, models.ItemAttributeOriginalPrice, "10", "12",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 5, models.ItemAttributeAvailable, "false", "true").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "available"=$1,"original_price"=$2,"updated_at"=$3 WHERE "items"."deleted_at" IS NULL AND "id" = $4`)).
+		WithArgs(true, float64(12), sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE data_shop_id = $1 AND "items"."deleted_at" IS NULL`)).WithArgs("101").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "menu_item_id", "listing_id", "data_shop_id"}).AddRow(5, true, shelving.ID, 1, "101"))
+
+	err := updateDB.ShopItemsUpdate(ExistingShop, UpdatedShop, MockedScrapper)
+
+	assert.NoError(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMatchMenus(t *testing.T) {
+
+	ExistingMenus := []models.MenuItem{
+		{Category: "Out Of Production", SectionID: "0"},
+		{Category: "All", SectionID: "0"},
+		{Category: "shelving", SectionID: "46696458"},
+		{Category: "tables", SectionID: "46704593"},
+		{Category: "lamps", SectionID: "46704111"},
+		{Category: "chairs", SectionID: "46704599"},
+	}
+	UpdatedMenus := []models.MenuItem{
+		{Category: "All", SectionID: "0"},
+		{Category: "wall shelves", SectionID: "46696458"},
+		{Category: "tables", SectionID: "47000000"},
+		{Category: "chairs", SectionID: "46704593"},
+	}
+
+	matched := scheduleUpdates.MatchMenus(ExistingMenus, UpdatedMenus)
+
+	assert.Equal(t, map[int]int{0: 1, 1: 2, 3: 3}, matched)
+
+	deleted := scheduleUpdates.GetDeletedMenus(ExistingMenus, matched)
+
+	assert.Equal(t, []models.MenuItem{ExistingMenus[4], ExistingMenus[5]}, deleted)
+}
+
+func TestShopItemsUpdateRenamedAndDeletedSections(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	MockedScrapper := &MockScrapper{}
+
+	shelving := models.MenuItem{Category: "shelving", SectionID: "46696458", Amount: 45}
+	shelving.ID = 3
+	lamps := models.MenuItem{Category: "lamps", SectionID: "46704111", Amount: 2}
+	lamps.ID = 4
+	outOfProduction := models.MenuItem{Category: "Out Of Production", SectionID: "0"}
+	outOfProduction.ID = 9
+	ExistingShop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{shelving, lamps, outOfProduction}}}
+	ExistingShop.ID = 1
+	ExistingShop.ShopMenu.ID = 2
+
+	UpdatedShop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{
+		{Category: "wall shelves", SectionID: "46696458", Amount: 46, Items: []models.Item{{ListingID: 7, DataShopID: "101", OriginalPrice: 10, Available: true}}},
+	}}}
+
+	MockedScrapper.On("ScrapAllMenuItems").Return(UpdatedShop, nil)
+
+	sqlMock.MatchExpectationsInOrder(true)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "menu_history_changes" ("created_at","updated_at","deleted_at","shop_id","menu_item_id","section_id","event","old_category","new_category","old_amount","new_amount") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, shelving.ID, "46696458", "renamed", "shelving", "wall shelves", 45, 46).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_items" SET "amount"=$1,"category"=$2,"updated_at"=$3 WHERE "menu_items"."deleted_at" IS NULL AND "id" = $4`)).
+		WithArgs(46, "wall shelves", sqlmock.AnyArg(), shelving.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE Listing_id = $1 AND "items"."deleted_at" IS NULL ORDER BY "items"."id" LIMIT $2`)).WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "original_price", "available", "menu_item_id", "listing_id", "data_shop_id"}).
+			AddRow(5, 10, true, lamps.ID, 7, "101"))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "item_history_changes" ("created_at","updated_at","deleted_at","item_id","new_item_created","old_price","new_price","old_available","new_available","old_menu_item_id","new_menu_item_id") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5, false, float64(10), float64(10), true, true, lamps.ID, shelving.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "available"=$1,"menu_item_id"=$2,"original_price"=$3,"updated_at"=$4 WHERE "items"."deleted_at" IS NULL AND "id" = $5`)).
+		WithArgs(true, shelving.ID, float64(10), sqlmock.AnyArg(), 5).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "items" WHERE data_shop_id = $1 AND "items"."deleted_at" IS NULL`)).WithArgs("101").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "available", "menu_item_id", "listing_id", "data_shop_id"}).AddRow(5, true, shelving.ID, 7, "101"))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "menu_history_changes" ("created_at","updated_at","deleted_at","shop_id","menu_item_id","section_id","event","old_category","new_category","old_amount","new_amount") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, lamps.ID, "46704111", "deleted", "lamps", "", 2, 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	sqlMock.ExpectCommit()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "menu_items" SET "deleted_at"=$1 WHERE "menu_items"."id" = $2 AND "menu_items"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), lamps.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.ShopItemsUpdate(ExistingShop, UpdatedShop, MockedScrapper)
+
+	assert.NoError(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestIsPartialMenuScrape(t *testing.T) {
+
+	ExistingMenus := []models.MenuItem{
+		{Category: "shelving", SectionID: "46696458"},
+		{Category: "tables", SectionID: "46704593"},
+		{Category: "lamps", SectionID: "46704111"},
+		{Category: "chairs", SectionID: "46704599"},
+		{Category: "Out Of Production", SectionID: "0"},
+	}
+
+	assert.True(t, scheduleUpdates.IsPartialMenuScrape(ExistingMenus, nil))
+	assert.True(t, scheduleUpdates.IsPartialMenuScrape(ExistingMenus, ExistingMenus[:1]))
+	assert.False(t, scheduleUpdates.IsPartialMenuScrape(ExistingMenus, ExistingMenus[:2]))
+	assert.False(t, scheduleUpdates.IsPartialMenuScrape(ExistingMenus[4:], nil))
+}
+
+func TestShopItemsUpdateEmptyScrapeKeepsSections(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	MockedScrapper := &MockScrapper{}
+
+	shelving := models.MenuItem{Category: "shelving", SectionID: "46696458", Amount: 45}
+	shelving.ID = 3
+	lamps := models.MenuItem{Category: "lamps", SectionID: "46704111", Amount: 2}
+	lamps.ID = 4
+	outOfProduction := models.MenuItem{Category: "Out Of Production", SectionID: "0"}
+	outOfProduction.ID = 9
+	ExistingShop := &models.Shop{ShopMenu: models.ShopMenu{Menu: []models.MenuItem{shelving, lamps, outOfProduction}}}
+	ExistingShop.ID = 1
+	ExistingShop.ShopMenu.ID = 2
+
+	UpdatedShop := &models.Shop{}
+
+	MockedScrapper.On("ScrapAllMenuItems").Return(UpdatedShop, nil)
+
+	err := updateDB.ShopItemsUpdate(ExistingShop, UpdatedShop, MockedScrapper)
+
+	assert.NoError(t, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestItemAttributeChanges(t *testing.T) {
+	existingItem := models.Item{Name: "Oak shelf", SalePrice: -1, DiscoutPercent: "", CurrencySymbol: "€"}
+	existingItem.ID = 4
+
+	assert.Empty(t, scheduleUpdates.ItemAttributeChanges(existingItem, existingItem))
+
+	item := models.Item{Name: "Solid oak wall shelf", SalePrice: 80, DiscoutPercent: "(20% off)", CurrencySymbol: "£"}
+	changes := scheduleUpdates.ItemAttributeChanges(existingItem, item)
+
+	assert.Equal(t, []models.ItemAttributeChange{
+		{ItemID: 4, Attribute: models.ItemAttributeName, OldValue: "Oak shelf", NewValue: "Solid oak wall shelf"},
+		{ItemID: 4, Attribute: models.ItemAttributeSalePrice, OldValue: "-1", NewValue: "80"},
+		{ItemID: 4, Attribute: models.ItemAttributeDiscount, OldValue: "", NewValue: "(20% off)"},
+		{ItemID: 4, Attribute: models.ItemAttributeCurrency, OldValue: "€", NewValue: "£"},
+	}, changes)
+
+	untitled := existingItem
+	untitled.Name = ""
+	assert.Empty(t, scheduleUpdates.ItemAttributeChanges(existingItem, untitled))
+
+	partlyRendered := existingItem
+	partlyRendered.CurrencySymbol = ""
+	assert.Empty(t, scheduleUpdates.ItemAttributeChanges(existingItem, partlyRendered))
+}
+
+func TestItemAttributeChangesPriceAndAvailability(t *testing.T) {
+	existingItem := models.Item{Name: "Oak shelf", OriginalPrice: 100, Available: true, SalePrice: -1, CurrencySymbol: "€"}
+	existingItem.ID = 4
+
+	item := existingItem
+	item.OriginalPrice = 101
+	item.Available = false
+	changes := scheduleUpdates.ItemAttributeChanges(existingItem, item)
+
+	assert.Equal(t, []models.ItemAttributeChange{
+		{ItemID: 4, Attribute: models.ItemAttributeOriginalPrice, OldValue: "100", NewValue: "101"},
+		{ItemID: 4, Attribute: models.ItemAttributeAvailable, OldValue: "true", NewValue: "false"},
+	}, changes)
+	assert.False(t, scheduleUpdates.ShouldUpdateItem(existingItem.OriginalPrice, item.OriginalPrice))
+}
+
+func TestUpdateItemAttributesRecordsTitleChange(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	existingItem := models.Item{Name: "Oak shelf", SalePrice: -1}
+	existingItem.ID = 4
+	item := models.Item{Name: "Solid oak wall shelf", SalePrice: -1}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "item_attribute_changes" ("created_at","updated_at","deleted_at","item_id","attribute","old_value","new_value") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 4, models.ItemAttributeName, "Oak shelf", "Solid oak wall shelf").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "items" SET "name"=$1,"updated_at"=$2 WHERE "items"."deleted_at" IS NULL AND "id" = $3`)).
+		WithArgs("Solid oak wall shelf", sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.UpdateItemAttributes(existingItem, item)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,121 @@
+package scheduleUpdates
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"EtsyScraper/models"
+	"EtsyScraper/utils"
+)
+
+// ShopProfileChanges compares the stored profile with a freshly scraped one and returns one
+// entry per changed field. Parts of the page that were not found in the scrape are left out
+// so a partly rendered page is not taken for a change.
+func ShopProfileChanges(Existing, Updated *models.Shop) []models.ShopHistoryChange {
+	changes := []models.ShopHistoryChange{}
+	if Updated.Description == "" {
+		return changes
+	}
+
+	addChange := func(Field, OldValue, NewValue string) {
+		if OldValue == NewValue {
+			return
+		}
+		changes = append(changes, models.ShopHistoryChange{
+			ShopID:   Existing.ID,
+			Field:    Field,
+			OldValue: OldValue,
+			NewValue: NewValue,
+		})
+	}
+
+	addChange(models.ShopFieldDescription, Existing.Description, Updated.Description)
+	if Updated.Location != "" {
+		addChange(models.ShopFieldLocation, Existing.Location, Updated.Location)
+	}
+	addChange(models.ShopFieldOnVacation, strconv.FormatBool(Existing.OnVacation), strconv.FormatBool(Updated.OnVacation))
+
+	if Updated.Member != nil {
+		addChange(models.ShopFieldMembers, membersToString(Existing.Member), membersToString(Updated.Member))
+	}
+	if Updated.SocialMediaLinks != nil {
+		addChange(models.ShopFieldSocialLinks, socialLinksToString(Existing.SocialMediaLinks), socialLinksToString(Updated.SocialMediaLinks))
+	}
+
+	if Updated.Reviews.ReviewsCount != 0 || Updated.Reviews.ShopRating != 0 {
+		addChange(models.ShopFieldRating, strconv.FormatFloat(Existing.Reviews.ShopRating, 'f', -1, 64), strconv.FormatFloat(Updated.Reviews.ShopRating, 'f', -1, 64))
+		addChange(models.ShopFieldReviewsCount, strconv.Itoa(Existing.Reviews.ReviewsCount), strconv.Itoa(Updated.Reviews.ReviewsCount))
+	}
+
+	return changes
+}
+
+func membersToString(Members []models.ShopMember) string {
+	values := []string{}
+	for _, member := range Members {
+		values = append(values, member.Name+" ("+member.Role+")")
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
+
+func socialLinksToString(Links []models.SocialMediaLinks) string {
+	values := []string{}
+	for _, link := range Links {
+		values = append(values, link.Link)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
+
+func (u *UpdateDB) UpdateShopProfile(ShopID uint, updatedShop *models.Shop) error {
+	if updatedShop.Description == "" {
+		return nil
+	}
+
+	Shop, err := u.Repo.GetShopProfileByID(ShopID)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+
+	changes := ShopProfileChanges(Shop, updatedShop)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	updatedShop.ID = Shop.ID
+	if err := u.Repo.UpdateShopProfile(updatedShop, changes); err != nil {
+		return utils.HandleError(err)
+	}
+
+	log.Printf("recorded %v profile changes for Shop: %s\n", len(changes), Shop.Name)
+	return nil
+}
+
+// SnapshotShopReviews stores the day's rating, review count and review topic counts. Nothing
+// is stored when the reviews block was not found on the page.
+func (u *UpdateDB) SnapshotShopReviews(ShopID uint, updatedShop *models.Shop) error {
+	Reviews := updatedShop.Reviews
+	if Reviews.ReviewsCount == 0 && Reviews.ShopRating == 0 {
+		return nil
+	}
+
+	DailyReviews := &models.DailyShopReviews{
+		ShopID:       ShopID,
+		ShopRating:   Reviews.ShopRating,
+		ReviewsCount: Reviews.ReviewsCount,
+	}
+	for _, topic := range Reviews.ReviewsTopic {
+		DailyReviews.Topics = append(DailyReviews.Topics, models.DailyReviewsTopic{
+			Keyword:      topic.Keyword,
+			KeywordCount: topic.KeywordCount,
+		})
+	}
+
+	if err := u.Repo.CreateDailyReviews(DailyReviews); err != nil {
+		return utils.HandleError(err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package scheduleUpdates_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
+	setupMockServer "EtsyScraper/setupTests"
+)
+
+func createProfileShop(Description, Location string, OnVacation bool, Rating float64, ReviewsCount int) *models.Shop {
+	return &models.Shop{
+		Description: Description,
+		Location:    Location,
+		OnVacation:  OnVacation,
+		Member:      []models.ShopMember{{Name: "Jane", Role: "Owner"}},
+		SocialMediaLinks: []models.SocialMediaLinks{
+			{Link: "https://www.instagram.com/exampleshop"},
+		},
+		Reviews: models.Reviews{ShopRating: Rating, ReviewsCount: ReviewsCount},
+	}
+}
+
+func TestShopProfileChangesNoChanges(t *testing.T) {
+	existing := createProfileShop("handmade shelves", "London", false, 4.8, 120)
+	updated := createProfileShop("handmade shelves", "London", false, 4.8, 120)
+
+	assert.Empty(t, scheduleUpdates.ShopProfileChanges(existing, updated))
+}
+
+func TestShopProfileChangesRecordsEveryChangedField(t *testing.T) {
+	existing := createProfileShop("handmade shelves", "London", false, 4.8, 120)
+	existing.ID = 3
+	updated := createProfileShop("handmade shelves and lamps", "Leeds", true, 4.9, 125)
+	updated.Member = append(updated.Member, models.ShopMember{Name: "Tom", Role: "Maker"})
+	updated.SocialMediaLinks = []models.SocialMediaLinks{}
+
+	changes := scheduleUpdates.ShopProfileChanges(existing, updated)
+
+	expected := []models.ShopHistoryChange{
+		{ShopID: 3, Field: models.ShopFieldDescription, OldValue: "handmade shelves", NewValue: "handmade shelves and lamps"},
+		{ShopID: 3, Field: models.ShopFieldLocation, OldValue: "London", NewValue: "Leeds"},
+		{ShopID: 3, Field: models.ShopFieldOnVacation, OldValue: "false", NewValue: "true"},
+		{ShopID: 3, Field: models.ShopFieldMembers, OldValue: "Jane (Owner)", NewValue: "Jane (Owner), Tom (Maker)"},
+		{ShopID: 3, Field: models.ShopFieldSocialLinks, OldValue: "https://www.instagram.com/exampleshop", NewValue: ""},
+		{ShopID: 3, Field: models.ShopFieldRating, OldValue: "4.8", NewValue: "4.9"},
+		{ShopID: 3, Field: models.ShopFieldReviewsCount, OldValue: "120", NewValue: "125"},
+	}
+	assert.Equal(t, expected, changes)
+}
+
+func TestShopProfileChangesSkipsPartsNotScraped(t *testing.T) {
+	existing := createProfileShop("handmade shelves", "London", false, 4.8, 120)
+
+	assert.Empty(t, scheduleUpdates.ShopProfileChanges(existing, &models.Shop{}))
+
+	updated := &models.Shop{Description: "handmade shelves", Location: "London"}
+	assert.Empty(t, scheduleUpdates.ShopProfileChanges(existing, updated))
+}
+
+func TestUpdateShopProfileSkipsShopWithoutProfile(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	err := updateDB.UpdateShopProfile(1, &models.Shop{TotalSales: 10})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateShopProfileSavesChanges(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	sqlMock.MatchExpectationsInOrder(false)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shops"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "location"}).AddRow(1, "Shop 1", "handmade shelves", "London"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shop_members"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "social_media_links"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "reviews"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shop_history_changes"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, models.ShopFieldLocation, "London", "Leeds").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops" SET "location"=$1`)).
+		WithArgs("Leeds", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.UpdateShopProfile(1, &models.Shop{Description: "handmade shelves", Location: "Leeds"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSnapshotShopReviewsSkipsMissingReviews(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	err := updateDB.SnapshotShopReviews(1, &models.Shop{TotalSales: 10})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSnapshotShopReviewsSavesTopics(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	updatedShop := &models.Shop{Reviews: models.Reviews{
+		ShopRating:   4.9,
+		ReviewsCount: 123,
+		ReviewsTopic: []models.ReviewsTopic{{Keyword: "quality", KeywordCount: 40}, {Keyword: "shipping", KeywordCount: 12}},
+	}}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_shop_reviews"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 4.9, 123).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "daily_reviews_topics"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "quality", 40, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "shipping", 12).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.SnapshotShopReviews(1, updatedShop)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
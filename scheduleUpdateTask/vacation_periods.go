@@ -0,0 +1,48 @@
+package scheduleUpdates
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	"EtsyScraper/utils"
+)
+
+// TrackVacation opens a vacation period when the shop goes on vacation and closes it when the
+// shop is back, followers are notified of both. Shop must be the stored row from before the update.
+func (u *UpdateDB) TrackVacation(Shop models.Shop, updatedShop *models.Shop) error {
+	if Shop.OnVacation == updatedShop.OnVacation {
+		return nil
+	}
+
+	Change := repository.VacationChange{
+		ShopID:     Shop.ID,
+		OnVacation: updatedShop.OnVacation,
+		At:         time.Now(),
+		Kind:       models.NotificationVacationEnded,
+		Message:    fmt.Sprintf("%s is back from vacation", Shop.Name),
+	}
+
+	if updatedShop.OnVacation {
+		Change.VacationMessage = updatedShop.VacationMessage
+		Change.Kind = models.NotificationVacationStarted
+		Change.Message = fmt.Sprintf("%s went on vacation", Shop.Name)
+		if updatedShop.VacationMessage != "" {
+			Change.Message += ": " + updatedShop.VacationMessage
+		}
+	}
+
+	recorded, err := u.Repo.RecordVacationChange(Change)
+	if err != nil {
+		return utils.HandleError(err)
+	}
+	if !recorded {
+		log.Printf("vacation change of Shop.ID %v was already recorded\n", Shop.ID)
+		return nil
+	}
+
+	log.Println(Change.Message)
+	return nil
+}
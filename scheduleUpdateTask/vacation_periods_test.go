@@ -0,0 +1,154 @@
+package scheduleUpdates_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"EtsyScraper/models"
+	"EtsyScraper/repository"
+	scheduleUpdates "EtsyScraper/scheduleUpdateTask"
+	setupMockServer "EtsyScraper/setupTests"
+)
+
+func TestTrackVacationNoChange(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	Shop := models.Shop{Name: "Shop 1", OnVacation: true}
+	Shop.ID = 1
+
+	err := updateDB.TrackVacation(Shop, &models.Shop{OnVacation: true})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTrackVacationStarted(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	Shop := models.Shop{Name: "Shop 1"}
+	Shop.ID = 1
+	updatedShop := &models.Shop{OnVacation: true, VacationMessage: "Back on May 20th"}
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "shops" WHERE id = $1 AND "shops"."deleted_at" IS NULL ORDER BY "shops"."id" LIMIT $2 FOR UPDATE`)).
+		WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vacation_periods" WHERE (shop_id = $1 AND ended_at IS NULL) AND "vacation_periods"."deleted_at" IS NULL`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "vacation_periods" ("created_at","updated_at","deleted_at","shop_id","started_at","ended_at","message") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, "Back on May 20th").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops" SET "on_vacation"=$1,"updated_at"=$2 WHERE id = $3 AND "shops"."deleted_at" IS NULL`)).
+		WithArgs(true, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notifications (account_id, shop_id, kind, message, read, created_at) SELECT account_id, $1, $2, $3, false, $4 FROM account_shop_following WHERE shop_id = $5`)).
+		WithArgs(1, models.NotificationVacationStarted, "Shop 1 went on vacation: Back on May 20th", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.TrackVacation(Shop, updatedShop)
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTrackVacationEnded(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	Shop := models.Shop{Name: "Shop 1", OnVacation: true}
+	Shop.ID = 1
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "shops"`)).
+		WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vacation_periods"`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "vacation_periods" SET "ended_at"=$1,"updated_at"=$2 WHERE (shop_id = $3 AND ended_at IS NULL) AND "vacation_periods"."deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops" SET "on_vacation"=$1`)).
+		WithArgs(false, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notifications`)).
+		WithArgs(1, models.NotificationVacationEnded, "Shop 1 is back from vacation", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.TrackVacation(Shop, &models.Shop{})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTrackVacationAlreadyRecordedByAnotherJob(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	Shop := models.Shop{Name: "Shop 1"}
+	Shop.ID = 1
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "shops"`)).
+		WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vacation_periods"`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	err := updateDB.TrackVacation(Shop, &models.Shop{OnVacation: true})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTrackVacationRollsBackWhenNotifyingFails(t *testing.T) {
+	sqlMock, testDB, MockedDataBase := setupMockServer.StartMockedDataBase()
+	testDB.Begin()
+	defer testDB.Close()
+
+	ShopRepo := &repository.DataBase{DB: MockedDataBase}
+	updateDB := &scheduleUpdates.UpdateDB{Repo: ShopRepo}
+
+	Shop := models.Shop{Name: "Shop 1", OnVacation: true}
+	Shop.ID = 1
+
+	sqlMock.MatchExpectationsInOrder(true)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "shops"`)).
+		WithArgs(1, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vacation_periods"`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "vacation_periods" SET "ended_at"=$1`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "shops" SET "on_vacation"=$1`)).
+		WithArgs(false, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO notifications`)).WillReturnError(errors.New("insert failed"))
+	sqlMock.ExpectRollback()
+
+	err := updateDB.TrackVacation(Shop, &models.Shop{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error while recording vacation change")
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
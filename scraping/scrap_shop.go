@@ -106,6 +106,7 @@ func scrapShopvacation(c *colly.Collector, shop *models.Shop) error {
 	c.OnHTML(`div[data-region="vacation-notification-bar"]`, func(e *colly.HTMLElement) {
 
 		shop.OnVacation = true
+		shop.VacationMessage = strings.TrimSpace(e.Text)
 
 	})
 	return nil
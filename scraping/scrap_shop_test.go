@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -98,3 +99,25 @@ func TestScrapShopAllCallBacks(t *testing.T) {
 	assert.Equal(t, scrapShopSocialLink, ShopSocialMediaLinkAsString)
 
 }
+
+func TestScrapShopVacationMessage(t *testing.T) {
+	shop := &models.Shop{}
+
+	collector.RateLimiting = 0 * time.Second
+	c := collector.NewCollyCollector().C
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><div data-region="vacation-notification-bar">
+			<p>Closed for the holidays, back on May 20th</p>
+		</div></body></html>`))
+	}))
+	defer server.Close()
+
+	scrapShopvacation(c, shop)
+
+	c.Visit(server.URL)
+	c.Wait()
+
+	assert.True(t, shop.OnVacation)
+	assert.Equal(t, "Closed for the holidays, back on May 20th", shop.VacationMessage)
+}
@@ -62,8 +62,69 @@ func (sc *Scraper) CheckForUpdates(Shop string, needUpdateItems bool) (*models.S
 		}
 	}
 
+	if err := scrapShopProfile(c, UpdatedShop); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
+	if err := scrapListingPositions(c, UpdatedShop); err != nil {
+		return nil, utils.HandleError(err)
+	}
+
 	c.Visit(shopLink + Shop)
 	c.Wait()
 
 	return UpdatedShop, nil
 }
+
+// scrapShopProfile collects the fields kept in the shop change log. The shop name
+// is restored once the page is scraped since CheckForUpdates was given it.
+func scrapShopProfile(c *colly.Collector, shop *models.Shop) error {
+	Name := shop.Name
+
+	if err := scrapShopDetails(c, shop); err != nil {
+		return utils.HandleError(err)
+	}
+	if err := scrapShopReviews(c, shop); err != nil {
+		return utils.HandleError(err)
+	}
+	if err := scrapShopMembers(c, shop); err != nil {
+		return utils.HandleError(err)
+	}
+	if err := scrapShopSocialMediaAcc(c, shop); err != nil {
+		return utils.HandleError(err)
+	}
+
+	c.OnScraped(func(r *colly.Response) {
+		shop.Name = Name
+	})
+	return nil
+}
+
+// scrapListingPositions records the order the shop home page shows its listings in, the
+// featured row and the default listing grid are numbered separately starting from 1.
+func scrapListingPositions(c *colly.Collector, shop *models.Shop) error {
+	addPositions := func(e *colly.HTMLElement, Featured bool) {
+		Position := 0
+		e.ForEach("div.js-merch-stash-check-listing", func(i int, h *colly.HTMLElement) {
+			ListingID, err := utils.StringToUint(h.Attr("data-listing-id"))
+			if err != nil {
+				utils.HandleError(nil, err.Error())
+				return
+			}
+			Position++
+			shop.ListingPositions = append(shop.ListingPositions, models.ListingPosition{
+				ListingID: ListingID,
+				Position:  Position,
+				Featured:  Featured,
+			})
+		})
+	}
+
+	c.OnHTML(`div[data-appears-component-name="shop_home_featured_listings"]`, func(e *colly.HTMLElement) {
+		addPositions(e, true)
+	})
+	c.OnHTML(`div[data-appears-component-name="shop_home_listing_grid"]`, func(e *colly.HTMLElement) {
+		addPositions(e, false)
+	})
+	return nil
+}
@@ -1,6 +1,8 @@
 package scrap
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -8,6 +10,7 @@ import (
 
 	"EtsyScraper/collector"
 	initializer "EtsyScraper/init"
+	"EtsyScraper/models"
 	setupMockServer "EtsyScraper/setupTests"
 )
 
@@ -33,5 +36,42 @@ func TestCheckForUpdatesSuccess(t *testing.T) {
 	assert.Equal(t, 2072, response.TotalSales)
 	assert.Equal(t, 694, response.Admirers)
 	assert.Equal(t, false, response.OnVacation)
+	assert.Equal(t, mockURL, response.Name)
+	assert.Equal(t, "Own Something Beautiful Made With Love", response.Description)
+	assert.Equal(t, "London, United Kingdom", response.Location)
+	assert.NotEmpty(t, response.Member)
+	assert.NotEmpty(t, response.SocialMediaLinks)
+	assert.NotZero(t, response.Reviews.ReviewsCount)
 
 }
+
+func TestScrapListingPositions(t *testing.T) {
+	shop := &models.Shop{}
+
+	collector.RateLimiting = 0 * time.Second
+	c := collector.NewCollyCollector().C
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>
+		<div data-appears-component-name="shop_home_featured_listings">
+			<div class="js-merch-stash-check-listing" data-listing-id="300"></div>
+		</div>
+		<div data-appears-component-name="shop_home_listing_grid">
+			<div class="js-merch-stash-check-listing" data-listing-id="100"></div>
+			<div class="js-merch-stash-check-listing" data-listing-id="not-a-listing"></div>
+			<div class="js-merch-stash-check-listing" data-listing-id="300"></div>
+		</div></body></html>`))
+	}))
+	defer server.Close()
+
+	scrapListingPositions(c, shop)
+
+	c.Visit(server.URL)
+	c.Wait()
+
+	assert.Equal(t, []models.ListingPosition{
+		{ListingID: 300, Position: 1, Featured: true},
+		{ListingID: 100, Position: 1},
+		{ListingID: 300, Position: 2},
+	}, shop.ListingPositions)
+}
@@ -0,0 +1,19 @@
+package setupMockServer
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func StartMockedRedis() (*miniredis.Miniredis, *redis.Client) {
+
+	server, err := miniredis.Run()
+	if err != nil {
+		panic("miniredis.Run() occurs an error")
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	return server, client
+}
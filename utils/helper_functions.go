@@ -70,6 +70,30 @@ func TruncateDate(date time.Time) time.Time {
 	return result
 }
 
+func TruncateDateInLocation(date time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	year, month, day := date.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// NormalizeShopName matches lower(btrim(name)) in postgres, which trims spaces only.
+func NormalizeShopName(ShopName string) string {
+	return strings.ToLower(strings.Trim(ShopName, " "))
+}
+
 func StringContains(str, subStr string) bool {
 	return strings.Contains(str, subStr)
 }
@@ -250,6 +250,48 @@ func TestTruncateDate(t *testing.T) {
 
 }
 
+func TestTruncateDateInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	tests := []struct {
+		input    time.Time
+		loc      *time.Location
+		expected time.Time
+	}{
+		{
+			input:    time.Date(2024, 5, 13, 23, 30, 0, 0, time.UTC),
+			loc:      berlin,
+			expected: time.Date(2024, 5, 14, 0, 0, 0, 0, berlin),
+		},
+		{
+			input:    time.Date(2024, 5, 13, 10, 30, 0, 0, time.UTC),
+			loc:      nil,
+			expected: time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		result := utils.TruncateDateInLocation(test.input, test.loc)
+		if !result.Equal(test.expected) {
+			t.Errorf("TruncateDateInLocation(%v) = %v; want %v", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	assert.Equal(t, time.UTC, utils.LoadLocation(""))
+	assert.Equal(t, time.UTC, utils.LoadLocation("Not/AZone"))
+	assert.Equal(t, "America/New_York", utils.LoadLocation("America/New_York").String())
+}
+
+func TestNormalizeShopName(t *testing.T) {
+	assert.Equal(t, "exampleshop", utils.NormalizeShopName("  ExampleShop "))
+	assert.Equal(t, "exampleshop", utils.NormalizeShopName("exampleshop"))
+}
+
 func TestStringContains(t *testing.T) {
 	tests := []struct {
 		name     string